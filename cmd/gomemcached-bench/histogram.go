@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// histogramPrecision is the relative width of the buckets of histogram, the percentiles are accurate up to it.
+const histogramPrecision = 0.01
+
+var histogramBase = math.Log1p(histogramPrecision)
+
+// histogram counts the latencies in the logarithmic buckets, so its size doesn't depend on the number of samples.
+// It is not safe for concurrent use, every worker has its own one, that are merged at the end.
+type histogram struct {
+	counts []uint64
+	total  uint64
+	max    time.Duration
+}
+
+func bucketOf(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	return int(math.Log(us)/histogramBase) + 1
+}
+
+// upperBound returns the maximum latency of the bucket i.
+func upperBound(i int) time.Duration {
+	if i == 0 {
+		return time.Microsecond
+	}
+	return time.Duration(math.Exp(float64(i)*histogramBase) * float64(time.Microsecond))
+}
+
+func (h *histogram) record(d time.Duration) {
+	i := bucketOf(d)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	h.total++
+	h.max = max(h.max, d)
+}
+
+func (h *histogram) merge(o *histogram) {
+	if len(o.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(o.counts)-len(h.counts))...)
+	}
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+	h.total += o.total
+	h.max = max(h.max, o.max)
+}
+
+// percentile returns the latency, that p percents of the samples don't exceed.
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return min(upperBound(i), h.max)
+		}
+	}
+	return h.max
+}
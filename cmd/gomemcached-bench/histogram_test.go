@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_histogram(t *testing.T) {
+	var h, o histogram
+	assert.Zero(t, h.percentile(99), "percentile: empty histogram")
+
+	for i := 1; i <= 900; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+	for i := 901; i <= 1000; i++ {
+		o.record(time.Duration(i) * time.Microsecond)
+	}
+	h.merge(&o)
+
+	assert.Equal(t, uint64(1000), h.total)
+	assert.Equal(t, time.Millisecond, h.max)
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 500 * time.Microsecond},
+		{90, 900 * time.Microsecond},
+		{99, 990 * time.Microsecond},
+		{100, time.Millisecond},
+	} {
+		got := h.percentile(tt.p)
+		assert.InDelta(t, float64(tt.want), float64(got), float64(tt.want)*histogramPrecision, "percentile(%v)", tt.p)
+		assert.GreaterOrEqual(t, got, tt.want, "percentile(%v): should be the upper bound", tt.p)
+	}
+
+	h.record(0)
+	assert.Equal(t, time.Microsecond, upperBound(bucketOf(0)))
+}
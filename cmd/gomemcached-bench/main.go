@@ -0,0 +1,205 @@
+// Command gomemcached-bench is a load generator built on the memcached client. It runs the mix of Get and Store
+// with the configured key cardinality, value sizes, read ratio and concurrency for the duration and reports
+// the throughput and the latency percentiles per operation. It is used to validate the settings of the pool
+// and the timeouts against the real cluster before rollout.
+//
+//	gomemcached-bench -servers 127.0.0.1:11211 -keys 100000 -value-size 512 -value-dist exp -reads 0.9 -c 64 -d 1m
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+type config struct {
+	servers     string
+	keys        int
+	keyDist     string
+	valueSize   int
+	valueDist   string
+	reads       float64
+	concurrency int
+	duration    time.Duration
+	timeout     time.Duration
+	poolSize    int
+	mux         int
+}
+
+// opStats are the results of one operation of a worker.
+type opStats struct {
+	latency histogram
+	errors  uint64
+	misses  uint64
+}
+
+type workerStats struct {
+	get, set opStats
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.servers, "servers", "127.0.0.1:11211", "comma separated addresses of memcached")
+	flag.IntVar(&cfg.keys, "keys", 10000, "number of the distinct keys")
+	flag.StringVar(&cfg.keyDist, "key-dist", "uniform", "distribution of the keys: uniform or zipf")
+	flag.IntVar(&cfg.valueSize, "value-size", 256, "size of the values in bytes, the mean one for not fixed distribution")
+	flag.StringVar(&cfg.valueDist, "value-dist", "fixed", "distribution of the value sizes: fixed, uniform or exp")
+	flag.Float64Var(&cfg.reads, "reads", 0.9, "fraction of Get in the operations from 0 to 1")
+	flag.IntVar(&cfg.concurrency, "c", 16, "number of the concurrent workers")
+	flag.DurationVar(&cfg.duration, "d", 30*time.Second, "duration of the run")
+	flag.DurationVar(&cfg.timeout, "timeout", memcached.DefaultTimeout, "read and write timeout of the client")
+	flag.IntVar(&cfg.poolSize, "pool", memcached.DefaultMaxIdleConns, "max idle connections per node")
+	flag.IntVar(&cfg.mux, "mux", 0, "multiplexed connections per node, zero turns off the multiplexing")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "gomemcached-bench:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if err := os.Setenv("MEMCACHED_SERVERS", cfg.servers); err != nil {
+		return err
+	}
+
+	opts := []memcached.Option{
+		memcached.WithTimeout(cfg.timeout),
+		memcached.WithMaxIdleConns(cfg.poolSize),
+		memcached.WithDisableMemcachedDiagnostic(),
+	}
+	if cfg.mux > 0 {
+		opts = append(opts, memcached.WithMultiplexing(cfg.mux))
+	}
+	mcl, err := memcached.InitFromEnv(opts...)
+	if err != nil {
+		return err
+	}
+	defer mcl.Close()
+
+	var (
+		wg      sync.WaitGroup
+		stats   = make([]workerStats, cfg.concurrency)
+		started = time.Now()
+		stopAt  = started.Add(cfg.duration)
+	)
+	for i := range stats {
+		wg.Add(1)
+		go func(ws *workerStats, seed int64) {
+			defer wg.Done()
+			work(mcl, cfg, ws, rand.New(rand.NewSource(seed)), stopAt)
+		}(&stats[i], started.UnixNano()+int64(i))
+	}
+	wg.Wait()
+
+	report(cfg, stats, time.Since(started))
+	return nil
+}
+
+func (cfg config) validate() error {
+	switch {
+	case cfg.keys <= 0:
+		return errors.New("keys must be positive")
+	case cfg.keyDist != "uniform" && cfg.keyDist != "zipf":
+		return fmt.Errorf("unknown key distribution %q", cfg.keyDist)
+	case cfg.valueSize < 0 || cfg.valueSize > memcached.MaxBodyLen:
+		return fmt.Errorf("value size must be from 0 to %d", memcached.MaxBodyLen)
+	case cfg.valueDist != "fixed" && cfg.valueDist != "uniform" && cfg.valueDist != "exp":
+		return fmt.Errorf("unknown value distribution %q", cfg.valueDist)
+	case cfg.reads < 0 || cfg.reads > 1:
+		return errors.New("reads must be from 0 to 1")
+	case cfg.concurrency <= 0:
+		return errors.New("concurrency must be positive")
+	case cfg.duration <= 0:
+		return errors.New("duration must be positive")
+	}
+	return nil
+}
+
+func work(mcl *memcached.Client, cfg config, ws *workerStats, rnd *rand.Rand, stopAt time.Time) {
+	nextKey := func() uint64 { return uint64(rnd.Intn(cfg.keys)) }
+	if cfg.keyDist == "zipf" {
+		nextKey = rand.NewZipf(rnd, 1.1, 1, uint64(cfg.keys-1)).Uint64
+	}
+	value := make([]byte, cfg.valueSize*4)
+	rnd.Read(value)
+
+	for time.Now().Before(stopAt) {
+		key := fmt.Sprintf("bench:%d", nextKey())
+
+		if rnd.Float64() < cfg.reads {
+			timer := time.Now()
+			_, err := mcl.Get(key)
+			ws.get.latency.record(time.Since(timer))
+			switch {
+			case errors.Is(err, memcached.ErrCacheMiss):
+				ws.get.misses++
+			case err != nil:
+				ws.get.errors++
+			}
+			continue
+		}
+
+		timer := time.Now()
+		_, err := mcl.Store(memcached.Set, key, 0, value[:valueSize(cfg, rnd)])
+		ws.set.latency.record(time.Since(timer))
+		if err != nil {
+			ws.set.errors++
+		}
+	}
+}
+
+// valueSize returns the size of the next value, it doesn't exceed the buffer of 4 mean sizes.
+func valueSize(cfg config, rnd *rand.Rand) int {
+	switch cfg.valueDist {
+	case "uniform":
+		return rnd.Intn(2*cfg.valueSize + 1)
+	case "exp":
+		return min(int(rnd.ExpFloat64()*float64(cfg.valueSize)), 4*cfg.valueSize)
+	default:
+		return cfg.valueSize
+	}
+}
+
+func report(cfg config, stats []workerStats, elapsed time.Duration) {
+	var get, set opStats
+	for i := range stats {
+		get.merge(&stats[i].get)
+		set.merge(&stats[i].set)
+	}
+
+	fmt.Printf("servers=%s keys=%d key-dist=%s value-size=%d value-dist=%s reads=%.2f c=%d elapsed=%s\n",
+		cfg.servers, cfg.keys, cfg.keyDist, cfg.valueSize, cfg.valueDist, cfg.reads, cfg.concurrency,
+		elapsed.Round(time.Millisecond))
+	fmt.Printf("%-4s %10s %10s %8s %8s %10s %10s %10s %10s %10s\n",
+		"op", "count", "ops/s", "errors", "misses", "p50", "p90", "p99", "p99.9", "max")
+	for _, op := range []struct {
+		name string
+		s    *opStats
+	}{{"get", &get}, {"set", &set}} {
+		h := &op.s.latency
+		fmt.Printf("%-4s %10d %10.0f %8d %8d %10s %10s %10s %10s %10s\n",
+			op.name, h.total, float64(h.total)/elapsed.Seconds(), op.s.errors, op.s.misses,
+			round(h.percentile(50)), round(h.percentile(90)), round(h.percentile(99)), round(h.percentile(99.9)), round(h.max))
+	}
+}
+
+// round rounds d to the microseconds for the report, the buckets of histogram are not more precise.
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+func (s *opStats) merge(o *opStats) {
+	s.latency.merge(&o.latency)
+	s.errors += o.errors
+	s.misses += o.misses
+}
@@ -0,0 +1,148 @@
+// Command gomemcached-proxy accepts the connections of the memcached binary protocol and routes the operations
+// to the cluster by the hash ring of the memcached client, so the services in other languages get the same placement
+// of the keys, as the Go services.
+//
+// The cluster is configured by the flags or by the environment of the client (MEMCACHED_SERVERS,
+// MEMCACHED_HEADLESS_SERVICE_ADDRESS and MEMCACHED_PORT):
+//
+//	gomemcached-proxy -listen :11211 -servers 10.0.0.1:11211,10.0.0.2:11211
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/logger"
+	"github.com/aliexpressru/gomemcached/memcached"
+	"github.com/aliexpressru/gomemcached/server"
+)
+
+// backend is the part of memcached.Client used by the proxy.
+type backend interface {
+	RoundTrip(req *memcached.Request, opts ...memcached.CallOption) (*memcached.Response, error)
+	FlushAll(exp uint32, opts ...memcached.FlushOption) error
+	Versions() (map[string]string, error)
+}
+
+// proxy is server.Handler, that routes the requests with keys to the nodes of the cluster.
+// NOOP is answered by the proxy, FLUSH is sent to all nodes and VERSION returns the lowest version of the nodes.
+// The other commands without key (e.g. STAT and SASL) are not supported.
+type proxy struct {
+	mcl backend
+	log logger.Logger
+}
+
+func main() {
+	var (
+		listen  = flag.String("listen", ":11211", "address to accept the connections")
+		servers = flag.String("servers", "", "comma separated addresses of memcached, overrides MEMCACHED_SERVERS")
+		timeout = flag.Duration("timeout", memcached.DefaultTimeout, "timeout of the requests to the nodes")
+		idle    = flag.Duration("idle-timeout", 5*time.Minute, "time, after that the idle connections of the clients are closed")
+		pool    = flag.Int("pool", memcached.DefaultMaxIdleConns, "max idle connections per node")
+		mux     = flag.Int("mux", 0, "multiplexed connections per node, zero turns off the multiplexing")
+	)
+	flag.Parse()
+
+	if err := run(*listen, *servers, *timeout, *idle, *pool, *mux); err != nil {
+		fmt.Fprintln(os.Stderr, "gomemcached-proxy:", err)
+		os.Exit(1)
+	}
+}
+
+func run(listen, servers string, timeout, idle time.Duration, pool, mux int) error {
+	if servers != "" {
+		if err := os.Setenv("MEMCACHED_SERVERS", servers); err != nil {
+			return err
+		}
+	}
+	opts := []memcached.Option{
+		memcached.WithTimeout(timeout),
+		memcached.WithMaxIdleConns(pool),
+	}
+	if mux > 0 {
+		opts = append(opts, memcached.WithMultiplexing(mux))
+	}
+	mcl, err := memcached.InitFromEnv(opts...)
+	if err != nil {
+		return err
+	}
+	defer mcl.Close()
+
+	srv := server.New(&proxy{mcl: mcl, log: logger.Default()}, server.WithIdleTimeout(idle), server.WithWriteTimeout(timeout))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	logger.Default().Infof("gomemcached-proxy: listening on %s", listen)
+	if err = srv.ListenAndServe(listen); !errors.Is(err, server.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Serve implements server.Handler.
+func (p *proxy) Serve(_ context.Context, req *memcached.Request) *memcached.Response {
+	switch req.Opcode {
+	case memcached.NOOP:
+		return &memcached.Response{}
+	case memcached.VERSION:
+		return p.version()
+	case memcached.FLUSH, memcached.FLUSHQ:
+		var exp uint32
+		if len(req.Extras) >= 4 {
+			exp = binary.BigEndian.Uint32(req.Extras)
+		}
+		if err := p.mcl.FlushAll(exp); err != nil {
+			p.log.Errorf("gomemcached-proxy: flush error: %s", err.Error())
+			return &memcached.Response{Status: memcached.TMPFAIL}
+		}
+		if req.Opcode.IsQuiet() {
+			return nil
+		}
+		return &memcached.Response{}
+	}
+
+	if len(req.Key) == 0 {
+		return &memcached.Response{Status: memcached.UNKNOWN_COMMAND}
+	}
+
+	resp, err := p.mcl.RoundTrip(req)
+	switch {
+	case resp != nil:
+		// the statuses of memcached are passed to the client as is.
+		return resp
+	case errors.Is(err, memcached.ErrMalformedKey), errors.Is(err, memcached.ErrInvalidArguments):
+		return &memcached.Response{Status: memcached.EINVAL}
+	case err != nil:
+		p.log.Warnf("gomemcached-proxy: %s error: %s", req.Opcode, err.Error())
+		return &memcached.Response{Status: memcached.TMPFAIL}
+	}
+	return nil
+}
+
+// version returns the lowest version of the nodes, so the version checks of the clients are not weakened.
+func (p *proxy) version() *memcached.Response {
+	versions, err := p.mcl.Versions()
+	if err != nil || len(versions) == 0 {
+		return &memcached.Response{Status: memcached.TMPFAIL}
+	}
+
+	var lowest string
+	for _, v := range versions {
+		if lowest == "" || memcached.CompareVersions(v, lowest) < 0 {
+			lowest = v
+		}
+	}
+	return &memcached.Response{Body: []byte(lowest)}
+}
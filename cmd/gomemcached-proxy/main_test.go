@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aliexpressru/gomemcached/logger"
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+type fakeBackend struct {
+	resp     *memcached.Response
+	err      error
+	flushExp *uint32
+	versions map[string]string
+}
+
+func (f *fakeBackend) RoundTrip(*memcached.Request, ...memcached.CallOption) (*memcached.Response, error) {
+	return f.resp, f.err
+}
+
+func (f *fakeBackend) FlushAll(exp uint32, _ ...memcached.FlushOption) error {
+	f.flushExp = &exp
+	return f.err
+}
+
+func (f *fakeBackend) Versions() (map[string]string, error) {
+	return f.versions, f.err
+}
+
+func Test_proxy_Serve(t *testing.T) {
+	ctx := context.Background()
+	b := &fakeBackend{}
+	p := &proxy{mcl: b, log: logger.Nop()}
+
+	b.resp = &memcached.Response{Body: []byte("value")}
+	assert.Equal(t, b.resp, p.Serve(ctx, &memcached.Request{Opcode: memcached.GET, Key: []byte("key")}))
+
+	b.resp = nil
+	assert.Nil(t, p.Serve(ctx, &memcached.Request{Opcode: memcached.SETQ, Key: []byte("key")}),
+		"Serve: the quiet success should not be answered")
+
+	b.err = memcached.ErrMalformedKey
+	assert.Equal(t, memcached.EINVAL, p.Serve(ctx, &memcached.Request{Opcode: memcached.GET, Key: []byte("bad key")}).Status)
+	b.err = errors.New("connection refused")
+	assert.Equal(t, memcached.TMPFAIL, p.Serve(ctx, &memcached.Request{Opcode: memcached.GET, Key: []byte("key")}).Status)
+
+	assert.Equal(t, memcached.UNKNOWN_COMMAND, p.Serve(ctx, &memcached.Request{Opcode: memcached.STAT}).Status)
+	assert.Equal(t, memcached.SUCCESS, p.Serve(ctx, &memcached.Request{Opcode: memcached.NOOP}).Status)
+
+	b.err = nil
+	extras := make([]byte, 4)
+	binary.BigEndian.PutUint32(extras, 30)
+	assert.Equal(t, memcached.SUCCESS, p.Serve(ctx, &memcached.Request{Opcode: memcached.FLUSH, Extras: extras}).Status)
+	assert.Equal(t, uint32(30), *b.flushExp)
+	assert.Nil(t, p.Serve(ctx, &memcached.Request{Opcode: memcached.FLUSHQ}))
+
+	b.versions = map[string]string{"a": "1.6.21", "b": "1.6.9", "c": "1.6.12"}
+	assert.Equal(t, "1.6.9", string(p.Serve(ctx, &memcached.Request{Opcode: memcached.VERSION}).Body),
+		"Serve: the lowest version of the nodes")
+	b.versions = nil
+	assert.Equal(t, memcached.TMPFAIL, p.Serve(ctx, &memcached.Request{Opcode: memcached.VERSION}).Status)
+}
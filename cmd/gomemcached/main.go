@@ -0,0 +1,218 @@
+// Command gomemcached is a command line tool for the memcached cluster. It is built on the memcached client,
+// so the keys are placed by the same hash ring, as in the applications, e.g. which-node shows the real node of the key.
+//
+// The cluster is configured by the flags or by the environment of the client (MEMCACHED_SERVERS,
+// MEMCACHED_HEADLESS_SERVICE_ADDRESS and MEMCACHED_PORT):
+//
+//	gomemcached -servers 127.0.0.1:11211,127.0.0.2:11211 get foo
+//	gomemcached set -exp 60 foo bar
+//	gomemcached incr foo 5
+//	gomemcached stats items
+//	gomemcached flush -node 127.0.0.1:11211
+//	gomemcached which-node foo
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"golang.org/x/exp/maps"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+const usage = `Usage: gomemcached [flags] <command> [args]
+
+Commands:
+  get <key>                       print the value of the key
+  set [-exp sec] <key> <value>    store the value
+  delete <key>                    delete the key
+  incr [-exp sec] <key> [delta]   increment the counter by delta (1 by default), it is created with zero if missing
+  stats [group]                   print the statistics of the nodes, e.g. "items", "slabs" or "settings"
+  flush [-exp sec] [-node addr]   flush all nodes or the node
+  which-node <key>                print the node of the key
+
+Flags:
+`
+
+// client is the part of memcached.Client used by the commands.
+type client interface {
+	Get(key string, opts ...memcached.CallOption) (*memcached.Response, error)
+	Store(storeMode memcached.StoreMode, key string, exp uint32, body []byte, opts ...memcached.CallOption) (*memcached.Response, error)
+	Delete(key string, opts ...memcached.CallOption) (*memcached.Response, error)
+	Delta(deltaMode memcached.DeltaMode, key string, delta, initial uint64, exp uint32, opts ...memcached.CallOption) (uint64, error)
+	FlushAll(exp uint32, opts ...memcached.FlushOption) error
+	FlushNode(addr string, exp uint32) error
+	Stats(group string) (map[string]map[string]string, error)
+	WhichNode(key string) (string, error)
+}
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	fs := flag.NewFlagSet("gomemcached", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	servers := fs.String("servers", "", "comma separated addresses of memcached, overrides MEMCACHED_SERVERS")
+	timeout := fs.Duration("timeout", memcached.DefaultTimeout, "timeout of the requests")
+	user := fs.String("user", "", "SASL user")
+	pass := fs.String("pass", "", "SASL password")
+	_ = fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *servers != "" {
+		if err := os.Setenv("MEMCACHED_SERVERS", *servers); err != nil {
+			exit(err)
+		}
+	}
+	opts := []memcached.Option{
+		memcached.WithTimeout(*timeout),
+		memcached.WithDisableNodeProvider(),
+		memcached.WithDisableMemcachedDiagnostic(),
+		memcached.WithDisableLogger(),
+	}
+	if *user != "" {
+		opts = append(opts, memcached.WithAuthentication(*user, *pass))
+	}
+	mcl, err := memcached.InitFromEnv(opts...)
+	if err != nil {
+		exit(err)
+	}
+	defer mcl.Close()
+
+	if err = run(mcl, fs.Args(), os.Stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+		}
+		mcl.Close()
+		exit(err)
+	}
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "gomemcached:", err)
+	os.Exit(1)
+}
+
+// run executes the command args[0] with the arguments args[1:] and writes its output to w.
+func run(mcl client, args []string, w io.Writer) error {
+	cmd, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	exp := fs.Uint("exp", 0, "expiration in seconds")
+	node := fs.String("node", "", "address of the node")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %s", errUsage, err.Error())
+	}
+	args = fs.Args()
+
+	nArgs := func(lo, hi int) error {
+		if len(args) < lo || len(args) > hi {
+			return fmt.Errorf("%w: %s takes from %d to %d arguments", errUsage, cmd, lo, hi)
+		}
+		return nil
+	}
+
+	switch cmd {
+	case "get":
+		if err := nArgs(1, 1); err != nil {
+			return err
+		}
+		resp, err := mcl.Get(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", resp.Body)
+		return err
+	case "set":
+		if err := nArgs(2, 2); err != nil {
+			return err
+		}
+		_, err := mcl.Store(memcached.Set, args[0], uint32(*exp), []byte(args[1]))
+		return err
+	case "delete":
+		if err := nArgs(1, 1); err != nil {
+			return err
+		}
+		_, err := mcl.Delete(args[0])
+		return err
+	case "incr":
+		if err := nArgs(1, 2); err != nil {
+			return err
+		}
+		delta := uint64(1)
+		if len(args) == 2 {
+			var err error
+			if delta, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+				return fmt.Errorf("%w: delta %q is not a number", errUsage, args[1])
+			}
+		}
+		value, err := mcl.Delta(memcached.Increment, args[0], delta, 0, uint32(*exp))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, value)
+		return err
+	case "stats":
+		if err := nArgs(0, 1); err != nil {
+			return err
+		}
+		var group string
+		if len(args) == 1 {
+			group = args[0]
+		}
+		stats, err := mcl.Stats(group)
+		if wErr := writeStats(w, stats); wErr != nil {
+			return wErr
+		}
+		return err
+	case "flush":
+		if err := nArgs(0, 0); err != nil {
+			return err
+		}
+		if *node != "" {
+			return mcl.FlushNode(*node, uint32(*exp))
+		}
+		return mcl.FlushAll(uint32(*exp))
+	case "which-node":
+		if err := nArgs(1, 1); err != nil {
+			return err
+		}
+		addr, err := mcl.WhichNode(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, addr)
+		return err
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+}
+
+// writeStats writes the statistics sorted by the nodes and the names.
+func writeStats(w io.Writer, stats map[string]map[string]string) error {
+	addrs := maps.Keys(stats)
+	slices.Sort(addrs)
+	for _, addr := range addrs {
+		names := maps.Keys(stats[addr])
+		slices.Sort(names)
+		for _, name := range names {
+			if _, err := fmt.Fprintf(w, "%s %s %s\n", addr, name, stats[addr][name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+	"github.com/aliexpressru/gomemcached/memcachedtest"
+)
+
+type fakeClient struct {
+	*memcachedtest.Mock
+	flushedNode string
+}
+
+func (f *fakeClient) FlushNode(addr string, exp uint32) error {
+	f.flushedNode = addr
+	return f.Mock.FlushNode(addr, exp)
+}
+
+func (f *fakeClient) Stats(group string) (map[string]map[string]string, error) {
+	return map[string]map[string]string{
+		"b:11211": {"pid": "2"},
+		"a:11211": {"pid": "1", "curr_items": "10"},
+	}, nil
+}
+
+func (f *fakeClient) WhichNode(key string) (string, error) {
+	return "a:11211", nil
+}
+
+func Test_run(t *testing.T) {
+	mcl := &fakeClient{Mock: memcachedtest.New()}
+	exec := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(mcl, args, &out)
+		return out.String(), err
+	}
+
+	_, err := exec("set", "-exp", "60", "foo", "bar")
+	require.Nil(t, err)
+	out, err := exec("get", "foo")
+	require.Nil(t, err)
+	assert.Equal(t, "bar\n", out)
+
+	out, err = exec("incr", "counter", "5")
+	require.Nil(t, err)
+	assert.Equal(t, "0\n", out, "incr: the missing counter should be created with zero")
+	out, err = exec("incr", "counter")
+	require.Nil(t, err)
+	assert.Equal(t, "1\n", out)
+
+	_, err = exec("delete", "foo")
+	require.Nil(t, err)
+	_, err = exec("get", "foo")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+
+	out, err = exec("stats")
+	require.Nil(t, err)
+	assert.Equal(t, "a:11211 curr_items 10\na:11211 pid 1\nb:11211 pid 2\n", out, "stats: should be sorted")
+
+	out, err = exec("which-node", "foo")
+	require.Nil(t, err)
+	assert.Equal(t, "a:11211\n", out)
+
+	_, err = exec("flush", "-node", "mock")
+	require.Nil(t, err)
+	assert.Equal(t, "mock", mcl.flushedNode)
+	_, err = exec("flush")
+	require.Nil(t, err)
+
+	for _, args := range [][]string{
+		{"get"},
+		{"set", "foo"},
+		{"incr", "counter", "x"},
+		{"flush", "extra"},
+		{"get", "-unknown", "foo"},
+		{"unknown"},
+	} {
+		_, err = exec(args...)
+		assert.ErrorIs(t, err, errUsage, "run(%v)", args)
+	}
+}
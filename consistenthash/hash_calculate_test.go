@@ -0,0 +1,46 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+func TestHashFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   Func
+		data string
+		want uint64
+	}{
+		{name: "fnv1a empty", fn: Fnv1a, data: "", want: 0xcbf29ce484222325},
+		{name: "fnv1a a", fn: Fnv1a, data: "a", want: 0xaf63dc4c8601ec8c},
+		{name: "murmur3 empty", fn: Murmur3, data: "", want: 0},
+		{name: "murmur3 long", fn: Murmur3, data: "The quick brown fox jumps over the lazy dog", want: 0xe34bbc7bbc071b6c},
+		{name: "xxhash empty", fn: Hash, data: "", want: 0xef46db3751d8e999},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, tt.fn([]byte(tt.data)), "hash of %q", tt.data)
+		})
+	}
+}
+
+func TestHashRingWithMurmur3(t *testing.T) {
+	ch := NewCustomHashRing(minReplicas, Murmur3)
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	keys := make(map[any]int)
+	for i := 0; i < requestSize; i++ {
+		node, ok := ch.Get(requestSize + i)
+		assert.True(t, ok)
+		keys[node]++
+	}
+	assert.Equal(t, keySize, len(keys))
+	assert.True(t, utils.CalcEntropy(keys) > .95)
+}
@@ -2,9 +2,11 @@ package consistenthash
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/aliexpressru/gomemcached/utils"
 )
@@ -15,6 +17,9 @@ const (
 
 	minReplicas = 256
 	prime       = 18245165
+
+	// hashSpace is a size of the hash space of Func
+	hashSpace = float64(math.MaxUint64) + 1
 )
 
 var _ ConsistentHash = (*HashRing)(nil)
@@ -25,22 +30,52 @@ type (
 		AddWithReplicas(node any, replicas int)
 		AddWithWeight(node any, weight int)
 		Get(v any) (any, bool)
+		GetN(v any, n int) ([]any, bool)
 		GetAllNodes() []any
 		Remove(node any)
 		GetNodesCount() int
+		// Batch applies the changes made by fn to the ring at once, fn must change the given ring.
+		Batch(fn func(ring ConsistentHash))
 	}
 
 	// Func defines the hash method.
 	Func func(data []byte) uint64
 
 	// A HashRing is implementation of consistent hash.
+	//
+	// The state of the ring is immutable, Add and Remove build a new state and swap it atomically,
+	// so Get never takes a lock.
 	HashRing struct {
 		hashFunc Func
 		replicas int
-		keys     []uint64
-		ring     map[uint64][]any
-		nodes    map[string]struct{}
-		lock     sync.RWMutex
+		state    atomic.Pointer[ringState]
+		// lock serializes the changes of the ring
+		lock sync.Mutex
+	}
+
+	// RingSnapshot is a point-in-time view of the HashRing for debugging the distribution of keys.
+	RingSnapshot struct {
+		// Nodes sorted by the string representation of the node.
+		Nodes []NodeSnapshot
+		// Replicas is the total number of virtual nodes in the ring.
+		Replicas int
+	}
+
+	// NodeSnapshot describes one node of the RingSnapshot.
+	NodeSnapshot struct {
+		Node any
+		// Replicas is a number of virtual nodes of the node.
+		Replicas int
+		// Ownership is a fraction of the hash space (from 0 to 1) owned by the node.
+		Ownership float64
+	}
+
+	// ringState is a snapshot of the ring, it must not be changed after publishing to HashRing.state.
+	ringState struct {
+		keys []uint64
+		ring map[uint64][]any
+		// nodes is an index of the node replicas hashes
+		nodes map[string][]uint64
 	}
 )
 
@@ -59,12 +94,16 @@ func NewCustomHashRing(replicas int, fn Func) *HashRing {
 		fn = Hash
 	}
 
-	return &HashRing{
+	h := &HashRing{
 		hashFunc: fn,
 		replicas: replicas,
-		ring:     make(map[uint64][]any),
-		nodes:    make(map[string]struct{}),
 	}
+	h.state.Store(&ringState{
+		ring:  make(map[uint64][]any),
+		nodes: make(map[string][]uint64),
+	})
+
+	return h
 }
 
 // Add adds the node with the number of h.replicas,
@@ -77,26 +116,32 @@ func (h *HashRing) Add(node any) {
 // replicas will be truncated to h.replicas if it's larger than h.replicas,
 // the later call will overwrite the replicas of the former calls.
 func (h *HashRing) AddWithReplicas(node any, replicas int) {
-	h.Remove(node)
-
 	if replicas > h.replicas {
 		replicas = h.replicas
 	}
 
 	nodeRepr := repr(node)
+
 	h.lock.Lock()
 	defer h.lock.Unlock()
-	h.addNode(nodeRepr)
 
+	st := h.state.Load().clone()
+	st.removeNode(nodeRepr)
+
+	hashes := make([]uint64, replicas)
 	for i := 0; i < replicas; i++ {
 		hash := h.hashFunc([]byte(replicaRepr(nodeRepr, i)))
-		h.keys = append(h.keys, hash)
-		h.ring[hash] = append(h.ring[hash], node)
+		hashes[i] = hash
+		st.keys = append(st.keys, hash)
+		st.ring[hash] = append(st.ring[hash], node)
 	}
+	st.nodes[nodeRepr] = hashes
 
-	sort.Slice(h.keys, func(i, j int) bool {
-		return h.keys[i] < h.keys[j]
+	sort.Slice(st.keys, func(i, j int) bool {
+		return st.keys[i] < st.keys[j]
 	})
+
+	h.state.Store(st)
 }
 
 // AddWithWeight adds the node with weight, the weight can be 1 to 100, indicates the percent,
@@ -110,28 +155,64 @@ func (h *HashRing) AddWithWeight(node any, weight int) {
 
 // Get returns the corresponding node from h base on the given v.
 func (h *HashRing) Get(v any) (any, bool) {
-	h.lock.RLock()
-	defer h.lock.RUnlock()
+	st := h.state.Load()
+
+	if len(st.ring) == 0 {
+		return nil, false
+	}
+
+	_, node := h.lookup(st, v)
+	return node, node != nil
+}
+
+// GetN returns up to n distinct nodes for the given v: the node from Get
+// and then the next nodes clockwise on the ring.
+func (h *HashRing) GetN(v any, n int) ([]any, bool) {
+	st := h.state.Load()
+
+	if len(st.ring) == 0 || n <= 0 {
+		return nil, false
+	}
 
-	if len(h.ring) == 0 {
+	index, node := h.lookup(st, v)
+	if node == nil {
 		return nil, false
 	}
 
+	var (
+		nodes = append(make([]any, 0, min(n, len(st.nodes))), node)
+		seen  = map[string]struct{}{repr(node): {}}
+	)
+	for i := 1; i < len(st.keys) && len(nodes) < n; i++ {
+		for _, next := range st.ring[st.keys[(index+i)%len(st.keys)]] {
+			nextRepr := repr(next)
+			if _, ok := seen[nextRepr]; !ok && len(nodes) < n {
+				seen[nextRepr] = struct{}{}
+				nodes = append(nodes, next)
+			}
+		}
+	}
+
+	return nodes, true
+}
+
+// lookup returns the index of the key in st.keys and the node for v, st must be not empty.
+func (h *HashRing) lookup(st *ringState, v any) (int, any) {
 	hash := h.hashFunc([]byte(repr(v)))
-	index := sort.Search(len(h.keys), func(i int) bool {
-		return h.keys[i] >= hash
-	}) % len(h.keys)
+	index := sort.Search(len(st.keys), func(i int) bool {
+		return st.keys[i] >= hash
+	}) % len(st.keys)
 
-	nodes := h.ring[h.keys[index]]
+	nodes := st.ring[st.keys[index]]
 	switch len(nodes) {
 	case 0:
-		return nil, false
+		return index, nil
 	case 1:
-		return nodes[0], true
+		return index, nodes[0]
 	default:
 		innerIndex := h.hashFunc([]byte(innerRepr(v)))
 		pos := int(innerIndex % uint64(len(nodes)))
-		return nodes[pos], true
+		return index, nodes[pos]
 	}
 }
 
@@ -139,19 +220,18 @@ func (h *HashRing) Get(v any) (any, bool) {
 //
 //	return a slice with a string representation of the nodes
 func (h *HashRing) GetAllNodes() []any {
-	h.lock.RLock()
-	defer h.lock.RUnlock()
+	st := h.state.Load()
 
-	if len(h.ring) == 0 {
+	if len(st.ring) == 0 {
 		return nil
 	}
 
 	var (
-		allNodes = make([]any, 0, len(h.nodes))
-		uqNodes  = make(map[any]struct{}, len(h.nodes))
+		allNodes = make([]any, 0, len(st.nodes))
+		uqNodes  = make(map[any]struct{}, len(st.nodes))
 	)
 
-	for _, nodes := range h.ring {
+	for _, nodes := range st.ring {
 		for _, node := range nodes {
 			if _, ok := uqNodes[node]; !ok {
 				allNodes = append(allNodes, node)
@@ -170,33 +250,141 @@ func (h *HashRing) Remove(node any) {
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
-	if !h.containsNode(nodeRepr) {
+	if !h.state.Load().containsNode(nodeRepr) {
 		return
 	}
 
-	for i := 0; i < h.replicas; i++ {
-		hash := h.hashFunc([]byte(replicaRepr(nodeRepr, i)))
-		index := sort.Search(len(h.keys), func(i int) bool {
-			return h.keys[i] >= hash
-		})
-		if index < len(h.keys) && h.keys[index] == hash {
-			h.keys = append(h.keys[:index], h.keys[index+1:]...)
-		}
-		h.removeRingNode(hash, nodeRepr)
+	st := h.state.Load().clone()
+	st.removeNode(nodeRepr)
+	h.state.Store(st)
+}
+
+// Batch applies the changes made by fn to a copy of h and publishes them at once,
+// so Get never sees an intermediate state of the ring. fn must change the given ring, not h.
+func (h *HashRing) Batch(fn func(ring ConsistentHash)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	next := &HashRing{
+		hashFunc: h.hashFunc,
+		replicas: h.replicas,
 	}
+	next.state.Store(h.state.Load())
+
+	fn(next)
 
-	h.removeNode(nodeRepr)
+	h.state.Store(next.state.Load())
 }
 
 // GetNodesCount returns the current number of nodes
 func (h *HashRing) GetNodesCount() int {
-	h.lock.RLock()
-	defer h.lock.RUnlock()
-	return len(h.nodes)
+	return len(h.state.Load().nodes)
 }
 
-func (h *HashRing) removeRingNode(hash uint64, nodeRepr string) {
-	if nodes, ok := h.ring[hash]; ok {
+// Snapshot returns the nodes of h with their replicas and the fraction of the hash space they own.
+func (h *HashRing) Snapshot() RingSnapshot {
+	st := h.state.Load()
+
+	snapshot := RingSnapshot{
+		Nodes:    make([]NodeSnapshot, 0, len(st.nodes)),
+		Replicas: len(st.keys),
+	}
+	if len(st.keys) == 0 {
+		return snapshot
+	}
+
+	ownership := make(map[string]float64, len(st.nodes))
+	nodes := make(map[string]any, len(st.nodes))
+	for i, hash := range st.keys {
+		if i > 0 && st.keys[i-1] == hash {
+			// duplicated hash of replicas, its range is already counted
+			continue
+		}
+
+		// the key owns the range from the previous key (exclusive), the first key owns the wrapped range
+		var rangeLen float64
+		if i == 0 {
+			rangeLen = float64(math.MaxUint64-st.keys[len(st.keys)-1]) + float64(hash) + 1
+		} else {
+			rangeLen = float64(hash - st.keys[i-1])
+		}
+
+		owners := st.ring[hash]
+		for _, node := range owners {
+			nodeRepr := repr(node)
+			nodes[nodeRepr] = node
+			ownership[nodeRepr] += rangeLen / float64(len(owners)) / hashSpace
+		}
+	}
+
+	for nodeRepr, hashes := range st.nodes {
+		node, ok := nodes[nodeRepr]
+		if !ok {
+			// the node without replicas is not in the ring
+			node = nodeRepr
+		}
+		snapshot.Nodes = append(snapshot.Nodes, NodeSnapshot{
+			Node:      node,
+			Replicas:  len(hashes),
+			Ownership: ownership[nodeRepr],
+		})
+	}
+	sort.Slice(snapshot.Nodes, func(i, j int) bool {
+		return repr(snapshot.Nodes[i].Node) < repr(snapshot.Nodes[j].Node)
+	})
+
+	return snapshot
+}
+
+// removeNode removes all replicas of the node from not published st.
+// The keys are rebuilt in one pass, without searching every replica.
+func (st *ringState) removeNode(nodeRepr string) {
+	hashes, ok := st.nodes[nodeRepr]
+	if !ok {
+		return
+	}
+
+	removed := make(map[uint64]int, len(hashes))
+	for _, hash := range hashes {
+		removed[hash]++
+		st.removeRingNode(hash, nodeRepr)
+	}
+
+	keys := st.keys[:0]
+	for _, hash := range st.keys {
+		if removed[hash] > 0 {
+			removed[hash]--
+			continue
+		}
+		keys = append(keys, hash)
+	}
+	st.keys = keys
+
+	delete(st.nodes, nodeRepr)
+}
+
+// clone returns a deep copy of st, which can be changed without affecting the readers of st.
+func (st *ringState) clone() *ringState {
+	cp := &ringState{
+		keys:  make([]uint64, len(st.keys)),
+		ring:  make(map[uint64][]any, len(st.ring)),
+		nodes: make(map[string][]uint64, len(st.nodes)),
+	}
+
+	copy(cp.keys, st.keys)
+	for hash, nodes := range st.ring {
+		cp.ring[hash] = append([]any(nil), nodes...)
+	}
+	// replicas hashes of the node are never changed, only replaced
+	for node, hashes := range st.nodes {
+		cp.nodes[node] = hashes
+	}
+
+	return cp
+}
+
+func (st *ringState) removeRingNode(hash uint64, nodeRepr string) {
+	if nodes, ok := st.ring[hash]; ok {
 		newNodes := nodes[:0]
 		for _, x := range nodes {
 			if repr(x) != nodeRepr {
@@ -204,26 +392,18 @@ func (h *HashRing) removeRingNode(hash uint64, nodeRepr string) {
 			}
 		}
 		if len(newNodes) > 0 {
-			h.ring[hash] = newNodes
+			st.ring[hash] = newNodes
 		} else {
-			delete(h.ring, hash)
+			delete(st.ring, hash)
 		}
 	}
 }
 
-func (h *HashRing) addNode(nodeRepr string) {
-	h.nodes[nodeRepr] = struct{}{}
-}
-
-func (h *HashRing) containsNode(nodeRepr string) bool {
-	_, ok := h.nodes[nodeRepr]
+func (st *ringState) containsNode(nodeRepr string) bool {
+	_, ok := st.nodes[nodeRepr]
 	return ok
 }
 
-func (h *HashRing) removeNode(nodeRepr string) {
-	delete(h.nodes, nodeRepr)
-}
-
 func innerRepr(node any) string {
 	return fmt.Sprintf("%d:%v", prime, node)
 }
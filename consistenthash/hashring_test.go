@@ -4,6 +4,7 @@ package consistenthash
 import (
 	"fmt"
 	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,6 +28,34 @@ func BenchmarkHashRingGet(b *testing.B) {
 	}
 }
 
+func BenchmarkHashRingGetParallel(b *testing.B) {
+	ch := NewHashRing()
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			ch.Get(i)
+		}
+	})
+}
+
+func BenchmarkHashRingRemove(b *testing.B) {
+	const node = "localhost:flapping"
+	ch := NewHashRing()
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		ch.Add(node)
+		b.StartTimer()
+		ch.Remove(node)
+	}
+}
+
 func TestHashRing_GetAllNodes(t *testing.T) {
 	ch := NewHashRing()
 
@@ -152,6 +181,23 @@ func TestHashRing_Remove(t *testing.T) {
 	}
 }
 
+func TestHashRing_RemoveRebuildsKeys(t *testing.T) {
+	const prefix = "localhost:"
+	expected := NewHashRing()
+	actual := NewHashRing()
+	for i := 0; i < keySize; i++ {
+		expected.Add(prefix + strconv.Itoa(i))
+		actual.Add(prefix + strconv.Itoa(i))
+	}
+	actual.AddWithWeight(prefix+strconv.Itoa(keySize), 30)
+	actual.Remove(prefix + strconv.Itoa(keySize))
+
+	expectedState, actualState := expected.state.Load(), actual.state.Load()
+	assert.Equal(t, expectedState.keys, actualState.keys)
+	assert.Equal(t, expectedState.ring, actualState.ring)
+	assert.Equal(t, expectedState.nodes, actualState.nodes)
+}
+
 func TestHashRing_RemoveInterface(t *testing.T) {
 	const key = "any"
 	ch := NewHashRing()
@@ -159,13 +205,173 @@ func TestHashRing_RemoveInterface(t *testing.T) {
 	node2 := newMockNode(key, 2)
 	ch.AddWithWeight(node1, 80)
 	ch.AddWithWeight(node2, 50)
-	assert.Equal(t, 1, len(ch.nodes))
+	assert.Equal(t, 1, len(ch.state.Load().nodes))
 	node, ok := ch.Get(1)
 	assert.True(t, ok)
 	assert.Equal(t, key, node.(*mockNode).addr)
 	assert.Equal(t, 2, node.(*mockNode).id)
 }
 
+func TestHashRing_ConcurrentGetWithChanges(t *testing.T) {
+	const prefix = "localhost:"
+	ch := NewHashRing()
+	for i := 0; i < keySize; i++ {
+		ch.Add(prefix + strconv.Itoa(i))
+	}
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				node, ok := ch.Get(j)
+				assert.True(t, ok)
+				assert.NotNil(t, node)
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		node := prefix + strconv.Itoa(keySize+i%3)
+		ch.AddWithWeight(node, 50)
+		ch.Remove(node)
+	}
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, keySize, ch.GetNodesCount())
+	assert.Equal(t, keySize*minReplicas, len(ch.state.Load().keys))
+}
+
+func TestHashRing_Batch(t *testing.T) {
+	const prefix = "localhost:"
+	ch := NewHashRing()
+	for i := 0; i < keySize; i++ {
+		ch.Add(prefix + strconv.Itoa(i))
+	}
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; ; j++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_, ok := ch.Get(j)
+			assert.True(t, ok, "Get: intermediate state of the batch should not be visible")
+			assert.Equal(t, keySize, ch.GetNodesCount())
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		ch.Batch(func(ring ConsistentHash) {
+			for j := 0; j < keySize; j++ {
+				ring.Remove(prefix + strconv.Itoa(j))
+			}
+			assert.Equal(t, 0, ring.GetNodesCount())
+			for j := 0; j < keySize; j++ {
+				ring.Add(prefix + strconv.Itoa(j))
+			}
+		})
+	}
+	close(done)
+	wg.Wait()
+
+	ch.Batch(func(ring ConsistentHash) {
+		ring.Remove(prefix + "0")
+		ring.Add("new")
+		assert.Equal(t, keySize, ring.GetNodesCount())
+		_, ok := ch.state.Load().nodes["new"]
+		assert.False(t, ok, "Batch: changes should not be published before the end")
+	})
+	_, ok := ch.state.Load().nodes["new"]
+	assert.True(t, ok, "Batch: changes should be published")
+	_, ok = ch.state.Load().nodes[prefix+"0"]
+	assert.False(t, ok, "Batch: changes should be published")
+}
+
+func TestHashRing_GetN(t *testing.T) {
+	ch := NewHashRing()
+	nodes, ok := ch.GetN("any", 2)
+	assert.False(t, ok)
+	assert.Nil(t, nodes)
+
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	nodes, ok = ch.GetN("any", 0)
+	assert.False(t, ok)
+	assert.Nil(t, nodes)
+
+	for i := 0; i < requestSize; i++ {
+		node, _ := ch.Get(i)
+		nodes, ok = ch.GetN(i, 3)
+		assert.True(t, ok)
+		assert.Equal(t, 3, len(nodes))
+		assert.Equal(t, node, nodes[0])
+		assert.NotEqual(t, nodes[0], nodes[1])
+		assert.NotEqual(t, nodes[1], nodes[2])
+		assert.NotEqual(t, nodes[0], nodes[2])
+	}
+
+	nodes, _ = ch.GetN("any", keySize*2)
+	assert.Equal(t, keySize, len(nodes))
+}
+
+func TestHashRing_Snapshot(t *testing.T) {
+	ch := NewHashRing()
+	snapshot := ch.Snapshot()
+	assert.Empty(t, snapshot.Nodes)
+	assert.Equal(t, 0, snapshot.Replicas)
+
+	ch.Add("first")
+	ch.AddWithWeight("second", 50)
+	ch.AddWithReplicas("third", 0)
+
+	snapshot = ch.Snapshot()
+	assert.Equal(t, minReplicas+minReplicas/2, snapshot.Replicas)
+	assert.Equal(t, 3, len(snapshot.Nodes))
+
+	var total float64
+	for _, node := range snapshot.Nodes {
+		total += node.Ownership
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+
+	first, second, third := snapshot.Nodes[0], snapshot.Nodes[1], snapshot.Nodes[2]
+	assert.Equal(t, "first", first.Node)
+	assert.Equal(t, minReplicas, first.Replicas)
+	assert.Equal(t, "second", second.Node)
+	assert.Equal(t, minReplicas/2, second.Replicas)
+	assert.True(t, first.Ownership > second.Ownership)
+	assert.Equal(t, "third", third.Node)
+	assert.Equal(t, 0, third.Replicas)
+	assert.Equal(t, float64(0), third.Ownership)
+
+	keys := make(map[any]int)
+	for i := 0; i < requestSize*10; i++ {
+		node, _ := ch.Get(i)
+		keys[node]++
+	}
+	assert.InDelta(t, first.Ownership, float64(keys["first"])/(requestSize*10), .05)
+}
+
 func Test_innerRepr(t *testing.T) {
 	type args struct {
 		node any
@@ -0,0 +1,176 @@
+package consistenthash
+
+import (
+	"slices"
+	"sync"
+)
+
+var _ ConsistentHash = (*JumpHash)(nil)
+
+// A JumpHash is implementation of consistent hash based on the jump consistent hash algorithm
+// (John Lamping, Eric Veach "A Fast, Minimal Memory, Consistent Hash Algorithm").
+//
+// It does not keep virtual nodes, so the memory footprint is one slice of nodes and Get works
+// without a binary search. The price is the topology: keys move minimally only when nodes are
+// appended to the end or removed from the end. Removing a node from the middle shifts all the
+// nodes after it and remaps their keys. Weights and replicas are not supported.
+type JumpHash struct {
+	hashFunc Func
+	nodes    []any
+	lock     sync.RWMutex
+	// mu serializes the changes of h, lock is held only for updating nodes.
+	mu sync.Mutex
+}
+
+// NewJumpHash returns a JumpHash.
+func NewJumpHash() *JumpHash {
+	return NewCustomJumpHash(Hash)
+}
+
+// NewCustomJumpHash returns a JumpHash with given hash func.
+func NewCustomJumpHash(fn Func) *JumpHash {
+	if fn == nil {
+		fn = Hash
+	}
+
+	return &JumpHash{
+		hashFunc: fn,
+	}
+}
+
+// Add appends the node to the end of h, if the node already exists, the call is ignored.
+func (h *JumpHash) Add(node any) {
+	nodeRepr := repr(node)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.indexOf(nodeRepr) >= 0 {
+		return
+	}
+	h.nodes = append(h.nodes, node)
+}
+
+// AddWithReplicas is the same as Add, JumpHash does not use virtual nodes.
+func (h *JumpHash) AddWithReplicas(node any, _ int) {
+	h.Add(node)
+}
+
+// AddWithWeight is the same as Add, JumpHash gives every node an equal share of the keys.
+func (h *JumpHash) AddWithWeight(node any, _ int) {
+	h.Add(node)
+}
+
+// Get returns the corresponding node from h base on the given v.
+func (h *JumpHash) Get(v any) (any, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.nodes) == 0 {
+		return nil, false
+	}
+
+	hash := h.hashFunc([]byte(repr(v)))
+	return h.nodes[jump(hash, len(h.nodes))], true
+}
+
+// GetN returns up to n distinct nodes for the given v: the node from Get and then the nodes following it.
+func (h *JumpHash) GetN(v any, n int) ([]any, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.nodes) == 0 || n <= 0 {
+		return nil, false
+	}
+
+	var (
+		bucket = jump(h.hashFunc([]byte(repr(v))), len(h.nodes))
+		nodes  = make([]any, min(n, len(h.nodes)))
+	)
+	for i := range nodes {
+		nodes[i] = h.nodes[(bucket+i)%len(h.nodes)]
+	}
+
+	return nodes, true
+}
+
+// GetAllNodes returns all nodes used in h in the order of addition.
+func (h *JumpHash) GetAllNodes() []any {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if len(h.nodes) == 0 {
+		return nil
+	}
+
+	allNodes := make([]any, len(h.nodes))
+	copy(allNodes, h.nodes)
+
+	return allNodes
+}
+
+// Remove removes the given node from h.
+// Only removing the last node keeps the keys of the other nodes in place.
+func (h *JumpHash) Remove(node any) {
+	nodeRepr := repr(node)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if i := h.indexOf(nodeRepr); i >= 0 {
+		h.nodes = append(h.nodes[:i], h.nodes[i+1:]...)
+	}
+}
+
+// Batch applies the changes made by fn to a copy of h and publishes them at once,
+// so Get never sees an intermediate state. fn must change the given ring, not h.
+func (h *JumpHash) Batch(fn func(ring ConsistentHash)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lock.RLock()
+	next := &JumpHash{
+		hashFunc: h.hashFunc,
+		nodes:    slices.Clone(h.nodes),
+	}
+	h.lock.RUnlock()
+
+	fn(next)
+
+	h.lock.Lock()
+	h.nodes = next.nodes
+	h.lock.Unlock()
+}
+
+// GetNodesCount returns the current number of nodes
+func (h *JumpHash) GetNodesCount() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.nodes)
+}
+
+func (h *JumpHash) indexOf(nodeRepr string) int {
+	for i, n := range h.nodes {
+		if repr(n) == nodeRepr {
+			return i
+		}
+	}
+	return -1
+}
+
+// jump returns the bucket in range [0, buckets) for the key.
+func jump(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
@@ -0,0 +1,143 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+func BenchmarkJumpHashGet(b *testing.B) {
+	ch := NewJumpHash()
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+	}
+
+	for i := 0; i < b.N; i++ {
+		ch.Get(i)
+	}
+}
+
+func TestJumpHash_GetAllNodes(t *testing.T) {
+	ch := NewCustomJumpHash(nil)
+
+	allNodes := ch.GetAllNodes()
+	assert.Nil(t, allNodes, "GetAllNodes: without added nodes")
+
+	val, ok := ch.Get("any")
+	assert.False(t, ok)
+	assert.Nil(t, val)
+
+	for i := 0; i < keySize; i++ {
+		ch.Add("localhost:" + strconv.Itoa(i))
+		ch.AddWithWeight("localhost:"+strconv.Itoa(i), 50)
+	}
+	assert.Equal(t, keySize, ch.GetNodesCount())
+
+	allNodes = ch.GetAllNodes()
+	for i := 0; i < keySize; i++ {
+		assert.Equal(t, "localhost:"+strconv.Itoa(i), allNodes[i])
+	}
+}
+
+func TestJumpHashWithEntropy(t *testing.T) {
+	ch := NewJumpHash()
+	for i := 0; i < keySize; i++ {
+		ch.AddWithReplicas("localhost:"+strconv.Itoa(i), minReplicas)
+	}
+
+	keys := make(map[any]int)
+	for i := 0; i < requestSize; i++ {
+		key, ok := ch.Get(requestSize + i)
+		assert.True(t, ok)
+		keys[key]++
+	}
+
+	assert.True(t, utils.CalcEntropy(keys) > .95)
+}
+
+func TestJumpHashTransferOnTailChanges(t *testing.T) {
+	const prefix = "localhost:"
+	ch := NewJumpHash()
+	for i := 0; i < keySize; i++ {
+		ch.Add(prefix + strconv.Itoa(i))
+	}
+
+	keys := make(map[int]any, requestSize)
+	for i := 0; i < requestSize; i++ {
+		keys[i], _ = ch.Get(requestSize + i)
+	}
+
+	tail := prefix + strconv.Itoa(keySize)
+	ch.Add(tail)
+	for i := 0; i < requestSize; i++ {
+		node, _ := ch.Get(requestSize + i)
+		assert.True(t, node == keys[i] || node == tail)
+	}
+
+	ch.Remove(tail)
+	for i := 0; i < requestSize; i++ {
+		node, _ := ch.Get(requestSize + i)
+		assert.Equal(t, keys[i], node)
+	}
+}
+
+func TestJumpHash_Remove(t *testing.T) {
+	ch := NewJumpHash()
+	ch.Add("first")
+	ch.Add("second")
+	ch.Remove("first")
+	ch.Remove("unknown")
+	assert.Equal(t, 1, ch.GetNodesCount())
+	for i := 0; i < 100; i++ {
+		val, ok := ch.Get(i)
+		assert.True(t, ok)
+		assert.Equal(t, "second", val)
+	}
+}
+
+func TestJumpHash_Batch(t *testing.T) {
+	ch := NewJumpHash()
+	ch.Add("first")
+	ch.Add("second")
+
+	ch.Batch(func(ring ConsistentHash) {
+		ring.Remove("first")
+		ring.Add("third")
+		assert.Equal(t, []any{"first", "second"}, ch.GetAllNodes(), "Batch: changes should not be published before the end")
+	})
+	assert.Equal(t, []any{"second", "third"}, ch.GetAllNodes(), "Batch: changes should be published")
+}
+
+func TestJumpHash_GetN(t *testing.T) {
+	ch := NewJumpHash()
+	nodes, ok := ch.GetN("any", 2)
+	assert.False(t, ok)
+	assert.Nil(t, nodes)
+
+	ch.Add("first")
+	ch.Add("second")
+	ch.Add("third")
+
+	for i := 0; i < requestSize; i++ {
+		node, _ := ch.Get(i)
+		nodes, ok = ch.GetN(i, 2)
+		assert.True(t, ok)
+		assert.Equal(t, node, nodes[0])
+		assert.NotEqual(t, nodes[0], nodes[1])
+	}
+
+	nodes, _ = ch.GetN("any", 5)
+	assert.Equal(t, 3, len(nodes))
+}
+
+func Test_jump(t *testing.T) {
+	for i := uint64(0); i < requestSize; i++ {
+		assert.Equal(t, 0, jump(i, 1))
+
+		b := jump(i, keySize)
+		assert.True(t, b >= 0 && b < keySize)
+	}
+}
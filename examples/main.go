@@ -18,7 +18,7 @@ func main() {
 		memcached.WithDisableMemcachedDiagnostic(),
 	)
 	mustInit(err)
-	defer mcl.CloseAllConns()
+	defer mcl.Close()
 
 	_, err = mcl.Store(memcached.Set, "foo", 10, []byte("bar"))
 	mustInit(err)
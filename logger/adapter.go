@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Logger is a logger of the library, it can be set per client by memcached.WithLogger,
+// so the logs of the client are written to the structured logging of the application.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+var (
+	_ Logger = (*zap.SugaredLogger)(nil)
+	_ Logger = globalLogger{}
+	_ Logger = slogLogger{}
+	_ Logger = nop{}
+	_ Logger = fieldsLogger{}
+)
+
+// Default returns Logger, that writes to the global logger (see SetLogger and DisableLogger).
+func Default() Logger {
+	return globalLogger{}
+}
+
+// NewZap returns Logger, that writes to l.
+func NewZap(l *zap.SugaredLogger) Logger {
+	return l
+}
+
+// NewSlog returns Logger, that writes the formatted messages to l with the same levels,
+// the fields added by With are the attributes of the records.
+func NewSlog(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+// With returns Logger, that adds the fields to every record of l, args are key-value pairs
+// as in slog.Logger.With (e.g. "cluster", "sessions", "env", "prod").
+// The fields are native for zap and slog loggers, for other loggers they are appended to the message.
+func With(l Logger, args ...any) Logger {
+	if len(args) == 0 {
+		return l
+	}
+	switch l := l.(type) {
+	case *zap.SugaredLogger:
+		return l.With(args...)
+	case slogLogger:
+		return slogLogger{l: l.l.With(args...)}
+	case globalLogger:
+		return globalLogger{fields: append(slices.Clip(l.fields), args...)}
+	case nop:
+		return l
+	case fieldsLogger:
+		return fieldsLogger{l: l.l, fields: append(slices.Clip(l.fields), args...)}
+	case *sampledLogger:
+		return &sampledLogger{l: With(l.l, args...), s: l.s}
+	default:
+		return fieldsLogger{l: l, fields: slices.Clone(args)}
+	}
+}
+
+// Nop returns Logger, that discards all logs.
+func Nop() Logger {
+	return nop{}
+}
+
+// globalLogger is Logger of the package functions with the fields.
+type globalLogger struct {
+	fields []any
+}
+
+func (g globalLogger) Debugf(format string, args ...any) {
+	if log := g.logger(); log != nil {
+		log.Debugf(format, args...)
+	}
+}
+
+func (g globalLogger) Infof(format string, args ...any) {
+	if log := g.logger(); log != nil {
+		log.Infof(format, args...)
+	}
+}
+
+func (g globalLogger) Warnf(format string, args ...any) {
+	if log := g.logger(); log != nil {
+		log.Warnf(format, args...)
+	}
+}
+
+func (g globalLogger) Errorf(format string, args ...any) {
+	if log := g.logger(); log != nil {
+		log.Errorf(format, args...)
+	}
+}
+
+// logger returns the global logger with the fields, nil if the logs are disabled.
+func (g globalLogger) logger() *zap.SugaredLogger {
+	if LoggerIsDisable() {
+		return nil
+	}
+	if len(g.fields) == 0 {
+		return GetLogger()
+	}
+	return GetLogger().With(g.fields...)
+}
+
+// fieldsLogger appends the fields to the messages of the logger without the native fields.
+type fieldsLogger struct {
+	l      Logger
+	fields []any
+}
+
+func (f fieldsLogger) Debugf(format string, args ...any) { f.l.Debugf("%s", f.message(format, args)) }
+func (f fieldsLogger) Infof(format string, args ...any)  { f.l.Infof("%s", f.message(format, args)) }
+func (f fieldsLogger) Warnf(format string, args ...any)  { f.l.Warnf("%s", f.message(format, args)) }
+func (f fieldsLogger) Errorf(format string, args ...any) { f.l.Errorf("%s", f.message(format, args)) }
+
+func (f fieldsLogger) message(format string, args []any) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(format, args...))
+	for i := 0; i+1 < len(f.fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", f.fields[i], f.fields[i+1])
+	}
+	return b.String()
+}
+
+type nop struct{}
+
+func (nop) Debugf(string, ...any) {}
+func (nop) Infof(string, ...any)  {}
+func (nop) Warnf(string, ...any)  {}
+func (nop) Errorf(string, ...any) {}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debugf(format string, args ...any) { s.log(slog.LevelDebug, format, args) }
+func (s slogLogger) Infof(format string, args ...any)  { s.log(slog.LevelInfo, format, args) }
+func (s slogLogger) Warnf(format string, args ...any)  { s.log(slog.LevelWarn, format, args) }
+func (s slogLogger) Errorf(format string, args ...any) { s.log(slog.LevelError, format, args) }
+
+// log formats the message only if the level is enabled.
+func (s slogLogger) log(level slog.Level, format string, args []any) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	s.l.Log(ctx, level, fmt.Sprintf(format, args...))
+}
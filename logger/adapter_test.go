@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	l.Debugf("debug %d", 1)
+	assert.Empty(t, buf.String(), "Debugf: level is disabled")
+
+	l.Warnf("warn %d", 2)
+	assert.Contains(t, buf.String(), "level=WARN")
+	assert.Contains(t, buf.String(), `msg="warn 2"`)
+}
+
+type recordLogger struct {
+	records []string
+}
+
+func (r *recordLogger) Debugf(format string, args ...any) { r.Infof(format, args...) }
+func (r *recordLogger) Infof(format string, args ...any) {
+	r.records = append(r.records, fmt.Sprintf(format, args...))
+}
+func (r *recordLogger) Warnf(format string, args ...any)  { r.Infof(format, args...) }
+func (r *recordLogger) Errorf(format string, args ...any) { r.Infof(format, args...) }
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	l := With(NewSlog(slog.New(slog.NewTextHandler(&buf, nil))), "cluster", "sessions")
+	With(l, "node", "127.0.0.1:11211").Errorf("error %d", 1)
+	assert.Contains(t, buf.String(), `msg="error 1" cluster=sessions node=127.0.0.1:11211`, "With: slog attributes")
+
+	r := &recordLogger{}
+	With(With(r, "cluster", "sessions"), "node", "127.0.0.1:11211").Warnf("warn %d", 2)
+	assert.Equal(t, []string{"warn 2 cluster=sessions node=127.0.0.1:11211"}, r.records, "With: fields in the message")
+
+	assert.Equal(t, Nop(), With(Nop(), "cluster", "sessions"))
+	assert.Equal(t, Logger(r), With(r), "With: without fields")
+}
+
+func TestNewSampled(t *testing.T) {
+	r := &recordLogger{}
+	l := NewSampled(r, 0, 2, 3)
+	for i := 1; i <= 8; i++ {
+		With(l, "node", "127.0.0.1:11211").Warnf("dead node %d", i)
+		l.Errorf("other %d", i)
+	}
+	assert.Equal(t, []string{
+		"dead node 1 node=127.0.0.1:11211", "other 1",
+		"dead node 2 node=127.0.0.1:11211", "other 2",
+		"dead node 5 node=127.0.0.1:11211", "other 5",
+		"dead node 8 node=127.0.0.1:11211", "other 8",
+	}, r.records, "NewSampled: the first 2 and then every 3rd record of every message")
+
+	r.records = nil
+	l = NewSampled(r, time.Millisecond, 1, 0)
+	l.Infof("message")
+	l.Infof("message")
+	time.Sleep(2 * time.Millisecond)
+	l.Infof("message")
+	assert.Equal(t, []string{"message", "message"}, r.records, "NewSampled: counters should be reset after the period")
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// sampledLogger writes the first records of every message and then every thereafter-th one,
+	// the loggers with fields derived by With share the counters.
+	sampledLogger struct {
+		l Logger
+		s *sampler
+	}
+
+	// sampler counts the records by the format of the message in the period.
+	sampler struct {
+		period     time.Duration
+		first      uint64
+		thereafter uint64
+
+		mu     sync.Mutex
+		counts map[string]uint64
+		reset  time.Time
+	}
+)
+
+var _ Logger = (*sampledLogger)(nil)
+
+// NewSampled returns Logger, that writes the first records of every message (counted by the format)
+// and then every thereafter-th one in the period, so a repeated warning (e.g. about a dead node on every
+// health check) doesn't flood the logs. Zero thereafter drops all records after the first ones,
+// zero period never resets the counters.
+func NewSampled(l Logger, period time.Duration, first, thereafter int) Logger {
+	return &sampledLogger{
+		l: l,
+		s: &sampler{
+			period:     period,
+			first:      uint64(max(first, 0)),
+			thereafter: uint64(max(thereafter, 0)),
+			counts:     make(map[string]uint64),
+		},
+	}
+}
+
+func (l *sampledLogger) Debugf(format string, args ...any) {
+	if l.s.allow(format) {
+		l.l.Debugf(format, args...)
+	}
+}
+
+func (l *sampledLogger) Infof(format string, args ...any) {
+	if l.s.allow(format) {
+		l.l.Infof(format, args...)
+	}
+}
+
+func (l *sampledLogger) Warnf(format string, args ...any) {
+	if l.s.allow(format) {
+		l.l.Warnf(format, args...)
+	}
+}
+
+func (l *sampledLogger) Errorf(format string, args ...any) {
+	if l.s.allow(format) {
+		l.l.Errorf(format, args...)
+	}
+}
+
+// allow counts the record with format and reports whether it is written.
+func (s *sampler) allow(format string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); s.period > 0 && now.After(s.reset) {
+		clear(s.counts)
+		s.reset = now.Add(s.period)
+	}
+
+	n := s.counts[format] + 1
+	s.counts[format] = n
+	return n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0)
+}
@@ -0,0 +1,246 @@
+// Package memcachecompat provides the adapter with the method set of *memcache.Client from
+// github.com/bradfitz/gomemcache, so the code bases using it can switch to gomemcached without a rewrite.
+package memcachecompat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+var (
+	// ErrCacheMiss means that a Get failed because the item wasn't present.
+	ErrCacheMiss = memcached.ErrCacheMiss
+	// ErrCASConflict means that a CompareAndSwap call failed due to the cached value being modified
+	// between the Get and the CompareAndSwap.
+	ErrCASConflict = memcached.ErrCASConflict
+	// ErrNotStored means that a conditional write operation (i.e. Add or CompareAndSwap) failed
+	// because the condition was not satisfied.
+	ErrNotStored = memcached.ErrNotStored
+	// ErrMalformedKey is returned when an invalid key is used.
+	ErrMalformedKey = memcached.ErrMalformedKey
+	// ErrNoServers is returned when no servers are configured or available.
+	ErrNoServers = memcached.ErrNoServers
+	// ErrServerError means that a server error occurred.
+	ErrServerError = memcached.ErrServerError
+)
+
+var _ Memcached = (*memcached.Client)(nil)
+
+type (
+	// Memcached is the part of memcached.Client used by Client, memcachedtest.Mock implements it too.
+	Memcached interface {
+		memcached.Memcached
+		Touch(key string, exp uint32, opts ...memcached.CallOption) (*memcached.Response, error)
+	}
+
+	// Client has the method set of *memcache.Client on top of the gomemcached client.
+	// The errors are the sentinels of this package (the ones of gomemcached), not of gomemcache,
+	// so they can be compared by == with ErrCacheMiss and others of this package, but not with memcache.ErrCacheMiss.
+	Client struct {
+		mc Memcached
+	}
+
+	// Item is an item to be got or stored in a memcached server.
+	Item struct {
+		// Key is the Item's key (250 bytes maximum).
+		Key string
+		// Value is the Item's value.
+		Value []byte
+		// Flags are server-opaque flags whose semantics are entirely up to the app.
+		Flags uint32
+		// Expiration is the cache expiration time, in seconds: either a relative time from now
+		// (up to 1 month), or an absolute Unix epoch time. Zero means the Item has no expiration time.
+		// Negative values mean the Item is expired immediately.
+		Expiration int32
+
+		// casID is the CAS identifier from Get for CompareAndSwap.
+		casID uint64
+	}
+
+	// healthChecker is implemented by memcached.Client.
+	healthChecker interface {
+		Health(ctx context.Context) memcached.HealthReport
+	}
+)
+
+// New returns the adapter for mc, usually *memcached.Client.
+func New(mc Memcached) *Client {
+	return &Client{mc: mc}
+}
+
+// Get gets the item for the given key. ErrCacheMiss is returned for a memcache cache miss.
+func (c *Client) Get(key string) (*Item, error) {
+	it := &Item{Key: key}
+	resp, err := c.mc.Get(key, memcached.WithFlagsOut(&it.Flags), memcached.WithCASOut(&it.casID))
+	if err != nil {
+		return nil, unwrapErr(err)
+	}
+	it.Value = resp.Body
+	return it, nil
+}
+
+// GetMulti is a batch version of Get. The returned map from keys to items may have fewer elements
+// than the input slice, due to memcache cache misses. The flags of the items are not returned.
+func (c *Client) GetMulti(keys []string) (map[string]*Item, error) {
+	values, err := c.mc.MultiGet(keys)
+	if err != nil {
+		return nil, unwrapErr(err)
+	}
+
+	items := make(map[string]*Item, len(values))
+	for key, value := range values {
+		items[key] = &Item{Key: key, Value: value}
+	}
+	return items, nil
+}
+
+// Set writes the given item, unconditionally.
+func (c *Client) Set(item *Item) error {
+	return c.store(memcached.Set, item)
+}
+
+// Add writes the given item, if no value already exists for its key.
+// ErrNotStored is returned if that condition is not met.
+func (c *Client) Add(item *Item) error {
+	return c.store(memcached.Add, item)
+}
+
+// Replace writes the given item, but only if the server *does* already hold data for this key.
+// ErrNotStored is returned if that condition is not met.
+func (c *Client) Replace(item *Item) error {
+	err := c.store(memcached.Replace, item)
+	if errors.Is(err, ErrCacheMiss) {
+		return ErrNotStored
+	}
+	return err
+}
+
+// CompareAndSwap writes the given item that was previously returned by Get,
+// if the value was neither modified nor evicted between the Get and the CompareAndSwap calls.
+// ErrCASConflict is returned, if the value was modified, and ErrNotStored, if it was evicted.
+func (c *Client) CompareAndSwap(item *Item) error {
+	err := c.store(memcached.Set, item, memcached.WithCAS(item.casID))
+	switch err {
+	case ErrCacheMiss:
+		return ErrNotStored
+	case ErrNotStored:
+		// KEY_EEXISTS of the request with CAS means that the item was modified.
+		return ErrCASConflict
+	}
+	return err
+}
+
+// Append appends the given item to the existing item, if a value already exists for its key.
+// ErrNotStored is returned if that condition is not met.
+func (c *Client) Append(item *Item) error {
+	_, err := c.mc.Append(memcached.Append, item.Key, item.Value)
+	return unwrapErr(err)
+}
+
+// Prepend prepends the given item to the existing item, if a value already exists for its key.
+// ErrNotStored is returned if that condition is not met.
+func (c *Client) Prepend(item *Item) error {
+	_, err := c.mc.Append(memcached.Prepend, item.Key, item.Value)
+	return unwrapErr(err)
+}
+
+// Delete deletes the item with the provided key. The error ErrCacheMiss is returned
+// if the item didn't already exist in the cache.
+func (c *Client) Delete(key string) error {
+	_, err := c.mc.Delete(key)
+	return unwrapErr(err)
+}
+
+// DeleteAll deletes all items in the cache.
+func (c *Client) DeleteAll() error {
+	return unwrapErr(c.mc.FlushAll(0))
+}
+
+// FlushAll deletes all items in the cache.
+func (c *Client) FlushAll() error {
+	return c.DeleteAll()
+}
+
+// Increment atomically increments key by delta. The return value is the new value after
+// being incremented or an error. If the value didn't exist in memcached the error is ErrCacheMiss.
+func (c *Client) Increment(key string, delta uint64) (uint64, error) {
+	newValue, err := c.mc.Delta(memcached.Increment, key, delta, 0, 0, memcached.WithoutCreate())
+	return newValue, unwrapErr(err)
+}
+
+// Decrement atomically decrements key by delta. The return value is the new value after
+// being decremented or an error. If the value didn't exist in memcached the error is ErrCacheMiss.
+// The value is capped at zero, it doesn't "wrap around".
+func (c *Client) Decrement(key string, delta uint64) (uint64, error) {
+	newValue, err := c.mc.Delta(memcached.Decrement, key, delta, 0, 0, memcached.WithoutCreate())
+	return newValue, unwrapErr(err)
+}
+
+// Touch updates the expiry for the given key. The seconds parameter is either a Unix timestamp or,
+// if seconds is less than 1 month, the number of seconds into the future at which time the item
+// will expire. ErrCacheMiss is returned if the key is not in the cache.
+func (c *Client) Touch(key string, seconds int32) error {
+	_, err := c.mc.Touch(key, expiration(seconds))
+	return unwrapErr(err)
+}
+
+// Ping checks all nodes, if they are alive. It does nothing, if the underlying client can't check the nodes.
+func (c *Client) Ping() error {
+	hc, ok := c.mc.(healthChecker)
+	if !ok {
+		return nil
+	}
+
+	var multiErr error
+	for _, node := range hc.Health(context.Background()).Nodes {
+		if node.Status == memcached.NodeAlive {
+			continue
+		}
+		nodeErr := fmt.Errorf("%w: %s is %s", memcached.ErrServerNotAvailable, node.Addr, node.Status)
+		if node.Err != nil {
+			nodeErr = fmt.Errorf("%w - %w", nodeErr, node.Err)
+		}
+		multiErr = errors.Join(multiErr, nodeErr)
+	}
+	return multiErr
+}
+
+// Close closes the underlying client, if it implements io.Closer.
+func (c *Client) Close() error {
+	if cl, ok := c.mc.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+func (c *Client) store(storeMode memcached.StoreMode, item *Item, opts ...memcached.CallOption) error {
+	opts = append(opts, memcached.WithFlags(item.Flags))
+	_, err := c.mc.Store(storeMode, item.Key, expiration(item.Expiration), item.Value, opts...)
+	return unwrapErr(err)
+}
+
+// expiredTime is the earliest absolute Unix time, that memcached doesn't take for the relative expiration,
+// it is in the past, so the item with it is expired immediately.
+const expiredTime = 30*24*60*60 + 1
+
+// expiration returns the expiration for memcached, the negative exp expires the item immediately like in gomemcache.
+func expiration(exp int32) uint32 {
+	if exp < 0 {
+		return expiredTime
+	}
+	return uint32(exp)
+}
+
+// unwrapErr returns the sentinel of the package for err, so it can be compared by ==.
+func unwrapErr(err error) error {
+	for _, sentinel := range []error{ErrCacheMiss, ErrCASConflict, ErrNotStored, ErrMalformedKey, ErrNoServers} {
+		if errors.Is(err, sentinel) {
+			return sentinel
+		}
+	}
+	return err
+}
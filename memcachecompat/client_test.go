@@ -0,0 +1,99 @@
+package memcachecompat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/memcachedtest"
+)
+
+func TestClient_Errors(t *testing.T) {
+	c := New(memcachedtest.New())
+
+	_, err := c.Get("missing")
+	assert.Equal(t, ErrCacheMiss, err, "Get: error should be comparable by ==")
+	assert.Equal(t, ErrCacheMiss, c.Delete("missing"))
+	assert.Equal(t, ErrCacheMiss, c.Touch("missing", 10))
+	assert.Equal(t, ErrNotStored, c.Replace(&Item{Key: "missing"}))
+	assert.Equal(t, ErrNotStored, c.Append(&Item{Key: "missing"}))
+	assert.Equal(t, ErrMalformedKey, c.Set(&Item{Key: "bad key"}))
+
+	_, err = c.Increment("missing", 1)
+	assert.Equal(t, ErrCacheMiss, err, "Increment: missing item should not be created")
+
+	require.Nil(t, c.Set(&Item{Key: "key"}))
+	assert.Equal(t, ErrNotStored, c.Add(&Item{Key: "key"}))
+}
+
+func TestClient_Operations(t *testing.T) {
+	m := memcachedtest.New()
+	c := New(m)
+
+	require.Nil(t, c.Set(&Item{Key: "key", Value: []byte("b"), Expiration: 10}))
+	require.Nil(t, c.Append(&Item{Key: "key", Value: []byte("c")}))
+	require.Nil(t, c.Prepend(&Item{Key: "key", Value: []byte("a")}))
+
+	it, err := c.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, "key", it.Key)
+	assert.Equal(t, []byte("abc"), it.Value)
+
+	require.Nil(t, c.Set(&Item{Key: "flags", Value: []byte("value"), Flags: 42}))
+	it, err = c.Get("flags")
+	require.Nil(t, err)
+	assert.Equal(t, uint32(42), it.Flags)
+
+	items, err := c.GetMulti([]string{"key", "missing"})
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, []byte("abc"), items["key"].Value)
+
+	require.Nil(t, c.Set(&Item{Key: "counter", Value: []byte("10")}))
+	value, err := c.Increment("counter", 5)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(15), value)
+	value, err = c.Decrement("counter", 20)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), value)
+
+	require.Nil(t, c.Touch("key", 100))
+	require.Nil(t, c.Delete("key"))
+	require.Nil(t, c.DeleteAll())
+	assert.Equal(t, 0, m.Len())
+
+	assert.Nil(t, c.Ping(), "Ping: without health check")
+	assert.Nil(t, c.Close(), "Close: without io.Closer")
+}
+
+func TestClient_NegativeExpiration(t *testing.T) {
+	c := New(memcachedtest.New())
+
+	require.Nil(t, c.Set(&Item{Key: "key", Value: []byte("value"), Expiration: -1}))
+	_, err := c.Get("key")
+	assert.Equal(t, ErrCacheMiss, err, "Set: negative expiration should expire the item immediately")
+
+	require.Nil(t, c.Set(&Item{Key: "key", Value: []byte("value")}))
+	require.Nil(t, c.Touch("key", -1))
+	_, err = c.Get("key")
+	assert.Equal(t, ErrCacheMiss, err, "Touch: negative expiration should expire the item immediately")
+}
+
+func TestClient_CompareAndSwap(t *testing.T) {
+	c := New(memcachedtest.New())
+	require.Nil(t, c.Set(&Item{Key: "key", Value: []byte("v1")}))
+
+	it, err := c.Get("key")
+	require.Nil(t, err)
+	stale, err := c.Get("key")
+	require.Nil(t, err)
+
+	it.Value = []byte("v2")
+	require.Nil(t, c.CompareAndSwap(it))
+	stale.Value = []byte("v3")
+	assert.Equal(t, ErrCASConflict, c.CompareAndSwap(stale))
+
+	require.Nil(t, c.Delete("key"))
+	assert.Equal(t, ErrNotStored, c.CompareAndSwap(it), "CompareAndSwap: evicted item")
+}
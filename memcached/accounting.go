@@ -0,0 +1,81 @@
+package memcached
+
+import (
+	"net"
+)
+
+const (
+	bytesWrittenEvent = "written"
+	bytesReadEvent    = "read"
+)
+
+// commandMethod returns the name of the method of the client, that sends the command, for the accounting
+// of the bytes. The quiet commands are sent by the multi-key methods only, the other commands are named as is.
+func commandMethod(opcode OpCode) string {
+	switch opcode {
+	case GET, GETK:
+		return "Get"
+	case GETQ, GETKQ:
+		return "MultiGet"
+	case SET, ADD, REPLACE:
+		return "Store"
+	case SETQ, ADDQ, REPLACEQ:
+		return "MultiStore"
+	case DELETE:
+		return "Delete"
+	case DELETEQ:
+		return "MultiDelete"
+	case INCREMENT, DECREMENT:
+		return "Delta"
+	case INCREMENTQ, DECREMENTQ:
+		return "MultiDelta"
+	case APPEND, PREPEND:
+		return "Append"
+	case APPENDQ, PREPENDQ:
+		return "MultiAppend"
+	case TOUCH:
+		return "Touch"
+	}
+	return opcode.String()
+}
+
+// countBytes counts n bytes of the command written to or read from the node by the metrics and DebugVars.
+func (c *Client) countBytes(node net.Addr, opcode OpCode, event string, n int) {
+	if c == nil || c.disableMemcachedDiagnostic || n <= 0 {
+		return
+	}
+	method := commandMethod(opcode)
+	observeBytes(method, node.String(), event, n)
+
+	counter := c.methodCounter(method)
+	if event == bytesWrittenEvent {
+		counter.bytesWritten.Add(uint64(n))
+	} else {
+		counter.bytesRead.Add(uint64(n))
+	}
+}
+
+// transmit writes req to the buffer of the connection and counts its bytes.
+func (cn *conn) transmit(req *Request) (int, error) {
+	n, err := transmitRequest(cn.wrtBuf, req)
+	cn.c.countBytes(cn.addr, req.Opcode, bytesWrittenEvent, n)
+	return n, err
+}
+
+// receive reads the response from the connection and counts its bytes.
+func (cn *conn) receive() (*Response, int, error) {
+	resp, n, err := getResponse(cn.rc, cn.hdrBuf, cn.c.getRecvBodyLen())
+	if resp != nil {
+		cn.c.countBytes(cn.addr, resp.Opcode, bytesReadEvent, n)
+	}
+	return resp, n, err
+}
+
+// receivePooled reads the pooled response from the connection and counts its bytes.
+func (cn *conn) receivePooled() (*Response, int, error) {
+	resp, n, err := getPooledResponse(cn.rc, cn.hdrBuf, cn.c.getRecvBodyLen())
+	if resp != nil {
+		cn.c.countBytes(cn.addr, resp.Opcode, bytesReadEvent, n)
+	}
+	return resp, n, err
+}
@@ -0,0 +1,26 @@
+package memcached
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_commandMethod(t *testing.T) {
+	tests := map[OpCode]string{
+		GET:        "Get",
+		GETKQ:      "MultiGet",
+		SET:        "Store",
+		SETQ:       "MultiStore",
+		DELETEQ:    "MultiDelete",
+		INCREMENT:  "Delta",
+		DECREMENTQ: "MultiDelta",
+		PREPEND:    "Append",
+		APPENDQ:    "MultiAppend",
+		TOUCH:      "Touch",
+		NOOP:       NOOP.String(),
+	}
+	for opcode, want := range tests {
+		assert.Equal(t, want, commandMethod(opcode), "commandMethod: wrong method of %s", opcode)
+	}
+}
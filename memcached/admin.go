@@ -0,0 +1,128 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+// Admin sends the commands of the runtime tuning of memcached to the nodes, so the operators don't need
+// to connect to the pods by netcat. The errors of the nodes are joined, the commands of the other nodes are applied.
+// The text commands are not supported by the nodes with SASL authentication.
+type Admin struct {
+	c     *Client
+	nodes []any
+}
+
+// Admin returns Admin of the nodes by their addresses (see Client.Nodes), all nodes of the hash ring if none are given.
+// It fails with ErrInvalidAddr, if any of the addresses is not a node of the hash ring.
+func (c *Client) Admin(addrs ...string) (*Admin, error) {
+	if len(addrs) == 0 {
+		return &Admin{c: c, nodes: c.hr.GetAllNodes()}, nil
+	}
+
+	nodes := make([]any, 0, len(addrs))
+	for _, addr := range addrs {
+		node, err := c.nodeByAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return &Admin{c: c, nodes: nodes}, nil
+}
+
+// Verbosity is sets the verbosity of the logs of memcached (0 - errors only, 1 - warnings, 2 - commands, 3 - internals).
+func (a *Admin) Verbosity(level uint32) (err error) {
+	timer := time.Now()
+	defer a.c.writeMethodDiagnostics("AdminVerbosity", timer, &err)
+
+	if len(a.nodes) == 0 {
+		return ErrNoServers
+	}
+	_, err = a.c.broadcast(a.nodes, func(int) *Request {
+		req := &Request{Opcode: VERBOSITY, Extras: make([]byte, 4)}
+		binary.BigEndian.PutUint32(req.Extras, level)
+		return req
+	})
+	return err
+}
+
+// LRUCrawler is turns on or off the LRU crawler, that reclaims the memory of the expired items in background
+// (lru_crawler enable/disable).
+func (a *Admin) LRUCrawler(enable bool) (err error) {
+	timer := time.Now()
+	defer a.c.writeMethodDiagnostics("AdminLRUCrawler", timer, &err)
+
+	cmd := "lru_crawler disable"
+	if enable {
+		cmd = "lru_crawler enable"
+	}
+	return a.textCommand(cmd)
+}
+
+// CacheMemLimit is sets the memory limit of the items in megabytes (cache_memlimit), the decrease of the limit
+// doesn't free the allocated memory, the items are evicted as the memory is reused.
+func (a *Admin) CacheMemLimit(megabytes uint32) (err error) {
+	timer := time.Now()
+	defer a.c.writeMethodDiagnostics("AdminCacheMemLimit", timer, &err)
+
+	if megabytes == 0 {
+		return fmt.Errorf("%w: cache memory limit 0", ErrInvalidArguments)
+	}
+	return a.textCommand("cache_memlimit " + strconv.FormatUint(uint64(megabytes), 10))
+}
+
+// textCommand sends the text command to the nodes in parallel, the command must be answered by OK.
+func (a *Admin) textCommand(cmd string) error {
+	if len(a.nodes) == 0 {
+		return ErrNoServers
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		multiErr error
+	)
+	for _, node := range a.nodes {
+		wg.Add(1)
+		go func(node any) {
+			defer wg.Done()
+
+			err := a.c.textCommandToNode(node, cmd)
+			if err != nil {
+				mu.Lock()
+				multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for node - %s", err, utils.Repr(node)))
+				mu.Unlock()
+			}
+		}(node)
+	}
+
+	wg.Wait()
+
+	return multiErr
+}
+
+func (c *Client) textCommandToNode(node any, cmd string) error {
+	tc, err := c.dialText(node)
+	if err != nil {
+		return err
+	}
+	defer tc.close()
+
+	line, err := tc.command(cmd)
+	switch {
+	case err != nil:
+		return err
+	case line == "ERROR":
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, cmd)
+	case line != "OK":
+		return fmt.Errorf("%w: %s: %s", ErrServerError, cmd, line)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package memcached
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminServer is a fake memcached with the binary VERBOSITY and the text lru_crawler and cache_memlimit.
+type adminServer struct {
+	addr string
+
+	mu        sync.Mutex
+	verbosity uint32
+	commands  []string
+}
+
+func newAdminServer(t *testing.T) *adminServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	srv := &adminServer{addr: ln.Addr().String()}
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			go srv.serve(nc)
+		}
+	}()
+	return srv
+}
+
+func (s *adminServer) serve(nc net.Conn) {
+	defer nc.Close()
+	rd := bufio.NewReader(nc)
+	first, err := rd.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if first[0] == REQ_MAGIC {
+		for {
+			req := new(Request)
+			if _, err = req.Receive(rd, nil); err != nil {
+				return
+			}
+			resp := &Response{Opcode: req.Opcode, Opaque: req.Opaque}
+			if req.Opcode == VERBOSITY {
+				s.mu.Lock()
+				s.verbosity = binary.BigEndian.Uint32(req.Extras)
+				s.mu.Unlock()
+			} else {
+				resp.Status = UNKNOWN_COMMAND
+			}
+			if _, err = resp.Transmit(nc); err != nil {
+				return
+			}
+		}
+	}
+
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		answer := "ERROR"
+		switch {
+		case cmd == "lru_crawler enable", cmd == "lru_crawler disable":
+			answer = "OK"
+		case strings.HasPrefix(cmd, "cache_memlimit "):
+			answer = "OK"
+			if cmd == "cache_memlimit 1" {
+				answer = "CLIENT_ERROR memory limit too small"
+			}
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+		if _, err = nc.Write([]byte(answer + "\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func TestClient_Admin(t *testing.T) {
+	srv1, srv2 := newAdminServer(t), newAdminServer(t)
+	c, err := newForTests(srv1.addr, srv2.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	admin, err := c.Admin()
+	require.Nil(t, err)
+	require.Nil(t, admin.Verbosity(2))
+	require.Nil(t, admin.LRUCrawler(true))
+	require.Nil(t, admin.CacheMemLimit(2048))
+	for _, srv := range []*adminServer{srv1, srv2} {
+		srv.mu.Lock()
+		assert.Equal(t, uint32(2), srv.verbosity)
+		assert.Equal(t, []string{"lru_crawler enable", "cache_memlimit 2048"}, srv.commands)
+		srv.mu.Unlock()
+	}
+
+	admin, err = c.Admin(srv2.addr)
+	require.Nil(t, err)
+	require.Nil(t, admin.LRUCrawler(false))
+	err = admin.CacheMemLimit(1)
+	assert.ErrorIs(t, err, ErrServerError)
+	assert.ErrorContains(t, err, srv2.addr)
+	assert.ErrorIs(t, admin.CacheMemLimit(0), ErrInvalidArguments)
+	srv1.mu.Lock()
+	assert.Len(t, srv1.commands, 2, "Admin: the commands should be sent to the given nodes only")
+	srv1.mu.Unlock()
+
+	_, err = c.Admin("127.0.0.3:11211")
+	assert.ErrorIs(t, err, ErrInvalidAddr)
+}
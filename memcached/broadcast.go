@@ -0,0 +1,98 @@
+package memcached
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+// Broadcast sends a copy of req to every node of the hash ring in parallel and returns the responses
+// by the addresses of the nodes (see Client.Nodes). It is used for the admin commands like VERSION or NOOP.
+// The request must have a single response, so the quiet commands and STAT are not supported.
+// The responses with not SUCCESS status are returned too, the errors of the nodes are joined.
+func (c *Client) Broadcast(req *Request) (_ map[string]*Response, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Broadcast", timer, &err)
+
+	if req.Opcode.IsQuiet() || req.Opcode == STAT {
+		return nil, fmt.Errorf("%w: %s can't be broadcast", ErrInvalidArguments, req.Opcode)
+	}
+
+	return c.broadcast(c.hr.GetAllNodes(), func(int) *Request {
+		r := *req
+		return &r
+	})
+}
+
+// RoundTrip sends a copy of req to the node of its key and returns the response, it is used by the proxies
+// (see the package server). The quiet commands are sent as the usual ones, and nil response is returned, when memcached
+// doesn't answer on them: on success and on the cache miss of GETQ and GETKQ.
+// The responses with not SUCCESS status are returned with the errors. The local cache and hedging are bypassed,
+// of the call options only WithCallTimeout and RouteTo are applied.
+func (c *Client) RoundTrip(req *Request, opts ...CallOption) (_ *Response, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("RoundTrip", timer, &err)
+
+	key := string(req.Key)
+	if key == "" {
+		return nil, fmt.Errorf("%w: %s without key can't be routed", ErrInvalidArguments, req.Opcode)
+	}
+	if !legalKey(key) {
+		return nil, ErrMalformedKey
+	}
+
+	co := ResolveCallOptions(opts...)
+	node, err := c.nodeForKey(key, &co)
+	if err != nil {
+		return nil, err
+	}
+
+	r := *req
+	r.Opcode = req.Opcode.changeOnNotQuiet()
+	resp, err := c.sendToNode(node, &r, co.Timeout)
+	if req.Opcode.IsQuiet() && resp != nil {
+		switch {
+		case resp.Status == SUCCESS,
+			resp.Status == KEY_ENOENT && (req.Opcode == GETQ || req.Opcode == GETKQ):
+			return nil, nil
+		}
+	}
+	return resp, err
+}
+
+// broadcast sends newRequest(i) to nodes[i] in parallel.
+func (c *Client) broadcast(nodes []any, newRequest func(i int) *Request) (map[string]*Response, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		multiErr error
+
+		ret = make(map[string]*Response, len(nodes))
+	)
+
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(node any, req *Request) {
+			defer wg.Done()
+
+			addr := utils.Repr(node)
+			resp, err := c.sendToNode(node, req, 0)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if resp != nil {
+				ret[addr] = resp
+			}
+			if err != nil {
+				multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for node - %s", err, addr))
+			}
+		}(node, newRequest(i))
+	}
+
+	wg.Wait()
+
+	return ret, multiErr
+}
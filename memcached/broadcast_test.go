@@ -0,0 +1,79 @@
+package memcached
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Broadcast(t *testing.T) {
+	newServer := func(version string) *fakeServer {
+		return newFakeServer(t, func(*Request) *Response {
+			if version == "" {
+				return &Response{Status: UNKNOWN_COMMAND}
+			}
+			return &Response{Body: []byte(version)}
+		})
+	}
+	srv1, srv2 := newServer("1.6.9"), newServer("")
+
+	c, err := newForTests(srv1.addr, srv2.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	req := &Request{Opcode: VERSION}
+	got, err := c.Broadcast(req)
+	assert.ErrorIs(t, err, ErrUnknownCommand, "Broadcast: errors of the nodes should be joined")
+	assert.ErrorContains(t, err, srv2.addr, "Broadcast: error should contain the address of the node")
+	require.Len(t, got, 2, "Broadcast: responses with error status should be returned too")
+	assert.Equal(t, []byte("1.6.9"), got[srv1.addr].Body)
+	assert.Equal(t, UNKNOWN_COMMAND, got[srv2.addr].Status)
+	assert.Zero(t, req.Opaque, "Broadcast: request should be copied")
+
+	_, err = c.Broadcast(&Request{Opcode: GETQ})
+	assert.ErrorIs(t, err, ErrInvalidArguments, "Broadcast: quiet command")
+	_, err = c.Broadcast(&Request{Opcode: STAT})
+	assert.ErrorIs(t, err, ErrInvalidArguments, "Broadcast: STAT")
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch {
+		case req.Opcode.IsQuiet():
+			return &Response{Status: EINVAL}
+		case string(req.Key) == "missing":
+			return &Response{Status: KEY_ENOENT}
+		}
+		return &Response{Body: []byte("value")}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	req := &Request{Opcode: GET, Key: []byte("key")}
+	resp, err := c.RoundTrip(req)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("value"), resp.Body)
+	assert.Zero(t, req.Opaque, "RoundTrip: request should be copied")
+
+	resp, err = c.RoundTrip(&Request{Opcode: GET, Key: []byte("missing")})
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	assert.Equal(t, KEY_ENOENT, resp.Status)
+
+	resp, err = c.RoundTrip(&Request{Opcode: GETQ, Key: []byte("missing")})
+	assert.Nil(t, err, "RoundTrip: the cache miss of GETQ is not answered")
+	assert.Nil(t, resp)
+	resp, err = c.RoundTrip(&Request{Opcode: SETQ, Key: []byte("key"), Extras: make([]byte, 8)})
+	assert.Nil(t, err, "RoundTrip: the success of the quiet command is not answered")
+	assert.Nil(t, resp)
+	resp, err = c.RoundTrip(&Request{Opcode: DELETEQ, Key: []byte("missing")})
+	assert.ErrorIs(t, err, ErrCacheMiss, "RoundTrip: the errors of the quiet commands are answered")
+	assert.Equal(t, KEY_ENOENT, resp.Status)
+
+	_, err = c.RoundTrip(&Request{Opcode: NOOP})
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = c.RoundTrip(&Request{Opcode: GET, Key: []byte("bad key")})
+	assert.ErrorIs(t, err, ErrMalformedKey)
+}
@@ -0,0 +1,135 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+type (
+	// CallOption is an option of a single call of Store, Get, Delete, Delta, Append or Touch,
+	// it overrides the settings of the client for this call only.
+	CallOption func(*CallOptions)
+
+	// CallOptions are the resolved options of a call. They are exported for the other implementations
+	// of Memcached (e.g. memcachedtest.Mock), see ResolveCallOptions.
+	CallOptions struct {
+		// Timeout limits every read and write of the call instead of the read and write timeouts of the client.
+		Timeout time.Duration
+		// CAS is the CAS identifier, the item must have to be changed, zero means any.
+		CAS uint64
+		// Flags are stored with the item by Store.
+		Flags uint32
+		// FlagsOut receives the flags of the item got by Get.
+		FlagsOut *uint32
+		// CASOut receives the CAS identifier of the item after the successful call.
+		CASOut *uint64
+		// NoCreate turns off the creation of the missing item by Delta.
+		NoCreate bool
+		// RouteTo is the address of the node, that serves the call instead of the node of the key.
+		RouteTo string
+	}
+)
+
+// ResolveCallOptions applies opts in order.
+func ResolveCallOptions(opts ...CallOption) CallOptions {
+	var co CallOptions
+	for _, opt := range opts {
+		opt(&co)
+	}
+	return co
+}
+
+// resolveCallOptions applies opts, the timeout of the method (see WithMethodTimeouts) is used,
+// if the timeout of the call is not set.
+func (c *Client) resolveCallOptions(method Method, opts ...CallOption) CallOptions {
+	co := ResolveCallOptions(opts...)
+	if co.Timeout <= 0 {
+		co.Timeout = c.methodTimeouts[method]
+	}
+	return co
+}
+
+// WithCallTimeout is sets the read and write timeout for the call.
+// By default, the timeout of the method (see WithMethodTimeouts) or the timeouts of the client
+// will be used (see WithReadTimeout, WithWriteTimeout).
+func WithCallTimeout(tm time.Duration) CallOption {
+	return func(co *CallOptions) {
+		co.Timeout = tm
+	}
+}
+
+// WithCAS is sets the CAS identifier, that the item must have to be changed.
+// If the item was changed after it was got, the call fails with ErrNotStored.
+// The identifier is returned by Get in Response.Cas or by WithCASOut.
+func WithCAS(cas uint64) CallOption {
+	return func(co *CallOptions) {
+		co.CAS = cas
+	}
+}
+
+// WithFlags is sets the flags stored with the item by Store, they are opaque for memcached.
+// By default, the flags are 0.
+func WithFlags(flags uint32) CallOption {
+	return func(co *CallOptions) {
+		co.Flags = flags
+	}
+}
+
+// WithFlagsOut is sets the destination of the flags of the item got by Get.
+func WithFlagsOut(flags *uint32) CallOption {
+	return func(co *CallOptions) {
+		co.FlagsOut = flags
+	}
+}
+
+// WithCASOut is sets the destination of the CAS identifier of the item after the successful call.
+func WithCASOut(cas *uint64) CallOption {
+	return func(co *CallOptions) {
+		co.CASOut = cas
+	}
+}
+
+// WithoutCreate is turn off the creation of the missing counter by Delta with the initial value,
+// ErrCacheMiss is returned instead. The expiration of Delta is replaced by DeltaNoCreate.
+func WithoutCreate() CallOption {
+	return func(co *CallOptions) {
+		co.NoCreate = true
+	}
+}
+
+// RouteTo is sets the node, that serves the call, by its address (see Client.Nodes), the hash ring is bypassed.
+// It is used for debugging of the key placement, the repair of the items on a certain node and the proxies.
+// The call fails with ErrInvalidAddr, if the address is not a node of the hash ring.
+// The routed Get is not served by the local cache and is not hedged.
+func RouteTo(addr string) CallOption {
+	return func(co *CallOptions) {
+		co.RouteTo = addr
+	}
+}
+
+// prepareRequest sets CAS, flags and the expiration of Delta without creation to req,
+// it must be called after prepareExtras.
+func (co *CallOptions) prepareRequest(req *Request) {
+	req.Cas = co.CAS
+	switch req.Opcode {
+	case SET, SETQ, ADD, ADDQ, REPLACE, REPLACEQ:
+		binary.BigEndian.PutUint32(req.Extras[:4], co.Flags)
+	case INCREMENT, INCREMENTQ, DECREMENT, DECREMENTQ:
+		if co.NoCreate {
+			binary.BigEndian.PutUint32(req.Extras[16:], DeltaNoCreate)
+		}
+	}
+}
+
+// readResponse sets the outputs of the successful call.
+func (co *CallOptions) readResponse(resp *Response, err error) {
+	if err != nil || resp == nil {
+		return
+	}
+	if co.CASOut != nil {
+		*co.CASOut = resp.Cas
+	}
+	if co.FlagsOut != nil && len(resp.Extras) >= 4 {
+		*co.FlagsOut = binary.BigEndian.Uint32(resp.Extras)
+	}
+}
@@ -0,0 +1,173 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CallOptions(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs = make(map[OpCode]*Request)
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		mu.Lock()
+		reqs[req.Opcode] = req
+		mu.Unlock()
+
+		switch {
+		case string(req.Key) == "silent":
+			return nil
+		case req.Opcode == GET:
+			extras := make([]byte, 4)
+			binary.BigEndian.PutUint32(extras, 42)
+			return &Response{Cas: 7, Extras: extras, Body: []byte("value")}
+		case req.Opcode == SET && req.Cas == 1:
+			return &Response{Status: KEY_EEXISTS}
+		case req.Opcode == INCREMENT:
+			body := make([]byte, 8)
+			binary.BigEndian.PutUint64(body, 11)
+			return &Response{Cas: 9, Body: body}
+		}
+		return &Response{Cas: 8}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	var (
+		flags uint32
+		cas   uint64
+	)
+	_, err = c.Get("key", WithFlagsOut(&flags), WithCASOut(&cas))
+	require.Nil(t, err)
+	assert.Equal(t, uint32(42), flags)
+	assert.Equal(t, uint64(7), cas)
+
+	_, err = c.Store(Set, "key", 10, []byte("value"), WithCAS(cas), WithFlags(flags), WithCASOut(&cas))
+	require.Nil(t, err)
+	assert.Equal(t, uint64(8), cas)
+	mu.Lock()
+	set := reqs[SET]
+	mu.Unlock()
+	assert.Equal(t, uint64(7), set.Cas, "Store: CAS should be sent")
+	assert.Equal(t, uint32(42), binary.BigEndian.Uint32(set.Extras[:4]), "Store: flags should be sent")
+	assert.Equal(t, uint32(10), binary.BigEndian.Uint32(set.Extras[4:]))
+
+	cas = 0
+	_, err = c.Store(Set, "key", 0, nil, WithCAS(1), WithCASOut(&cas))
+	assert.ErrorIs(t, err, ErrNotStored, "Store: KEY_EEXISTS for the stale CAS")
+	assert.Zero(t, cas, "CASOut: should not be set for the failed call")
+
+	_, err = c.Delete("key", WithCAS(5))
+	require.Nil(t, err)
+	mu.Lock()
+	assert.Equal(t, uint64(5), reqs[DELETE].Cas, "Delete: CAS should be sent")
+	mu.Unlock()
+
+	value, err := c.Delta(Increment, "counter", 1, 10, 60, WithoutCreate(), WithCASOut(&cas))
+	require.Nil(t, err)
+	assert.Equal(t, uint64(11), value)
+	assert.Equal(t, uint64(9), cas, "Delta: CAS should be returned")
+	mu.Lock()
+	assert.Equal(t, DeltaNoCreate, binary.BigEndian.Uint32(reqs[INCREMENT].Extras[16:]), "Delta: should not create the item")
+	mu.Unlock()
+
+	timer := time.Now()
+	_, err = c.Get("silent", WithCallTimeout(50*time.Millisecond))
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr), "Get: should fail by the timeout of the call, got %v", err)
+	assert.True(t, netErr.Timeout())
+	assert.Less(t, time.Since(timer), DefaultTimeout)
+
+	_, err = c.Get("key")
+	assert.Nil(t, err, "Get: the timeout of the call should not break the next calls")
+}
+
+func TestClient_RouteTo(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys = make(map[string][]string)
+	)
+	handler := func(name string) func(req *Request) *Response {
+		return func(req *Request) *Response {
+			mu.Lock()
+			keys[name] = append(keys[name], string(req.Key))
+			mu.Unlock()
+			return &Response{}
+		}
+	}
+	srv1 := newFakeServer(t, handler("srv1"))
+	srv2 := newFakeServer(t, handler("srv2"))
+	names := map[string]string{srv1.addr: "srv1", srv2.addr: "srv2"}
+
+	c, err := newForTests(srv1.addr, srv2.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	owner, err := c.WhichNode("key")
+	require.Nil(t, err)
+	other := srv1.addr
+	if owner == srv1.addr {
+		other = srv2.addr
+	}
+
+	_, err = c.Store(Set, "key", 0, []byte("value"), RouteTo(other))
+	require.Nil(t, err)
+	_, err = c.Get("key", RouteTo(other))
+	require.Nil(t, err)
+	_, err = c.Delete("key", RouteTo(other))
+	require.Nil(t, err)
+	_, err = c.Get("key")
+	require.Nil(t, err)
+
+	mu.Lock()
+	assert.Equal(t, []string{"key", "key", "key"}, keys[names[other]], "RouteTo: calls should be sent to the node")
+	assert.Equal(t, []string{"key"}, keys[names[owner]], "Get: call without RouteTo should be sent to the owner")
+	mu.Unlock()
+
+	_, err = c.Get("key", RouteTo("127.0.0.1:1"))
+	assert.ErrorIs(t, err, ErrInvalidAddr)
+	_, err = c.Delta(Increment, "key", 1, 0, 0, RouteTo("127.0.0.1:1"))
+	assert.ErrorIs(t, err, ErrInvalidAddr)
+}
+
+func TestClient_MethodTimeouts(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case SETQ:
+			return nil
+		case NOOP, GET, DELETE:
+			time.Sleep(100 * time.Millisecond)
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.timeout = 50 * time.Millisecond
+	c.methodTimeouts = map[Method]time.Duration{
+		MethodMultiStore: time.Second,
+		MethodGet:        10 * time.Millisecond,
+	}
+
+	require.Nil(t, c.MultiStore(Set, map[string][]byte{"key1": []byte("1"), "key2": []byte("2")}, 0),
+		"MultiStore: the timeout of the method should override the timeout of the client")
+
+	_, err = c.Get("key")
+	assert.True(t, IsTimeout(err), "Get: the timeout of the method should be used, error - %v", err)
+	_, err = c.Get("key", WithCallTimeout(time.Second))
+	assert.Nil(t, err, "Get: the timeout of the call should override the timeout of the method")
+
+	_, err = c.Delete("key")
+	assert.True(t, IsTimeout(err), "Delete: the timeout of the client should be used without the timeout of the method")
+}
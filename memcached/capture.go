@@ -0,0 +1,319 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// trafficMagic starts the file of the traffic capture.
+	trafficMagic = "GMCAP1\n"
+	// captureMaxPending is a maximum number of the sampled requests on a connection waiting for the responses,
+	// the quiet commands don't respond on success, so the waiting ones are dropped over the limit.
+	captureMaxPending = 1024
+)
+
+// TrafficRecorder writes the frames of the binary protocol for a sampled fraction of the requests to a file,
+// the responses are written for the sampled requests only. Every record has the time and the address of the node.
+// The file is read by TrafficReader and replayed by Client.Replay, see WithTrafficCapture.
+type TrafficRecorder struct {
+	sampleRate float64
+
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	err    error
+	closed bool
+}
+
+// NewTrafficRecorder creates the file of the capture by path, sampleRate is the fraction of the requests
+// to write from 0 to 1.
+func NewTrafficRecorder(path string, sampleRate float64) (*TrafficRecorder, error) {
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("%w: sample rate %v is out of [0, 1]", ErrInvalidArguments, sampleRate)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	tr := &TrafficRecorder{sampleRate: sampleRate, f: f, w: bufio.NewWriter(f)}
+	if _, err = tr.w.WriteString(trafficMagic); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return tr, nil
+}
+
+// Close flushes the records and closes the file, it returns the first error of the writing.
+// The frames of the connections after Close are not written.
+func (tr *TrafficRecorder) Close() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed {
+		return tr.err
+	}
+	tr.closed = true
+	if err := tr.w.Flush(); err != nil && tr.err == nil {
+		tr.err = err
+	}
+	if err := tr.f.Close(); err != nil && tr.err == nil {
+		tr.err = err
+	}
+	return tr.err
+}
+
+func (tr *TrafficRecorder) sample() bool {
+	return tr.sampleRate > 0 && rand.Float64() < tr.sampleRate
+}
+
+// write writes the record: the time in unix nanoseconds, the length of addr, addr and the frame.
+func (tr *TrafficRecorder) write(addr string, hdr, body []byte) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closed || tr.err != nil {
+		return
+	}
+
+	var head [9]byte
+	binary.BigEndian.PutUint64(head[:8], uint64(time.Now().UnixNano()))
+	head[8] = byte(min(len(addr), 255))
+	for _, p := range [][]byte{head[:], []byte(addr[:head[8]]), hdr, body} {
+		if _, err := tr.w.Write(p); err != nil {
+			tr.err = err
+			return
+		}
+	}
+}
+
+// captureConn is net.Conn, that writes the sampled frames to TrafficRecorder, see WithTrafficCapture.
+type captureConn struct {
+	net.Conn
+	rec     *TrafficRecorder
+	addr    string
+	out, in frameSplitter
+
+	mu sync.Mutex
+	// pending are the opaques of the sampled requests waiting for the responses.
+	pending map[uint32]struct{}
+}
+
+func newCaptureConn(nc net.Conn, rec *TrafficRecorder, addr string) *captureConn {
+	cc := &captureConn{Conn: nc, rec: rec, addr: addr, pending: make(map[uint32]struct{})}
+	cc.out = frameSplitter{bodyLimit: -1, onFrame: cc.onRequest}
+	cc.in = frameSplitter{bodyLimit: -1, onFrame: cc.onResponse}
+	return cc
+}
+
+func (cc *captureConn) Write(p []byte) (int, error) {
+	n, err := cc.Conn.Write(p)
+	cc.out.feed(p[:n])
+	return n, err
+}
+
+func (cc *captureConn) Read(p []byte) (int, error) {
+	n, err := cc.Conn.Read(p)
+	cc.in.feed(p[:n])
+	return n, err
+}
+
+func (cc *captureConn) onRequest(hdr, body []byte) {
+	// the frames of SASL carry the credentials, so neither they nor their responses are written.
+	if saslOpcode(OpCode(hdr[1])) || !cc.rec.sample() {
+		return
+	}
+
+	cc.mu.Lock()
+	if len(cc.pending) >= captureMaxPending {
+		clear(cc.pending)
+	}
+	cc.pending[binary.BigEndian.Uint32(hdr[12:16])] = struct{}{}
+	cc.mu.Unlock()
+
+	cc.rec.write(cc.addr, hdr, body)
+}
+
+// saslOpcode returns true for the commands of SASL authentication.
+func saslOpcode(op OpCode) bool {
+	switch op {
+	case SASL_LIST_MECHS, SASL_AUTH, SASL_STEP:
+		return true
+	}
+	return false
+}
+
+func (cc *captureConn) onResponse(hdr, body []byte) {
+	opaque := binary.BigEndian.Uint32(hdr[12:16])
+
+	cc.mu.Lock()
+	_, ok := cc.pending[opaque]
+	delete(cc.pending, opaque)
+	cc.mu.Unlock()
+
+	if ok {
+		cc.rec.write(cc.addr, hdr, body)
+	}
+}
+
+type (
+	// TrafficReader reads the records of the capture written by TrafficRecorder.
+	TrafficReader struct {
+		r       *bufio.Reader
+		started bool
+	}
+
+	// TrafficRecord is a frame of the capture.
+	TrafficRecord struct {
+		// Time is the time, when the frame was written or read by the client.
+		Time time.Time
+		// Node is the address of the node of the connection.
+		Node string
+		// Request is the request frame, it is nil for the response one.
+		Request *Request
+		// Response is the response frame, it is nil for the request one.
+		Response *Response
+	}
+)
+
+// NewTrafficReader returns the reader of the capture from r.
+func NewTrafficReader(r io.Reader) *TrafficReader {
+	return &TrafficReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record of the capture, io.EOF is returned at the end.
+func (tr *TrafficReader) Next() (*TrafficRecord, error) {
+	if !tr.started {
+		magic := make([]byte, len(trafficMagic))
+		if _, err := io.ReadFull(tr.r, magic); err != nil || string(magic) != trafficMagic {
+			return nil, fmt.Errorf("%w: not a traffic capture", ErrInvalidArguments)
+		}
+		tr.started = true
+	}
+
+	var head [9]byte
+	if _, err := io.ReadFull(tr.r, head[:]); err != nil {
+		// io.EOF is returned only at the boundary of the records.
+		return nil, err
+	}
+	addr := make([]byte, head[8])
+	hdr := make([]byte, HDR_LEN)
+	if _, err := io.ReadFull(tr.r, addr); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if _, err := io.ReadFull(tr.r, hdr); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[8:12]))
+	if _, err := io.ReadFull(tr.r, body); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	rec := &TrafficRecord{
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(head[:8]))),
+		Node: string(addr),
+	}
+	frame := io.MultiReader(bytes.NewReader(hdr), bytes.NewReader(body))
+	var err error
+	switch hdr[0] {
+	case REQ_MAGIC:
+		rec.Request = &Request{}
+		_, err = rec.Request.Receive(frame, nil)
+	case RES_MAGIC:
+		rec.Response = &Response{}
+		_, err = rec.Response.Receive(frame, nil)
+	default:
+		err = fmt.Errorf("bad magic: 0x%02x", hdr[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Replay sends the requests of the capture from r (see TrafficRecorder) to the nodes of their keys in the hash ring
+// of the client, the intervals between the requests are the original ones divided by speed, zero speed sends them
+// without pauses. It is used to reproduce the access patterns of production in the load tests.
+// The quiet commands are sent as the usual ones, the requests without key (e.g. NOOP of the batches)
+// and the commands of SASL are skipped.
+// The statuses of the responses are ignored. It returns the number of the sent requests and stops on the first
+// error of the reading or the connection.
+func (c *Client) Replay(ctx context.Context, r io.Reader, speed float64) (int, error) {
+	var (
+		tr         = NewTrafficReader(r)
+		sent       int
+		first      time.Time
+		replayFrom = time.Now()
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		rec, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+		if rec.Request == nil || len(rec.Request.Key) == 0 || saslOpcode(rec.Request.Opcode) {
+			continue
+		}
+
+		if speed > 0 {
+			if first.IsZero() {
+				first = rec.Time
+			}
+			wait := time.Until(replayFrom.Add(time.Duration(float64(rec.Time.Sub(first)) / speed)))
+			if err = sleepCtx(ctx, wait); err != nil {
+				return sent, err
+			}
+		}
+
+		req := rec.Request
+		req.Opcode = req.Opcode.changeOnNotQuiet()
+		req.Opaque = 0
+		node, err := c.nodeForKey(string(req.Key), &CallOptions{})
+		if err != nil {
+			return sent, err
+		}
+		if _, err = c.sendToNode(node, req, 0); err != nil && UnwrapMemcachedError(err) == nil {
+			return sent, err
+		}
+		sent++
+	}
+}
+
+// sleepCtx waits for d or the cancellation of ctx.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
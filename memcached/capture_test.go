@@ -0,0 +1,147 @@
+package memcached
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_TrafficCapture(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == GET {
+			return &Response{Body: []byte("value")}
+		}
+		return &Response{}
+	})
+
+	path := filepath.Join(t.TempDir(), "traffic.cap")
+	rec, err := NewTrafficRecorder(path, 1)
+	require.Nil(t, err)
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	c.trafficRecorder = rec
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	c.CloseAllConns()
+	require.Nil(t, rec.Close())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	tr := NewTrafficReader(bytes.NewReader(data))
+	var records []*TrafficRecord
+	for {
+		r, nErr := tr.Next()
+		if nErr == io.EOF {
+			break
+		}
+		require.Nil(t, nErr)
+		records = append(records, r)
+	}
+	require.Len(t, records, 5, "TrafficRecorder: requests and responses should be written")
+	assert.Equal(t, SET, records[0].Request.Opcode)
+	assert.Equal(t, "key", string(records[0].Request.Key))
+	assert.Equal(t, "value", string(records[0].Request.Body))
+	assert.Equal(t, SET, records[1].Response.Opcode)
+	assert.Equal(t, GET, records[2].Request.Opcode)
+	assert.Equal(t, "value", string(records[3].Response.Body))
+	assert.Equal(t, QUITQ, records[4].Request.Opcode, "CloseAllConns: QUITQ should be written without response")
+	assert.Equal(t, srv.addr, records[0].Node)
+	assert.False(t, records[3].Time.Before(records[0].Time))
+
+	var (
+		mu       sync.Mutex
+		replayed []OpCode
+	)
+	target := newFakeServer(t, func(req *Request) *Response {
+		mu.Lock()
+		replayed = append(replayed, req.Opcode)
+		mu.Unlock()
+		return &Response{Status: KEY_ENOENT}
+	})
+	rc, err := newForTests(target.addr)
+	require.Nil(t, err)
+	defer rc.CloseAllConns()
+
+	n, err := rc.Replay(context.Background(), bytes.NewReader(data), 0)
+	require.Nil(t, err, "Replay: the statuses should be ignored")
+	assert.Equal(t, 2, n)
+	mu.Lock()
+	assert.Equal(t, []OpCode{SET, GET}, replayed)
+	mu.Unlock()
+
+	_, err = rc.Replay(context.Background(), bytes.NewReader([]byte("garbage")), 0)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = rc.Replay(context.Background(), bytes.NewReader(data[:len(data)-1]), 0)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestClient_TrafficCaptureAuth(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case SASL_LIST_MECHS:
+			return &Response{Body: []byte(SaslMechanism)}
+		case GET:
+			return &Response{Body: []byte("value")}
+		}
+		return &Response{}
+	})
+
+	path := filepath.Join(t.TempDir(), "traffic.cap")
+	rec, err := NewTrafficRecorder(path, 1)
+	require.Nil(t, err)
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	c.authEnable, c.authData = true, prepareAuthData("user", "secret")
+	c.trafficRecorder = rec
+
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	c.CloseAllConns()
+	require.Nil(t, rec.Close())
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.NotContains(t, string(data), "secret", "TrafficRecorder: the credentials should not be written")
+	tr := NewTrafficReader(bytes.NewReader(data))
+	for {
+		r, nErr := tr.Next()
+		if nErr == io.EOF {
+			break
+		}
+		require.Nil(t, nErr)
+		if r.Request != nil {
+			assert.NotContains(t, []OpCode{SASL_LIST_MECHS, SASL_AUTH, SASL_STEP}, r.Request.Opcode,
+				"TrafficRecorder: the frames of SASL should not be written")
+		}
+	}
+}
+
+func TestNewTrafficRecorder(t *testing.T) {
+	_, err := NewTrafficRecorder(filepath.Join(t.TempDir(), "traffic.cap"), 1.5)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	path := filepath.Join(t.TempDir(), "traffic.cap")
+	rec, err := NewTrafficRecorder(path, 0)
+	require.Nil(t, err)
+	cc := newCaptureConn(nil, rec, "node")
+	req := &Request{Opcode: GET, Key: []byte("key")}
+	cc.out.feed(req.Bytes())
+	require.Nil(t, rec.Close())
+	assert.Nil(t, rec.Close(), "Close: should be idempotent")
+
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, trafficMagic, string(data), "TrafficRecorder: nothing should be sampled with zero rate")
+}
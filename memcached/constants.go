@@ -44,6 +44,8 @@ const (
 	FLUSHQ     = OpCode(0x18)
 	APPENDQ    = OpCode(0x19)
 	PREPENDQ   = OpCode(0x1a)
+	VERBOSITY  = OpCode(0x1b)
+	TOUCH      = OpCode(0x1c)
 
 	SASL_LIST_MECHS = OpCode(0x20)
 	SASL_AUTH       = OpCode(0x21)
@@ -82,6 +84,10 @@ const (
 	UNKNOWN_STATUS = Status(0xffff)
 )
 
+// DeltaNoCreate is the expiration of Delta, with which the missing item is not created
+// and ErrCacheMiss is returned, see WithoutCreate.
+const DeltaNoCreate = uint32(0xffffffff)
+
 const (
 	// HDR_LEN is a number of bytes in a binary protocol header.
 	HDR_LEN  = 24
@@ -123,6 +129,8 @@ func init() {
 	CommandNames[FLUSHQ] = "FLUSHQ"
 	CommandNames[APPENDQ] = "APPENDQ"
 	CommandNames[PREPENDQ] = "PREPENDQ"
+	CommandNames[VERBOSITY] = "VERBOSITY"
+	CommandNames[TOUCH] = "TOUCH"
 
 	CommandNames[SASL_LIST_MECHS] = "SASL_LIST_MECHS"
 	CommandNames[SASL_AUTH] = "SASL_AUTH"
@@ -211,6 +219,38 @@ func (o OpCode) changeOnQuiet(def OpCode) OpCode {
 	}
 }
 
+// changeOnNotQuiet returns the usual command for the quiet one, the other commands are returned as is.
+func (o OpCode) changeOnNotQuiet() OpCode {
+	switch o {
+	case GETQ:
+		return GET
+	case GETKQ:
+		return GETK
+	case SETQ:
+		return SET
+	case ADDQ:
+		return ADD
+	case REPLACEQ:
+		return REPLACE
+	case DELETEQ:
+		return DELETE
+	case INCREMENTQ:
+		return INCREMENT
+	case DECREMENTQ:
+		return DECREMENT
+	case QUITQ:
+		return QUIT
+	case FLUSHQ:
+		return FLUSH
+	case APPENDQ:
+		return APPEND
+	case PREPENDQ:
+		return PREPEND
+	default:
+		return o
+	}
+}
+
 func prepareAuthData(user, pass string) []byte {
 	return []byte(fmt.Sprintf("\x00%s\x00%s", user, pass))
 }
@@ -0,0 +1,141 @@
+package memcached
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// DebugSnapshot is the state of the client returned by DebugVars.
+	DebugSnapshot struct {
+		// Nodes are the sorted addresses of the nodes of the hash ring.
+		Nodes []string `json:"nodes"`
+		// DeadNodes are the sorted addresses of the nodes removed from the hash ring by the health check.
+		DeadNodes []string `json:"dead_nodes"`
+		// TopologyVersion is the version of the hash ring, see TopologyEvents.
+		TopologyVersion uint64 `json:"topology_version"`
+		// DiscoveryTime and DiscoveryErr are the time and the error of the last discovery of the nodes.
+		DiscoveryTime time.Time `json:"discovery_time"`
+		DiscoveryErr  string    `json:"discovery_error,omitempty"`
+		// Pools are the statistics of the connection pools by the addresses of the nodes.
+		Pools map[string]DebugPool `json:"pools"`
+		// MuxInFlight is the number of the multiplexed requests waiting for the response.
+		MuxInFlight int `json:"mux_in_flight"`
+		// LocalCacheItems is the number of the items in the local cache.
+		LocalCacheItems int `json:"local_cache_items"`
+		// Methods are the counters of the calls of the methods by their names.
+		Methods map[string]DebugMethod `json:"methods"`
+	}
+
+	// DebugPool is the statistics of the connection pool of the node in DebugSnapshot.
+	DebugPool struct {
+		Idle        int     `json:"idle"`
+		InUse       int     `json:"in_use"`
+		Waits       int64   `json:"waits"`
+		WaitSeconds float64 `json:"wait_seconds"`
+	}
+
+	// DebugMethod is the counters of the calls of the method in DebugSnapshot.
+	DebugMethod struct {
+		// Calls are all calls of the method, Errors are the failed ones, the cache misses are not errors.
+		Calls  uint64 `json:"calls"`
+		Errors uint64 `json:"errors"`
+		// Hits and Misses are the keys read by the method, HitRatio is the share of the hits from 0 to 1.
+		Hits     uint64  `json:"hits,omitempty"`
+		Misses   uint64  `json:"misses,omitempty"`
+		HitRatio float64 `json:"hit_ratio,omitempty"`
+		// BytesWritten and BytesRead are the bytes of the commands of the method sent to and received from the nodes.
+		BytesWritten uint64 `json:"bytes_written,omitempty"`
+		BytesRead    uint64 `json:"bytes_read,omitempty"`
+	}
+
+	// methodCounter counts the calls of the method for DebugVars.
+	methodCounter struct {
+		calls, errors, hits, misses atomic.Uint64
+		bytesWritten, bytesRead     atomic.Uint64
+	}
+)
+
+// DebugVars returns expvar.Var with the snapshot of the client (see DebugSnapshot), that is taken on every read,
+// so the client can be inspected by /debug/vars without Prometheus:
+//
+//	expvar.Publish("memcached", mcl.DebugVars())
+//
+// The calls of the methods are counted with the metrics, so they are not counted with WithDisableMemcachedDiagnostic.
+func (c *Client) DebugVars() expvar.Var {
+	return expvar.Func(func() any {
+		return c.DebugSnapshot()
+	})
+}
+
+// DebugSnapshot returns the current state of the client.
+func (c *Client) DebugSnapshot() DebugSnapshot {
+	s := DebugSnapshot{
+		Nodes:           c.Nodes(),
+		DeadNodes:       c.DeadNodes(),
+		TopologyVersion: c.topologyVersion.Load(),
+		Pools:           make(map[string]DebugPool),
+		MuxInFlight:     c.muxRequestsInFlight(),
+		Methods:         make(map[string]DebugMethod),
+	}
+
+	var discoveryErr error
+	s.DiscoveryTime, discoveryErr = c.safeGetDiscoveryResult()
+	if discoveryErr != nil {
+		s.DiscoveryErr = discoveryErr.Error()
+	}
+	for addr, stats := range c.PoolStats() {
+		s.Pools[addr] = DebugPool{
+			Idle:        stats.Idle,
+			InUse:       stats.InUse,
+			Waits:       stats.Waits,
+			WaitSeconds: stats.WaitDuration.Seconds(),
+		}
+	}
+	if c.localCache != nil {
+		s.LocalCacheItems = c.localCache.len()
+	}
+	c.methodCounters.Range(func(name, mc any) bool {
+		counter := mc.(*methodCounter)
+		dm := DebugMethod{
+			Calls:  counter.calls.Load(),
+			Errors: counter.errors.Load(),
+			Hits:   counter.hits.Load(),
+			Misses: counter.misses.Load(),
+
+			BytesWritten: counter.bytesWritten.Load(),
+			BytesRead:    counter.bytesRead.Load(),
+		}
+		if dm.Hits+dm.Misses > 0 {
+			dm.HitRatio = float64(dm.Hits) / float64(dm.Hits+dm.Misses)
+		}
+		s.Methods[name.(string)] = dm
+		return true
+	})
+	return s
+}
+
+// countMethodCall counts the call of the method for DebugVars.
+func (c *Client) countMethodCall(methodName, result string) {
+	counter := c.methodCounter(methodName)
+	counter.calls.Add(1)
+	if result == requestErrorResult {
+		counter.errors.Add(1)
+	}
+}
+
+// countMethodKeys counts the hits and the misses of the keys read by the method for DebugVars.
+func (c *Client) countMethodKeys(methodName string, hits, misses int) {
+	counter := c.methodCounter(methodName)
+	counter.hits.Add(uint64(hits))
+	counter.misses.Add(uint64(misses))
+}
+
+func (c *Client) methodCounter(methodName string) *methodCounter {
+	mc, ok := c.methodCounters.Load(methodName)
+	if !ok {
+		mc, _ = c.methodCounters.LoadOrStore(methodName, &methodCounter{})
+	}
+	return mc.(*methodCounter)
+}
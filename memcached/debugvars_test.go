@@ -0,0 +1,55 @@
+package memcached
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DebugVars(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == GET {
+			return &Response{Status: KEY_ENOENT}
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.disableMemcachedDiagnostic = false
+	c.deadNodes = map[string]struct{}{"127.0.0.3:11211": {}}
+	c.safeSetDiscoveryResult(errors.New("lookup error"))
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+	_, err = c.Get("key")
+	require.ErrorIs(t, err, ErrCacheMiss)
+	_, err = c.Get("key")
+	require.ErrorIs(t, err, ErrCacheMiss)
+
+	var s DebugSnapshot
+	require.Nil(t, json.Unmarshal([]byte(c.DebugVars().String()), &s), "DebugVars: should be JSON")
+	assert.Equal(t, []string{srv.addr}, s.Nodes)
+	assert.Equal(t, []string{"127.0.0.3:11211"}, s.DeadNodes)
+	assert.Equal(t, "lookup error", s.DiscoveryErr)
+	assert.False(t, s.DiscoveryTime.IsZero())
+	assert.Equal(t, 1, s.Pools[srv.addr].Idle)
+	assert.Zero(t, s.Pools[srv.addr].InUse)
+	// Store writes the header, the extras, the key and the value, Get writes the header and the key.
+	assert.Equal(t, DebugMethod{Calls: 1, BytesWritten: HDR_LEN + 8 + 3 + 5, BytesRead: HDR_LEN}, s.Methods["Store"])
+	assert.Equal(t, DebugMethod{Calls: 2, Misses: 2, BytesWritten: 2 * (HDR_LEN + 3), BytesRead: 2 * HDR_LEN}, s.Methods["Get"],
+		"DebugVars: the cache misses are not errors")
+
+	c.writeMethodKeys("MultiGet", 3, 1)
+	assert.Equal(t, DebugMethod{Hits: 3, Misses: 1, HitRatio: 0.75}, c.DebugSnapshot().Methods["MultiGet"])
+
+	c.disableMemcachedDiagnostic = true
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+	assert.Equal(t, DebugMethod{Calls: 1, BytesWritten: HDR_LEN + 8 + 3 + 5, BytesRead: HDR_LEN}, c.DebugSnapshot().Methods["Store"],
+		"DebugVars: calls and bytes are not counted without diagnostics")
+}
@@ -0,0 +1,455 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+const (
+	// dumpMagic starts the export of Client.Export.
+	dumpMagic = "GMDUMP1\n"
+	// exportBatch is a number of the keys of Export got by one Pipeline.
+	exportBatch = 100
+	// maxRelativeExp is a maximum expiration in seconds, that memcached treats as relative, the greater ones
+	// are the unix times.
+	maxRelativeExp = 30 * 24 * 60 * 60
+)
+
+type (
+	// KeyInfo is the key of the item found by KeysIterator.
+	KeyInfo struct {
+		Key string
+		// Node is the address of the node of the item.
+		Node string
+		// Expiration is the time of the expiration of the item, it is zero for the item without expiration.
+		Expiration time.Time
+		// Size is the size of the item, it is the size of the whole item for lru_crawler metadump
+		// and the size of the value for stats cachedump.
+		Size int
+	}
+
+	// KeysIterator is the best-effort iterator of the keys of all nodes by the text protocol of memcached:
+	// lru_crawler metadump, or stats cachedump, if the crawler is not available (it is limited by memcached
+	// to about 2 MB of the keys per slab class). The keys written or deleted during the iteration may be missed
+	// or returned. The nodes with SASL authentication are not supported.
+	//
+	//	it := mcl.Keys(ctx)
+	//	defer it.Close()
+	//	for it.Next() {
+	//		fmt.Println(it.Key().Key)
+	//	}
+	//	err := it.Err()
+	KeysIterator struct {
+		c     *Client
+		ctx   context.Context
+		nodes []any
+
+		addr string
+		tc   *textConn
+		// pending are the keys of the node got by stats cachedump.
+		pending []KeyInfo
+
+		cur KeyInfo
+		err error
+	}
+)
+
+// Keys returns the iterator of the keys of all nodes of the hash ring, see KeysIterator.
+// ctx limits the iteration, it is checked on every key.
+func (c *Client) Keys(ctx context.Context) *KeysIterator {
+	return &KeysIterator{c: c, ctx: ctx, nodes: c.hr.GetAllNodes()}
+}
+
+// Next moves the iterator to the next key, it returns false at the end or on the error, see Err.
+func (it *KeysIterator) Next() bool {
+	for it.err == nil {
+		if it.err = it.ctx.Err(); it.err != nil {
+			break
+		}
+		if len(it.pending) != 0 {
+			it.cur, it.pending = it.pending[0], it.pending[1:]
+			return true
+		}
+		if it.tc == nil {
+			if len(it.nodes) == 0 {
+				return false
+			}
+			it.err = it.openNode(it.nodes[0])
+			it.nodes = it.nodes[1:]
+			continue
+		}
+
+		line, err := it.tc.readLine()
+		switch {
+		case err != nil:
+			it.err = err
+		case line == "END":
+			it.closeNode()
+		default:
+			it.cur, it.err = parseMetadump(line, it.addr)
+			if it.err == nil {
+				return true
+			}
+		}
+	}
+	it.closeNode()
+	return false
+}
+
+// Key returns the current key of the iterator.
+func (it *KeysIterator) Key() KeyInfo {
+	return it.cur
+}
+
+// Err returns the error of the iteration, the error of the node is wrapped with its address.
+func (it *KeysIterator) Err() error {
+	if it.err == nil || errors.Is(it.err, it.ctx.Err()) || it.addr == "" {
+		return it.err
+	}
+	return fmt.Errorf("%w. Error for node - %s", it.err, it.addr)
+}
+
+// Close stops the iteration and closes the connection to the current node.
+func (it *KeysIterator) Close() error {
+	it.closeNode()
+	it.nodes = nil
+	it.pending = nil
+	return nil
+}
+
+// openNode dials the node and starts lru_crawler metadump, stats cachedump of the node is read into pending,
+// if the crawler is not available.
+func (it *KeysIterator) openNode(node any) error {
+	it.addr = utils.Repr(node)
+	tc, err := it.c.dialText(node)
+	if err != nil {
+		return err
+	}
+	it.tc = tc
+
+	line, err := it.tc.command("lru_crawler metadump all")
+	switch {
+	case err != nil && !errors.Is(err, ErrServerError):
+		return err
+	case err == nil && strings.HasPrefix(line, "key="):
+		key, err := parseMetadump(line, it.addr)
+		if err != nil {
+			return err
+		}
+		it.pending = []KeyInfo{key}
+		return nil
+	case line == "END":
+		it.closeNode()
+		return nil
+	}
+
+	// ERROR, CLIENT_ERROR or BUSY of the crawler, the connection is in the usual state.
+	if it.pending, err = it.cachedump(); err != nil {
+		return err
+	}
+	it.closeNode()
+	return nil
+}
+
+// cachedump reads the keys of all slab classes of the node by stats cachedump.
+func (it *KeysIterator) cachedump() ([]KeyInfo, error) {
+	if err := it.tc.writeLine("stats items"); err != nil {
+		return nil, err
+	}
+	var classes []string
+	for {
+		line, err := it.tc.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			break
+		}
+		// STAT items:<class>:number <count>
+		fields := strings.Fields(line)
+		if len(fields) == 3 && strings.HasPrefix(fields[1], "items:") && strings.HasSuffix(fields[1], ":number") {
+			classes = append(classes, strings.TrimSuffix(strings.TrimPrefix(fields[1], "items:"), ":number"))
+		}
+	}
+
+	var keys []KeyInfo
+	for _, class := range classes {
+		if err := it.tc.writeLine("stats cachedump " + class + " 0"); err != nil {
+			return nil, err
+		}
+		for {
+			line, err := it.tc.readLine()
+			if err != nil {
+				return nil, err
+			}
+			if line == "END" {
+				break
+			}
+			key, err := parseCachedump(line, it.addr)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (it *KeysIterator) closeNode() {
+	if it.tc != nil {
+		it.tc.close()
+	}
+	it.tc = nil
+}
+
+// parseMetadump parses the line of lru_crawler metadump:
+//
+//	key=foo exp=-1 la=1690000000 cas=2 fetch=no cls=1 size=64
+func parseMetadump(line, addr string) (KeyInfo, error) {
+	info := KeyInfo{Node: addr}
+	for _, field := range strings.Fields(line) {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "key":
+			key, err := url.PathUnescape(value)
+			if err != nil {
+				return KeyInfo{}, fmt.Errorf("%w: metadump key %q", ErrServerError, value)
+			}
+			info.Key = key
+		case "exp":
+			if exp, err := strconv.ParseInt(value, 10, 64); err == nil && exp > 0 {
+				info.Expiration = time.Unix(exp, 0)
+			}
+		case "size":
+			info.Size, _ = strconv.Atoi(value)
+		}
+	}
+	if info.Key == "" {
+		return KeyInfo{}, fmt.Errorf("%w: metadump line %q", ErrServerError, line)
+	}
+	return info, nil
+}
+
+// parseCachedump parses the line of stats cachedump:
+//
+//	ITEM foo [5 b; 1690000000 s]
+func parseCachedump(line, addr string) (KeyInfo, error) {
+	fields := strings.Fields(strings.NewReplacer("[", "", "]", "", ";", "").Replace(line))
+	if len(fields) != 6 || fields[0] != "ITEM" {
+		return KeyInfo{}, fmt.Errorf("%w: cachedump line %q", ErrServerError, line)
+	}
+	info := KeyInfo{Key: fields[1], Node: addr}
+	info.Size, _ = strconv.Atoi(fields[2])
+	if exp, err := strconv.ParseInt(fields[4], 10, 64); err == nil && exp > 0 {
+		info.Expiration = time.Unix(exp, 0)
+	}
+	return info, nil
+}
+
+// Export writes the items of all nodes found by Keys to w, they are restored by Import, e.g. to pre-warm
+// the staging environment by the snapshot of a small cluster. Every record has the key, the flags, the expiration
+// and the value of the item. The items expired or deleted during the export are skipped. With WithEncryption
+// the values are decrypted, so the export must be protected as the plain data. It returns the number of the items.
+func (c *Client) Export(ctx context.Context, w io.Writer) (n int, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Export", timer, &err)
+
+	bw := bufio.NewWriter(w)
+	if _, err = bw.WriteString(dumpMagic); err != nil {
+		return 0, err
+	}
+
+	it := c.Keys(ctx)
+	defer it.Close()
+
+	batch := make([]KeyInfo, 0, exportBatch)
+	flushBatch := func() error {
+		p := c.Pipeline()
+		for _, key := range batch {
+			p.Get(key.Key)
+		}
+		results, err := p.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		for i, res := range results {
+			if errors.Is(res.Err, ErrCacheMiss) {
+				continue
+			}
+			if res.Err != nil {
+				return fmt.Errorf("%w. Error for key - %s", res.Err, res.Key)
+			}
+			var flags uint32
+			if c.keyProvider == nil {
+				flags = responseFlags(res.Response)
+			}
+			if err = writeDumpRecord(bw, res.Key, flags, batch[i].Expiration, res.Response.Body); err != nil {
+				return err
+			}
+			n++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for it.Next() {
+		if !legalKey(it.Key().Key) {
+			continue
+		}
+		if batch = append(batch, it.Key()); len(batch) == exportBatch {
+			if err = flushBatch(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err = it.Err(); err != nil {
+		return n, err
+	}
+	if len(batch) != 0 {
+		if err = flushBatch(); err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// writeDumpRecord writes the record of Export: the length of the key (2 bytes), the key, the flags (4 bytes),
+// the expiration in unix seconds, zero for none (8 bytes), the length of the value (4 bytes) and the value.
+func writeDumpRecord(w io.Writer, key string, flags uint32, exp time.Time, value []byte) error {
+	var head [18]byte
+	binary.BigEndian.PutUint16(head[:2], uint16(len(key)))
+	binary.BigEndian.PutUint32(head[2:6], flags)
+	if !exp.IsZero() {
+		binary.BigEndian.PutUint64(head[6:14], uint64(exp.Unix()))
+	}
+	binary.BigEndian.PutUint32(head[14:18], uint32(len(value)))
+	for _, p := range [][]byte{head[:2], []byte(key), head[2:], value} {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import stores the items written by Export from r by Set, the items expired since the export are skipped.
+// The expiration of the items is kept. It returns the number of the stored items and stops on the first error.
+func (c *Client) Import(ctx context.Context, r io.Reader) (n int, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Import", timer, &err)
+
+	dr := newDumpReader(r)
+	for {
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
+
+		rec, rErr := dr.next()
+		if errors.Is(rErr, io.EOF) {
+			return n, nil
+		}
+		if rErr != nil {
+			return n, rErr
+		}
+
+		var opts []CallOption
+		if rec.flags != 0 {
+			opts = append(opts, WithFlags(rec.flags))
+		}
+		exp, ok := importExpiration(rec.exp, time.Now())
+		if !ok {
+			continue
+		}
+		if _, err = c.Store(Set, rec.key, exp, rec.value, opts...); err != nil {
+			return n, fmt.Errorf("%w. Error for key - %s", err, rec.key)
+		}
+		n++
+	}
+}
+
+type (
+	// dumpReader reads the records of Export.
+	dumpReader struct {
+		r       *bufio.Reader
+		started bool
+	}
+
+	dumpRecord struct {
+		key   string
+		flags uint32
+		// exp is the expiration in unix seconds, zero for none.
+		exp   int64
+		value []byte
+	}
+)
+
+func newDumpReader(r io.Reader) *dumpReader {
+	return &dumpReader{r: bufio.NewReader(r)}
+}
+
+// next returns the next record, io.EOF is returned at the end.
+func (dr *dumpReader) next() (dumpRecord, error) {
+	if !dr.started {
+		magic := make([]byte, len(dumpMagic))
+		if _, err := io.ReadFull(dr.r, magic); err != nil || string(magic) != dumpMagic {
+			return dumpRecord{}, fmt.Errorf("%w: not an export of memcached", ErrInvalidArguments)
+		}
+		dr.started = true
+	}
+
+	var keyLen [2]byte
+	if _, err := io.ReadFull(dr.r, keyLen[:]); err != nil {
+		// io.EOF is returned only at the boundary of the records.
+		return dumpRecord{}, err
+	}
+	key := make([]byte, binary.BigEndian.Uint16(keyLen[:]))
+	var head [16]byte
+	if _, err := io.ReadFull(dr.r, key); err != nil {
+		return dumpRecord{}, unexpectedEOF(err)
+	}
+	if _, err := io.ReadFull(dr.r, head[:]); err != nil {
+		return dumpRecord{}, unexpectedEOF(err)
+	}
+	// the length comes from the stream, so it is checked and the value is read in chunks like the frames.
+	valueLen := binary.BigEndian.Uint32(head[12:16])
+	if int64(valueLen) > int64(MaxBodyLen) {
+		return dumpRecord{}, fmt.Errorf("%w: value length %d of key %q is too big (max %d)", ErrMalformedFrame, valueLen, key, MaxBodyLen)
+	}
+	value, _, err := readFrame(dr.r, nil, int(valueLen))
+	if err != nil {
+		return dumpRecord{}, unexpectedEOF(err)
+	}
+
+	return dumpRecord{
+		key:   string(key),
+		flags: binary.BigEndian.Uint32(head[:4]),
+		exp:   int64(binary.BigEndian.Uint64(head[4:12])),
+		value: value,
+	}, nil
+}
+
+// importExpiration returns the expiration of Store for the unix time of the expiration of the exported item,
+// the relative one, if it fits in maxRelativeExp. ok is false for the expired item.
+func importExpiration(unix int64, now time.Time) (exp uint32, ok bool) {
+	if unix == 0 {
+		return 0, true
+	}
+	left := unix - now.Unix()
+	switch {
+	case left <= 0:
+		return 0, false
+	case left > maxRelativeExp:
+		return uint32(unix), true
+	default:
+		return uint32(left), true
+	}
+}
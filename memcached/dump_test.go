@@ -0,0 +1,316 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dumpItem struct {
+	flags uint32
+	exp   int64
+	value []byte
+}
+
+// dumpServer is a fake memcached with the binary GET, GETQ, SET and SETQ and the text lru_crawler metadump,
+// or stats cachedump without crawler, and mg unless noMeta. PROXY protocol headers are skipped and counted in proxied.
+type dumpServer struct {
+	addr    string
+	crawler bool
+	noMeta  bool
+	proxied int
+	// undumped - the key, that is skipped by the dumps, like the item expired after GET.
+	undumped string
+	// conns - accepted connections, they are closed by dropConns.
+	conns []net.Conn
+
+	mu    sync.Mutex
+	items map[string]dumpItem
+}
+
+func newDumpServer(t *testing.T, crawler bool, items map[string]dumpItem) *dumpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	srv := &dumpServer{addr: ln.Addr().String(), crawler: crawler, items: items}
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns = append(srv.conns, nc)
+			srv.mu.Unlock()
+			go srv.serve(nc)
+		}
+	}()
+	return srv
+}
+
+// dropConns closes the accepted connections like memcached closes the idle ones.
+func (s *dumpServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, nc := range s.conns {
+		_ = nc.Close()
+	}
+	s.conns = nil
+}
+
+func (s *dumpServer) serve(nc net.Conn) {
+	defer nc.Close()
+	rd := bufio.NewReader(nc)
+	if sig, err := rd.Peek(len(proxyV2Signature) + 4); err == nil && bytes.Equal(sig[:len(proxyV2Signature)], proxyV2Signature) {
+		if _, err = rd.Discard(len(sig) + int(binary.BigEndian.Uint16(sig[len(sig)-2:]))); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.proxied++
+		s.mu.Unlock()
+	}
+	first, err := rd.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] != REQ_MAGIC {
+		s.serveText(nc, rd)
+		return
+	}
+
+	hdr := make([]byte, HDR_LEN)
+	for {
+		req := new(Request)
+		if _, err = req.Receive(rd, hdr); err != nil {
+			return
+		}
+		resp := s.handle(req)
+		if resp == nil {
+			continue
+		}
+		resp.Opcode, resp.Opaque = req.Opcode, req.Opaque
+		if _, err = resp.Transmit(nc); err != nil {
+			return
+		}
+	}
+}
+
+func (s *dumpServer) handle(req *Request) *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Opcode {
+	case GET, GETQ:
+		it, ok := s.items[string(req.Key)]
+		if !ok && req.Opcode == GET {
+			return &Response{Status: KEY_ENOENT}
+		}
+		if !ok {
+			return nil
+		}
+		extras := make([]byte, 4)
+		binary.BigEndian.PutUint32(extras, it.flags)
+		return &Response{Extras: extras, Body: it.value}
+	case SET, SETQ:
+		var exp int64
+		if rel := int64(binary.BigEndian.Uint32(req.Extras[4:8])); rel > 0 {
+			exp = time.Now().Unix() + rel
+		}
+		s.items[string(req.Key)] = dumpItem{flags: binary.BigEndian.Uint32(req.Extras[:4]), exp: exp, value: bytes.Clone(req.Body)}
+		if req.Opcode.IsQuiet() {
+			return nil
+		}
+		return &Response{}
+	case NOOP:
+		return &Response{}
+	}
+	return &Response{Status: UNKNOWN_COMMAND}
+}
+
+func (s *dumpServer) serveText(nc net.Conn, rd *bufio.Reader) {
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return
+		}
+		var out strings.Builder
+		s.mu.Lock()
+		switch cmd := strings.TrimSpace(line); {
+		case cmd == "lru_crawler metadump all" && s.crawler:
+			for key, it := range s.items {
+				if key == s.undumped {
+					continue
+				}
+				exp := it.exp
+				if exp == 0 {
+					exp = -1
+				}
+				fmt.Fprintf(&out, "key=%s exp=%d la=1690000000 cas=1 fetch=no cls=1 size=%d\r\n",
+					strings.NewReplacer(":", "%3A", " ", "%20").Replace(key), exp, len(it.value)+50)
+			}
+			out.WriteString("END\r\n")
+		case cmd == "version":
+			out.WriteString("VERSION 1.6.21\r\n")
+		case cmd == "stats items":
+			out.WriteString("STAT items:1:number 1\r\nSTAT items:1:age 10\r\nSTAT items:5:number 1\r\nEND\r\n")
+		case strings.HasPrefix(cmd, "stats cachedump "):
+			class := strings.Fields(cmd)[2]
+			for key, it := range s.items {
+				if key != s.undumped && (len(it.value) < 10) == (class == "1") {
+					fmt.Fprintf(&out, "ITEM %s [%d b; %d s]\r\n", key, len(it.value), it.exp)
+				}
+			}
+			out.WriteString("END\r\n")
+		case strings.HasPrefix(cmd, "mg ") && !s.noMeta:
+			args := strings.Fields(cmd)[1:]
+			key := args[0]
+			if slices.Contains(args[1:], "b") {
+				decoded, _ := base64.StdEncoding.DecodeString(key)
+				key = string(decoded)
+			}
+			it, ok := s.items[key]
+			if !ok {
+				out.WriteString("EN\r\n")
+				break
+			}
+			ttl := int64(-1)
+			if it.exp > 0 {
+				ttl = it.exp - time.Now().Unix()
+			}
+			fmt.Fprintf(&out, "VA %d t%d f%d c1\r\n%s\r\n", len(it.value), ttl, it.flags, it.value)
+		default:
+			out.WriteString("ERROR\r\n")
+		}
+		s.mu.Unlock()
+		if _, err = nc.Write([]byte(out.String())); err != nil {
+			return
+		}
+	}
+}
+
+func TestClient_Keys(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	crawled := newDumpServer(t, true, map[string]dumpItem{
+		"a:b": {exp: exp, value: []byte("1")},
+		"c":   {value: []byte("2")},
+	})
+	dumped := newDumpServer(t, false, map[string]dumpItem{
+		"small": {value: []byte("3")},
+		"large": {exp: exp, value: []byte("large value")},
+	})
+	empty := newDumpServer(t, true, map[string]dumpItem{})
+
+	c, err := newForTests(crawled.addr, dumped.addr, empty.addr)
+	require.Nil(t, err)
+
+	it := c.Keys(context.Background())
+	defer it.Close()
+	var keys []KeyInfo
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	require.Nil(t, it.Err())
+	slices.SortFunc(keys, func(a, b KeyInfo) int { return strings.Compare(a.Key, b.Key) })
+
+	assert.Equal(t, []KeyInfo{
+		{Key: "a:b", Node: crawled.addr, Expiration: time.Unix(exp, 0), Size: 51},
+		{Key: "c", Node: crawled.addr, Size: 51},
+		{Key: "large", Node: dumped.addr, Expiration: time.Unix(exp, 0), Size: 11},
+		{Key: "small", Node: dumped.addr, Size: 1},
+	}, keys, "Keys: metadump with the encoded keys and cachedump without crawler")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it = c.Keys(ctx)
+	assert.False(t, it.Next())
+	assert.ErrorIs(t, it.Err(), context.Canceled)
+}
+
+func TestClient_ExportImport(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	src := newDumpServer(t, true, map[string]dumpItem{
+		"a":     {flags: 7, exp: exp, value: []byte("1")},
+		"b":     {value: []byte("2")},
+		"b c":   {value: []byte("illegal key")},
+		"empty": {value: []byte{}},
+	})
+	c, err := newForTests(src.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	var buf bytes.Buffer
+	n, err := c.Export(context.Background(), &buf)
+	require.Nil(t, err)
+	assert.Equal(t, 3, n, "Export: the illegal keys should be skipped")
+
+	// the expired item is skipped by Import.
+	require.Nil(t, writeDumpRecord(&buf, "expired", 0, time.Now().Add(-time.Minute), []byte("x")))
+
+	dst := newDumpServer(t, true, map[string]dumpItem{})
+	c2, err := newForTests(dst.addr)
+	require.Nil(t, err)
+	defer c2.CloseAllConns()
+
+	n, err = c2.Import(context.Background(), &buf)
+	require.Nil(t, err)
+	assert.Equal(t, 3, n)
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+	assert.Len(t, dst.items, 3)
+	assert.Equal(t, uint32(7), dst.items["a"].flags)
+	assert.Equal(t, "1", string(dst.items["a"].value))
+	assert.InDelta(t, exp, dst.items["a"].exp, 2, "Import: the expiration should be kept")
+	assert.Equal(t, dumpItem{value: []byte("2")}, dst.items["b"])
+	assert.Empty(t, dst.items["empty"].value)
+
+	_, err = c2.Import(context.Background(), strings.NewReader("not a dump"))
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	// the length of the value is checked before the allocation.
+	huge := bytes.NewBufferString(dumpMagic)
+	require.Nil(t, writeDumpRecord(huge, "huge", 0, time.Time{}, []byte("x")))
+	forged := huge.Bytes()
+	binary.BigEndian.PutUint32(forged[len(forged)-5:], math.MaxUint32)
+	_, err = c2.Import(context.Background(), bytes.NewReader(forged))
+	assert.ErrorIs(t, err, ErrMalformedFrame, "Import: the value longer than MaxBodyLen should be rejected")
+
+	binary.BigEndian.PutUint32(forged[len(forged)-5:], uint32(MaxBodyLen))
+	_, err = c2.Import(context.Background(), bytes.NewReader(forged))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "Import: the truncated value should be an error")
+}
+
+func Test_importExpiration(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	for _, tc := range []struct {
+		name string
+		unix int64
+		exp  uint32
+		ok   bool
+	}{
+		{name: "no expiration", unix: 0, exp: 0, ok: true},
+		{name: "relative", unix: now.Unix() + 60, exp: 60, ok: true},
+		{name: "absolute", unix: now.Unix() + maxRelativeExp + 1, exp: uint32(now.Unix() + maxRelativeExp + 1), ok: true},
+		{name: "expired", unix: now.Unix(), ok: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exp, ok := importExpiration(tc.unix, now)
+			assert.Equal(t, tc.exp, exp)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
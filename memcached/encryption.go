@@ -0,0 +1,167 @@
+package memcached
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+type (
+	// KeyProvider gives the keys of AES-GCM for WithEncryption, the keys are 16, 24 or 32 bytes (AES-128, AES-192 or AES-256).
+	// The id of the key is stored in the flags of the item, so the keys are rotated by the change of the current key:
+	// the new values are encrypted by it, and the old ones are decrypted by their keys, while Key returns them.
+	// KeyProvider must be safe for concurrent use.
+	KeyProvider interface {
+		// CurrentKey returns the key, that encrypts the written values, and its id. The id must not be zero,
+		// as the items with zero flags are not encrypted.
+		CurrentKey() (id uint32, key []byte, err error)
+		// Key returns the key by its id from the flags of the item.
+		Key(id uint32) ([]byte, error)
+	}
+
+	// StaticKeys is KeyProvider with the fixed keys by their ids, Current is the id of the key of the new values.
+	StaticKeys struct {
+		Current uint32
+		Keys    map[uint32][]byte
+	}
+)
+
+var _ KeyProvider = StaticKeys{}
+
+// CurrentKey implements KeyProvider.
+func (s StaticKeys) CurrentKey() (uint32, []byte, error) {
+	key, err := s.Key(s.Current)
+	return s.Current, key, err
+}
+
+// Key implements KeyProvider.
+func (s StaticKeys) Key(id uint32) ([]byte, error) {
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %d", id)
+	}
+	return key, nil
+}
+
+// encryptValue seals body by the current key of the provider, see sealValue.
+func (c *Client) encryptValue(key string, body []byte) (uint32, []byte, error) {
+	id, aead, err := c.currentGCM()
+	if err != nil {
+		return 0, nil, err
+	}
+	sealed, err := sealValue(aead, key, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, sealed, nil
+}
+
+// encryptItems returns the copy of items with the values sealed by the current key and the id of the key.
+// The key is taken once, so all items have the same id in the flags, even if the key is rotated meanwhile.
+func (c *Client) encryptItems(items map[string][]byte) (uint32, map[string][]byte, error) {
+	id, aead, err := c.currentGCM()
+	if err != nil {
+		return 0, nil, err
+	}
+	sealedItems := make(map[string][]byte, len(items))
+	for key, body := range items {
+		if sealedItems[key], err = sealValue(aead, key, body); err != nil {
+			return 0, nil, err
+		}
+	}
+	return id, sealedItems, nil
+}
+
+// currentGCM returns the cipher of the current key of the provider and the id of the key.
+func (c *Client) currentGCM() (uint32, cipher.AEAD, error) {
+	id, secret, err := c.keyProvider.CurrentKey()
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: current key: %s", ErrEncryption, err.Error())
+	}
+	if id == 0 {
+		return 0, nil, fmt.Errorf("%w: key id 0 is reserved for the plain values", ErrEncryption)
+	}
+	aead, err := newGCM(secret)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, aead, nil
+}
+
+// sealValue seals body by aead, the key of the item is the additional data,
+// so the value can't be moved to another key. The sealed value is the nonce followed by the ciphertext.
+func sealValue(aead cipher.AEAD, key string, body []byte) ([]byte, error) {
+	sealed := make([]byte, aead.NonceSize(), aead.NonceSize()+len(body)+aead.Overhead())
+	if _, err := rand.Read(sealed); err != nil {
+		return nil, fmt.Errorf("%w: nonce: %s", ErrEncryption, err.Error())
+	}
+	return aead.Seal(sealed, sealed, body, []byte(key)), nil
+}
+
+// decryptValue opens the value of the item with flags, the values with zero flags are returned as is,
+// so the items written before WithEncryption are read.
+func (c *Client) decryptValue(key string, flags uint32, body []byte) ([]byte, error) {
+	if flags == 0 {
+		return body, nil
+	}
+	secret, err := c.keyProvider.Key(flags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %d: %s", ErrEncryption, flags, err.Error())
+	}
+	aead, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: value of key %s is too short", ErrEncryption, key)
+	}
+
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: value of key %s: %s", ErrEncryption, key, err.Error())
+	}
+	return plain, nil
+}
+
+// decryptResponse replaces the body of the successful response of Get by the decrypted one.
+func (c *Client) decryptResponse(key string, resp *Response) error {
+	if c.keyProvider == nil || resp == nil {
+		return nil
+	}
+	plain, err := c.decryptValue(key, responseFlags(resp), resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = plain
+	return nil
+}
+
+// checkEncryptedAppend returns ErrInvalidArguments for Append with the encryption, as the sealed values can't be appended.
+func (c *Client) checkEncryptedAppend() error {
+	if c.keyProvider != nil {
+		return fmt.Errorf("%w: append of the encrypted values", ErrInvalidArguments)
+	}
+	return nil
+}
+
+func newGCM(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrEncryption, err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrEncryption, err.Error())
+	}
+	return aead, nil
+}
+
+// responseFlags returns the flags of the item from the extras of the response of Get.
+func responseFlags(resp *Response) uint32 {
+	if len(resp.Extras) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(resp.Extras)
+}
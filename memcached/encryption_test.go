@@ -0,0 +1,150 @@
+package memcached
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithEncryption(t *testing.T) {
+	type item struct {
+		extras, body []byte
+	}
+	var (
+		mu    sync.Mutex
+		items = map[string]item{"plain": {extras: make([]byte, 4), body: []byte("old value")}}
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := string(req.Key)
+		it, ok := items[key]
+		switch req.Opcode {
+		case GET, GETQ:
+			if !ok {
+				if req.Opcode.IsQuiet() {
+					return nil
+				}
+				return &Response{Status: KEY_ENOENT}
+			}
+			return &Response{Extras: it.extras, Body: it.body}
+		case SET, SETQ:
+			items[key] = item{extras: bytes.Clone(req.Extras[:4]), body: bytes.Clone(req.Body)}
+			if req.Opcode.IsQuiet() {
+				return nil
+			}
+		case NOOP:
+		default:
+			return &Response{Status: UNKNOWN_COMMAND}
+		}
+		return &Response{}
+	})
+
+	keys := StaticKeys{Current: 1, Keys: map[uint32][]byte{1: bytes.Repeat([]byte{1}, 32)}}
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.keyProvider = keys
+
+	_, err = c.Store(Set, "key", 0, []byte("secret"))
+	require.Nil(t, err)
+	mu.Lock()
+	stored := items["key"]
+	mu.Unlock()
+	assert.Equal(t, uint32(1), responseFlags(&Response{Extras: stored.extras}), "Store: the key id should be in the flags")
+	assert.NotContains(t, string(stored.body), "secret", "Store: the value should be encrypted")
+	assert.Len(t, stored.body, len("secret")+28)
+
+	resp, err := c.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, "secret", string(resp.Body))
+	value, found, err := c.Lookup("plain")
+	require.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "old value", string(value), "Lookup: the items with zero flags should be read as is")
+
+	require.Nil(t, c.MultiStore(Set, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, 0))
+	got, err := c.MultiGet([]string{"a", "b", "plain", "missing"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2"), "plain": []byte("old value")}, got)
+
+	p := c.Pipeline()
+	p.Store(Set, "c", 0, []byte("3"))
+	p.Get("a")
+	p.Append(Append, "a", []byte("x"))
+	results, err := p.Exec(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "1", string(results[1].Response.Body))
+	assert.ErrorIs(t, results[2].Err, ErrInvalidArguments)
+	resp, err = c.Get("c")
+	require.Nil(t, err)
+	assert.Equal(t, "3", string(resp.Body))
+
+	// rotation: the new values are encrypted by the new key, the old ones are read by the old key.
+	keys.Keys[2] = bytes.Repeat([]byte{2}, 16)
+	keys.Current = 2
+	c.keyProvider = keys
+	_, err = c.Store(Set, "new", 0, []byte("rotated"))
+	require.Nil(t, err)
+	got, err = c.MultiGet([]string{"key", "new"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"key": []byte("secret"), "new": []byte("rotated")}, got)
+
+	delete(keys.Keys, 1)
+	_, err = c.Get("key")
+	assert.ErrorIs(t, err, ErrEncryption, "Get: the value of the unknown key id")
+	_, err = c.MultiGet([]string{"key", "new"})
+	assert.ErrorIs(t, err, ErrEncryption)
+
+	// the value can't be moved to another key.
+	mu.Lock()
+	items["moved"] = items["new"]
+	mu.Unlock()
+	_, err = c.Get("moved")
+	assert.ErrorIs(t, err, ErrEncryption)
+
+	_, err = c.Append(Append, "new", []byte("x"))
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	assert.ErrorIs(t, c.MultiAppend(Append, map[string][]byte{"new": []byte("x")}), ErrInvalidArguments)
+	_, err = c.Store(Set, "new", 0, []byte("x"), WithFlags(5))
+	assert.ErrorIs(t, err, ErrInvalidArguments, "Store: the flags are used by the encryption")
+
+	c.keyProvider = StaticKeys{Current: 0, Keys: map[uint32][]byte{0: keys.Keys[2]}}
+	_, err = c.Store(Set, "new", 0, []byte("x"))
+	assert.ErrorIs(t, err, ErrEncryption, "Store: the key id 0 is reserved")
+}
+
+// rotatingKeys is KeyProvider, that rotates the current key on every call of CurrentKey.
+type rotatingKeys struct {
+	StaticKeys
+	calls int
+}
+
+func (r *rotatingKeys) CurrentKey() (uint32, []byte, error) {
+	r.calls++
+	r.Current = uint32(r.calls)
+	return r.StaticKeys.CurrentKey()
+}
+
+func TestClient_encryptItemsRotation(t *testing.T) {
+	keys := &rotatingKeys{StaticKeys: StaticKeys{Keys: map[uint32][]byte{
+		1: bytes.Repeat([]byte{1}, 32),
+		2: bytes.Repeat([]byte{2}, 32),
+		3: bytes.Repeat([]byte{3}, 32),
+	}}}
+	c := &Client{keyProvider: keys}
+
+	id, sealed, err := c.encryptItems(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")})
+	require.Nil(t, err)
+	assert.Equal(t, 1, keys.calls, "encryptItems: the current key should be taken once per batch")
+	for key, want := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		plain, err := c.decryptValue(key, id, sealed[key])
+		require.Nil(t, err, "decryptValue: the item should be sealed by the key of the flags")
+		assert.Equal(t, want, string(plain))
+	}
+}
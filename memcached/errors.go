@@ -1,8 +1,14 @@
 package memcached
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
 )
 
 const libPrefix = "gomemcached"
@@ -55,6 +61,41 @@ var (
 
 	// ErrAuthFail indicates that an authorization attempt was made, but it did not work
 	ErrAuthFail = errors.New("gomemcached: authentication enabled but operation failed")
+
+	// ErrAuthMechanism means that the server doesn't offer the SASL mechanism of the client (PLAIN),
+	// or doesn't support SASL at all. The error is returned with ErrAuthFail.
+	ErrAuthMechanism = errors.New("gomemcached: SASL mechanism is not offered by the server")
+
+	// ErrClientClosed means that the client is closed by Close or Shutdown.
+	ErrClientClosed = errors.New("gomemcached: client is closed")
+
+	// ErrProtocolDesync means that the response doesn't match the request by opcode or opaque,
+	// so the connection stream is out of sync and the connection is closed.
+	ErrProtocolDesync = errors.New("gomemcached: response doesn't match the request")
+
+	// ErrMalformedFrame means that the header of the frame received from the peer has a bad magic or lengths,
+	// e.g. the key and extras don't fit into the total body length or the body is larger than MaxBodyLen (see WithMaxBodyLen).
+	// The connection stream can't be read anymore.
+	ErrMalformedFrame = errors.New("gomemcached: malformed frame")
+
+	// ErrNodeSaturated means that the node has the maximum number of requests in flight set by WithMaxInflightPerNode,
+	// so the request is failed without waiting for the node, or it is shed from the queue set by WithRequestQueue.
+	ErrNodeSaturated = errors.New("gomemcached: too many requests in flight to the node")
+
+	// ErrServerVersion means that the version of memcached is below the minimum set by WithMinServerVersion.
+	ErrServerVersion = errors.New("gomemcached: server version is below the minimum")
+
+	// ErrRetryBudgetExhausted means that the failed request is not retried, as the retry budget is exhausted
+	// (see WithRetryBudget), it wraps the error of the last attempt.
+	ErrRetryBudgetExhausted = errors.New("gomemcached: retry budget exhausted")
+
+	// ErrEncryption means that the value can't be encrypted or decrypted with the keys of WithEncryption,
+	// e.g. the key id of the item is unknown to KeyProvider or the value is corrupted.
+	ErrEncryption = errors.New("gomemcached: value encryption failed")
+
+	// ErrTextProtocolAuth means that the command of the text protocol (e.g. GetWithTTL or Keys) is not sent,
+	// as the client uses SASL authentication (see WithAuthentication), memcached with SASL accepts only the binary protocol.
+	ErrTextProtocolAuth = errors.New("gomemcached: text protocol is not available with SASL authentication")
 )
 
 // resumableError returns true if err is only a protocol-level cache error.
@@ -70,34 +111,155 @@ func resumableError(err error) bool {
 	return false
 }
 
+// statusError is the error of the response with not SUCCESS status, it wraps the sentinel and the response.
+// The message is formatted only when it is needed, so the frequent errors (e.g. cache miss) are cheap.
+type statusError struct {
+	err  error
+	resp *Response
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error() + ". " + e.resp.Error()
+}
+
+func (e *statusError) Unwrap() []error {
+	return []error{e.err, e.resp}
+}
+
+// MultiGetError is the error of MultiGet, if some of the nodes failed. The values of the other nodes are returned
+// by MultiGet, so the keys of Nodes are unknown, unlike the other missing keys, that are the cache misses.
+type MultiGetError struct {
+	// Nodes are the failed nodes in no particular order.
+	Nodes []NodeKeysError
+}
+
+// BatchAbortError is the error of the batch of the quiet requests, that failed to be written to the node.
+// The connection is closed, the requests of Unsent keys are not sent, the requests of the other keys of the batch
+// may be processed by the node.
+type BatchAbortError struct {
+	Addr   string
+	Unsent []string
+	Err    error
+}
+
+func (e *BatchAbortError) Error() string {
+	return fmt.Sprintf("%s. Batch to node - %s is aborted (%d keys are not sent)", e.Err, e.Addr, len(e.Unsent))
+}
+
+func (e *BatchAbortError) Unwrap() error {
+	return e.Err
+}
+
+// NodeKeysError is the error of the node with the keys, that were not got from it.
+type NodeKeysError struct {
+	Addr string
+	Keys []string
+	Err  error
+}
+
+func (e *MultiGetError) Error() string {
+	msgs := make([]string, 0, len(e.Nodes))
+	for _, n := range e.Nodes {
+		msgs = append(msgs, fmt.Sprintf("%s. Error for node - %s (%d keys)", n.Err, n.Addr, len(n.Keys)))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *MultiGetError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Nodes))
+	for _, n := range e.Nodes {
+		errs = append(errs, n.Err)
+	}
+	return errs
+}
+
+// Keys returns the keys of all failed nodes.
+func (e *MultiGetError) Keys() []string {
+	var keys []string
+	for _, n := range e.Nodes {
+		keys = append(keys, n.Keys...)
+	}
+	return keys
+}
+
+// casConflict returns the error of KEY_EEXISTS for the request with CAS.
+func casConflict(resp *Response) error {
+	return &statusError{err: ErrCASConflict, resp: resp}
+}
+
 func wrapMemcachedResp(resp *Response) error {
 	switch resp.Status {
 	case SUCCESS:
 		return nil
 	case KEY_ENOENT:
-		return fmt.Errorf("%w. %w", ErrCacheMiss, resp)
+		return &statusError{err: ErrCacheMiss, resp: resp}
 	case NOT_STORED, KEY_EEXISTS:
-		return fmt.Errorf("%w. %w", ErrNotStored, resp)
+		return &statusError{err: ErrNotStored, resp: resp}
 	case EINVAL, DELTA_BADVAL:
-		return fmt.Errorf("%w. %w", ErrInvalidArguments, resp)
+		return &statusError{err: ErrInvalidArguments, resp: resp}
 	case ENOMEM:
-		return fmt.Errorf("%w. %w", ErrServerError, resp)
+		return &statusError{err: ErrServerError, resp: resp}
 	case TMPFAIL:
-		return fmt.Errorf("%w. %w", ErrServerNotAvailable, resp)
+		return &statusError{err: ErrServerNotAvailable, resp: resp}
 	case UNKNOWN_COMMAND:
-		return fmt.Errorf("%w. %w", ErrUnknownCommand, resp)
+		return &statusError{err: ErrUnknownCommand, resp: resp}
 	case E2BIG:
-		return fmt.Errorf("%w. %w", ErrDataSizeExceedsLimit, resp)
+		return &statusError{err: ErrDataSizeExceedsLimit, resp: resp}
 	default:
-		return fmt.Errorf("%w. %w", ErrServerError, resp)
+		return &statusError{err: ErrServerError, resp: resp}
 	}
 }
 
-func errStatus(e error) Status {
+// StatusOf returns the status of the memcached response wrapped in err,
+// UNKNOWN_STATUS if err is not an error of the response (e.g. network error).
+func StatusOf(err error) Status {
 	status := UNKNOWN_STATUS
 	var res *Response
-	if errors.As(e, &res) {
+	if errors.As(err, &res) {
 		status = res.Status
 	}
 	return status
 }
+
+// IsMiss returns true, if err means that the item is not found.
+func IsMiss(err error) bool {
+	return errors.Is(err, ErrCacheMiss)
+}
+
+// IsTimeout returns true, if err is caused by a timeout of the connection or the context.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsRetryable returns true, if the request may succeed on retry: the timeouts, the network errors,
+// the temporary errors of the server and the unavailable nodes. The errors of the request itself
+// (cache miss, not stored, malformed key and so on) and the closed client are not retryable.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrClientClosed), errors.Is(err, ErrAuthFail):
+		return false
+	case IsTimeout(err):
+		return true
+	case errors.Is(err, ErrServerNotAvailable), errors.Is(err, ErrNoServers), errors.Is(err, ErrProtocolDesync):
+		return true
+	}
+
+	switch StatusOf(err) {
+	case TMPFAIL, ENOMEM:
+		return true
+	case UNKNOWN_STATUS:
+	default:
+		// the other statuses are the answers of the server to the request.
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,47 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    Status
+		miss      bool
+		timeout   bool
+		retryable bool
+	}{
+		{name: "nil", err: nil, status: UNKNOWN_STATUS},
+		{name: "cache miss", err: wrapMemcachedResp(&Response{Status: KEY_ENOENT}), status: KEY_ENOENT, miss: true},
+		{name: "not stored", err: wrapMemcachedResp(&Response{Status: NOT_STORED}), status: NOT_STORED},
+		{name: "tmpfail", err: wrapMemcachedResp(&Response{Status: TMPFAIL}), status: TMPFAIL, retryable: true},
+		{name: "enomem", err: wrapMemcachedResp(&Response{Status: ENOMEM}), status: ENOMEM, retryable: true},
+		{name: "malformed key", err: ErrMalformedKey, status: UNKNOWN_STATUS},
+		{name: "client closed", err: ErrClientClosed, status: UNKNOWN_STATUS},
+		{name: "no servers", err: ErrNoServers, status: UNKNOWN_STATUS, retryable: true},
+		{name: "deadline", err: fmt.Errorf("read: %w", os.ErrDeadlineExceeded), status: UNKNOWN_STATUS, timeout: true, retryable: true},
+		{name: "context deadline", err: context.DeadlineExceeded, status: UNKNOWN_STATUS, timeout: true, retryable: true},
+		{name: "connection reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, status: UNKNOWN_STATUS, retryable: true},
+		{name: "eof", err: io.EOF, status: UNKNOWN_STATUS, retryable: true},
+		{name: "other", err: errors.New("other"), status: UNKNOWN_STATUS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.status, StatusOf(tt.err), "StatusOf")
+			assert.Equal(t, tt.miss, IsMiss(tt.err), "IsMiss")
+			assert.Equal(t, tt.timeout, IsTimeout(tt.err), "IsTimeout")
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err), "IsRetryable")
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package memcached
+
+import (
+	"math/rand"
+	"net"
+	"slices"
+	"time"
+)
+
+type (
+	// FaultInjector is called before every request to the node (once per node for Multi*, Pipeline and FlushAll),
+	// it may sleep to add latency, and the returned error is returned instead of sending the request.
+	// It is used to test the fallback paths of the application without touching the servers, see WithFaultInjector.
+	FaultInjector func(node net.Addr, opcode OpCode) error
+
+	// FaultRule describes the fault of NewFaultInjector.
+	FaultRule struct {
+		// Opcodes are the commands, that get the fault, all commands if empty.
+		// Multi* methods are sent by quiet commands (GETQ, SETQ, DELETEQ and so on).
+		Opcodes []OpCode
+		// Percent is a share of the matched requests (from 0 to 100), that get the fault.
+		Percent float64
+		// Latency is added to the request.
+		Latency time.Duration
+		// Status is returned as the response of the server, if it is not SUCCESS.
+		Status Status
+		// Err is returned instead of sending the request, it takes precedence over Status.
+		Err error
+	}
+)
+
+// NewFaultInjector returns FaultInjector, that applies the rules in order.
+// Every matched rule adds its latency, the first rule with an error or a status fails the request.
+func NewFaultInjector(rules ...FaultRule) FaultInjector {
+	rules = slices.Clone(rules)
+	return func(_ net.Addr, opcode OpCode) error {
+		for _, rule := range rules {
+			if len(rule.Opcodes) != 0 && !slices.Contains(rule.Opcodes, opcode) {
+				continue
+			}
+			if rand.Float64()*100 >= rule.Percent {
+				continue
+			}
+
+			if rule.Latency > 0 {
+				time.Sleep(rule.Latency)
+			}
+			if rule.Err != nil {
+				return rule.Err
+			}
+			if rule.Status != SUCCESS {
+				return wrapMemcachedResp(&Response{Opcode: opcode, Status: rule.Status})
+			}
+		}
+		return nil
+	}
+}
+
+// injectFault runs the fault injector of the client, if it is set.
+func (c *Client) injectFault(node any, opcode OpCode) error {
+	if c.faultInjector == nil {
+		return nil
+	}
+	addr, _ := node.(net.Addr)
+	return c.faultInjector(addr, opcode)
+}
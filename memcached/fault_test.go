@@ -0,0 +1,88 @@
+package memcached
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFaultInjector(t *testing.T) {
+	errFault := errors.New("fault")
+	tests := []struct {
+		name    string
+		rules   []FaultRule
+		opcode  OpCode
+		wantErr error
+		latency time.Duration
+	}{
+		{name: "No rules", opcode: GET},
+		{name: "Zero percent", rules: []FaultRule{{Percent: 0, Err: errFault}}, opcode: GET},
+		{name: "Error", rules: []FaultRule{{Percent: 100, Err: errFault}}, opcode: GET, wantErr: errFault},
+		{name: "Status", rules: []FaultRule{{Percent: 100, Status: TMPFAIL}}, opcode: GET, wantErr: ErrServerNotAvailable},
+		{
+			name:   "Other opcode",
+			rules:  []FaultRule{{Opcodes: []OpCode{SET}, Percent: 100, Err: errFault}},
+			opcode: GET,
+		},
+		{
+			name:    "Matched opcode",
+			rules:   []FaultRule{{Opcodes: []OpCode{SET, GET}, Percent: 100, Err: errFault}},
+			opcode:  GET,
+			wantErr: errFault,
+		},
+		{
+			name:    "Latency of all matched rules",
+			rules:   []FaultRule{{Percent: 100, Latency: 20 * time.Millisecond}, {Percent: 100, Latency: 20 * time.Millisecond}},
+			opcode:  GET,
+			latency: 40 * time.Millisecond,
+		},
+		{
+			name:    "First failed rule",
+			rules:   []FaultRule{{Percent: 100, Err: errFault}, {Percent: 100, Latency: time.Second}},
+			opcode:  GET,
+			wantErr: errFault,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timer := time.Now()
+			err := NewFaultInjector(tt.rules...)(nil, tt.opcode)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.GreaterOrEqual(t, time.Since(timer), tt.latency)
+			assert.Less(t, time.Since(timer), tt.latency+500*time.Millisecond)
+		})
+	}
+}
+
+func TestClient_FaultInjector(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response { return &Response{} })
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	c.faultInjector = NewFaultInjector(
+		FaultRule{Opcodes: []OpCode{GET, GETQ}, Percent: 100, Status: KEY_ENOENT},
+		FaultRule{Opcodes: []OpCode{SETQ, DELETE}, Percent: 100, Status: TMPFAIL},
+	)
+
+	resp, err := c.Get("key")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	require.NotNil(t, resp, "Get: injected status should be returned as the response")
+	assert.Equal(t, KEY_ENOENT, resp.Status)
+
+	got, err := c.MultiGet([]string{"key1", "key2"})
+	assert.Nil(t, err, "MultiGet: injected ENOENT should not be an error")
+	assert.Empty(t, got)
+
+	err = c.MultiStore(Set, map[string][]byte{"key1": nil, "key2": nil}, 0)
+	assert.ErrorIs(t, err, ErrServerNotAvailable)
+	_, err = c.Delete("key")
+	assert.ErrorIs(t, err, ErrServerNotAvailable)
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	assert.Nil(t, err, "Store: request without the fault should be sent to the server")
+}
@@ -0,0 +1,107 @@
+package memcached
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+type (
+	// FlushOption is an option of FlushAll.
+	FlushOption func(*FlushOptions)
+
+	// FlushOptions are the resolved options of FlushAll. They are exported for the other implementations
+	// of Memcached (e.g. memcachedtest.Mock), see ResolveFlushOptions.
+	FlushOptions struct {
+		// Stagger is the delay between the flushes of the nodes.
+		Stagger time.Duration
+		// Nodes are the addresses of the nodes to flush, all nodes if empty.
+		Nodes []string
+		// DryRun receives the plan of the flush, if it is set, and nothing is flushed.
+		DryRun *[]FlushStep
+	}
+
+	// FlushStep is the flush of one node by FlushAll.
+	FlushStep struct {
+		// Addr is the address of the node.
+		Addr string
+		// Exp is the expiration of FLUSH sent to the node, the items expire after Exp seconds (or at the unix time).
+		Exp uint32
+	}
+)
+
+// ResolveFlushOptions applies opts in order.
+func ResolveFlushOptions(opts ...FlushOption) FlushOptions {
+	var fo FlushOptions
+	for _, opt := range opts {
+		opt(&fo)
+	}
+	return fo
+}
+
+// WithFlushStagger is sets the delay between the flushes of the nodes, so the shards don't lose the items at once.
+// The nodes are flushed in order of addresses, the i-th node gets the expiration exp + i*offset.
+// The offset is rounded down to seconds, as the expiration of memcached.
+func WithFlushStagger(offset time.Duration) FlushOption {
+	return func(o *FlushOptions) {
+		o.Stagger = offset
+	}
+}
+
+// WithFlushNodes is sets the nodes to flush by their addresses (see Client.Nodes), all nodes by default.
+// FlushAll fails without flushing anything, if any of the addresses is not a node of the hash ring.
+func WithFlushNodes(addrs ...string) FlushOption {
+	return func(o *FlushOptions) {
+		o.Nodes = addrs
+	}
+}
+
+// WithFlushDryRun is turn on the dry run of FlushAll, nothing is flushed and the plan is written to steps.
+func WithFlushDryRun(steps *[]FlushStep) FlushOption {
+	return func(o *FlushOptions) {
+		o.DryRun = steps
+	}
+}
+
+// FlushNode is a deletes all items of one node by its address (see Client.Nodes), e.g. after the corruption
+// of the data on one pod. It is FlushAll with WithFlushNodes(addr).
+func (c *Client) FlushNode(addr string, exp uint32) error {
+	return c.FlushAll(exp, WithFlushNodes(addr))
+}
+
+type flushTarget struct {
+	node any
+	FlushStep
+}
+
+// flushPlan returns the nodes to flush with their expirations in order of addresses.
+func (c *Client) flushPlan(exp uint32, fo *FlushOptions) ([]flushTarget, error) {
+	nodes := c.hr.GetAllNodes()
+	targets := make([]flushTarget, 0, len(nodes))
+	for _, node := range nodes {
+		targets = append(targets, flushTarget{node: node, FlushStep: FlushStep{Addr: utils.Repr(node)}})
+	}
+	slices.SortFunc(targets, func(a, b flushTarget) int {
+		return strings.Compare(a.Addr, b.Addr)
+	})
+
+	if len(fo.Nodes) != 0 {
+		for _, addr := range fo.Nodes {
+			if !slices.ContainsFunc(targets, func(t flushTarget) bool { return t.Addr == addr }) {
+				return nil, fmt.Errorf("%w: %s is not a node of the hash ring", ErrInvalidAddr, addr)
+			}
+		}
+		targets = slices.DeleteFunc(targets, func(t flushTarget) bool {
+			return !slices.Contains(fo.Nodes, t.Addr)
+		})
+	}
+
+	offset := uint32(fo.Stagger / time.Second)
+	for i := range targets {
+		targets[i].Exp = exp + uint32(i)*offset
+	}
+	return targets, nil
+}
@@ -0,0 +1,66 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_FlushAllOptions(t *testing.T) {
+	var (
+		mu sync.Mutex
+		// flushes - expiration of FLUSH by the name of the server.
+		flushes = make(map[string]uint32)
+	)
+	newServer := func(name string) *fakeServer {
+		return newFakeServer(t, func(req *Request) *Response {
+			if req.Opcode == FLUSH {
+				mu.Lock()
+				flushes[name] = binary.BigEndian.Uint32(req.Extras)
+				mu.Unlock()
+			}
+			return &Response{}
+		})
+	}
+	srv1, srv2 := newServer("srv1"), newServer("srv2")
+
+	c, err := newForTests(srv1.addr, srv2.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	nodes := c.Nodes()
+	// rename the servers by their addresses.
+	names := map[string]string{"srv1": srv1.addr, "srv2": srv2.addr}
+	flushed := func() map[string]uint32 {
+		mu.Lock()
+		defer mu.Unlock()
+		res := make(map[string]uint32, len(flushes))
+		for name, exp := range flushes {
+			res[names[name]] = exp
+		}
+		clear(flushes)
+		return res
+	}
+
+	var plan []FlushStep
+	require.Nil(t, c.FlushAll(10, WithFlushStagger(time.Minute), WithFlushDryRun(&plan)))
+	assert.Equal(t, []FlushStep{{Addr: nodes[0], Exp: 10}, {Addr: nodes[1], Exp: 70}}, plan)
+	assert.Empty(t, flushed(), "FlushAll: dry run should not flush")
+
+	require.Nil(t, c.FlushAll(10, WithFlushStagger(time.Minute)))
+	assert.Equal(t, map[string]uint32{nodes[0]: 10, nodes[1]: 70}, flushed())
+
+	require.Nil(t, c.FlushAll(0, WithFlushNodes(nodes[1])))
+	assert.Equal(t, map[string]uint32{nodes[1]: 0}, flushed())
+
+	require.Nil(t, c.FlushNode(nodes[0], 5))
+	assert.Equal(t, map[string]uint32{nodes[0]: 5}, flushed())
+	assert.ErrorIs(t, c.FlushNode("127.0.0.1:1", 0), ErrInvalidAddr, "FlushNode: unknown node")
+
+	err = c.FlushAll(0, WithFlushNodes(nodes[0], "127.0.0.1:1"))
+	assert.ErrorIs(t, err, ErrInvalidAddr, "FlushAll: unknown node")
+	assert.Empty(t, flushed(), "FlushAll: nothing should be flushed with unknown node")
+}
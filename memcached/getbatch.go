@@ -0,0 +1,221 @@
+package memcached
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+type (
+	// getBatcher collects the single Gets of a node until the window is over or the batch is full.
+	getBatcher struct {
+		pending []*pendingGet
+		// gen - generation of the batch, the timer of the sent batch doesn't send the next one.
+		gen uint64
+	}
+
+	// pendingGet is a Get waiting for the batch.
+	pendingGet struct {
+		key     string
+		timeout time.Duration
+		done    chan getResult
+	}
+
+	getResult struct {
+		resp *Response
+		err  error
+	}
+)
+
+// batchedGet gets the key from the node by the GETQ batch with the other Gets of the node within c.getBatchWindow
+// (see WithGetBatching). The response is the same as the response of GET.
+func (c *Client) batchedGet(node any, key string, timeout time.Duration) (*Response, error) {
+	pg := &pendingGet{
+		key:     key,
+		timeout: timeout,
+		done:    make(chan getResult, 1),
+	}
+	c.enqueueGet(node, pg)
+
+	res := <-pg.done
+	return res.resp, res.err
+}
+
+// enqueueGet adds pg to the batch of the node. The first Get of the batch starts the window,
+// the full batch is sent at once.
+func (c *Client) enqueueGet(node any, pg *pendingGet) {
+	addr := utils.Repr(node)
+
+	c.gbmu.Lock()
+	defer c.gbmu.Unlock()
+
+	if c.getBatchers == nil {
+		c.getBatchers = make(map[string]*getBatcher)
+	}
+	b, ok := c.getBatchers[addr]
+	if !ok {
+		b = &getBatcher{}
+		c.getBatchers[addr] = b
+	}
+
+	b.pending = append(b.pending, pg)
+	switch {
+	case len(b.pending) >= c.getBatchKeys:
+		gets := b.take()
+		c.goBatch(func() { c.sendGets(node, gets) })
+	case len(b.pending) == 1:
+		gen := b.gen
+		time.AfterFunc(c.getBatchWindow, func() {
+			c.gbmu.Lock()
+			if b.gen != gen {
+				// the batch is already sent, as it was full
+				c.gbmu.Unlock()
+				return
+			}
+			gets := b.take()
+			c.gbmu.Unlock()
+			c.sendGets(node, gets)
+		})
+	}
+}
+
+// removeGetBatcher drops the batcher of the node of addr, its pending Gets are sent by the timer of the window as usual.
+func (c *Client) removeGetBatcher(addr net.Addr) {
+	c.gbmu.Lock()
+	defer c.gbmu.Unlock()
+
+	delete(c.getBatchers, addr.String())
+}
+
+// take returns the pending Gets and starts the next batch, c.gbmu must be held.
+func (b *getBatcher) take() []*pendingGet {
+	gets := b.pending
+	b.pending = nil
+	b.gen++
+	return gets
+}
+
+// sendGets sends the keys of gets to the node by GETQ with the closing NOOP and passes the responses to gets.
+// The misses are the keys without responses, they get KEY_ENOENT like GET.
+func (c *Client) sendGets(node any, gets []*pendingGet) {
+	var (
+		keys    []string
+		waiters = make(map[string][]*pendingGet, len(gets))
+		timeout time.Duration
+	)
+	for i, pg := range gets {
+		if _, ok := waiters[pg.key]; !ok {
+			keys = append(keys, pg.key)
+		}
+		waiters[pg.key] = append(waiters[pg.key], pg)
+		// the batch waits for the longest timeout of its Gets, zero is the timeout of the client
+		if i == 0 || timeout > 0 && (pg.timeout <= 0 || pg.timeout > timeout) {
+			timeout = pg.timeout
+		}
+	}
+
+	// reply passes the response to the Gets of the key, each of them gets its own copy like from GET.
+	reply := func(key string, resp *Response, err error) {
+		for i, pg := range waiters[key] {
+			if i > 0 && resp != nil {
+				cp := *resp
+				cp.Extras, cp.Body = bytes.Clone(resp.Extras), bytes.Clone(resp.Body)
+				resp = &cp
+				if err != nil && resp.Status != SUCCESS {
+					err = wrapMemcachedResp(resp)
+				}
+			}
+			pg.done <- getResult{resp: resp, err: err}
+		}
+		delete(waiters, key)
+	}
+	defer func() {
+		// the keys without responses are missed
+		for key := range waiters {
+			resp := &Response{Opcode: GET, Status: KEY_ENOENT}
+			reply(key, resp, wrapMemcachedResp(resp))
+		}
+	}()
+	failAll := func(err error) {
+		for key := range waiters {
+			reply(key, nil, err)
+		}
+	}
+
+	if err := c.injectFault(node, GETQ); err != nil {
+		if !errors.Is(err, ErrCacheMiss) {
+			failAll(err)
+		}
+		return
+	}
+
+	release, err := c.acquireNode(node, timeout)
+	if err != nil {
+		failAll(err)
+		return
+	}
+	defer release()
+
+	cn, err := c.getConnForNode(node)
+	if err != nil {
+		failAll(err)
+		return
+	}
+	var cnErr error
+	defer cn.condRelease(&cnErr)
+	defer cn.setTimeout(timeout)()
+
+	b := cn.newBatch(GETQ, keys)
+	cnErr = cn.writeBatch(b, func(i int) *Request {
+		req := &Request{
+			Opcode: GETQ,
+			Key:    []byte(keys[i]),
+		}
+		req.prepareExtras(0, 0, 0)
+		return req
+	})
+	if cnErr != nil {
+		failAll(cnErr)
+		return
+	}
+
+	for {
+		var resp *Response
+		resp, _, cnErr = cn.receivePooled()
+		if isFatal(cnErr) {
+			cn.healthy = false
+			failAll(cnErr)
+			return
+		}
+
+		key, done, mErr := b.match(resp)
+		if mErr != nil {
+			cnErr = mErr
+			cn.healthy = false
+			failAll(mErr)
+			return
+		}
+		if done {
+			releaseResponse(resp)
+			return
+		}
+
+		// the buffer of resp is reused, so the response is copied for the waiters
+		got := &Response{
+			Opcode: GET,
+			Status: resp.Status,
+			Cas:    resp.Cas,
+			Extras: bytes.Clone(resp.Extras),
+			Body:   bytes.Clone(resp.Body),
+		}
+		releaseResponse(resp)
+		if got.Status != SUCCESS {
+			reply(key, got, wrapMemcachedResp(got))
+			continue
+		}
+		reply(key, got, c.decryptResponse(key, got))
+	}
+}
@@ -0,0 +1,86 @@
+package memcached
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+func TestClient_GetBatching(t *testing.T) {
+	var gets, batches atomic.Int32
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case GET:
+			gets.Add(1)
+		case GETQ:
+			if strings.HasPrefix(string(req.Key), "hit") {
+				return &Response{Body: append([]byte("value-"), req.Key...)}
+			}
+			return nil
+		case NOOP:
+			batches.Add(1)
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.getBatchWindow, c.getBatchKeys = 50*time.Millisecond, DefaultGetBatchKeys
+
+	keys := []string{"hit1", "hit2", "miss1", "hit1"}
+	var (
+		wg     sync.WaitGroup
+		values = make([][]byte, len(keys))
+		errs   = make([]error, len(keys))
+	)
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			var resp *Response
+			if resp, errs[i] = c.Get(key); resp != nil {
+				values[i] = resp.Body
+			}
+		}(i, key)
+	}
+	wg.Wait()
+
+	assert.Equal(t, []byte("value-hit1"), values[0])
+	assert.Nil(t, errs[0])
+	assert.Equal(t, []byte("value-hit2"), values[1])
+	assert.ErrorIs(t, errs[2], ErrCacheMiss, "GetBatching: the key without response should be missed like by GET")
+	assert.Equal(t, []byte("value-hit1"), values[3], "GetBatching: the Gets of the same key should get the value")
+	assert.Zero(t, gets.Load(), "GetBatching: the single Gets should not be sent by GET")
+	assert.EqualValues(t, 1, batches.Load(), "GetBatching: the Gets within the window should be sent by one batch")
+
+	// the full batch is sent without waiting for the window
+	c.getBatchWindow, c.getBatchKeys = time.Hour, 2
+	for _, key := range []string{"hit1", "hit2"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			_, err := c.Get(key)
+			assert.Nil(t, err)
+		}(key)
+	}
+	wg.Wait()
+	assert.EqualValues(t, 2, batches.Load())
+
+	addr, err := utils.AddrRepr(srv.addr)
+	require.Nil(t, err)
+	c.gbmu.Lock()
+	require.Len(t, c.getBatchers, 1)
+	c.gbmu.Unlock()
+	c.removeFromFreeConns(addr)
+	c.gbmu.Lock()
+	assert.Empty(t, c.getBatchers, "removeFromFreeConns: the batcher of the removed node should be dropped")
+	c.gbmu.Unlock()
+}
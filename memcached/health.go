@@ -0,0 +1,146 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"golang.org/x/exp/maps"
+
+	"github.com/aliexpressru/gomemcached/pool"
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+// NodeStatus is a status of the node in HealthReport.
+type NodeStatus string
+
+const (
+	// NodeAlive is the node, that answered NOOP.
+	NodeAlive NodeStatus = "alive"
+	// NodeDead is the node, that failed the health check of NodeProvider or didn't answer NOOP.
+	NodeDead NodeStatus = "dead"
+	// NodeAuthFailed is the node, that rejected the authentication, including the nodes found dead
+	// by NodeProvider for this reason.
+	NodeAuthFailed NodeStatus = "auth_failed"
+)
+
+type (
+	// HealthReport is a state of the cluster from the point of view of the client.
+	HealthReport struct {
+		// Healthy is true, if at least one node is alive.
+		Healthy bool
+		// Nodes are sorted by address, the nodes of the hash ring go with the dead nodes removed from it.
+		Nodes []NodeHealth
+		// DiscoveryErr is the error of the last discovery of nodes by NodeProvider, nil if it succeeded.
+		DiscoveryErr error
+		// DiscoveryTime is the time of the last discovery of nodes, zero if it hasn't run yet.
+		DiscoveryTime time.Time
+	}
+
+	// NodeHealth is a state of one node in HealthReport.
+	NodeHealth struct {
+		Addr   string
+		Status NodeStatus
+		// Err is the reason, why the node isn't alive, for the nodes found dead by NodeProvider it is the error
+		// of their health check (e.g. ErrAuthFail for the rejected credentials), nil if it is unknown.
+		Err error
+		// Pool is the statistics of the connection pool of the node.
+		Pool pool.Stats
+		// Saturation is a share of the connections in use from the maximum of the pool (from 0 to 1).
+		Saturation float64
+	}
+)
+
+// Health checks the nodes of the hash ring by NOOP in parallel and returns the report,
+// that is suitable for readiness probes and health handlers.
+// The nodes, that didn't answer before ctx is done, are reported dead with the error of ctx.
+func (c *Client) Health(ctx context.Context) HealthReport {
+	ringNodes := c.hr.GetAllNodes()
+	results := make(chan NodeHealth, len(ringNodes))
+	for _, node := range ringNodes {
+		go func(node any) {
+			results <- c.nodeHealth(node)
+		}(node)
+	}
+
+	checked := make(map[string]NodeHealth, len(ringNodes))
+wait:
+	for range ringNodes {
+		select {
+		case nh := <-results:
+			checked[nh.Addr] = nh
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	for _, node := range ringNodes {
+		addr := utils.Repr(node)
+		if _, ok := checked[addr]; !ok {
+			checked[addr] = NodeHealth{Addr: addr, Status: NodeDead, Err: ctx.Err()}
+		}
+	}
+	for node := range c.safeGetDeadNodes() {
+		if _, ok := checked[node]; !ok {
+			nh := NodeHealth{Addr: node, Status: NodeDead, Err: c.safeGetDeadReason(node)}
+			if errors.Is(nh.Err, ErrAuthFail) {
+				nh.Status = NodeAuthFailed
+			}
+			checked[node] = nh
+		}
+	}
+
+	addrs := maps.Keys(checked)
+	slices.Sort(addrs)
+
+	report := HealthReport{Nodes: make([]NodeHealth, 0, len(addrs))}
+	report.DiscoveryTime, report.DiscoveryErr = c.safeGetDiscoveryResult()
+	for _, addr := range addrs {
+		nh := checked[addr]
+		nh.Pool, nh.Saturation = c.poolSaturation(addr)
+		report.Healthy = report.Healthy || nh.Status == NodeAlive
+		report.Nodes = append(report.Nodes, nh)
+	}
+
+	return report
+}
+
+func (c *Client) nodeHealth(node any) NodeHealth {
+	nh := NodeHealth{Addr: utils.Repr(node), Status: NodeAlive}
+
+	_, err := c.sendToNode(node, &Request{Opcode: NOOP}, 0)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrAuthFail):
+		nh.Status, nh.Err = NodeAuthFailed, err
+	default:
+		nh.Status, nh.Err = NodeDead, err
+	}
+	return nh
+}
+
+// poolSaturation returns the statistics of the pool of the node and the share of the connections in use.
+func (c *Client) poolSaturation(addr string) (pool.Stats, float64) {
+	c.fmu.RLock()
+	defer c.fmu.RUnlock()
+
+	p, ok := c.freeConns[addr]
+	if !ok {
+		return pool.Stats{}, 0
+	}
+	stats := p.Stats()
+	return stats, float64(stats.InUse) / float64(c.getMaxIdleConns())
+}
+
+func (c *Client) safeSetDiscoveryResult(err error) {
+	c.dsmu.Lock()
+	defer c.dsmu.Unlock()
+	c.discoveryErr, c.discoveryTime = err, time.Now()
+}
+
+func (c *Client) safeGetDiscoveryResult() (time.Time, error) {
+	c.dsmu.Lock()
+	defer c.dsmu.Unlock()
+	return c.discoveryTime, c.discoveryErr
+}
@@ -0,0 +1,96 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Health(t *testing.T) {
+	alive := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == SASL_LIST_MECHS {
+			return &Response{Body: []byte(SaslMechanism)}
+		}
+		return &Response{}
+	})
+	authFailed := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case SASL_LIST_MECHS:
+			return &Response{Body: []byte(SaslMechanism)}
+		case SASL_AUTH:
+			return &Response{Status: AUTHFAIL}
+		}
+		return &Response{}
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	unreachable := ln.Addr().String()
+	require.Nil(t, ln.Close())
+	const deadNode = "127.0.0.3:11211"
+
+	c, err := newForTests(alive.addr, authFailed.addr, unreachable)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.authEnable, c.authData = true, []byte("\x00user\x00pass")
+	c.deadNodes = map[string]struct{}{deadNode: {}}
+	discoveryErr := errors.New("lookup error")
+	c.safeSetDiscoveryResult(discoveryErr)
+
+	report := c.Health(context.Background())
+	assert.True(t, report.Healthy)
+	assert.ErrorIs(t, report.DiscoveryErr, discoveryErr)
+	assert.False(t, report.DiscoveryTime.IsZero())
+
+	statuses := make(map[string]NodeStatus, len(report.Nodes))
+	for _, nh := range report.Nodes {
+		statuses[nh.Addr] = nh.Status
+		if nh.Status == NodeAlive {
+			assert.Nil(t, nh.Err, "Err: alive node %s", nh.Addr)
+		} else if nh.Addr != deadNode {
+			assert.Error(t, nh.Err, "Err: %s node %s", nh.Status, nh.Addr)
+		}
+	}
+	assert.Equal(t, map[string]NodeStatus{
+		alive.addr:      NodeAlive,
+		authFailed.addr: NodeAuthFailed,
+		unreachable:     NodeDead,
+		deadNode:        NodeDead,
+	}, statuses)
+	assert.True(t, slices.IsSortedFunc(report.Nodes, func(a, b NodeHealth) int {
+		return strings.Compare(a.Addr, b.Addr)
+	}), "Nodes: should be sorted by address")
+	for _, nh := range report.Nodes {
+		if nh.Addr == alive.addr {
+			assert.Equal(t, 1, nh.Pool.Idle)
+			assert.Zero(t, nh.Saturation)
+		}
+	}
+}
+
+func TestClient_HealthContextDone(t *testing.T) {
+	// the server doesn't answer, so the check waits the read timeout.
+	silent := newFakeServer(t, func(req *Request) *Response { return nil })
+
+	c, err := newForTests(silent.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.timeout = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	timer := time.Now()
+	report := c.Health(ctx)
+	assert.Less(t, time.Since(timer), c.timeout, "Health: should return, when ctx is done")
+	assert.False(t, report.Healthy)
+	require.Len(t, report.Nodes, 1)
+	assert.Equal(t, NodeDead, report.Nodes[0].Status)
+	assert.ErrorIs(t, report.Nodes[0].Err, context.DeadlineExceeded)
+}
@@ -0,0 +1,87 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RegisterKeepalive is touches the key every interval with the expiration ttl, while the client is not closed
+// or the key is not unregistered by UnregisterKeepalive, so the long-lived keys (e.g. sessions and locks) don't expire
+// while the process is alive. The first Touch is sent after interval, so the key must be stored before.
+// The registration of the registered key replaces its interval and ttl.
+// The failed touches are logged, the missing item is not stored again, as its value is unknown to the client.
+func (c *Client) RegisterKeepalive(key string, interval time.Duration, ttl uint32) error {
+	if !legalKey(key) {
+		return ErrMalformedKey
+	}
+	if interval <= 0 {
+		return fmt.Errorf("%w: keepalive interval %s", ErrInvalidArguments, interval)
+	}
+
+	c.keepalivesMu.Lock()
+	defer c.keepalivesMu.Unlock()
+	if c.closed.Load() {
+		return ErrClientClosed
+	}
+
+	if cancel, ok := c.keepalives[key]; ok {
+		cancel()
+	}
+	if c.keepalives == nil {
+		c.keepalives = make(map[string]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.keepalives[key] = cancel
+
+	c.bgWG.Add(1)
+	go func() {
+		defer c.bgWG.Done()
+		c.keepalive(ctx, key, interval, ttl)
+	}()
+	return nil
+}
+
+// UnregisterKeepalive is stops the touches of the key registered by RegisterKeepalive,
+// returns false, if the key is not registered.
+func (c *Client) UnregisterKeepalive(key string) bool {
+	c.keepalivesMu.Lock()
+	defer c.keepalivesMu.Unlock()
+
+	cancel, ok := c.keepalives[key]
+	if ok {
+		cancel()
+		delete(c.keepalives, key)
+	}
+	return ok
+}
+
+// Keepalives returns the number of the keys registered by RegisterKeepalive.
+func (c *Client) Keepalives() int {
+	c.keepalivesMu.Lock()
+	defer c.keepalivesMu.Unlock()
+	return len(c.keepalives)
+}
+
+func (c *Client) keepalive(ctx context.Context, key string, interval time.Duration, ttl uint32) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, err := c.Touch(key, ttl)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrCacheMiss):
+			c.getLogger().Warnf("%s: Keepalive of key %s, the item is missing", libPrefix, key)
+		default:
+			c.getLogger().Warnf("%s: Keepalive of key %s error - %s", libPrefix, key, err.Error())
+		}
+	}
+}
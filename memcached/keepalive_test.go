@@ -0,0 +1,73 @@
+package memcached
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RegisterKeepalive(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		touches = make(map[string]int)
+		ttls    = make(map[string]uint32)
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode != TOUCH {
+			return &Response{Status: UNKNOWN_COMMAND}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		touches[string(req.Key)]++
+		ttls[string(req.Key)] = binary.BigEndian.Uint32(req.Extras)
+		if string(req.Key) == "missing" {
+			return &Response{Status: KEY_ENOENT}
+		}
+		return &Response{}
+	})
+	touched := func(key string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return touches[key]
+	}
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	c.ctx, c.cancel = context.WithCancel(c.ctx)
+
+	assert.ErrorIs(t, c.RegisterKeepalive("bad key", time.Second, 60), ErrMalformedKey)
+	assert.ErrorIs(t, c.RegisterKeepalive("session", 0, 60), ErrInvalidArguments)
+
+	require.Nil(t, c.RegisterKeepalive("session", 10*time.Millisecond, 60))
+	require.Nil(t, c.RegisterKeepalive("lock", 10*time.Millisecond, 30))
+	require.Nil(t, c.RegisterKeepalive("missing", 10*time.Millisecond, 30))
+	require.Nil(t, c.RegisterKeepalive("lock", 10*time.Millisecond, 5), "RegisterKeepalive: should replace the registration")
+	assert.Equal(t, 3, c.Keepalives())
+
+	assert.Eventually(t, func() bool {
+		return touched("session") >= 2 && touched("lock") >= 2 && touched("missing") >= 2
+	}, time.Second, 5*time.Millisecond, "the keys should be touched every interval, the misses don't stop the touches")
+	mu.Lock()
+	assert.Equal(t, uint32(60), ttls["session"])
+	assert.Equal(t, uint32(5), ttls["lock"], "the replaced registration should be stopped")
+	mu.Unlock()
+
+	assert.True(t, c.UnregisterKeepalive("session"))
+	assert.False(t, c.UnregisterKeepalive("session"))
+	assert.Equal(t, 2, c.Keepalives())
+	time.Sleep(20 * time.Millisecond)
+	stopped := touched("session")
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, touched("session"), "the unregistered key should not be touched")
+
+	require.Nil(t, c.Close())
+	lock := touched("lock")
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, lock, touched("lock"), "Close should stop the keepalives")
+	assert.ErrorIs(t, c.RegisterKeepalive("session", time.Second, 60), ErrClientClosed)
+}
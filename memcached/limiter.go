@@ -0,0 +1,181 @@
+package memcached
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+// ShedPolicy defines, which request is rejected, when the request queue of the node is full, see WithRequestQueue.
+type ShedPolicy int
+
+const (
+	// ShedNewest rejects the new request, the queued requests keep waiting.
+	ShedNewest ShedPolicy = iota
+	// ShedOldest rejects the oldest queued request, that is the closest to its deadline, and queues the new one.
+	ShedOldest
+)
+
+type (
+	// nodeLimiter limits the requests in flight to the node, the requests over the limit wait in the queue.
+	nodeLimiter struct {
+		c     *Client
+		addr  string
+		limit int
+
+		mu       sync.Mutex
+		inflight int
+		// queue - the requests waiting for a slot in order of arrival.
+		queue []*waiter
+	}
+
+	waiter struct {
+		deadline time.Time
+		// ready receives nil, when the slot is handed over to the waiter, or ErrNodeSaturated, when it is shed.
+		ready chan error
+	}
+)
+
+// acquireNode takes a slot of the requests in flight to the node, the returned release frees it.
+// If all slots are taken, the request waits in the queue of the node not longer than the timeout of the call
+// and the maximum wait of the queue (see WithRequestQueue). ErrNodeSaturated is returned, if the request is not queued,
+// shed from the queue or its wait is expired.
+func (c *Client) acquireNode(node any, timeout time.Duration) (release func(), err error) {
+	limit := c.inflightLimit()
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	wait := c.getQueueMaxWait()
+	if timeout > 0 && timeout < wait {
+		wait = timeout
+	}
+
+	l := c.nodeLimiter(utils.Repr(node), limit)
+	if err = l.acquire(c.queueSize, c.shedPolicy, wait); err != nil {
+		return nil, fmt.Errorf("%w. Error for node - %s", err, l.addr)
+	}
+	return l.release, nil
+}
+
+// inflightLimit returns the maximum number of requests in flight to one node, zero means no limit.
+// The queued requests are limited by the capacity of the pool, if WithMaxInflightPerNode is not set.
+func (c *Client) inflightLimit() int {
+	switch {
+	case c.maxInflightPerNode > 0:
+		return c.maxInflightPerNode
+	case c.queueSize > 0 && c.muxConnsPerNode <= 0:
+		return c.getMaxIdleConns()
+	default:
+		return 0
+	}
+}
+
+func (c *Client) getQueueMaxWait() time.Duration {
+	if c.queueMaxWait > 0 {
+		return c.queueMaxWait
+	}
+	return DefaultSocketPoolingTimeout
+}
+
+func (c *Client) nodeLimiter(addr string, limit int) *nodeLimiter {
+	c.imu.Lock()
+	defer c.imu.Unlock()
+
+	if c.limiters == nil {
+		c.limiters = make(map[string]*nodeLimiter)
+	}
+	l, ok := c.limiters[addr]
+	if !ok {
+		l = &nodeLimiter{c: c, addr: addr, limit: limit}
+		c.limiters[addr] = l
+	}
+	return l
+}
+
+// removeLimiter drops the limiter of the node of addr, the requests holding or waiting for its slots release them as usual.
+func (c *Client) removeLimiter(addr net.Addr) {
+	c.imu.Lock()
+	defer c.imu.Unlock()
+
+	delete(c.limiters, addr.String())
+}
+
+// acquire takes a slot or waits for it in the queue of size not longer than wait.
+func (l *nodeLimiter) acquire(size int, policy ShedPolicy, wait time.Duration) error {
+	l.mu.Lock()
+	if l.inflight < l.limit && len(l.queue) == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return nil
+	}
+	if size <= 0 {
+		l.mu.Unlock()
+		return ErrNodeSaturated
+	}
+	if len(l.queue) >= size {
+		if policy != ShedOldest {
+			l.mu.Unlock()
+			l.c.observeNodeQueue(l.addr, queueShedEvent)
+			return ErrNodeSaturated
+		}
+		l.queue[0].ready <- ErrNodeSaturated
+		l.queue = slices.Delete(l.queue, 0, 1)
+		l.c.observeNodeQueue(l.addr, queueShedEvent)
+	}
+	w := &waiter{deadline: time.Now().Add(wait), ready: make(chan error, 1)}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+	l.c.observeNodeQueue(l.addr, queueEnqueuedEvent)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case err := <-w.ready:
+		return err
+	case <-timer.C:
+	}
+
+	l.mu.Lock()
+	if i := slices.Index(l.queue, w); i >= 0 {
+		l.queue = slices.Delete(l.queue, i, i+1)
+		l.mu.Unlock()
+		l.c.observeNodeQueue(l.addr, queueExpiredEvent)
+		return ErrNodeSaturated
+	}
+	l.mu.Unlock()
+	// the slot was handed over or the waiter was shed before the timer fired.
+	return <-w.ready
+}
+
+// release hands the slot over to the first waiter with not expired deadline or frees it.
+func (l *nodeLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for len(l.queue) > 0 {
+		w := l.queue[0]
+		l.queue = slices.Delete(l.queue, 0, 1)
+		if now.After(w.deadline) {
+			w.ready <- ErrNodeSaturated
+			l.c.observeNodeQueue(l.addr, queueExpiredEvent)
+			continue
+		}
+		w.ready <- nil
+		l.c.observeNodeQueue(l.addr, queueAdmittedEvent)
+		return
+	}
+	l.inflight--
+}
+
+func (c *Client) observeNodeQueue(node, event string) {
+	if c.disableMemcachedDiagnostic {
+		return
+	}
+	observeNodeQueue(node, event)
+}
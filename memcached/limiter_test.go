@@ -0,0 +1,153 @@
+package memcached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+func Test_nodeLimiter(t *testing.T) {
+	const wait = time.Second
+
+	// waitQueued blocks until the limiter has n queued requests.
+	waitQueued := func(l *nodeLimiter, n int) {
+		require.Eventually(t, func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return len(l.queue) == n
+		}, wait, time.Millisecond)
+	}
+	acquire := func(l *nodeLimiter, size int, policy ShedPolicy, wait time.Duration) chan error {
+		res := make(chan error, 1)
+		go func() {
+			res <- l.acquire(size, policy, wait)
+		}()
+		return res
+	}
+
+	t.Run("without queue", func(t *testing.T) {
+		l := &nodeLimiter{c: &Client{}, addr: "node", limit: 1}
+		require.Nil(t, l.acquire(0, ShedNewest, wait))
+		assert.ErrorIs(t, l.acquire(0, ShedNewest, wait), ErrNodeSaturated)
+		l.release()
+		assert.Nil(t, l.acquire(0, ShedNewest, wait), "acquire: the slot should be freed")
+	})
+
+	t.Run("handover", func(t *testing.T) {
+		l := &nodeLimiter{c: &Client{}, addr: "node", limit: 1}
+		require.Nil(t, l.acquire(1, ShedNewest, wait))
+		queued := acquire(l, 1, ShedNewest, wait)
+		waitQueued(l, 1)
+
+		l.release()
+		assert.Nil(t, <-queued, "acquire: the slot should be handed over")
+		assert.Equal(t, 1, l.inflight)
+	})
+
+	t.Run("shed newest", func(t *testing.T) {
+		l := &nodeLimiter{c: &Client{}, addr: "node", limit: 1}
+		require.Nil(t, l.acquire(1, ShedNewest, wait))
+		queued := acquire(l, 1, ShedNewest, wait)
+		waitQueued(l, 1)
+
+		assert.ErrorIs(t, l.acquire(1, ShedNewest, wait), ErrNodeSaturated, "acquire: the new request should be shed")
+		l.release()
+		assert.Nil(t, <-queued)
+	})
+
+	t.Run("shed oldest", func(t *testing.T) {
+		l := &nodeLimiter{c: &Client{}, addr: "node", limit: 1}
+		require.Nil(t, l.acquire(1, ShedOldest, wait))
+		oldest := acquire(l, 1, ShedOldest, wait)
+		waitQueued(l, 1)
+		newest := acquire(l, 1, ShedOldest, wait)
+
+		assert.ErrorIs(t, <-oldest, ErrNodeSaturated, "acquire: the oldest request should be shed")
+		waitQueued(l, 1)
+		l.release()
+		assert.Nil(t, <-newest)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		// the queue events are not counted without the diagnostic
+		l := &nodeLimiter{c: &Client{disableMemcachedDiagnostic: true}, addr: "node", limit: 1}
+		require.Nil(t, l.acquire(1, ShedNewest, wait))
+
+		timer := time.Now()
+		assert.ErrorIs(t, l.acquire(1, ShedNewest, 20*time.Millisecond), ErrNodeSaturated)
+		assert.GreaterOrEqual(t, time.Since(timer), 20*time.Millisecond)
+		assert.Empty(t, l.queue, "acquire: the expired request should leave the queue")
+
+		l.release()
+		assert.Equal(t, 0, l.inflight)
+	})
+}
+
+func TestClient_RequestQueue(t *testing.T) {
+	var (
+		entered = make(chan struct{})
+		unblock = make(chan struct{})
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if string(req.Key) == "slow" {
+			close(entered)
+			<-unblock
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.maxInflightPerNode = 1
+	c.queueSize = 1
+	c.queueMaxWait = time.Second
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Get("slow")
+		done <- err
+	}()
+	<-entered
+
+	_, err = c.Get("key", WithCallTimeout(20*time.Millisecond))
+	assert.ErrorIs(t, err, ErrNodeSaturated, "Get: the wait should be limited by the timeout of the call")
+
+	queued := make(chan error)
+	go func() {
+		_, err := c.Get("key")
+		queued <- err
+	}()
+	time.AfterFunc(20*time.Millisecond, func() { close(unblock) })
+
+	require.Nil(t, <-done)
+	assert.Nil(t, <-queued, "Get: the queued request should get the slot of the slow request")
+}
+
+func TestClient_removeLimiter(t *testing.T) {
+	srv := newFakeServer(t, func(*Request) *Response { return &Response{} })
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.maxInflightPerNode = 1
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+	addr, err := utils.AddrRepr(srv.addr)
+	require.Nil(t, err)
+	release, err := c.acquireNode(addr, time.Second)
+	require.Nil(t, err)
+
+	c.imu.Lock()
+	require.Len(t, c.limiters, 1)
+	c.imu.Unlock()
+	c.removeFromFreeConns(addr)
+	c.imu.Lock()
+	assert.Empty(t, c.limiters, "removeFromFreeConns: the limiter of the removed node should be dropped")
+	c.imu.Unlock()
+	assert.NotPanics(t, release, "release: the slot of the dropped limiter should be released")
+}
@@ -0,0 +1,230 @@
+package memcached
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	localTier  = "l1"
+	remoteTier = "l2"
+
+	cacheHitResult  = "hit"
+	cacheMissResult = "miss"
+)
+
+// LocalInvalidationHook is called after the keys are written or deleted by the client with the local cache
+// (see WithLocalCache). nil keys mean all keys (FlushAll). The keys can be published to the other instances
+// of the application and applied there by Client.ApplyInvalidation as is.
+// The hook is called synchronously by the method, so it must not block.
+type LocalInvalidationHook func(keys []string)
+
+type (
+	// localCache is the in-process LRU cache of Get responses in front of memcached, see WithLocalCache.
+	localCache struct {
+		mu sync.Mutex
+		// size - maximum number of items, ttl - time of life of an item.
+		size int
+		ttl  time.Duration
+		// items - elements of lru by key, the front of lru is the most recently used item.
+		items map[string]*list.Element
+		lru   *list.List
+		// gen - number of invalidations, the responses got before the invalidation are not cached.
+		gen uint64
+	}
+
+	localItem struct {
+		key     string
+		resp    Response
+		expires time.Time
+	}
+)
+
+func newLocalCache(size int, ttl time.Duration) *localCache {
+	return &localCache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element, size),
+		lru:   list.New(),
+	}
+}
+
+// generation returns the number of invalidations for putIfGeneration.
+func (lc *localCache) generation() uint64 {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.gen
+}
+
+// get returns a copy of the cached response.
+func (lc *localCache) get(key string) (*Response, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	el, ok := lc.items[key]
+	if !ok {
+		return nil, false
+	}
+	it := el.Value.(*localItem)
+	if !time.Now().Before(it.expires) {
+		lc.remove(el)
+		return nil, false
+	}
+
+	lc.lru.MoveToFront(el)
+	return cloneResponse(&it.resp), true
+}
+
+// putIfGeneration caches a copy of resp, if there were no invalidations since gen,
+// so the value read before a write is not cached after it.
+func (lc *localCache) putIfGeneration(key string, resp *Response, gen uint64) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.gen != gen {
+		return
+	}
+
+	it := &localItem{key: key, resp: *cloneResponse(resp), expires: time.Now().Add(lc.ttl)}
+	if el, ok := lc.items[key]; ok {
+		el.Value = it
+		lc.lru.MoveToFront(el)
+		return
+	}
+
+	lc.items[key] = lc.lru.PushFront(it)
+	for lc.lru.Len() > lc.size {
+		lc.remove(lc.lru.Back())
+	}
+}
+
+// invalidate removes the keys, the responses got before the call are not cached anymore.
+func (lc *localCache) invalidate(keys ...string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.gen++
+	for _, key := range keys {
+		if el, ok := lc.items[key]; ok {
+			lc.remove(el)
+		}
+	}
+}
+
+// clear removes all items.
+func (lc *localCache) clear() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.gen++
+	clear(lc.items)
+	lc.lru.Init()
+}
+
+// len returns the number of cached items including the expired ones.
+func (lc *localCache) len() int {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.lru.Len()
+}
+
+func (lc *localCache) remove(el *list.Element) {
+	lc.lru.Remove(el)
+	delete(lc.items, el.Value.(*localItem).key)
+}
+
+func cloneResponse(resp *Response) *Response {
+	return &Response{
+		Opcode: resp.Opcode,
+		Status: resp.Status,
+		Cas:    resp.Cas,
+		Extras: bytes.Clone(resp.Extras),
+		Key:    bytes.Clone(resp.Key),
+		Body:   bytes.Clone(resp.Body),
+	}
+}
+
+// localGet returns the response from the local cache, otherwise gets it from memcached and caches it.
+func (c *Client) localGet(key string, timeout time.Duration) (*Response, error) {
+	if resp, ok := c.localCache.get(key); ok {
+		c.observeCacheRequest(localTier, true)
+		return resp, nil
+	}
+	c.observeCacheRequest(localTier, false)
+
+	gen := c.localCache.generation()
+	resp, err := c.get(key, timeout)
+	switch {
+	case err == nil:
+		c.observeCacheRequest(remoteTier, true)
+		c.localCache.putIfGeneration(key, resp, gen)
+	case errors.Is(err, ErrCacheMiss):
+		c.observeCacheRequest(remoteTier, false)
+	}
+	return resp, err
+}
+
+// localMultiGet puts the values of the keys from the local cache to ret and returns the missing keys.
+func (c *Client) localMultiGet(keys []string, ret map[string][]byte) []string {
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if resp, ok := c.localCache.get(key); ok {
+			c.observeCacheRequest(localTier, true)
+			ret[key] = resp.Body
+		} else {
+			c.observeCacheRequest(localTier, false)
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// localMultiPut caches the values of the keys from ret got from memcached.
+func (c *Client) localMultiPut(keys []string, ret map[string][]byte, gen uint64) {
+	for _, key := range keys {
+		body, ok := ret[key]
+		c.observeCacheRequest(remoteTier, ok)
+		if ok {
+			c.localCache.putIfGeneration(key, &Response{Opcode: GET, Status: SUCCESS, Body: body}, gen)
+		}
+	}
+}
+
+// ApplyInvalidation removes the keys from the local cache (see WithLocalCache), all keys if none are given.
+// It is used to apply the keys from the hooks of other instances (see WithOnLocalWrite), the hooks are not called.
+func (c *Client) ApplyInvalidation(keys ...string) {
+	if c.localCache == nil {
+		return
+	}
+	if len(keys) == 0 {
+		c.localCache.clear()
+		return
+	}
+	c.localCache.invalidate(keys...)
+}
+
+// invalidateLocal removes the keys from the local cache, all keys if none are given, and calls the hook,
+// if the local cache is turned on.
+func (c *Client) invalidateLocal(hook LocalInvalidationHook, keys ...string) {
+	if c.localCache == nil {
+		return
+	}
+	c.ApplyInvalidation(keys...)
+	if hook != nil {
+		hook(keys)
+	}
+}
+
+func (c *Client) observeCacheRequest(tier string, hit bool) {
+	if c.disableMemcachedDiagnostic {
+		return
+	}
+	result := cacheMissResult
+	if hit {
+		result = cacheHitResult
+	}
+	observeCacheRequest(tier, result)
+}
@@ -0,0 +1,145 @@
+package memcached
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_localCache(t *testing.T) {
+	lc := newLocalCache(2, time.Hour)
+
+	_, ok := lc.get("key1")
+	assert.False(t, ok)
+
+	lc.putIfGeneration("key1", &Response{Body: []byte("value1")}, lc.generation())
+	lc.putIfGeneration("key2", &Response{Body: []byte("value2")}, lc.generation())
+	resp, ok := lc.get("key1")
+	require.True(t, ok)
+	assert.Equal(t, []byte("value1"), resp.Body)
+
+	resp.Body[0] = 'X'
+	resp, _ = lc.get("key1")
+	assert.Equal(t, []byte("value1"), resp.Body, "get: should return a copy")
+
+	lc.putIfGeneration("key3", &Response{Body: []byte("value3")}, lc.generation())
+	assert.Equal(t, 2, lc.len())
+	_, ok = lc.get("key2")
+	assert.False(t, ok, "putIfGeneration: the least recently used item should be evicted")
+
+	gen := lc.generation()
+	lc.invalidate("key1")
+	_, ok = lc.get("key1")
+	assert.False(t, ok, "invalidate: item should be removed")
+	lc.putIfGeneration("key1", &Response{Body: []byte("stale")}, gen)
+	_, ok = lc.get("key1")
+	assert.False(t, ok, "putIfGeneration: value got before the invalidation should not be cached")
+
+	lc.clear()
+	assert.Equal(t, 0, lc.len())
+
+	lc = newLocalCache(2, 10*time.Millisecond)
+	lc.putIfGeneration("key", &Response{}, lc.generation())
+	time.Sleep(20 * time.Millisecond)
+	_, ok = lc.get("key")
+	assert.False(t, ok, "get: expired item should not be returned")
+	assert.Equal(t, 0, lc.len(), "get: expired item should be removed")
+}
+
+func TestClient_LocalCache(t *testing.T) {
+	var gets, version atomic.Int32
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case GET, GETQ:
+			gets.Add(1)
+			return &Response{Body: []byte(string(req.Key) + strconv.Itoa(int(version.Load())))}
+		case SET:
+			version.Add(1)
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.localCache = newLocalCache(10, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		resp, gErr := c.Get("key")
+		require.Nil(t, gErr)
+		assert.Equal(t, []byte("key0"), resp.Body)
+	}
+	assert.Equal(t, int32(1), gets.Load(), "Get: should be sent to memcached once")
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+	resp, err := c.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("key1"), resp.Body, "Store: should invalidate the key")
+	assert.Equal(t, int32(2), gets.Load())
+
+	got, err := c.MultiGet([]string{"key", "other"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"key": []byte("key1"), "other": []byte("other1")}, got)
+	assert.Equal(t, int32(3), gets.Load(), "MultiGet: only the missing key should be sent to memcached")
+
+	got, err = c.MultiGet([]string{"key", "other"})
+	require.Nil(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, int32(3), gets.Load(), "MultiGet: should be served from the local cache")
+
+	require.Nil(t, c.MultiDelete([]string{"other"}))
+	_, err = c.Get("other")
+	require.Nil(t, err)
+	assert.Equal(t, int32(4), gets.Load(), "MultiDelete: should invalidate the keys")
+
+	require.Nil(t, c.FlushAll(0))
+	assert.Equal(t, 0, c.localCache.len(), "FlushAll: should clear the local cache")
+}
+
+func TestClient_LocalInvalidationHooks(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response { return &Response{Body: []byte("value")} })
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	var written, deleted [][]string
+	c.onLocalWrite = func(keys []string) { written = append(written, keys) }
+	c.onLocalDelete = func(keys []string) { deleted = append(deleted, keys) }
+
+	_, err = c.Store(Set, "key", 0, nil)
+	require.Nil(t, err)
+	assert.Empty(t, written, "hooks: should not be called without the local cache")
+
+	c.localCache = newLocalCache(10, time.Hour)
+	_, err = c.Store(Set, "key", 0, nil)
+	require.Nil(t, err)
+	_, err = c.Append(Append, "key", nil)
+	require.Nil(t, err)
+	require.Nil(t, c.MultiStore(Set, map[string][]byte{"key1": nil}, 0))
+	_, err = c.Delete("key")
+	require.Nil(t, err)
+	require.Nil(t, c.MultiDelete([]string{"key1", "key2"}))
+	require.Nil(t, c.FlushAll(0))
+
+	assert.Equal(t, [][]string{{"key"}, {"key"}, {"key1"}}, written)
+	assert.Equal(t, [][]string{{"key"}, {"key1", "key2"}, nil}, deleted)
+
+	_, err = c.Get("key1")
+	require.Nil(t, err)
+	_, err = c.Get("key2")
+	require.Nil(t, err)
+	c.ApplyInvalidation("key1")
+	_, ok := c.localCache.get("key1")
+	assert.False(t, ok, "ApplyInvalidation: key should be removed")
+	_, ok = c.localCache.get("key2")
+	assert.True(t, ok, "ApplyInvalidation: other keys should stay")
+	c.ApplyInvalidation()
+	assert.Equal(t, 0, c.localCache.len(), "ApplyInvalidation: without keys should remove all keys")
+	assert.Len(t, deleted, 3, "ApplyInvalidation: hooks should not be called")
+}
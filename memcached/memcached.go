@@ -2,18 +2,22 @@ package memcached
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/exp/maps"
 
 	"github.com/aliexpressru/gomemcached/consistenthash"
@@ -35,6 +39,10 @@ const (
 	// DefaultRebuildingNodePeriod is the default time period for rebuilds the nodes in hash ring using freshly discovered
 	DefaultRebuildingNodePeriod = 15 * time.Second
 
+	// DefaultDiscoveryCacheTTL is the default time for which the discovered nodes are cached,
+	// so the health check and the rebuilding of the nodes share one lookup.
+	DefaultDiscoveryCacheTTL = 5 * time.Second
+
 	// DefaultRetryCountForConn is a default number of connection retries before return i/o timeout error
 	DefaultRetryCountForConn = uint8(3)
 
@@ -43,18 +51,33 @@ const (
 
 	// DefaultSocketPoolingTimeout Amount of time to acquire socket from pool
 	DefaultSocketPoolingTimeout = 50 * time.Millisecond
+
+	// defaultWriteBufferSize is the size of the write buffer of the connections, that is flushed, when it is full.
+	defaultWriteBufferSize = 4096
+
+	// shutdownPollInterval is the period for checking the operations in progress in Shutdown.
+	shutdownPollInterval = 10 * time.Millisecond
+
+	// DefaultConnValidationTimeout is the time to wait the NOOP response when validating an idle connection
+	DefaultConnValidationTimeout = 100 * time.Millisecond
+
+	// DefaultGetBatchKeys is the default maximum number of the keys in the batch of the single Gets (see WithGetBatching).
+	DefaultGetBatchKeys = 100
 )
 
-var _ Memcached = (*Client)(nil)
+var (
+	_ Memcached = (*Client)(nil)
+	_ io.Closer = (*Client)(nil)
+)
 
 type (
 	Memcached interface {
-		Store(storeMode StoreMode, key string, exp uint32, body []byte) (*Response, error)
-		Get(key string) (*Response, error)
-		Delete(key string) (*Response, error)
-		Delta(deltaMode DeltaMode, key string, delta, initial uint64, exp uint32) (newValue uint64, err error)
-		Append(appendMode AppendMode, key string, data []byte) (*Response, error)
-		FlushAll(exp uint32) error
+		Store(storeMode StoreMode, key string, exp uint32, body []byte, opts ...CallOption) (*Response, error)
+		Get(key string, opts ...CallOption) (*Response, error)
+		Delete(key string, opts ...CallOption) (*Response, error)
+		Delta(deltaMode DeltaMode, key string, delta, initial uint64, exp uint32, opts ...CallOption) (newValue uint64, err error)
+		Append(appendMode AppendMode, key string, data []byte, opts ...CallOption) (*Response, error)
+		FlushAll(exp uint32, opts ...FlushOption) error
 		MultiDelete(keys []string) error
 		MultiStore(storeMode StoreMode, items map[string][]byte, exp uint32) error
 		MultiGet(keys []string) (map[string][]byte, error)
@@ -67,15 +90,35 @@ type (
 	// It is safe for unlocked use by multiple concurrent goroutines.
 	Client struct {
 		ctx context.Context
-		nw  *network
-		cfg *config
-
-		// opaque - a unique identifier for the request, used to associate the request with its corresponding response.
-		opaque *uint32
-
-		// timeout specifies the socket read/write timeout.
+		// cancel stops the background goroutines of the client.
+		cancel context.CancelFunc
+		// bgWG waits for the background goroutines of the client.
+		bgWG sync.WaitGroup
+		// closed - is flag that the client is closed and doesn't give connections anymore.
+		closed atomic.Bool
+		nw     *network
+		cfg    *config
+
+		// keepalives - the cancels of the touches of the keys registered by RegisterKeepalive.
+		keepalivesMu sync.Mutex
+		keepalives   map[string]context.CancelFunc
+
+		// timeout specifies the socket dial/read/write timeout.
 		// If zero, DefaultTimeout is used.
 		timeout time.Duration
+		// dialTimeout, readTimeout and writeTimeout override timeout for a specific socket operation.
+		// If zero, timeout is used.
+		dialTimeout  time.Duration
+		readTimeout  time.Duration
+		writeTimeout time.Duration
+
+		// tcpKeepAlive - period between TCP keep-alive probes, zero keeps the system default,
+		// negative disables keep-alive.
+		tcpKeepAlive time.Duration
+		// disableTCPNoDelay - is flag for turn off TCP_NODELAY, so the system may buffer small writes (Nagle's algorithm).
+		disableTCPNoDelay bool
+		// tcpLinger - SO_LINGER in seconds for closing connections, nil keeps the system default.
+		tcpLinger *int
 
 		// maxIdleConns specifies the maximum number of idle connections that will
 		// be maintained per address. If less than one, DefaultMaxIdleConns will be
@@ -85,15 +128,115 @@ type (
 		// be set to a number higher than your peak parallel requests.
 		maxIdleConns int
 
+		// maxBodyLen - maximum size of the value sent by Store, Append and MultiStore,
+		// if zero, MaxBodyLen is used.
+		maxBodyLen int
+
 		// hr - hash ring implementation (can be a custom consistenthash.NewCustomHashRing)
 		hr consistenthash.ConsistentHash
+		// nodesWeight - weights of nodes from MEMCACHED_SERVERS, nil if weights are not specified.
+		nodesWeight map[string]int
+		// proxyProtocol - sends PROXY protocol v2 header after dialing, proxyProtocolNodes - the addresses
+		// of the nodes behind the proxy, nil means all nodes.
+		proxyProtocol      bool
+		proxyProtocolNodes map[string]struct{}
+		// hedgingDelay - delay after which Get is sent to the next node of the ring, zero disables hedging.
+		hedgingDelay time.Duration
+		// getBatchWindow - window, within which the single Gets of a node are coalesced into one GETQ batch,
+		// zero disables the batching, getBatchKeys - the batch is sent before the end of the window, when it is full.
+		getBatchWindow time.Duration
+		getBatchKeys   int
+		// gbmu - mutex for getBatchers, the batches of the single Gets by the nodes.
+		gbmu        sync.Mutex
+		getBatchers map[string]*getBatcher
+		// tmu - mutex for textConns, the pools of the connections of the text protocol by the nodes (see GetWithTTL).
+		tmu       sync.Mutex
+		textConns map[string]*pool.Pool[*textConn]
+		// ttlDumpFallback - GetWithTTL finds the TTL by the dump of the keys on memcached without mg.
+		ttlDumpFallback bool
+		// batchWorkers - number of the long-lived goroutines, that run the batches of the multi-key operations,
+		// batchTasks - the batches for them, nil if the workers are off.
+		batchWorkers int
+		batchTasks   chan func()
+		// flushEveryRequests, flushEveryBytes - the write buffer of the batches is flushed after so many quiet requests
+		// or buffered bytes, zero flushes it only when it is full and at the end of the batch.
+		flushEveryRequests int
+		flushEveryBytes    int
+		// verifyKeys - MultiGet sends GETKQ and checks the keys of the responses.
+		verifyKeys bool
+		// routingKeyFunc - extracts the key, that is hashed to find the node of the key, nil hashes the key itself.
+		routingKeyFunc func(key string) string
+		// stableHostnames - is flag for use hostnames of nodes instead of ip addresses in the hash ring.
+		stableHostnames bool
+		// slowStartWindow - period, during which the weight of a new node grows to the full weight,
+		// zero disables slow start.
+		slowStartWindow time.Duration
+		// slowStartNodes - time of adding the nodes in slow start, it is used only by the topology loop.
+		slowStartNodes map[string]time.Time
+		// nodeRecoveryHook - is called for nodes added to the ring by NodeProvider, nil if not set.
+		nodeRecoveryHook NodeRecoveryHook
+		// topologyEvents - the changes of the ring for TopologyEvents, topologyVersion - the current version of the ring.
+		topologyEvents  chan TopologyEvent
+		topologyVersion atomic.Uint64
+		// tpmu - mutex for the sending to topologyEvents and its closing, topologyClosed - the channel is closed.
+		tpmu           sync.Mutex
+		topologyClosed bool
+		// topologyPaused - the topology loop leaves the ring as is, see PauseTopologyUpdates,
+		// topologyResync - wakes up the topology loop after ResumeTopologyUpdates, nil without the loop.
+		topologyPaused atomic.Bool
+		topologyResync chan struct{}
+		// localCache - in-process cache of Get responses in front of memcached, nil if it is turned off.
+		localCache *localCache
+		// onLocalWrite and onLocalDelete - are called after the keys are invalidated in localCache, nil if not set.
+		onLocalWrite  LocalInvalidationHook
+		onLocalDelete LocalInvalidationHook
+		// onMultiGetMiss - is called with the keys missed by MultiGet, nil if not set.
+		onMultiGetMiss MissHook
+		// retryAttempts - number of the retries of the failed idempotent requests, retryBackoff - the delay
+		// before the first retry, it is doubled for every next one.
+		retryAttempts int
+		retryBackoff  time.Duration
+		// retryBudget - throttles the retries and the hedges, nil if neither WithRetries nor WithRetryBudget is set.
+		retryBudget *RetryBudget
+		// faultInjector - is called before the requests to the nodes, nil if not set.
+		faultInjector FaultInjector
+		// connValidationIdle - connections idle in the pool longer than this are checked by NOOP before use,
+		// zero disables the check.
+		connValidationIdle time.Duration
 
 		// disableMemcachedDiagnostic - is flag for turn off write metrics from lib.
 		disableMemcachedDiagnostic bool
+		// methodTimeouts - the read and write timeouts of the methods, that override the timeouts of the client.
+		methodTimeouts map[Method]time.Duration
+		// methodDurationSeconds - the histogram of the durations of the methods with the buckets of WithDurationBuckets,
+		// if nil, the histogram of the package is used.
+		methodDurationSeconds *prometheus.HistogramVec
+		// methodCounters - the counters of the calls by method name (*methodCounter) for DebugVars.
+		methodCounters sync.Map
+		// logger - logger of the client, if nil, the global logger of the package logger is used.
+		logger logger.Logger
+		// wireLogging - is flag for logging the frames of the binary protocol, wireLoggingBodies - with their bodies.
+		wireLogging       bool
+		wireLoggingBodies bool
+		// trafficRecorder - writes the sampled frames of the connections, nil if the capture is turned off.
+		trafficRecorder *TrafficRecorder
+		// keyProvider - gives the keys of the encryption of the values, nil if the encryption is turned off.
+		keyProvider KeyProvider
 		// disableNodeProvider - is flag for turn off rebuild and health check nodes.
 		disableNodeProvider bool
 		// disableRefreshConns - is flag for turn off to refresh conns in the pool.
 		disableRefreshConns bool
+		// connsRefreshPerPeriod - number of conns closed in every pool per rebuild period,
+		// if nil, DefaultOfNumberConnsToDestroyPerRBPeriod is used.
+		connsRefreshPerPeriod *int
+		// connRetryCount - number of dial retries on timeout in the node health check,
+		// if nil, DefaultRetryCountForConn is used.
+		connRetryCount *uint8
+		// minIdleConns - number of connections per node, that Warmup dials ahead of traffic.
+		minIdleConns int
+		// maxConnLifetime and maxConnIdleTime - limits for retire connections in the pool, zero means no limit.
+		maxConnLifetime time.Duration
+		maxConnIdleTime time.Duration
 		// nodeHCPeriod - period for execute nodes health checker
 		// if zero, DefaultNodeHealthCheckPeriod is used.
 		nodeHCPeriod time.Duration
@@ -104,11 +247,58 @@ type (
 		// fmu - mutex for freeConns
 		fmu sync.RWMutex
 		// freeConns hashmap with nodes and their open dial connections
-		freeConns map[string]*pool.Pool
+		freeConns map[string]*pool.Pool[*conn]
+		// muxConnsPerNode - number of multiplexed connections per node, zero turns off the multiplexing.
+		muxConnsPerNode int
+		// mmu - mutex for muxNodes
+		mmu sync.Mutex
+		// muxNodes hashmap with nodes and their multiplexed connections
+		muxNodes map[string]*muxNode
+
+		// maxInflightPerNode - maximum number of requests in flight to one node, zero means no limit.
+		maxInflightPerNode int
+		// queueSize - maximum number of requests waiting for the node, zero means the requests are not queued.
+		queueSize int
+		// queueMaxWait - maximum time of waiting in the queue of the node.
+		queueMaxWait time.Duration
+		// shedPolicy - which request is rejected, when the queue of the node is full.
+		shedPolicy ShedPolicy
+		// imu - mutex for limiters
+		imu sync.Mutex
+		// limiters hashmap with nodes and their limiters of requests in flight
+		limiters map[string]*nodeLimiter
 		// dmu - mutex for deadNodes
 		dmu sync.RWMutex
 		// deadNodes hashmap with nodes that did not respond to health check
 		deadNodes map[string]struct{}
+		// deadReasons - errors of the health checks of the dead nodes
+		deadReasons map[string]error
+		// nodeFailures - the consecutive failed health checks of the nodes in the ring, that are not dead yet.
+		nodeFailures map[string]int
+		// removalGraceChecks - the number of the consecutive failed health checks or discoveries without the node,
+		// after which the node is removed from the ring, absentNodes - the nodes, that are not discovered yet,
+		// it is used only by the topology loop.
+		removalGraceChecks int
+		absentNodes        map[string]int
+		// dsmu - mutex for discoveryErr and discoveryTime
+		dsmu sync.Mutex
+		// discoveryErr and discoveryTime - result of the last discovery of nodes by NodeProvider.
+		discoveryErr  error
+		discoveryTime time.Time
+		// discoveryTTL - time for which the discovered nodes are cached, negative turns the cache off,
+		// zero is replaced with DefaultDiscoveryCacheTTL by InitFromEnv.
+		discoveryTTL time.Duration
+		// dcmu - mutex for the cache of the discovered nodes, it is held during the lookup,
+		// so the concurrent discoveries wait for one lookup.
+		dcmu sync.Mutex
+		// discoveredNodes and discoveredAt - the sorted nodes of the last successful discovery and its time,
+		// discoveryVersion - it is increased, when the discovered nodes are changed.
+		discoveredNodes  []string
+		discoveredAt     time.Time
+		discoveryVersion uint64
+		// rebuiltVersion and rebuiltDeadNodes - discoveryVersion and dead nodes of the last rebuilding of the ring.
+		rebuiltVersion   uint64
+		rebuiltDeadNodes map[string]struct{}
 
 		authEnable bool
 		// authData ready body for authentication request
@@ -119,12 +309,14 @@ type (
 		dial        func(network string, address string) (net.Conn, error)
 		dialTimeout func(network string, address string, timeout time.Duration) (net.Conn, error)
 		lookupHost  func(host string) (addrs []string, err error)
+		lookupAddr  func(addr string) (names []string, err error)
 	}
 
 	config struct {
 		// HeadlessServiceAddress Headless service to lookup all the memcached ip addresses.
 		HeadlessServiceAddress string `envconfig:"MEMCACHED_HEADLESS_SERVICE_ADDRESS"`
-		// Servers List of servers with hosted memcached
+		// Servers List of servers with hosted memcached.
+		// Each server can have an optional weight in format host:port|weight.
 		Servers []string `envconfig:"MEMCACHED_SERVERS"`
 		// MemcachedPort The optional port override for cases when memcached IP addresses are obtained from headless service.
 		MemcachedPort int `envconfig:"MEMCACHED_PORT" default:"11211"`
@@ -136,7 +328,17 @@ type (
 		hdrBuf  []byte
 		healthy bool
 		wrtBuf  *bufio.Writer
-		authed  bool
+		// lastUsed - time when the connection was returned to the pool.
+		lastUsed time.Time
+		// opaque - the last opaque used on the connection, it associates the request with its corresponding response.
+		opaque uint32
+		// unflushed - number of the quiet requests written to wrtBuf since the last flush.
+		unflushed int
+		// poisoned - the connection is closed after the failed write (see poison), it must not be released.
+		poisoned bool
+		// pool - the pool, that created the connection, it is released and closed only by it,
+		// as the pool of the node re-added after the removal doesn't own it. Nil for the connections out of the pools.
+		pool *pool.Pool[*conn]
 	}
 )
 
@@ -158,25 +360,48 @@ func InitFromEnv(opts ...Option) (*Client, error) {
 		opt(op)
 	}
 
+	if op.jumpHash && !op.Client.disableNodeProvider {
+		return nil, fmt.Errorf("%w, WithJumpHash requires WithDisableNodeProvider", ErrNotConfigured)
+	}
+
 	if op.Client.nw == nil {
 		op.Client.nw = &network{
 			dial:        net.Dial,
 			dialTimeout: net.DialTimeout,
 			lookupHost:  net.LookupHost,
+			lookupAddr:  net.LookupAddr,
 		}
 	}
 	if op.Client.hr == nil {
-		op.Client.hr = consistenthash.NewHashRing()
+		if op.jumpHash {
+			op.Client.hr = consistenthash.NewCustomJumpHash(op.hashFunc)
+		} else {
+			op.Client.hr = consistenthash.NewCustomHashRing(op.hashReplicas, op.hashFunc)
+		}
+	}
+	if op.Client.retryAttempts > 0 && op.Client.retryBudget == nil {
+		op.Client.retryBudget, _ = NewRetryBudget(DefaultRetryBudgetTokens, DefaultRetryBudgetRatio)
+	}
+	if op.Client.discoveryTTL == 0 {
+		op.Client.discoveryTTL = DefaultDiscoveryCacheTTL
 	}
 	if op.Client.ctx == nil {
 		op.Client.ctx = context.Background()
 	}
-	if op.Client.opaque == nil {
-		op.Client.opaque = new(uint32)
-	}
+	op.Client.ctx, op.Client.cancel = context.WithCancel(op.Client.ctx)
 	if op.disableLogger {
 		logger.DisableLogger()
 	}
+	if len(op.logFields) != 0 || op.logSamplingFirst > 0 {
+		l := op.Client.logger
+		if l == nil {
+			l = logger.Default()
+		}
+		if op.logSamplingFirst > 0 {
+			l = logger.NewSampled(l, op.logSamplingPeriod, op.logSamplingFirst, op.logSamplingThereafter)
+		}
+		op.Client.logger = logger.With(l, op.logFields...)
+	}
 
 	return newFromConfig(op)
 }
@@ -192,13 +417,13 @@ func newForTests(servers ...string) (*Client, error) {
 	}
 	cm := &Client{
 		ctx:                        context.Background(),
-		opaque:                     new(uint32),
 		hr:                         hr,
 		disableMemcachedDiagnostic: true,
 		nw: &network{
 			dial:        net.Dial,
 			dialTimeout: net.DialTimeout,
 			lookupHost:  net.LookupHost,
+			lookupAddr:  net.LookupAddr,
 		},
 	}
 
@@ -209,21 +434,41 @@ func newFromConfig(op *options) (*Client, error) {
 	if op.cfg != nil && !(op.cfg.HeadlessServiceAddress != "" || len(op.cfg.Servers) != 0) {
 		return nil, fmt.Errorf("%w, you must fill in either MEMCACHED_HEADLESS_SERVICE_ADDRESS or MEMCACHED_SERVERS", ErrNotConfigured)
 	}
-	nodes, err := getNodes(op.nw.lookupHost, op.cfg)
+	mc := &op.Client
+
+	nodes, err := mc.discoverNodes()
 	if err != nil {
 		return nil, fmt.Errorf("%w, %s", ErrInvalidAddr, err.Error())
 	}
 
-	mc := &op.Client
+	mc.nodesWeight = getNodesWeight(op.cfg)
 
+	addrs := make([]net.Addr, 0, len(nodes))
 	for _, n := range nodes {
-		addr, err := utils.AddrRepr(n)
+		addr, err := mc.nodeAddr(n)
 		if err != nil {
 			return nil, fmt.Errorf("%w: %s", ErrInvalidAddr, err.Error())
 		}
-		mc.hr.Add(addr)
+		mc.addNodeToRing(mc.hr, n, addr)
+		addrs = append(addrs, addr)
+	}
+
+	mc.topologyEvents = make(chan TopologyEvent, topologyEventsBuffer)
+	mc.emitTopologyEvent(TopologyInit, addrs, nil, nil)
+
+	if op.minServerVersion != "" {
+		if err = mc.checkVersions(op.minServerVersion); err != nil {
+			if op.versionCheckFailFast {
+				mc.CloseAllConns()
+				return nil, err
+			}
+			mc.getLogger().Warnf("%s", err.Error())
+		}
 	}
 
+	if mc.batchWorkers > 0 {
+		mc.startBatchWorkers(mc.batchWorkers)
+	}
 	if !mc.disableNodeProvider {
 		mc.initNodesProvider()
 	}
@@ -232,10 +477,15 @@ func newFromConfig(op *options) (*Client, error) {
 
 // release returns this connection back to the client's free pool
 func (cn *conn) release() {
+	cn.lastUsed = time.Now()
 	cn.c.putFreeConn(cn)
 }
 
 func (cn *conn) close() {
+	if cn.pool != nil {
+		cn.pool.Close(cn)
+		return
+	}
 	if p, ok := cn.c.safeGetFreeConn(cn.addr); ok {
 		p.Close(cn)
 	} else {
@@ -243,31 +493,277 @@ func (cn *conn) close() {
 	}
 }
 
+// ping sends NOOP and waits the response not longer than timeout.
+func (cn *conn) ping(timeout time.Duration) error {
+	req := &Request{
+		Opcode: NOOP,
+		Opaque: cn.nextOpaque(),
+	}
+	if _, err := cn.transmit(req); err != nil {
+		return err
+	}
+	if err := cn.wrtBuf.Flush(); err != nil {
+		return err
+	}
+
+	var r io.Reader = cn.rc
+	if tc, ok := cn.rc.(*timeoutConn); ok {
+		// read from the underlying conn, so the short deadline isn't overwritten by the read timeout.
+		if err := tc.Conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		r = tc.Conn
+	}
+
+	resp, n, err := getResponse(r, cn.hdrBuf, cn.c.getRecvBodyLen())
+	if resp != nil {
+		cn.c.countBytes(cn.addr, resp.Opcode, bytesReadEvent, n)
+	}
+	if err != nil {
+		return err
+	}
+	return checkResponse(resp, req.Opcode, req.Opaque)
+}
+
 // condRelease releases this connection if the error pointed to by err
 // is nil (not an error) or is only a protocol level error (e.g. a
 // cache miss).  The purpose is to not recycle TCP connections that
 // are bad.
 func (cn *conn) condRelease(err *error) {
+	if cn.poisoned {
+		// already closed
+		return
+	}
 	if (*err == nil || resumableError(*err)) && cn.healthy {
 		cn.release()
 	} else {
+		cn.healthy = false
 		cn.close()
 	}
 }
 
-func (c *Client) getOpaque() uint32 {
-	atomic.CompareAndSwapUint32(c.opaque, math.MaxUint32, uint32(0))
-	return atomic.AddUint32(c.opaque, uint32(1))
+// quit sends QUITQ to let the server close the connection gracefully, the server doesn't answer on it.
+func (cn *conn) quit() {
+	if _, err := cn.transmit(&Request{Opcode: QUITQ}); err != nil {
+		return
+	}
+	_ = cn.wrtBuf.Flush()
+}
+
+// setTimeout overrides the read and write timeouts of the connection, if timeout is not zero,
+// the returned function restores them.
+func (cn *conn) setTimeout(timeout time.Duration) (restore func()) {
+	tc, ok := cn.rc.(*timeoutConn)
+	if !ok || timeout <= 0 {
+		return func() {}
+	}
+	readTimeout, writeTimeout := tc.readTimeout, tc.writeTimeout
+	tc.readTimeout, tc.writeTimeout = timeout, timeout
+	return func() { tc.readTimeout, tc.writeTimeout = readTimeout, writeTimeout }
+}
+
+// setDeadline sets the deadline of the whole operation on the connection, if it is not zero,
+// the returned function removes it.
+func (cn *conn) setDeadline(deadline time.Time) (restore func()) {
+	tc, ok := cn.rc.(*timeoutConn)
+	if !ok || deadline.IsZero() {
+		return func() {}
+	}
+	tc.deadline = deadline
+	return func() { tc.deadline = time.Time{} }
+}
+
+// closeOnDone closes the connection, when ctx is done, so the blocked reads and writes of the batch return.
+// The returned function stops the watching, the connection is marked as unhealthy, if it was closed.
+func (cn *conn) closeOnDone(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	stopClose := context.AfterFunc(ctx, func() { _ = cn.rc.Close() })
+	return func() {
+		if !stopClose() {
+			cn.healthy = false
+		}
+	}
+}
+
+// ctxErr returns the error of ctx instead of err, if ctx is done, so the errors of the connection
+// closed by closeOnDone are reported as the cancellation.
+func ctxErr(ctx context.Context, err error) error {
+	if cErr := ctx.Err(); cErr != nil {
+		return cErr
+	}
+	return err
+}
+
+// nextOpaque returns the opaque for the next request on the connection.
+func (cn *conn) nextOpaque() uint32 {
+	cn.opaque++
+	return cn.opaque
+}
+
+// transmitQuiet writes the quiet request of the batch and flushes the buffer after flushEveryRequests requests
+// or flushEveryBytes bytes (see WithFlushEvery), so the server starts processing the long batch before it is written.
+func (cn *conn) transmitQuiet(req *Request) (int, error) {
+	if cn.wrtBuf.Buffered() == 0 {
+		// the buffer is flushed since the last request
+		cn.unflushed = 0
+	}
+	n, err := cn.transmit(req)
+	if err != nil || cn.c == nil {
+		return n, err
+	}
+
+	cn.unflushed++
+	if everyReqs, everyBytes := cn.c.flushEveryRequests, cn.c.flushEveryBytes; everyReqs > 0 && cn.unflushed >= everyReqs ||
+		everyBytes > 0 && cn.wrtBuf.Buffered() >= everyBytes {
+		cn.unflushed = 0
+		err = cn.wrtBuf.Flush()
+	}
+	return n, err
+}
+
+// writeBatch writes the quiet requests of b, that are built by req, with the closing NOOP and flushes them.
+// If a write fails, the stream may end with a truncated frame, so the connection is poisoned,
+// and the error is *BatchAbortError with the keys, whose requests are not known to be sent.
+func (cn *conn) writeBatch(b batch, req func(i int) *Request) error {
+	var (
+		// ends are the offsets of the ends of the requests in the stream of the batch,
+		// sent is the number of the first requests written to the socket completely.
+		ends        = make([]int, len(b.keys))
+		total, sent int
+	)
+	abort := func(err error) error {
+		cn.poison(err)
+		return &BatchAbortError{Addr: utils.Repr(cn.addr), Unsent: b.keys[sent:], Err: err}
+	}
+
+	for i := range b.keys {
+		r := req(i)
+		r.Opaque = b.opaque(i)
+		n, err := cn.transmitQuiet(r)
+		if err != nil {
+			return abort(err)
+		}
+		total += n
+		ends[i] = total
+		// the requests, that ended before the bytes still in the buffer, are written to the socket.
+		flushed := total - cn.wrtBuf.Buffered()
+		for sent <= i && ends[sent] <= flushed {
+			sent++
+		}
+	}
+
+	noop := &Request{
+		Opcode: NOOP,
+		Opaque: b.noopOpaque(),
+	}
+	noop.prepareExtras(0, 0, 0)
+	if _, err := cn.transmit(noop); err != nil {
+		return abort(err)
+	}
+	if err := cn.wrtBuf.Flush(); err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
+// poison closes the connection after the failed write at once and evicts it from the pool,
+// as its stream may end with a truncated frame and the server would take the next request for its rest.
+func (cn *conn) poison(err error) {
+	if cn.poisoned {
+		return
+	}
+	cn.healthy = false
+	cn.poisoned = true
+	if cn.c != nil {
+		cn.c.nodeLogger(cn.addr).Errorf("%s. %s: connection is closed after the failed write", ErrServerError.Error(), err.Error())
+	}
+	cn.close()
+}
+
+// writeBufferSize returns the size of the write buffer of the connections, it holds flushEveryBytes bytes at least.
+func (c *Client) writeBufferSize() int {
+	return max(defaultWriteBufferSize, c.flushEveryBytes)
+}
+
+// newBatch allocates opaques on the connection for the quiet requests with opcode for keys,
+// and for the NOOP closing the batch.
+func (cn *conn) newBatch(opcode OpCode, keys []string) batch {
+	b := batch{
+		opcode: opcode,
+		keys:   keys,
+		first:  cn.opaque + 1,
+	}
+	cn.opaque += uint32(len(keys)) + 1
+	return b
+}
+
+// newMixedBatch allocates opaques on the connection for the requests with different opcodes,
+// keys[i] is sent with opcodes[i].
+func (cn *conn) newMixedBatch(opcodes []OpCode, keys []string) batch {
+	b := cn.newBatch(0, keys)
+	b.opcodes = opcodes
+	return b
+}
+
+// batch is a set of quiet requests sent on one connection. keys[i] is sent with the opaque first+i
+// and the closing NOOP with the opaque first+len(keys), so responses are matched by index
+// and can't be confused with responses of other batches.
+type batch struct {
+	opcode OpCode
+	// opcodes are the opcodes of the requests by index, if they are different, then opcode is not used.
+	opcodes []OpCode
+	keys    []string
+	first   uint32
+}
+
+func (b batch) opaque(i int) uint32 {
+	return b.first + uint32(i)
+}
+
+func (b batch) noopOpaque() uint32 {
+	return b.opaque(len(b.keys))
+}
+
+// index returns the index of the key of resp, resp must be matched by match.
+func (b batch) index(resp *Response) int {
+	return int(resp.Opaque - b.first)
+}
+
+func (b batch) opcodeOf(i int) OpCode {
+	if b.opcodes != nil {
+		return b.opcodes[i]
+	}
+	return b.opcode
+}
+
+// match returns the key of the resp, done is true for the NOOP closing the batch.
+// An error means the response doesn't belong to the batch and the connection stream is out of sync.
+// The successful responses of GETKQ must contain the key of the request as well.
+func (b batch) match(resp *Response) (key string, done bool, err error) {
+	if resp.Opcode == NOOP && resp.Opaque == b.noopOpaque() {
+		return "", true, nil
+	}
+	if i := resp.Opaque - b.first; i < uint32(len(b.keys)) && resp.Opcode == b.opcodeOf(int(i)) {
+		key = b.keys[i]
+		if b.opcode == GETKQ && resp.Status == SUCCESS && string(resp.Key) != key {
+			return "", false, fmt.Errorf("%w: key %q of the response with opaque %d, expected %q",
+				ErrProtocolDesync, resp.Key, resp.Opaque, key)
+		}
+		return key, false, nil
+	}
+	return "", false, unexpectedResponse(resp)
 }
 
-func (c *Client) safeGetFreeConn(addr net.Addr) (*pool.Pool, bool) {
+func (c *Client) safeGetFreeConn(addr net.Addr) (*pool.Pool[*conn], bool) {
 	c.fmu.RLock()
 	defer c.fmu.RUnlock()
 	connPool, ok := c.freeConns[addr.String()]
 	return connPool, ok
 }
 
-func (c *Client) safeGetOrInitFreeConn(addr net.Addr) *pool.Pool {
+func (c *Client) safeGetOrInitFreeConn(addr net.Addr) *pool.Pool[*conn] {
 	c.fmu.Lock()
 	defer c.fmu.Unlock()
 
@@ -276,35 +772,73 @@ func (c *Client) safeGetOrInitFreeConn(addr net.Addr) *pool.Pool {
 		return connPool
 	}
 
-	dialConn := func() (any, error) {
+	var newPool *pool.Pool[*conn]
+	dialConn := func() (*conn, error) {
 		nc, err := c.dial(addr)
 		if err != nil {
 			return nil, err
 		}
-		return &conn{
-			rc:      nc,
+		tc := newTimeoutConn(nc, c.getReadTimeout(), c.getWriteTimeout())
+		cn := &conn{
+			rc:      tc,
 			addr:    addr,
 			c:       c,
 			hdrBuf:  make([]byte, HDR_LEN),
-			wrtBuf:  bufio.NewWriter(nc),
+			wrtBuf:  bufio.NewWriterSize(tc, c.writeBufferSize()),
 			healthy: true,
-		}, nil
+			pool:    newPool,
+		}
+		if c.authEnable {
+			if err = c.authenticate(cn); err != nil {
+				_ = nc.Close()
+				return nil, err
+			}
+		}
+		return cn, nil
 	}
 
-	closeConn := func(cn any) {
-		_ = cn.(*conn).rc.Close()
+	closeConn := func(cn *conn) {
+		if cn.healthy {
+			cn.quit()
+		}
+		_ = cn.rc.Close()
 	}
 
-	newPool := pool.New(c.ctx, int32(c.getMaxIdleConns()), DefaultSocketPoolingTimeout, dialConn, closeConn)
+	newPool = pool.New(c.ctx, int32(c.getMaxIdleConns()), DefaultSocketPoolingTimeout, dialConn, closeConn,
+		c.poolOptions(addr)...)
 
 	if c.freeConns == nil {
-		c.freeConns = make(map[string]*pool.Pool)
+		c.freeConns = make(map[string]*pool.Pool[*conn])
 	}
 	c.freeConns[addr.String()] = newPool
 
 	return newPool
 }
 
+// poolOptions returns options of the pool for the node with addr.
+func (c *Client) poolOptions(addr net.Addr) []pool.Option[*conn] {
+	opts := []pool.Option[*conn]{
+		pool.WithMaxConnLifetime[*conn](c.maxConnLifetime),
+		pool.WithMaxIdleTime[*conn](c.maxConnIdleTime),
+		pool.WithMinIdle[*conn](int32(c.minIdleConns)),
+	}
+	if c.disableMemcachedDiagnostic {
+		return opts
+	}
+
+	node := addr.String()
+	return append(opts,
+		pool.WithOnCreate(func(*conn) {
+			observePoolConnection(node, connCreatedEvent)
+			c.getLogger().Debugf("%s: New connection to %s", libPrefix, node)
+		}),
+		pool.WithOnClose(func(*conn) {
+			observePoolConnection(node, connClosedEvent)
+			c.getLogger().Debugf("%s: Connection to %s closed", libPrefix, node)
+		}),
+	)
+}
+
 func (c *Client) freeConnsIsNil() bool {
 	c.fmu.RLock()
 	defer c.fmu.RUnlock()
@@ -312,6 +846,11 @@ func (c *Client) freeConnsIsNil() bool {
 }
 
 func (c *Client) putFreeConn(cn *conn) {
+	if cn.pool != nil {
+		// the destroyed pool closes the connection
+		cn.pool.Put(cn)
+		return
+	}
 	connPool, ok := c.safeGetFreeConn(cn.addr)
 	if ok {
 		connPool.Put(cn)
@@ -321,28 +860,49 @@ func (c *Client) putFreeConn(cn *conn) {
 }
 
 func (c *Client) getFreeConn(addr net.Addr) (*conn, error) {
+	if c.closed.Load() {
+		return nil, ErrClientClosed
+	}
+
 	connPool := c.safeGetOrInitFreeConn(addr)
 
-	connRaw, err := connPool.Get()
-	if err != nil {
-		return nil, fmt.Errorf("%s: Get from pool error - %w", libPrefix, err)
-	}
+	ctx, cancel := context.WithTimeout(c.ctx, DefaultSocketPoolingTimeout)
+	defer cancel()
 
-	cn := connRaw.(*conn)
+	var (
+		cn  *conn
+		err error
+	)
+	for {
+		cn, err = connPool.GetContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: Get from pool error - %w", libPrefix, err)
+		}
 
-	if c.authEnable && !cn.authed {
-		if c.authenticate(cn) {
-			cn.authed = true
-			return cn, nil
-		} else {
-			return nil, ErrAuthFail
+		if !c.needValidation(cn) {
+			break
+		}
+		if err = cn.ping(DefaultConnValidationTimeout); err == nil {
+			break
 		}
+		c.nodeLogger(addr).Warnf("%s: Idle connection to %s is broken, closed - %s", libPrefix, addr.String(), err.Error())
+		cn.healthy = false
+		cn.close()
 	}
 
-	return connRaw.(*conn), nil
+	return cn, nil
+}
+
+// needValidation reports whether cn was idle in the pool long enough to be checked before use.
+func (c *Client) needValidation(cn *conn) bool {
+	return c.connValidationIdle > 0 && !cn.lastUsed.IsZero() && time.Since(cn.lastUsed) > c.connValidationIdle
 }
 
 func (c *Client) removeFromFreeConns(addr net.Addr) {
+	c.closeMuxConns(addr)
+	c.closeTextConns(addr)
+	c.removeLimiter(addr)
+	c.removeGetBatcher(addr)
 	if c.freeConnsIsNil() {
 		return
 	}
@@ -363,6 +923,53 @@ func (c *Client) netTimeout() time.Duration {
 	return DefaultTimeout
 }
 
+func (c *Client) getDialTimeout() time.Duration {
+	if c.dialTimeout > 0 {
+		return c.dialTimeout
+	}
+	return c.netTimeout()
+}
+
+func (c *Client) getReadTimeout() time.Duration {
+	if c.readTimeout > 0 {
+		return c.readTimeout
+	}
+	return c.netTimeout()
+}
+
+func (c *Client) getWriteTimeout() time.Duration {
+	if c.writeTimeout > 0 {
+		return c.writeTimeout
+	}
+	return c.netTimeout()
+}
+
+func (c *Client) getLogger() logger.Logger {
+	switch {
+	case logger.LoggerIsDisable():
+		return logger.Nop()
+	case c.logger != nil:
+		return c.logger
+	default:
+		return logger.Default()
+	}
+}
+
+// nodeLogger returns the logger of the client with the field of the node.
+func (c *Client) nodeLogger(node any) logger.Logger {
+	return logger.With(c.getLogger(), "node", utils.Repr(node))
+}
+
+// opLogger returns the logger of the client with the fields of the operation: the node, the opcode
+// and the hash of the key, if it is not empty, so the key itself is not written to the logs.
+func (c *Client) opLogger(node any, opcode OpCode, key string) logger.Logger {
+	args := []any{"node", utils.Repr(node), "opcode", opcode.String()}
+	if key != "" {
+		args = append(args, "key_hash", strconv.FormatUint(consistenthash.Hash([]byte(key)), 16))
+	}
+	return logger.With(c.getLogger(), args...)
+}
+
 func (c *Client) getMaxIdleConns() int {
 	if c.maxIdleConns > 0 {
 		return c.maxIdleConns
@@ -370,6 +977,51 @@ func (c *Client) getMaxIdleConns() int {
 	return DefaultMaxIdleConns
 }
 
+func (c *Client) getMethodDurationSeconds() *prometheus.HistogramVec {
+	if c.methodDurationSeconds != nil {
+		return c.methodDurationSeconds
+	}
+	return methodDurationSeconds
+}
+
+func (c *Client) getMaxBodyLen() int {
+	if c.maxBodyLen > 0 {
+		return c.maxBodyLen
+	}
+	return MaxBodyLen
+}
+
+// getRecvBodyLen returns the maximum size of the received body: the larger of MaxBodyLen and WithMaxBodyLen,
+// so the values stored by the client are read back, as well as the ones of the clients with the default limit.
+func (c *Client) getRecvBodyLen() int {
+	return max(c.getMaxBodyLen(), MaxBodyLen)
+}
+
+// checkBodyLen returns ErrDataSizeExceedsLimit, if body for key is larger than the client allows.
+func (c *Client) checkBodyLen(key string, body []byte) error {
+	if maxLen := c.getMaxBodyLen(); len(body) > maxLen {
+		return fmt.Errorf("%w: %d bytes for key %s (max %d)", ErrDataSizeExceedsLimit, len(body), key, maxLen)
+	}
+	return nil
+}
+
+func (c *Client) getConnRetryCount() uint8 {
+	if c.connRetryCount != nil {
+		return *c.connRetryCount
+	}
+	return DefaultRetryCountForConn
+}
+
+func (c *Client) getConnsRefreshPerPeriod() int {
+	if c.disableRefreshConns {
+		return 0
+	}
+	if c.connsRefreshPerPeriod != nil {
+		return max(*c.connsRefreshPerPeriod, 0)
+	}
+	return DefaultOfNumberConnsToDestroyPerRBPeriod
+}
+
 func (c *Client) getHCPeriod() time.Duration {
 	if c.nodeHCPeriod > 0 {
 		return c.nodeHCPeriod
@@ -396,8 +1048,12 @@ func (cte *ConnectTimeoutError) Error() string {
 }
 
 func (c *Client) dial(addr net.Addr) (net.Conn, error) {
-	if c.netTimeout() > 0 {
-		nc, err := c.nw.dialTimeout(addr.Network(), addr.String(), c.netTimeout())
+	var (
+		nc  net.Conn
+		err error
+	)
+	if c.getDialTimeout() > 0 {
+		nc, err = c.nw.dialTimeout(addr.Network(), addr.String(), c.getDialTimeout())
 		if err != nil {
 			var ne net.Error
 			if errors.As(err, &ne) && ne.Timeout() {
@@ -405,9 +1061,62 @@ func (c *Client) dial(addr net.Addr) (net.Conn, error) {
 			}
 			return nil, err
 		}
-		return nc, nil
+	} else {
+		nc, err = c.nw.dial(addr.Network(), addr.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = c.setSocketOptions(nc); err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	if c.proxyProtocolFor(addr) {
+		if err = c.sendProxyHeader(nc); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	if c.wireLogging {
+		nc = newWireConn(nc, c.nodeLogger(addr), c.wireLoggingBodies)
+	}
+	if c.trafficRecorder != nil {
+		nc = newCaptureConn(nc, c.trafficRecorder, addr.String())
+	}
+	return nc, nil
+}
+
+// setSocketOptions applies TCP options of the client to nc, connections of other types are left as is.
+func (c *Client) setSocketOptions(nc net.Conn) error {
+	tc, ok := nc.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if c.tcpKeepAlive < 0 {
+		if err := tc.SetKeepAlive(false); err != nil {
+			return fmt.Errorf("%s: set keep-alive error - %w", libPrefix, err)
+		}
+	} else if c.tcpKeepAlive > 0 {
+		if err := tc.SetKeepAlive(true); err != nil {
+			return fmt.Errorf("%s: set keep-alive error - %w", libPrefix, err)
+		}
+		if err := tc.SetKeepAlivePeriod(c.tcpKeepAlive); err != nil {
+			return fmt.Errorf("%s: set keep-alive period error - %w", libPrefix, err)
+		}
+	}
+	if c.disableTCPNoDelay {
+		if err := tc.SetNoDelay(false); err != nil {
+			return fmt.Errorf("%s: set no delay error - %w", libPrefix, err)
+		}
 	}
-	return c.nw.dial(addr.Network(), addr.String())
+	if c.tcpLinger != nil {
+		if err := tc.SetLinger(*c.tcpLinger); err != nil {
+			return fmt.Errorf("%s: set linger error - %w", libPrefix, err)
+		}
+	}
+	return nil
 }
 
 func (c *Client) getConnForNode(node any) (*conn, error) {
@@ -424,7 +1133,7 @@ func (c *Client) getConnForNode(node any) (*conn, error) {
 }
 
 // Store is a wrote the provided item with expiration.
-func (c *Client) Store(storeMode StoreMode, key string, exp uint32, body []byte) (_ *Response, err error) {
+func (c *Client) Store(storeMode StoreMode, key string, exp uint32, body []byte, opts ...CallOption) (_ *Response, err error) {
 	timer := time.Now()
 	defer c.writeMethodDiagnostics("Store", timer, &err)
 
@@ -432,32 +1141,63 @@ func (c *Client) Store(storeMode StoreMode, key string, exp uint32, body []byte)
 		return nil, ErrMalformedKey
 	}
 
-	node, find := c.hr.Get(key)
-	if !find {
-		return nil, ErrNoServers
+	co := c.resolveCallOptions(MethodStore, opts...)
+	if c.keyProvider != nil {
+		if co.Flags != 0 {
+			return nil, fmt.Errorf("%w: flags are used by the encryption", ErrInvalidArguments)
+		}
+		if co.Flags, body, err = c.encryptValue(key, body); err != nil {
+			return nil, err
+		}
+	}
+	if err = c.checkBodyLen(key, body); err != nil {
+		return nil, err
 	}
 
-	cn, err := c.getConnForNode(node)
+	node, err := c.nodeForKey(key, &co)
 	if err != nil {
 		return nil, err
 	}
-	return c.store(cn, storeMode.Resolve(), key, exp, c.getOpaque(), body)
-}
+	defer c.invalidateLocal(c.onLocalWrite, key)
 
-func (c *Client) store(cn *conn, opcode OpCode, key string, exp, opaque uint32, body []byte) (*Response, error) {
 	req := &Request{
-		Opcode: opcode,
+		Opcode: storeMode.Resolve(),
 		Key:    []byte(key),
-		Opaque: opaque,
 		Body:   body,
 	}
 	req.prepareExtras(exp, 0, 0)
-	return c.send(cn, req)
+	co.prepareRequest(req)
+
+	resp, err := c.sendToNode(node, req, co.Timeout)
+	co.readResponse(resp, err)
+	return resp, err
 }
 
-func (c *Client) send(cn *conn, req *Request) (resp *Response, err error) {
+// send sends req by cn, the timeout overrides the read and write timeouts of cn, if it is not zero,
+// the deadline limits the whole exchange, if it is not zero.
+// If the server answers AUTHFAIL on the authenticated connection (e.g. the server was restarted and lost
+// the session), the connection is authenticated again and req is sent once more.
+func (c *Client) send(cn *conn, req *Request, timeout time.Duration, deadline time.Time) (resp *Response, err error) {
 	defer cn.condRelease(&err)
-	_, err = transmitRequest(cn.wrtBuf, req)
+	defer cn.setTimeout(timeout)()
+	defer cn.setDeadline(deadline)()
+
+	resp, err = c.exchange(cn, req)
+	if c.authEnable && StatusOf(err) == AUTHFAIL {
+		c.opLogger(cn.addr, req.Opcode, string(req.Key)).Warnf("%s: session is lost, connection is authenticated again", libPrefix)
+		if err = c.authenticate(cn); err != nil {
+			cn.healthy = false
+			return nil, err
+		}
+		req.Opaque = cn.nextOpaque()
+		resp, err = c.exchange(cn, req)
+	}
+	return resp, err
+}
+
+// exchange writes req to cn and reads its response.
+func (c *Client) exchange(cn *conn, req *Request) (resp *Response, err error) {
+	_, err = cn.transmit(req)
 	if err != nil {
 		cn.healthy = false
 		return
@@ -467,73 +1207,225 @@ func (c *Client) send(cn *conn, req *Request) (resp *Response, err error) {
 		return nil, err
 	}
 
-	resp, _, err = getResponse(cn.rc, cn.hdrBuf)
+	resp, _, err = cn.receive()
+	// status errors are received responses too, they must match the request as well.
+	if err == nil || UnwrapMemcachedError(err) != nil {
+		if cErr := checkResponse(resp, req.Opcode, req.Opaque); cErr != nil {
+			cn.healthy = false
+			c.opLogger(cn.addr, req.Opcode, string(req.Key)).Warnf("%s: %s, connection is closed", libPrefix, cErr.Error())
+			return nil, cErr
+		}
+	}
 	cn.healthy = !isFatal(err)
 	return resp, err
 }
 
+// StoreWithCAS is a wrote the provided item with expiration, if its CAS identifier is equal to cas.
+// Zero cas is not checked. ErrCASConflict is returned, if the item was changed, and ErrCacheMiss, if it is missing.
+func (c *Client) StoreWithCAS(storeMode StoreMode, key string, exp uint32, cas uint64, body []byte, opts ...CallOption) (*Response, error) {
+	resp, err := c.Store(storeMode, key, exp, body, append(slices.Clip(opts), WithCAS(cas))...)
+	if cas != 0 && resp != nil && resp.Status == KEY_EEXISTS {
+		return resp, casConflict(resp)
+	}
+	return resp, err
+}
+
 // Get is return an item for provided key.
-func (c *Client) Get(key string) (_ *Response, err error) {
+func (c *Client) Get(key string, opts ...CallOption) (_ *Response, err error) {
 	timer := time.Now()
 	defer c.writeMethodDiagnostics("Get", timer, &err)
 
+	resp, err := c.getWithOptions(key, opts)
+	c.writeGetKeys("Get", err)
+	return resp, err
+}
+
+// Lookup is return the value for provided key and whether it is found.
+// Unlike Get, the cache miss is not an error, so the callers don't need errors.Is(err, ErrCacheMiss),
+// and the miss is counted as a successful call by the method metrics.
+func (c *Client) Lookup(key string, opts ...CallOption) (value []byte, found bool, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Lookup", timer, &err)
+
+	resp, err := c.getWithOptions(key, opts)
+	c.writeGetKeys("Lookup", err)
+	switch {
+	case err == nil:
+		return resp.Body, true, nil
+	case errors.Is(err, ErrCacheMiss):
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+func (c *Client) getWithOptions(key string, opts []CallOption) (resp *Response, err error) {
 	if !legalKey(key) {
 		return nil, ErrMalformedKey
 	}
 
-	node, find := c.hr.Get(key)
+	co := c.resolveCallOptions(MethodGet, opts...)
+	switch {
+	case co.RouteTo != "":
+		// the routed call asks the node itself, neither the local cache nor the hedging are used.
+		var node any
+		if node, err = c.nodeByAddr(co.RouteTo); err != nil {
+			return nil, err
+		}
+		resp, err = c.getFromNode(node, key, co.Timeout)
+	case c.localCache != nil:
+		resp, err = c.localGet(key, co.Timeout)
+	default:
+		resp, err = c.get(key, co.Timeout)
+	}
+	co.readResponse(resp, err)
+	return resp, err
+}
+
+func (c *Client) get(key string, timeout time.Duration) (*Response, error) {
+	if c.hedgingDelay > 0 {
+		return c.hedgedGet(key, timeout)
+	}
+
+	node, find := c.hr.Get(c.routingKey(key))
 	if !find {
 		return nil, ErrNoServers
 	}
 
-	cn, err := c.getConnForNode(node)
-	if err != nil {
-		return nil, err
+	if c.getBatchWindow > 0 {
+		return c.batchedGet(node, key, timeout)
 	}
+	return c.getFromNode(node, key, timeout)
+}
 
+func (c *Client) getFromNode(node any, key string, timeout time.Duration) (*Response, error) {
 	req := &Request{
 		Opcode: GET,
-		Opaque: c.getOpaque(),
 		Key:    []byte(key),
 	}
 	req.prepareExtras(0, 0, 0)
 
-	return c.send(cn, req)
-}
-
-// Delete is a deletes the element with the provided key.
-// If the element does not exist, an ErrCacheMiss error is returned.
-func (c *Client) Delete(key string) (_ *Response, err error) {
-	timer := time.Now()
-	defer c.writeMethodDiagnostics("Delete", timer, &err)
-
-	if !legalKey(key) {
-		return nil, ErrMalformedKey
+	resp, err := c.sendToNode(node, req, timeout)
+	if err == nil {
+		err = c.decryptResponse(key, resp)
 	}
+	return resp, err
+}
 
-	node, find := c.hr.Get(key)
+// hedgedGet sends Get to the node of the key, and if it doesn't answer within c.hedgingDelay,
+// sends the same Get to the next node of the ring. Returns the first successful response,
+// or the response of the node of the key if none of them succeeded.
+func (c *Client) hedgedGet(key string, timeout time.Duration) (*Response, error) {
+	nodes, find := c.hr.GetN(c.routingKey(key), 2)
 	if !find {
 		return nil, ErrNoServers
 	}
 
-	cn, err := c.getConnForNode(node)
-	if err != nil {
-		return nil, err
+	type result struct {
+		resp    *Response
+		err     error
+		primary bool
 	}
 
+	results := make(chan result, len(nodes))
+	get := func(node any, primary bool) {
+		resp, err := c.getFromNode(node, key, timeout)
+		results <- result{resp: resp, err: err, primary: primary}
+	}
+
+	go get(nodes[0], true)
+
+	timer := time.NewTimer(c.hedgingDelay)
+	defer timer.Stop()
+
+	var (
+		pending = 1
+		primary result
+	)
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if len(nodes) > 1 && c.allowRetry() {
+				pending++
+				go get(nodes[1], false)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.resp, nil
+			}
+			if res.primary {
+				primary = res
+			}
+		}
+	}
+
+	return primary.resp, primary.err
+}
+
+// Delete is a deletes the element with the provided key.
+// If the element does not exist, an ErrCacheMiss error is returned.
+func (c *Client) Delete(key string, opts ...CallOption) (_ *Response, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Delete", timer, &err)
+
+	if !legalKey(key) {
+		return nil, ErrMalformedKey
+	}
+
+	co := c.resolveCallOptions(MethodDelete, opts...)
+	node, err := c.nodeForKey(key, &co)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.invalidateLocal(c.onLocalDelete, key)
+
 	req := &Request{
 		Opcode: DELETE,
-		Opaque: c.getOpaque(),
 		Key:    []byte(key),
 	}
 	req.prepareExtras(0, 0, 0)
+	co.prepareRequest(req)
 
-	return c.send(cn, req)
+	resp, err := c.sendToNode(node, req, co.Timeout)
+	co.readResponse(resp, err)
+	return resp, err
+}
+
+// Touch is a changes the expiration of the element with the provided key without fetching it.
+// If the element does not exist, an ErrCacheMiss error is returned.
+func (c *Client) Touch(key string, exp uint32, opts ...CallOption) (_ *Response, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Touch", timer, &err)
+
+	if !legalKey(key) {
+		return nil, ErrMalformedKey
+	}
+
+	co := c.resolveCallOptions(MethodTouch, opts...)
+	node, err := c.nodeForKey(key, &co)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{
+		Opcode: TOUCH,
+		Key:    []byte(key),
+	}
+	req.prepareExtras(exp, 0, 0)
+	co.prepareRequest(req)
+
+	resp, err := c.sendToNode(node, req, co.Timeout)
+	co.readResponse(resp, err)
+	return resp, err
 }
 
 // Delta is an atomically increments/decrements value by delta. The return value is
 // the new value after being incremented/decrements or an error.
-func (c *Client) Delta(deltaMode DeltaMode, key string, delta, initial uint64, exp uint32) (newValue uint64, err error) {
+// The missing item is created with the initial value, unless WithoutCreate or exp DeltaNoCreate is used.
+// The CAS identifier of the counter is returned by WithCASOut.
+func (c *Client) Delta(deltaMode DeltaMode, key string, delta, initial uint64, exp uint32, opts ...CallOption) (newValue uint64, err error) {
 	timer := time.Now()
 	defer c.writeMethodDiagnostics("Delta", timer, &err)
 
@@ -541,23 +1433,23 @@ func (c *Client) Delta(deltaMode DeltaMode, key string, delta, initial uint64, e
 		return 0, ErrMalformedKey
 	}
 
-	node, find := c.hr.Get(key)
-	if !find {
-		return 0, ErrNoServers
-	}
-
-	cn, err := c.getConnForNode(node)
+	co := c.resolveCallOptions(MethodDelta, opts...)
+	node, err := c.nodeForKey(key, &co)
 	if err != nil {
 		return 0, err
 	}
 
+	defer c.invalidateLocal(c.onLocalWrite, key)
+
 	req := &Request{
 		Opcode: deltaMode.Resolve(),
 		Key:    []byte(key),
 	}
 	req.prepareExtras(exp, delta, initial)
+	co.prepareRequest(req)
 
-	resp, err := c.send(cn, req)
+	resp, err := c.sendToNode(node, req, co.Timeout)
+	co.readResponse(resp, err)
 	if err != nil {
 		return 0, err
 	}
@@ -567,104 +1459,94 @@ func (c *Client) Delta(deltaMode DeltaMode, key string, delta, initial uint64, e
 
 // Append is an appends/prepends the given item to the existing item, if a value already
 // exists for its key. ErrNotStored is returned if that condition is not met.
-func (c *Client) Append(appendMode AppendMode, key string, data []byte) (_ *Response, err error) {
+func (c *Client) Append(appendMode AppendMode, key string, data []byte, opts ...CallOption) (_ *Response, err error) {
 	timer := time.Now()
 	defer c.writeMethodDiagnostics("Append", timer, &err)
 
 	if !legalKey(key) {
 		return nil, ErrMalformedKey
 	}
-
-	node, find := c.hr.Get(key)
-	if !find {
-		return nil, ErrNoServers
+	if err = c.checkEncryptedAppend(); err != nil {
+		return nil, err
+	}
+	if err = c.checkBodyLen(key, data); err != nil {
+		return nil, err
 	}
 
-	cn, err := c.getConnForNode(node)
+	co := c.resolveCallOptions(MethodAppend, opts...)
+	node, err := c.nodeForKey(key, &co)
 	if err != nil {
 		return nil, err
 	}
 
+	defer c.invalidateLocal(c.onLocalWrite, key)
+
 	req := &Request{
 		Opcode: appendMode.Resolve(),
-		Opaque: c.getOpaque(),
 		Key:    []byte(key),
 		Body:   data,
 	}
 	req.prepareExtras(0, 0, 0)
+	co.prepareRequest(req)
 
-	return c.send(cn, req)
+	resp, err := c.sendToNode(node, req, co.Timeout)
+	co.readResponse(resp, err)
+	return resp, err
 }
 
 // FlushAll is a deletes all items in the cache.
-func (c *Client) FlushAll(exp uint32) (err error) {
+// The flush can be staggered between the nodes, limited to some nodes and planned without flushing, see FlushOption.
+func (c *Client) FlushAll(exp uint32, opts ...FlushOption) (err error) {
 	timerMethod := time.Now()
 	defer c.writeMethodDiagnostics("FlushAll", timerMethod, &err)
 
-	var (
-		wg       sync.WaitGroup
-		mu       sync.Mutex
-		multiErr error
-
-		nodes = c.hr.GetAllNodes()
-	)
-
-	addToMultiErr := func(e error) {
-		mu.Lock()
-		defer mu.Unlock()
-		multiErr = errors.Join(multiErr, e)
+	fo := ResolveFlushOptions(opts...)
+	targets, err := c.flushPlan(exp, &fo)
+	if err != nil {
+		return err
 	}
-
-	for _, node := range nodes {
-		wg.Add(1)
-		go func(node any) {
-			defer wg.Done()
-
-			var cn *conn
-			cn, err = c.getConnForNode(node)
-			if err != nil {
-				addToMultiErr(err)
-				return
-			}
-			defer cn.condRelease(&err)
-
-			req := &Request{
-				Opcode: FLUSH,
-			}
-			req.prepareExtras(exp, 0, 0)
-
-			_, err = transmitRequest(cn.wrtBuf, req)
-			if err != nil {
-				cn.healthy = false
-				return
-			}
-
-			if err = cn.wrtBuf.Flush(); err != nil {
-				logger.Errorf("%s. %s", ErrServerError.Error(), err.Error())
-				return
-			}
-
-			_, _, err = getResponse(cn.rc, cn.hdrBuf)
-			if err != nil {
-				if isFatal(err) {
-					cn.healthy = false
-					return
-				}
-				addToMultiErr(err)
-			}
-		}(node)
+	if fo.DryRun != nil {
+		steps := make([]FlushStep, 0, len(targets))
+		for _, t := range targets {
+			steps = append(steps, t.FlushStep)
+		}
+		*fo.DryRun = steps
+		return nil
 	}
 
-	wg.Wait()
+	// the items are removed from the local cache at once, even if the flush is delayed by exp.
+	defer c.invalidateLocal(c.onLocalDelete)
 
-	return multiErr
+	nodes := make([]any, 0, len(targets))
+	for _, t := range targets {
+		nodes = append(nodes, t.node)
+	}
+	_, err = c.broadcast(nodes, func(i int) *Request {
+		req := &Request{
+			Opcode: FLUSH,
+		}
+		req.prepareExtras(targets[i].Exp, 0, 0)
+		return req
+	})
+	return err
 }
 
+// MissHook is called with the keys missed by MultiGet, see WithOnMultiGetMiss.
+type MissHook func(keys []string)
+
 // MultiGet is a batch version of Get. The returned map from keys to
 // items may have fewer elements than the input slice, due to memcached
 // cache misses. Each key must be at most 250 bytes in length.
 // If no error is returned, the returned map will also be non-nil.
-func (c *Client) MultiGet(keys []string) (_ map[string][]byte, err error) {
+// If some of the nodes failed, the values of the other nodes are returned with *MultiGetError,
+// that has the failed nodes and their keys, so they can be told from the cache misses.
+func (c *Client) MultiGet(keys []string) (map[string][]byte, error) {
+	return c.MultiGetContext(context.Background(), keys)
+}
+
+// MultiGetContext is MultiGet, that stops waiting for the responses, when ctx is done.
+// The connections of the unfinished batches are closed, their nodes fail with the error of ctx.
+func (c *Client) MultiGetContext(ctx context.Context, keys []string) (_ map[string][]byte, err error) {
 	var (
 		wg sync.WaitGroup
 		mu sync.Mutex
@@ -678,24 +1560,40 @@ func (c *Client) MultiGet(keys []string) (_ map[string][]byte, err error) {
 	timerMethod := time.Now()
 	defer c.writeMethodDiagnostics("MultiGet", timerMethod, &err)
 
-	if len(keys) == 1 {
-		var res *Response
-		res, err = c.Get(keys[0])
-		if res != nil {
-			if res.Status == SUCCESS {
-				ret[keys[0]] = res.Body
-			} else if res.Status == KEY_ENOENT {
-				// MultiGet never returns a ENOENT
-				err = nil
-			}
-		}
+	if err = ctx.Err(); err != nil {
 		return ret, err
 	}
 
+	// the single key is got without the batch, if ctx can't be done, otherwise by the batch, that stops on ctx.
+	if len(keys) == 1 && ctx.Done() == nil {
+		return c.multiGetOne(keys[0])
+	}
+
+	if c.localCache != nil {
+		gen := c.localCache.generation()
+		requested := len(keys)
+		keys = c.localMultiGet(keys, ret)
+		c.writeMethodKeys("MultiGet", requested-len(keys), 0)
+		if len(keys) == 0 {
+			return ret, nil
+		}
+		defer c.localMultiPut(keys, ret, gen)
+	}
+
 	var (
-		once        sync.Once
-		singleError error
+		// failed are the nodes with errors, missed are the keys of the other nodes, that are not returned by them.
+		failed []NodeKeysError
+		missed []string
 	)
+	defer func() {
+		c.onMultiGetMissed(missed)
+	}()
+
+	fail := func(node any, keys []string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, NodeKeysError{Addr: utils.Repr(node), Keys: keys, Err: err})
+	}
 
 	addToRet := func(key string, body []byte) {
 		mu.Lock()
@@ -703,92 +1601,215 @@ func (c *Client) MultiGet(keys []string) (_ map[string][]byte, err error) {
 		ret[key] = body
 	}
 
-	nodes, err := getNodesForKeys(c.hr, keys)
+	nodes, err := c.getNodesForKeys(keys)
 	if err != nil {
 		return ret, err
 	}
 
-	for node, ks := range nodes {
-		wg.Add(1)
-		go func(node any, keys []string) {
-			defer wg.Done()
+	// GETKQ returns the key with the value, so the value can't be given to another key on a desynced connection.
+	opcode := GETQ
+	if c.verifyKeys {
+		opcode = GETKQ
+	}
 
-			var cnErr error
+	// getBatch gets the keys from the node, the misses are the keys of the batch without responses.
+	getBatch := func(node any, keys []string) {
+		defer wg.Done()
 
-			cn, nErr := c.getConnForNode(node)
-			if nErr != nil {
-				once.Do(func() {
-					singleError = nErr
-				})
-				return
-			}
-			defer cn.condRelease(&cnErr)
+		var cnErr error
 
-			idToKey := make(map[uint32]string, len(keys))
-
-			for _, key := range keys {
-				opaqueGet := c.getOpaque()
-				req := &Request{
-					Opcode: GETQ,
-					Opaque: opaqueGet,
-					Key:    []byte(key),
-				}
-				req.prepareExtras(0, 0, 0)
+		if fErr := c.injectFault(node, opcode); fErr != nil {
+			// MultiGet never returns a ENOENT
+			if !errors.Is(fErr, ErrCacheMiss) {
+				fail(node, keys, fErr)
+			}
+			return
+		}
 
-				_, cnErr = transmitRequest(cn.wrtBuf, req)
-				if cnErr != nil {
-					cn.healthy = false
-					return
-				}
+		release, nErr := c.acquireNode(node, 0)
+		if nErr != nil {
+			fail(node, keys, nErr)
+			return
+		}
+		defer release()
 
-				idToKey[opaqueGet] = key
-			}
+		cn, nErr := c.getConnForNode(node)
+		if nErr != nil {
+			fail(node, keys, nErr)
+			return
+		}
+		defer cn.condRelease(&cnErr)
+		defer cn.closeOnDone(ctx)()
+		defer cn.setTimeout(c.methodTimeouts[MethodMultiGet])()
 
-			opaqueNOOP := c.getOpaque()
+		b := cn.newBatch(opcode, keys)
+		cnErr = cn.writeBatch(b, func(i int) *Request {
+			key := keys[i]
 			req := &Request{
-				Opcode: NOOP,
-				Opaque: opaqueNOOP,
+				Opcode: opcode,
+				Key:    []byte(key),
 			}
 			req.prepareExtras(0, 0, 0)
+			return req
+		})
+		if cnErr != nil {
+			fail(node, keys, ctxErr(ctx, cnErr))
+			return
+		}
 
-			_, cnErr = transmitRequest(cn.wrtBuf, req)
-			if cnErr != nil {
+		// GETQ doesn't respond on the cache miss, so the misses are the keys of the batch without responses.
+		var (
+			answered = make([]bool, len(keys))
+			hits     int
+		)
+		// notFound returns the keys of the batch without responses.
+		notFound := func() []string {
+			var ks []string
+			for i, key := range keys {
+				if !answered[i] {
+					ks = append(ks, key)
+				}
+			}
+			return ks
+		}
+		for {
+			if cErr := ctx.Err(); cErr != nil {
+				cnErr = cErr
 				cn.healthy = false
+				fail(node, notFound(), cErr)
 				return
 			}
 
-			if cnErr = cn.wrtBuf.Flush(); err != nil {
-				logger.Errorf("%s. %s", ErrServerError.Error(), cnErr.Error())
+			var resp *Response
+			resp, _, cnErr = cn.receivePooled()
+			if isFatal(cnErr) {
+				cn.healthy = false
+				fail(node, notFound(), ctxErr(ctx, cnErr))
 				return
 			}
 
-			for {
-				var resp *Response
-				resp, _, cnErr = getResponse(cn.rc, cn.hdrBuf)
-				if isFatal(cnErr) {
-					cn.healthy = false
-					return
+			key, done, mErr := b.match(resp)
+			if mErr != nil {
+				cnErr = mErr
+				cn.healthy = false
+				fail(node, notFound(), mErr)
+				return
+			}
+			if done {
+				releaseResponse(resp)
+				break
+			}
+			answered[b.index(resp)] = true
+
+			if cnErr == nil {
+				// the buffer of resp is reused, so the value is copied (the decrypted value is a new one)
+				var body []byte
+				if c.keyProvider != nil {
+					body, mErr = c.decryptValue(key, responseFlags(resp), bytes.Clone(resp.Body))
+				} else {
+					body = bytes.Clone(resp.Body)
 				}
-
-				if resp.Opcode == NOOP && resp.Opaque == opaqueNOOP {
-					break
+				releaseResponse(resp)
+				if mErr != nil {
+					// the connection is in sync, only the value is lost
+					fail(node, []string{key}, mErr)
+					continue
 				}
+				addToRet(key, body)
+				hits++
+			}
+		}
 
-				if key, ok := idToKey[resp.Opaque]; ok && cnErr == nil {
-					addToRet(key, resp.Body)
-				}
+		batchMissed := notFound()
+		c.writeMethodKeys("MultiGet", hits, len(batchMissed))
+		mu.Lock()
+		missed = append(missed, batchMissed...)
+		mu.Unlock()
+	}
+
+	// dispatch sends the batches to the nodes and waits for their responses.
+	dispatch := func(nodes map[any][]string) {
+		for node, ks := range nodes {
+			wg.Add(1)
+			node, ks := node, ks
+			c.goBatch(func() { getBatch(node, ks) })
+		}
+		wg.Wait()
+	}
+
+	dispatch(nodes)
+	if len(failed) != 0 && ctx.Err() == nil {
+		var retry map[any][]string
+		if retry, failed = c.redispatch(failed); len(retry) != 0 {
+			dispatch(retry)
+		}
+	}
+
+	if len(failed) != 0 {
+		return ret, &MultiGetError{Nodes: failed}
+	}
+	return ret, nil
+}
+
+// multiGetOne gets the single key of MultiGet without the batch, the failed node is re-dispatched
+// like the nodes of the batches (see redispatch).
+func (c *Client) multiGetOne(key string) (map[string][]byte, error) {
+	ret := make(map[string][]byte, 1)
+
+	node, find := c.hr.Get(c.routingKey(key))
+	res, err := c.getWithOptions(key, nil)
+	if err != nil && !errors.Is(err, ErrCacheMiss) && find && legalKey(key) {
+		failed := []NodeKeysError{{Addr: utils.Repr(node), Keys: []string{key}, Err: err}}
+		var retry map[any][]string
+		if retry, failed = c.redispatch(failed); len(retry) != 0 {
+			for next := range retry {
+				node = next
 			}
-		}(node, ks)
+			res, err = c.getFromNode(node, key, 0)
+		}
+		if len(failed) != 0 {
+			return ret, &MultiGetError{Nodes: failed}
+		}
+	}
+	if res != nil {
+		if res.Status == SUCCESS {
+			ret[key] = res.Body
+		} else if res.Status == KEY_ENOENT {
+			// MultiGet never returns a ENOENT
+			err = nil
+		}
 	}
 
-	wg.Wait()
+	switch {
+	case err != nil:
+		if find && legalKey(key) {
+			err = &MultiGetError{Nodes: []NodeKeysError{{Addr: utils.Repr(node), Keys: []string{key}, Err: err}}}
+		}
+	case len(ret) == 0:
+		c.writeMethodKeys("MultiGet", 0, 1)
+		c.onMultiGetMissed([]string{key})
+	default:
+		c.writeMethodKeys("MultiGet", 1, 0)
+	}
+	return ret, err
+}
 
-	return ret, singleError
+// onMultiGetMissed calls the hook of WithOnMultiGetMiss with the missed keys.
+func (c *Client) onMultiGetMissed(missed []string) {
+	if c.onMultiGetMiss != nil && len(missed) != 0 {
+		c.onMultiGetMiss(missed)
+	}
 }
 
 // MultiStore is a batch version of Store.
 // Writes the provided items with expiration.
-func (c *Client) MultiStore(storeMode StoreMode, items map[string][]byte, exp uint32) (err error) {
+func (c *Client) MultiStore(storeMode StoreMode, items map[string][]byte, exp uint32) error {
+	return c.MultiStoreContext(context.Background(), storeMode, items, exp)
+}
+
+// MultiStoreContext is MultiStore, that stops waiting for the responses, when ctx is done.
+// The connections of the unfinished batches are closed, the items of their nodes may be stored or not.
+func (c *Client) MultiStoreContext(ctx context.Context, storeMode StoreMode, items map[string][]byte, exp uint32) (err error) {
 	if len(items) == 0 {
 		return nil
 	}
@@ -796,6 +1817,60 @@ func (c *Client) MultiStore(storeMode StoreMode, items map[string][]byte, exp ui
 	timerMethod := time.Now()
 	defer c.writeMethodDiagnostics("MultiStore", timerMethod, &err)
 
+	return c.multiStore(ctx, storeMode.Resolve().changeOnQuiet(SETQ), items, nil, exp)
+}
+
+// CASItem is an item of MultiStoreWithCAS.
+type CASItem struct {
+	Value []byte
+	// Cas is the CAS identifier, that the stored item must have, zero means any.
+	Cas uint64
+}
+
+// MultiStoreWithCAS is a batch version of StoreWithCAS.
+// Writes the provided items with expiration, if their CAS identifiers are not changed,
+// ErrCASConflict is returned for the keys of the changed items.
+func (c *Client) MultiStoreWithCAS(storeMode StoreMode, items map[string]CASItem, exp uint32) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+
+	timerMethod := time.Now()
+	defer c.writeMethodDiagnostics("MultiStoreWithCAS", timerMethod, &err)
+
+	values := make(map[string][]byte, len(items))
+	cas := make(map[string]uint64, len(items))
+	for key, it := range items {
+		values[key] = it.Value
+		cas[key] = it.Cas
+	}
+	return c.multiStore(context.Background(), storeMode.Resolve().changeOnQuiet(SETQ), values, cas, exp)
+}
+
+// MultiAppend is a batch version of Append.
+// Appends/prepends the provided data to the existing items, the errors of the missing items
+// (ErrNotStored) are returned for their keys.
+func (c *Client) MultiAppend(appendMode AppendMode, items map[string][]byte) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+
+	timerMethod := time.Now()
+	defer c.writeMethodDiagnostics("MultiAppend", timerMethod, &err)
+
+	if err = c.checkEncryptedAppend(); err != nil {
+		return err
+	}
+	return c.multiStore(context.Background(), appendMode.Resolve().changeOnQuiet(APPENDQ), items, nil, 0)
+}
+
+// multiStore sends the items by the quiet command per node with the closing NOOP.
+// cas contains the CAS identifiers of the items, it may be nil.
+func (c *Client) multiStore(ctx context.Context, quietCode OpCode, items map[string][]byte, cas map[string]uint64, exp uint32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	var (
 		wg       sync.WaitGroup
 		muMErr   sync.Mutex
@@ -815,86 +1890,119 @@ func (c *Client) MultiStore(storeMode StoreMode, items map[string][]byte, exp ui
 		return items[key]
 	}
 
-	quietCode := storeMode.Resolve().changeOnQuiet(SETQ)
+	var flags uint32
+	if c.keyProvider != nil {
+		var err error
+		if flags, items, err = c.encryptItems(items); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(items))
+	for key, body := range items {
+		// too big values are not sent, the server would reject them anyway
+		if lErr := c.checkBodyLen(key, body); lErr != nil {
+			addToMultiErr(lErr)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return multiErr
+	}
+	defer c.invalidateLocal(c.onLocalWrite, keys...)
 
-	keys := maps.Keys(items)
-	nodes, err := getNodesForKeys(c.hr, keys)
+	nodes, err := c.getNodesForKeys(keys)
 	if err != nil {
-		return err
+		return errors.Join(multiErr, err)
 	}
 
 	for node, ks := range nodes {
+		node, keys := node, ks
 		wg.Add(1)
-		go func(node any, keys []string, exp uint32) {
+		c.goBatch(func() {
 			defer wg.Done()
 
 			var cnErr error
 
+			if fErr := c.injectFault(node, quietCode); fErr != nil {
+				addToMultiErr(fErr)
+				return
+			}
+
+			release, nErr := c.acquireNode(node, 0)
+			if nErr != nil {
+				addToMultiErr(nErr)
+				return
+			}
+			defer release()
+
 			cn, nErr := c.getConnForNode(node)
 			if nErr != nil {
 				addToMultiErr(nErr)
 				return
 			}
 			defer cn.condRelease(&cnErr)
+			defer cn.closeOnDone(ctx)()
+			defer cn.setTimeout(c.methodTimeouts[MethodMultiStore])()
 
-			idToKey := make(map[uint32]string, len(keys))
-
-			for _, key := range keys {
-				opaqueStore := c.getOpaque()
+			b := cn.newBatch(quietCode, keys)
+			cnErr = cn.writeBatch(b, func(i int) *Request {
+				key := keys[i]
 				req := &Request{
 					Opcode: quietCode,
-					Opaque: opaqueStore,
 					Key:    []byte(key),
 					Body:   safeGetItems(key),
+					Cas:    cas[key],
 				}
 				req.prepareExtras(exp, 0, 0)
-
-				_, cnErr = transmitRequest(cn.wrtBuf, req)
-				if cnErr != nil {
-					cn.healthy = false
-					return
+				if flags != 0 {
+					binary.BigEndian.PutUint32(req.Extras[:4], flags)
 				}
-
-				idToKey[opaqueStore] = key
-			}
-
-			opaqueNOOP := c.getOpaque()
-			req := &Request{
-				Opcode: NOOP,
-				Opaque: opaqueNOOP,
-			}
-			req.prepareExtras(0, 0, 0)
-
-			_, cnErr = transmitRequest(cn.wrtBuf, req)
+				return req
+			})
 			if cnErr != nil {
-				cn.healthy = false
-				return
-			}
-
-			if cnErr = cn.wrtBuf.Flush(); err != nil {
-				logger.Errorf("%s. %s", ErrServerError.Error(), cnErr.Error())
+				addToMultiErr(ctxErr(ctx, cnErr))
 				return
 			}
 
 			for {
+				if cnErr = ctx.Err(); cnErr != nil {
+					cn.healthy = false
+					addToMultiErr(cnErr)
+					return
+				}
+
 				var resp *Response
-				resp, _, cnErr = getResponse(cn.rc, cn.hdrBuf)
+				resp, _, cnErr = cn.receivePooled()
 				if isFatal(cnErr) {
 					cn.healthy = false
+					addToMultiErr(ctxErr(ctx, cnErr))
 					return
 				}
 
-				if resp.Opcode == NOOP && resp.Opaque == opaqueNOOP {
+				key, done, mErr := b.match(resp)
+				if mErr != nil {
+					cnErr = mErr
+					cn.healthy = false
+					addToMultiErr(mErr)
+					return
+				}
+				if done {
+					releaseResponse(resp)
 					break
 				}
 
-				if key, ok := idToKey[resp.Opaque]; ok {
-					if resp.Status != SUCCESS {
-						addToMultiErr(fmt.Errorf("%w. Error for key - %s", cnErr, key))
+				if resp.Status != SUCCESS {
+					if resp.Status == KEY_EEXISTS && cas[key] != 0 {
+						cnErr = casConflict(resp)
 					}
+					addToMultiErr(fmt.Errorf("%w. Error for key - %s", cnErr, key))
+				} else {
+					releaseResponse(resp)
 				}
 			}
-		}(node, ks, exp)
+		})
 	}
 
 	wg.Wait()
@@ -906,7 +2014,13 @@ func (c *Client) MultiStore(storeMode StoreMode, items map[string][]byte, exp ui
 // Deletes the items with the provided keys.
 // If there is a key in the provided keys that is missing in the cache,
 // the ErrCacheMiss error is ignored.
-func (c *Client) MultiDelete(keys []string) (err error) {
+func (c *Client) MultiDelete(keys []string) error {
+	return c.MultiDeleteContext(context.Background(), keys)
+}
+
+// MultiDeleteContext is MultiDelete, that stops waiting for the responses, when ctx is done.
+// The connections of the unfinished batches are closed, the items of their nodes may be deleted or not.
+func (c *Client) MultiDeleteContext(ctx context.Context, keys []string) (err error) {
 	if len(keys) == 0 {
 		return nil
 	}
@@ -914,6 +2028,11 @@ func (c *Client) MultiDelete(keys []string) (err error) {
 	timerMethod := time.Now()
 	defer c.writeMethodDiagnostics("MultiDelete", timerMethod, &err)
 
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	defer c.invalidateLocal(c.onLocalDelete, keys...)
+
 	var (
 		wg       sync.WaitGroup
 		mu       sync.Mutex
@@ -926,92 +2045,233 @@ func (c *Client) MultiDelete(keys []string) (err error) {
 		multiErr = errors.Join(multiErr, e)
 	}
 
-	nodes, err := getNodesForKeys(c.hr, keys)
+	nodes, err := c.getNodesForKeys(keys)
 	if err != nil {
 		return err
 	}
 
 	for node, ks := range nodes {
+		node, keys := node, ks
 		wg.Add(1)
-		go func(node any, keys []string) {
+		c.goBatch(func() {
 			defer wg.Done()
 
 			var cnErr error
 
+			if fErr := c.injectFault(node, DELETEQ); fErr != nil {
+				if !errors.Is(fErr, ErrCacheMiss) {
+					addToMultiErr(fErr)
+				}
+				return
+			}
+
+			release, nErr := c.acquireNode(node, 0)
+			if nErr != nil {
+				addToMultiErr(nErr)
+				return
+			}
+			defer release()
+
 			cn, nErr := c.getConnForNode(node)
 			if nErr != nil {
 				addToMultiErr(nErr)
 				return
 			}
 			defer cn.condRelease(&cnErr)
+			defer cn.closeOnDone(ctx)()
+			defer cn.setTimeout(c.methodTimeouts[MethodMultiDelete])()
 
-			idToKey := make(map[uint32]string, len(keys))
-
-			for _, key := range keys {
-				opaqueDel := c.getOpaque()
+			b := cn.newBatch(DELETEQ, keys)
+			cnErr = cn.writeBatch(b, func(i int) *Request {
+				key := keys[i]
 				req := &Request{
 					Opcode: DELETEQ,
-					Opaque: opaqueDel,
 					Key:    []byte(key),
 				}
 				req.prepareExtras(0, 0, 0)
+				return req
+			})
+			if cnErr != nil {
+				addToMultiErr(ctxErr(ctx, cnErr))
+				return
+			}
+
+			for {
+				if cnErr = ctx.Err(); cnErr != nil {
+					cn.healthy = false
+					addToMultiErr(cnErr)
+					return
+				}
+
+				var resp *Response
+				resp, _, cnErr = cn.receivePooled()
+				if isFatal(cnErr) {
+					cn.healthy = false
+					addToMultiErr(ctxErr(ctx, cnErr))
+					return
+				}
 
-				_, cnErr = transmitRequest(cn.wrtBuf, req)
-				if cnErr != nil {
+				key, done, mErr := b.match(resp)
+				if mErr != nil {
+					cnErr = mErr
 					cn.healthy = false
+					addToMultiErr(mErr)
 					return
 				}
+				if done {
+					releaseResponse(resp)
+					break
+				}
 
-				idToKey[opaqueDel] = key
+				if resp.Status != SUCCESS && resp.Status != KEY_ENOENT {
+					addToMultiErr(fmt.Errorf("%w. Error for key - %s", cnErr, key))
+				} else if resp.Status == SUCCESS {
+					releaseResponse(resp)
+				}
 			}
+		})
+	}
 
-			opaqueNOOP := c.getOpaque()
-			req := &Request{
-				Opcode: NOOP,
-				Opaque: opaqueNOOP,
+	wg.Wait()
+
+	return multiErr
+}
+
+// MultiDelta is a batch version of Delta. Increments/decrements the counters by their deltas
+// and returns the new values. The requests are pipelined per node, the quiet commands are not used,
+// because they don't return the values. The counters, that are missing and not created
+// (exp is DeltaNoCreate), are not present in the result.
+func (c *Client) MultiDelta(deltaMode DeltaMode, deltas map[string]uint64, initial uint64, exp uint32) (_ map[string]uint64, err error) {
+	ret := make(map[string]uint64, len(deltas))
+	if len(deltas) == 0 {
+		return ret, nil
+	}
+
+	timerMethod := time.Now()
+	defer c.writeMethodDiagnostics("MultiDelta", timerMethod, &err)
+
+	keys := maps.Keys(deltas)
+	defer c.invalidateLocal(c.onLocalWrite, keys...)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		multiErr error
+	)
+
+	addToMultiErr := func(e error) {
+		mu.Lock()
+		defer mu.Unlock()
+		multiErr = errors.Join(multiErr, e)
+	}
+	addToRet := func(key string, value uint64) {
+		mu.Lock()
+		defer mu.Unlock()
+		ret[key] = value
+	}
+
+	nodes, err := c.getNodesForKeys(keys)
+	if err != nil {
+		return ret, err
+	}
+
+	opcode := deltaMode.Resolve()
+	for node, ks := range nodes {
+		node, keys := node, ks
+		wg.Add(1)
+		c.goBatch(func() {
+			defer wg.Done()
+
+			var cnErr error
+
+			if fErr := c.injectFault(node, opcode); fErr != nil {
+				if !errors.Is(fErr, ErrCacheMiss) {
+					addToMultiErr(fErr)
+				}
+				return
 			}
-			req.prepareExtras(0, 0, 0)
 
-			_, cnErr = transmitRequest(cn.wrtBuf, req)
-			if cnErr != nil {
-				cn.healthy = false
+			release, nErr := c.acquireNode(node, 0)
+			if nErr != nil {
+				addToMultiErr(nErr)
+				return
+			}
+			defer release()
+
+			cn, nErr := c.getConnForNode(node)
+			if nErr != nil {
+				addToMultiErr(nErr)
 				return
 			}
+			defer cn.condRelease(&cnErr)
+			defer cn.setTimeout(c.methodTimeouts[MethodMultiDelta])()
 
-			if cnErr = cn.wrtBuf.Flush(); err != nil {
-				logger.Errorf("%s. %s", ErrServerError.Error(), cnErr.Error())
+			b := cn.newBatch(opcode, keys)
+			cnErr = cn.writeBatch(b, func(i int) *Request {
+				key := keys[i]
+				req := &Request{
+					Opcode: opcode,
+					Key:    []byte(key),
+				}
+				req.prepareExtras(exp, deltas[key], initial)
+				return req
+			})
+			if cnErr != nil {
+				addToMultiErr(cnErr)
 				return
 			}
 
 			for {
 				var resp *Response
-				resp, _, cnErr = getResponse(cn.rc, cn.hdrBuf)
-				if isFatal(cnErr) {
+				resp, _, cnErr = cn.receivePooled()
+				// the errors of the statuses are the responses to the requests of the batch.
+				if cnErr != nil && UnwrapMemcachedError(cnErr) == nil {
 					cn.healthy = false
+					addToMultiErr(cnErr)
 					return
 				}
 
-				if resp.Opcode == NOOP && resp.Opaque == opaqueNOOP {
+				key, done, mErr := b.match(resp)
+				if mErr != nil {
+					cnErr = mErr
+					cn.healthy = false
+					addToMultiErr(mErr)
+					return
+				}
+				if done {
+					releaseResponse(resp)
 					break
 				}
 
-				if key, ok := idToKey[resp.Opaque]; ok {
-					if resp.Status != SUCCESS && resp.Status != KEY_ENOENT {
-						addToMultiErr(fmt.Errorf("%w. Error for key - %s", cnErr, key))
-					}
+				switch {
+				case cnErr == nil && len(resp.Body) != 8:
+					addToMultiErr(fmt.Errorf("%w: counter of %d bytes. Error for key - %s", ErrMalformedFrame, len(resp.Body), key))
+					releaseResponse(resp)
+				case cnErr == nil:
+					addToRet(key, binary.BigEndian.Uint64(resp.Body))
+					releaseResponse(resp)
+				case resp.Status == KEY_ENOENT:
+					releaseResponse(resp)
+				default:
+					// the error of the status keeps resp, so it is not released.
+					addToMultiErr(fmt.Errorf("%w. Error for key - %s", cnErr, key))
 				}
+				cnErr = nil
 			}
-		}(node, ks)
+		})
 	}
 
 	wg.Wait()
 
-	return multiErr
+	return ret, multiErr
 }
 
 // CloseAllConns is close all opened connection per shards.
 // Once closed, resources should be released.
 func (c *Client) CloseAllConns() {
+	c.closeMuxConns(nil)
+	c.closeTextConns(nil)
+
 	c.fmu.Lock()
 	defer c.fmu.Unlock()
 
@@ -1021,6 +2281,90 @@ func (c *Client) CloseAllConns() {
 	}
 }
 
+// Shutdown stops the background goroutines of the client, waits for the operations in progress
+// to return their connections and then closes all connections with QUIT.
+// If ctx is done before the operations are finished, the connections are closed anyway and ctx.Err() is returned.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.stopBackground()
+	defer c.CloseAllConns()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for c.connsInUse() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Close stops the background goroutines of the client and closes all connections.
+// Operations after Close return ErrClientClosed.
+func (c *Client) Close() error {
+	c.stopBackground()
+	c.CloseAllConns()
+	return nil
+}
+
+// stopBackground marks the client as closed, cancels the internal context and waits for the background goroutines.
+func (c *Client) stopBackground() {
+	c.keepalivesMu.Lock()
+	c.closed.Store(true)
+	c.keepalivesMu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.bgWG.Wait()
+	c.closeTopologyEvents()
+}
+
+// connsInUse returns the number of connections taken from the pools and multiplexed requests in progress.
+func (c *Client) connsInUse() int {
+	c.fmu.RLock()
+	defer c.fmu.RUnlock()
+
+	var inUse int
+	for _, p := range c.freeConns {
+		inUse += p.Stats().InUse
+	}
+	return inUse + c.muxRequestsInFlight()
+}
+
+// Warmup dials connections to all nodes of the hash ring up to the number set by WithMinIdleConns,
+// so the first requests don't wait for dial and authentication.
+func (c *Client) Warmup(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		multiErr error
+	)
+
+	for _, node := range c.hr.GetAllNodes() {
+		addr, ok := node.(net.Addr)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(addr net.Addr) {
+			defer wg.Done()
+
+			if err := c.safeGetOrInitFreeConn(addr).Warmup(ctx); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				multiErr = errors.Join(multiErr, fmt.Errorf("%s: warmup %s error - %w", libPrefix, addr.String(), err))
+			}
+		}(addr)
+	}
+
+	wg.Wait()
+
+	return multiErr
+}
+
 // CloseAvailableConnsInAllShardPools - removes the specified number of connections from the pools of all shards.
 func (c *Client) CloseAvailableConnsInAllShardPools(numOfClose int) int {
 	var closed int
@@ -1030,8 +2374,8 @@ func (c *Client) CloseAvailableConnsInAllShardPools(numOfClose int) int {
 
 	for _, p := range c.freeConns {
 		for i := 0; i < numOfClose; i++ {
-			if connRaw, ok := p.Pop(); ok {
-				p.Close(connRaw)
+			if cn, ok := p.Pop(); ok {
+				p.Close(cn)
 				closed++
 			}
 		}
@@ -1040,56 +2384,215 @@ func (c *Client) CloseAvailableConnsInAllShardPools(numOfClose int) int {
 	return closed
 }
 
-func (c *Client) writeMethodDiagnostics(methodName string, timer time.Time, err *error) {
-	if methodName == "" || c.disableMemcachedDiagnostic {
-		return
+// DistributionReport estimates the share of keys per node (from 0 to 1) by the given sample keys.
+// The nodes of the hash ring without any sample key are reported with zero share.
+func (c *Client) DistributionReport(sampleKeys []string) map[string]float64 {
+	nodes := c.hr.GetAllNodes()
+	report := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		report[utils.Repr(node)] = 0
+	}
+	if len(sampleKeys) == 0 {
+		return report
+	}
+
+	share := 1 / float64(len(sampleKeys))
+	for _, key := range sampleKeys {
+		if node, find := c.hr.Get(c.routingKey(key)); find {
+			report[utils.Repr(node)] += share
+		}
 	}
 
-	observeMethodDurationSeconds(methodName, time.Since(timer).Seconds(), *err == nil)
+	return report
 }
 
-func (c *Client) authenticate(cn *conn) (ok bool) {
-	req := &Request{
-		Key:  []byte(SaslMechanism),
-		Body: c.authData,
+// Nodes returns the sorted addresses of the nodes in the hash ring.
+func (c *Client) Nodes() []string {
+	nodes := c.hr.GetAllNodes()
+	res := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		res = append(res, utils.Repr(node))
 	}
+	slices.Sort(res)
+	return res
+}
 
-	req.Opcode = SASL_AUTH
-	_, err := transmitRequest(cn.wrtBuf, req)
-	if err != nil {
-		return
+// DeadNodes returns the sorted addresses of the nodes, that failed the health check and were removed from the hash ring.
+func (c *Client) DeadNodes() []string {
+	deadNodes := c.safeGetDeadNodes()
+	res := make([]string, 0, len(deadNodes))
+	for node := range deadNodes {
+		res = append(res, node)
 	}
+	slices.Sort(res)
+	return res
+}
 
-	if err = cn.wrtBuf.Flush(); err != nil {
-		return
+// PoolStats returns the statistics of the connection pools by node address.
+// Only the nodes with already initialized pools are reported.
+func (c *Client) PoolStats() map[string]pool.Stats {
+	c.fmu.RLock()
+	defer c.fmu.RUnlock()
+
+	stats := make(map[string]pool.Stats, len(c.freeConns))
+	for addr, p := range c.freeConns {
+		stats[addr] = p.Stats()
 	}
+	return stats
+}
 
-	resp, _, err := getResponse(cn.rc, cn.hdrBuf)
-	if err == nil {
-		return true
+// WhichNode returns the address of the node, that owns the key.
+func (c *Client) WhichNode(key string) (string, error) {
+	if !legalKey(key) {
+		return "", ErrMalformedKey
 	}
-	if err != nil && resp.Status != FURTHER_AUTH {
-		logger.Errorf("%s: Error from sasl auth - %v", libPrefix, resp)
-		return
+
+	node, find := c.hr.Get(c.routingKey(key))
+	if !find {
+		return "", ErrNoServers
 	}
+	return utils.Repr(node), nil
+}
 
-	req.Opcode = SASL_STEP
-	_, err = transmitRequest(cn.wrtBuf, req)
+// MapKeys returns the keys grouped by the addresses of the nodes, that own them, like the batches
+// of the multi-key operations, e.g. for capacity planning or the external batch schedulers.
+func (c *Client) MapKeys(keys []string) (map[string][]string, error) {
+	nodes, err := c.getNodesForKeys(keys)
 	if err != nil {
-		return
+		return nil, err
+	}
+	if len(keys) != 0 && len(nodes) == 0 {
+		return nil, ErrNoServers
 	}
 
-	resp, _, err = getResponse(cn.rc, cn.hdrBuf)
-	if err != nil {
-		logger.Errorf("%s: Error from sasl step - %v", libPrefix, resp)
+	res := make(map[string][]string, len(nodes))
+	for node, nodeKeys := range nodes {
+		res[utils.Repr(node)] = nodeKeys
+	}
+	return res, nil
+}
+
+// nodeForKey returns the node of the key in the hash ring or the node set by RouteTo.
+func (c *Client) nodeForKey(key string, co *CallOptions) (any, error) {
+	if co.RouteTo != "" {
+		return c.nodeByAddr(co.RouteTo)
+	}
+
+	node, find := c.hr.Get(c.routingKey(key))
+	if !find {
+		return nil, ErrNoServers
+	}
+	return node, nil
+}
+
+// routingKey returns the key, that is hashed to find the node of the key (see WithRoutingKeyFunc).
+func (c *Client) routingKey(key string) string {
+	if c.routingKeyFunc == nil {
+		return key
+	}
+	return c.routingKeyFunc(key)
+}
+
+// nodeByAddr returns the node of the hash ring by its address (see Client.Nodes).
+func (c *Client) nodeByAddr(addr string) (any, error) {
+	for _, node := range c.hr.GetAllNodes() {
+		if utils.Repr(node) == addr {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is not a node of the hash ring", ErrInvalidAddr, addr)
+}
+
+func (c *Client) writeMethodDiagnostics(methodName string, timer time.Time, err *error) {
+	if methodName == "" || c.disableMemcachedDiagnostic {
 		return
 	}
 
-	if err = cn.wrtBuf.Flush(); err != nil {
+	observeMethodDuration(c.getMethodDurationSeconds(), methodName, time.Since(timer).Seconds(), *err == nil)
+
+	// the cache miss is counted apart from the errors, so the error rate is not inflated by the misses.
+	result := requestSuccessResult
+	switch {
+	case *err == nil:
+	case errors.Is(*err, ErrCacheMiss):
+		result = cacheMissResult
+	default:
+		result = requestErrorResult
+	}
+	observeMethodRequest(methodName, result)
+	c.countMethodCall(methodName, result)
+}
+
+// writeMethodKeys counts the hits and the misses of the keys read by the method.
+func (c *Client) writeMethodKeys(methodName string, hits, misses int) {
+	if c.disableMemcachedDiagnostic {
 		return
 	}
 
-	return true
+	observeMethodKeys(methodName, hits, misses)
+	c.countMethodKeys(methodName, hits, misses)
+}
+
+// writeGetKeys counts the hit or the miss of the key by the result of getWithOptions.
+func (c *Client) writeGetKeys(methodName string, err error) {
+	switch {
+	case err == nil:
+		c.writeMethodKeys(methodName, 1, 0)
+	case errors.Is(err, ErrCacheMiss):
+		c.writeMethodKeys(methodName, 0, 1)
+	}
+}
+
+// authenticate checks, that the server offers SaslMechanism by SASL_LIST_MECHS, and authenticates cn.
+// The errors wrap ErrAuthFail, ErrAuthMechanism means, that the mechanism is not offered by the server.
+func (c *Client) authenticate(cn *conn) error {
+	resp, err := cn.authRoundTrip(&Request{Opcode: SASL_LIST_MECHS})
+	switch {
+	case StatusOf(err) == UNKNOWN_COMMAND:
+		return fmt.Errorf("%w: %w: SASL is not supported by the server", ErrAuthFail, ErrAuthMechanism)
+	case err != nil:
+		return fmt.Errorf("%w: list of SASL mechanisms: %w", ErrAuthFail, err)
+	case !slices.Contains(strings.Fields(string(resp.Body)), SaslMechanism):
+		return fmt.Errorf("%w: %w: %s is not offered by the server, offered mechanisms: %q",
+			ErrAuthFail, ErrAuthMechanism, SaslMechanism, resp.Body)
+	}
+
+	req := &Request{
+		Opcode: SASL_AUTH,
+		Key:    []byte(SaslMechanism),
+		Body:   c.authData,
+	}
+	_, err = cn.authRoundTrip(req)
+	if StatusOf(err) == FURTHER_AUTH {
+		req.Opcode = SASL_STEP
+		_, err = cn.authRoundTrip(req)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case StatusOf(err) == AUTHFAIL:
+		c.opLogger(cn.addr, req.Opcode, "").Errorf("%s: Error from sasl auth - %v", libPrefix, err)
+		return fmt.Errorf("%w: invalid credentials: %w", ErrAuthFail, err)
+	default:
+		return fmt.Errorf("%w: %s: %w", ErrAuthFail, req.Opcode, err)
+	}
+}
+
+// authRoundTrip sends the request of the authentication and returns its response, the status of the response
+// is returned as the error of wrapMemcachedResp.
+func (cn *conn) authRoundTrip(req *Request) (*Response, error) {
+	req.Opaque = cn.nextOpaque()
+	if _, err := cn.transmit(req); err != nil {
+		return nil, err
+	}
+	if err := cn.wrtBuf.Flush(); err != nil {
+		return nil, err
+	}
+	resp, _, err := cn.receive()
+	if err != nil {
+		return resp, err
+	}
+	return resp, checkResponse(resp, req.Opcode, req.Opaque)
 }
 
 func legalKey(key string) bool {
@@ -1105,14 +2608,14 @@ func legalKey(key string) bool {
 }
 
 // getNodesForKeys return a map where key is a node and value is a suitable keys
-func getNodesForKeys(hr consistenthash.ConsistentHash, keys []string) (map[any][]string, error) {
-	resp := make(map[any][]string, hr.GetNodesCount())
+func (c *Client) getNodesForKeys(keys []string) (map[any][]string, error) {
+	resp := make(map[any][]string, c.hr.GetNodesCount())
 
 	for _, key := range keys {
 		if !legalKey(key) {
 			return nil, fmt.Errorf("%w. Invalid key - %v", ErrMalformedKey, key)
 		}
-		if node, found := hr.Get(key); found {
+		if node, found := c.hr.Get(c.routingKey(key)); found {
 			resp[node] = append(resp[node], key)
 		}
 	}
@@ -4,13 +4,22 @@ package memcached
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -193,7 +202,7 @@ func TestDecodeSpecSample(t *testing.T) {
 	}
 
 	buf := make([]byte, HDR_LEN)
-	res, _, err := getResponse(bytes.NewReader(data), buf)
+	res, _, err := getResponse(bytes.NewReader(data), buf, MaxBodyLen)
 	if err != nil {
 		t.Fatalf("Error parsing response: %v", err)
 	}
@@ -214,7 +223,7 @@ func TestDecodeSpecSample(t *testing.T) {
 }
 
 func TestNilReader(t *testing.T) {
-	res, _, err := getResponse(nil, nil)
+	res, _, err := getResponse(nil, nil, MaxBodyLen)
 	if !errors.Is(err, ErrNoServers) {
 		t.Fatalf("Expected error reading from nil, got %#v", res)
 	}
@@ -261,10 +270,45 @@ func TestErrWrap(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			wrapErr := wrapMemcachedResp(tt.args.resp)
 			require.ErrorIs(t, wrapErr, tt.wantErr, "wrapMemcachedResp wrap error not equal expected")
+			assert.Equal(t, tt.wantErr.Error()+". "+tt.args.resp.Error(), wrapErr.Error())
+			assert.Equal(t, tt.args.resp, UnwrapMemcachedError(wrapErr))
 		})
 	}
 }
 
+func TestClient_Lookup(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch string(req.Key) {
+		case "missing":
+			return &Response{Status: KEY_ENOENT}
+		case "broken":
+			return &Response{Status: ENOMEM}
+		}
+		return &Response{Body: []byte("value")}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	value, found, err := c.Lookup("key")
+	require.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("value"), value)
+
+	value, found, err = c.Lookup("missing")
+	assert.Nil(t, err, "Lookup: cache miss should not be an error")
+	assert.False(t, found)
+	assert.Nil(t, value)
+
+	_, found, err = c.Lookup("broken")
+	assert.ErrorIs(t, err, ErrServerError)
+	assert.False(t, found)
+
+	_, _, err = c.Lookup("bad key")
+	assert.ErrorIs(t, err, ErrMalformedKey)
+}
+
 func TestDecode(t *testing.T) {
 	data := []byte{
 		RES_MAGIC, byte(SET),
@@ -280,7 +324,7 @@ func TestDecode(t *testing.T) {
 	}
 
 	buf := make([]byte, HDR_LEN)
-	res, _, _ := getResponse(bytes.NewReader(data), buf)
+	res, _, _ := getResponse(bytes.NewReader(data), buf, MaxBodyLen)
 
 	expected := &Response{
 		Opcode: SET,
@@ -314,7 +358,7 @@ func BenchmarkDecodeResponse(b *testing.B) {
 	b.SetBytes(int64(len(buf)))
 
 	for i := 0; i < b.N; i++ {
-		getResponse(bytes.NewReader(data), buf)
+		getResponse(bytes.NewReader(data), buf, MaxBodyLen)
 	}
 }
 
@@ -336,7 +380,7 @@ func TestLocalhost(t *testing.T) {
 	}
 
 	buf := make([]byte, HDR_LEN)
-	resp, _, err := getResponse(c, buf)
+	resp, _, err := getResponse(c, buf, MaxBodyLen)
 	if err != nil {
 		t.Fatalf("Error transmitting request: %v", err)
 	}
@@ -408,6 +452,12 @@ func testWithClient(t *testing.T, c *Client) {
 	_, err = c.Store(Set, "foo"+string(rune(0x7f)), 0, []byte("foobarval"))
 	assert.ErrorIsf(t, err, ErrMalformedKey, "set(foo<0x7f>) should return ErrMalformedKey instead of %v", err)
 
+	// Touch
+	_, err = c.Touch("touch", 100)
+	assert.ErrorIsf(t, err, ErrCacheMiss, "touch(touch) with missing key want ErrCacheMiss, got %v", err)
+	_, err = c.Touch("foo", 100)
+	assert.Nilf(t, err, "touch(foo): %v", err)
+
 	// Append
 	_, err = c.Append(Append, "append", []byte("appendval"))
 	assert.ErrorIsf(t, err, ErrNotStored, "first append(append) want ErrNotStored, got %v", err)
@@ -604,7 +654,7 @@ func TestLocalhost_FlushAll_MultiDelete(t *testing.T) {
 	}
 
 	buf := make([]byte, HDR_LEN)
-	resp, _, err := getResponse(c, buf)
+	resp, _, err := getResponse(c, buf, MaxBodyLen)
 	if err != nil {
 		t.Fatalf("Error transmitting request: %v", err)
 	}
@@ -717,7 +767,7 @@ func TestConn(t *testing.T) {
 	}
 
 	buf := make([]byte, HDR_LEN)
-	resp, _, err := getResponse(c, buf)
+	resp, _, err := getResponse(c, buf, MaxBodyLen)
 	if err != nil {
 		t.Fatalf("Error transmitting request: %v", err)
 	}
@@ -786,6 +836,1041 @@ func TestClient_Getters(t *testing.T) {
 	}
 }
 
+func TestClient_DistributionReport(t *testing.T) {
+	hr := consistenthash.NewHashRing()
+	c := &Client{hr: hr}
+	assert.Empty(t, c.DistributionReport([]string{"key"}), "DistributionReport: without nodes")
+
+	const first, second = "127.0.0.1:11211", "127.0.0.2:11211"
+	hr.Add(first)
+	hr.Add(second)
+	assert.Equal(t, map[string]float64{first: 0, second: 0}, c.DistributionReport(nil))
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	report := c.DistributionReport(keys)
+	assert.Equal(t, 2, len(report))
+	assert.InDelta(t, 1, report[first]+report[second], 1e-9)
+	assert.InDelta(t, .5, report[first], .1)
+}
+
+func TestClient_MaxInflightPerNode(t *testing.T) {
+	var (
+		entered = make(chan struct{})
+		unblock = make(chan struct{})
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if string(req.Key) == "slow" {
+			close(entered)
+			<-unblock
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.maxInflightPerNode = 1
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Get("slow")
+		done <- err
+	}()
+	<-entered
+
+	_, err = c.Get("key")
+	assert.ErrorIs(t, err, ErrNodeSaturated, "Get: node is saturated by the slow request")
+	_, err = c.MultiGet([]string{"key1", "key2"})
+	assert.ErrorIs(t, err, ErrNodeSaturated, "MultiGet: node is saturated by the slow request")
+
+	close(unblock)
+	require.Nil(t, <-done)
+
+	_, err = c.Get("key")
+	assert.Nil(t, err, "Get: slot should be released after the request")
+}
+
+func TestClient_RoutingKeyFunc(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = make(map[string]int)
+	)
+	handler := func(name string) func(req *Request) *Response {
+		return func(req *Request) *Response {
+			if req.Opcode == NOOP {
+				return &Response{}
+			}
+			mu.Lock()
+			calls[name]++
+			mu.Unlock()
+			return nil
+		}
+	}
+	srv1 := newFakeServer(t, handler("srv1"))
+	srv2 := newFakeServer(t, handler("srv2"))
+
+	c, err := newForTests(srv1.addr, srv2.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.routingKeyFunc = func(key string) string {
+		if i := strings.LastIndexByte(key, ':'); i >= 0 {
+			return key[:i]
+		}
+		return key
+	}
+
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		keys = append(keys, "user:123:"+strconv.Itoa(i))
+	}
+	owner, err := c.WhichNode(keys[0])
+	require.Nil(t, err)
+	for _, key := range keys {
+		node, err := c.WhichNode(key)
+		require.Nil(t, err)
+		assert.Equal(t, owner, node, "WhichNode: keys with one routing key should be on one node")
+	}
+	assert.InDelta(t, 1, c.DistributionReport(keys)[owner], 1e-9)
+	mapped, err := c.MapKeys(keys)
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]string{owner: keys}, mapped, "MapKeys: keys with one routing key should be on one node")
+
+	_, err = c.MultiGet(keys)
+	require.Nil(t, err)
+	mu.Lock()
+	assert.Equal(t, 1, len(calls), "MultiGet: keys should be sent to a single node")
+	assert.Equal(t, len(keys), calls["srv1"]+calls["srv2"])
+	mu.Unlock()
+}
+
+func TestClient_Introspection(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response { return &Response{} })
+	const deadNode = "127.0.0.3:11211"
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	c.deadNodes = map[string]struct{}{deadNode: {}}
+	defer c.CloseAllConns()
+
+	assert.Equal(t, []string{srv.addr}, c.Nodes())
+	assert.Equal(t, []string{deadNode}, c.DeadNodes())
+	assert.Empty(t, c.PoolStats(), "PoolStats: pools are not initialized before the first request")
+
+	node, err := c.WhichNode("key")
+	require.Nil(t, err)
+	assert.Equal(t, srv.addr, node)
+	_, err = c.WhichNode("bad key")
+	assert.ErrorIs(t, err, ErrMalformedKey)
+	mapped, err := c.MapKeys([]string{"key1", "key2"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]string{srv.addr: {"key1", "key2"}}, mapped)
+	_, err = c.MapKeys([]string{"key1", "bad key"})
+	assert.ErrorIs(t, err, ErrMalformedKey)
+
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	stats := c.PoolStats()
+	require.Contains(t, stats, srv.addr)
+	assert.Equal(t, 0, stats[srv.addr].InUse)
+	assert.Equal(t, 1, stats[srv.addr].Idle)
+
+	empty := &Client{hr: consistenthash.NewHashRing()}
+	assert.Empty(t, empty.Nodes())
+	assert.Empty(t, empty.DeadNodes())
+	_, err = empty.WhichNode("key")
+	assert.ErrorIs(t, err, ErrNoServers)
+	_, err = empty.MapKeys([]string{"key"})
+	assert.ErrorIs(t, err, ErrNoServers)
+}
+
+func TestClient_TimeoutGetters(t *testing.T) {
+	c := &Client{timeout: 5 * time.Second}
+	assert.Equal(t, 5*time.Second, c.getDialTimeout(), "getDialTimeout: should fallback to timeout")
+	assert.Equal(t, 5*time.Second, c.getReadTimeout(), "getReadTimeout: should fallback to timeout")
+	assert.Equal(t, 5*time.Second, c.getWriteTimeout(), "getWriteTimeout: should fallback to timeout")
+
+	c.dialTimeout, c.readTimeout, c.writeTimeout = time.Second, 2*time.Second, 3*time.Second
+	assert.Equal(t, time.Second, c.getDialTimeout(), "getDialTimeout()")
+	assert.Equal(t, 2*time.Second, c.getReadTimeout(), "getReadTimeout()")
+	assert.Equal(t, 3*time.Second, c.getWriteTimeout(), "getWriteTimeout()")
+}
+
+func TestClient_getConnsRefreshPerPeriod(t *testing.T) {
+	num, zero, negative := 5, 0, -1
+	tests := []struct {
+		name   string
+		client *Client
+		want   int
+	}{
+		{name: "Default", client: &Client{}, want: DefaultOfNumberConnsToDestroyPerRBPeriod},
+		{name: "Custom", client: &Client{connsRefreshPerPeriod: &num}, want: num},
+		{name: "Zero disables refresh", client: &Client{connsRefreshPerPeriod: &zero}, want: 0},
+		{name: "Negative disables refresh", client: &Client{connsRefreshPerPeriod: &negative}, want: 0},
+		{name: "Disabled refresh", client: &Client{connsRefreshPerPeriod: &num, disableRefreshConns: true}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.client.getConnsRefreshPerPeriod())
+		})
+	}
+}
+
+func TestClient_setSocketOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	linger := 0
+	tests := []struct {
+		name   string
+		client *Client
+	}{
+		{name: "Default", client: &Client{}},
+		{name: "Custom", client: &Client{tcpKeepAlive: time.Minute, disableTCPNoDelay: true, tcpLinger: &linger}},
+		{name: "Disable keep-alive", client: &Client{tcpKeepAlive: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nc, err := net.Dial("tcp", ln.Addr().String())
+			require.Nil(t, err)
+			defer nc.Close()
+
+			assert.Nil(t, tt.client.setSocketOptions(nc), "setSocketOptions: tcp conn")
+		})
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	assert.Nil(t, (&Client{tcpKeepAlive: time.Minute}).setSocketOptions(client), "setSocketOptions: not tcp conn should be skipped")
+}
+
+func TestClient_ConnValidation(t *testing.T) {
+	const idle = 10 * time.Millisecond
+	var (
+		noops  atomic.Int32
+		broken atomic.Bool
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == NOOP {
+			noops.Add(1)
+			if broken.Load() {
+				return nil
+			}
+			return &Response{}
+		}
+		return &Response{Body: []byte("value")}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+	c.connValidationIdle = idle
+
+	for i := 0; i < 2; i++ {
+		_, err = c.Get("key")
+		require.Nil(t, err)
+	}
+	assert.Equal(t, int32(0), noops.Load(), "Get: new and recently used conns should not be validated")
+
+	time.Sleep(3 * idle)
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, int32(1), noops.Load(), "Get: idle conn should be validated")
+
+	broken.Store(true)
+	time.Sleep(3 * idle)
+	resp, err := c.Get("key")
+	require.Nil(t, err, "Get: broken idle conn should be replaced with new one")
+	assert.Equal(t, "value", string(resp.Body))
+	assert.Equal(t, int32(2), noops.Load(), "Get: idle conn should be validated")
+}
+
+func TestClient_Warmup(t *testing.T) {
+	var dials atomic.Int32
+	srv := newFakeServer(t, func(_ *Request) *Response {
+		return &Response{}
+	})
+	srv2 := newFakeServer(t, func(_ *Request) *Response {
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr, srv2.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+	c.minIdleConns = 3
+	dial := c.nw.dial
+	c.nw.dialTimeout = func(network, address string, _ time.Duration) (net.Conn, error) {
+		dials.Add(1)
+		return dial(network, address)
+	}
+
+	require.Nil(t, c.Warmup(context.Background()))
+	assert.Equal(t, int32(6), dials.Load(), "Warmup: should dial minIdleConns per node")
+	for _, p := range c.freeConns {
+		assert.Equal(t, 3, p.Len(), "Warmup: pool should have minIdleConns")
+	}
+
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, int32(6), dials.Load(), "Get: should use warm conn")
+}
+
+func TestClient_Shutdown(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	var quits atomic.Int32
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == QUITQ {
+			quits.Add(1)
+			return nil
+		}
+		time.Sleep(delay)
+		return &Response{Body: []byte("value")}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(c.ctx)
+	c.ctx = ctx
+
+	getErr := make(chan error, 1)
+	go func() {
+		_, gErr := c.Get("key")
+		getErr <- gErr
+	}()
+	require.Eventually(t, func() bool { return c.connsInUse() == 1 }, time.Second, time.Millisecond)
+
+	require.Nil(t, c.Shutdown(context.Background()))
+	assert.Nil(t, <-getErr, "Shutdown: operation in progress should be finished")
+	assert.ErrorIs(t, c.ctx.Err(), context.Canceled, "Shutdown: internal ctx should be canceled")
+	assert.Equal(t, 0, len(c.freeConns), "Shutdown: pools should be closed")
+	assert.Eventually(t, func() bool { return quits.Load() == 1 }, time.Second, time.Millisecond, "Shutdown: QUITQ should be sent")
+
+	c2, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	go func() {
+		_, _ = c2.Get("key")
+	}()
+	require.Eventually(t, func() bool { return c2.connsInUse() == 1 }, time.Second, time.Millisecond)
+
+	tCtx, cancel := context.WithTimeout(context.Background(), delay/5)
+	defer cancel()
+	assert.ErrorIs(t, c2.Shutdown(tCtx), context.DeadlineExceeded, "Shutdown: ctx is done before operations finished")
+	assert.Equal(t, 0, len(c2.freeConns), "Shutdown: pools should be closed anyway")
+}
+
+func TestClient_Close(t *testing.T) {
+	srv := newFakeServer(t, func(_ *Request) *Response {
+		return &Response{Body: []byte("value")}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(c.ctx)
+	c.ctx = ctx
+	c.initNodesProvider()
+
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(c.freeConns))
+
+	done := make(chan struct{})
+	go func() {
+		assert.Nil(t, c.Close())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close: background goroutines should be stopped")
+	}
+
+	assert.ErrorIs(t, c.ctx.Err(), context.Canceled, "Close: internal ctx should be canceled")
+	assert.Equal(t, 0, len(c.freeConns), "Close: pools should be destroyed")
+	_, err = c.Get("key")
+	assert.ErrorIs(t, err, ErrClientClosed, "Get: after Close, want ErrClientClosed")
+	assert.Equal(t, 0, len(c.freeConns), "Get: after Close, pools should not be created")
+	assert.Nil(t, c.Close(), "Close: second call should be safe")
+}
+
+func TestClient_ReadTimeout(t *testing.T) {
+	srv := newFakeServer(t, func(_ *Request) *Response {
+		return nil
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+	c.readTimeout = 50 * time.Millisecond
+
+	timer := time.Now()
+	_, err = c.Get("key")
+	require.NotNil(t, err, "Get: server doesn't answer, want timeout error")
+	var nErr net.Error
+	assert.True(t, errors.As(err, &nErr) && nErr.Timeout(), "Get: want net timeout error, got %v", err)
+	assert.True(t, time.Since(timer) < time.Second, "Get: should not wait longer than read timeout")
+}
+
+func TestClient_HedgedGet(t *testing.T) {
+	const (
+		key   = "hedged"
+		delay = 20 * time.Millisecond
+	)
+
+	var slowAddr atomic.Value
+	handler := func(addr string) func(req *Request) *Response {
+		return func(req *Request) *Response {
+			if slowAddr.Load() == addr {
+				time.Sleep(10 * delay)
+			}
+			return &Response{Body: []byte(addr)}
+		}
+	}
+
+	first, second := newFakeServer(t, nil), newFakeServer(t, nil)
+	first.handler, second.handler = handler(first.addr), handler(second.addr)
+
+	c, err := newForTests(first.addr, second.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+	c.hedgingDelay = delay
+
+	nodes, _ := c.hr.GetN(key, 2)
+	primary, secondary := utils.Repr(nodes[0]), utils.Repr(nodes[1])
+
+	resp, err := c.Get(key)
+	require.Nil(t, err)
+	assert.Equal(t, primary, string(resp.Body), "Get: without delay primary node should answer")
+
+	slowAddr.Store(primary)
+	timer := time.Now()
+	resp, err = c.Get(key)
+	require.Nil(t, err)
+	assert.Equal(t, secondary, string(resp.Body), "Get: slow primary node, secondary node should answer")
+	assert.True(t, time.Since(timer) < 5*delay, "Get: hedged request should not wait the slow node")
+}
+
+func TestClient_MaxBodyLen(t *testing.T) {
+	const maxBodyLen = 8
+	var (
+		mu     sync.Mutex
+		stored []string
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case SET, SETQ, APPEND:
+			mu.Lock()
+			stored = append(stored, string(req.Key))
+			mu.Unlock()
+		}
+		if req.Opcode == SETQ {
+			return nil
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+	assert.Equal(t, MaxBodyLen, c.getMaxBodyLen(), "getMaxBodyLen: should be MaxBodyLen by default")
+	c.maxBodyLen = maxBodyLen
+
+	_, err = c.Store(Set, "fit", 0, make([]byte, maxBodyLen))
+	assert.Nil(t, err)
+
+	_, err = c.Store(Set, "big", 0, make([]byte, maxBodyLen+1))
+	assert.ErrorIs(t, err, ErrDataSizeExceedsLimit, "Store: body > maxBodyLen should be rejected")
+	assert.ErrorContains(t, err, "big")
+
+	_, err = c.Append(Append, "big", make([]byte, maxBodyLen+1))
+	assert.ErrorIs(t, err, ErrDataSizeExceedsLimit, "Append: body > maxBodyLen should be rejected")
+
+	err = c.MultiStore(Set, map[string][]byte{"small": {1}, "huge": make([]byte, maxBodyLen+1)}, 0)
+	assert.ErrorIs(t, err, ErrDataSizeExceedsLimit, "MultiStore: body > maxBodyLen should be rejected")
+	assert.ErrorContains(t, err, "huge")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"fit", "small"}, stored, "too big values should not be sent")
+}
+
+func TestClient_UnexpectedResponse(t *testing.T) {
+	var (
+		shiftOpaque atomic.Uint32
+		wrongOpcode atomic.Bool
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case NOOP:
+			return &Response{}
+		case GETQ:
+			if string(req.Key) == "missing" {
+				return nil
+			}
+			req.Opaque += shiftOpaque.Load()
+		case GET:
+			if wrongOpcode.Load() {
+				req.Opcode = GETQ
+			}
+		}
+		return &Response{Body: append([]byte("value-"), req.Key...)}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+
+	keys := []string{"key1", "key2", "missing"}
+	for i := 0; i < 2; i++ {
+		items, mErr := c.MultiGet(keys)
+		require.Nil(t, mErr)
+		assert.Equal(t, map[string][]byte{"key1": []byte("value-key1"), "key2": []byte("value-key2")}, items,
+			"MultiGet: values should match keys in every batch on the conn")
+	}
+	for _, p := range c.freeConns {
+		assert.Equal(t, 1, p.Len(), "MultiGet: conn should be reused")
+	}
+
+	shiftOpaque.Store(100)
+	items, err := c.MultiGet(keys)
+	assert.ErrorIs(t, err, ErrProtocolDesync, "MultiGet: response with foreign opaque should be an error")
+	assert.Empty(t, items, "MultiGet: response with foreign opaque should not be assigned to a key")
+	for _, p := range c.freeConns {
+		assert.Equal(t, 0, p.Len(), "MultiGet: out of sync conn should be closed")
+	}
+
+	shiftOpaque.Store(0)
+	wrongOpcode.Store(true)
+	_, err = c.Get("key1")
+	assert.ErrorIs(t, err, ErrProtocolDesync, "Get: response with unexpected opcode should be an error")
+	for _, p := range c.freeConns {
+		assert.Equal(t, 0, p.Len(), "Get: out of sync conn should be closed")
+	}
+}
+
+func TestClient_ProtocolDesync(t *testing.T) {
+	tests := []struct {
+		name    string
+		mangle  func(req *Request)
+		miss    bool
+		wantErr error
+	}{
+		{name: "matched response", mangle: func(_ *Request) {}},
+		{name: "matched cache miss", mangle: func(_ *Request) {}, miss: true, wantErr: ErrCacheMiss},
+		{name: "foreign opaque", mangle: func(req *Request) { req.Opaque++ }, wantErr: ErrProtocolDesync},
+		{name: "foreign opcode", mangle: func(req *Request) { req.Opcode = SET }, wantErr: ErrProtocolDesync},
+		{name: "cache miss with foreign opaque", mangle: func(req *Request) { req.Opaque-- }, miss: true, wantErr: ErrProtocolDesync},
+	}
+	for _, tt := range tests {
+		tt := tt
+		for _, mux := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s mux=%t", tt.name, mux), func(t *testing.T) {
+				srv := newFakeServer(t, func(req *Request) *Response {
+					tt.mangle(req)
+					if tt.miss {
+						return &Response{Status: KEY_ENOENT}
+					}
+					return &Response{Body: []byte("value")}
+				})
+
+				c, err := newForTests(srv.addr)
+				require.Nil(t, err)
+				t.Cleanup(c.CloseAllConns)
+				if mux {
+					c.muxConnsPerNode = 1
+				}
+
+				_, err = c.Get("key")
+				if tt.wantErr == nil {
+					assert.Nil(t, err)
+				} else {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+
+				poisoned := errors.Is(err, ErrProtocolDesync)
+				for _, p := range c.freeConns {
+					assert.Equal(t, !poisoned, p.Len() == 1, "Get: out of sync conn should be closed")
+				}
+				for _, n := range c.muxNodes {
+					assert.Equal(t, poisoned, n.conns[0].broken(), "Get: out of sync multiplexed conn should be closed")
+				}
+			})
+		}
+	}
+}
+
+// writesCounter counts the writes of the flushed buffer.
+type writesCounter struct{ writes int }
+
+func (w *writesCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+func TestConn_transmitQuiet(t *testing.T) {
+	req := &Request{Opcode: SETQ, Key: []byte("key"), Body: []byte("value")}
+	req.prepareExtras(0, 0, 0)
+
+	transmit := func(c *Client, n int) int {
+		w := &writesCounter{}
+		c.disableMemcachedDiagnostic = true
+		cn := &conn{c: c, wrtBuf: bufio.NewWriterSize(w, c.writeBufferSize())}
+		for i := 0; i < n; i++ {
+			_, err := cn.transmitQuiet(req)
+			require.Nil(t, err)
+		}
+		return w.writes
+	}
+
+	assert.Zero(t, transmit(&Client{}, 10), "transmitQuiet: the buffer should be flushed at the end of the batch by default")
+	assert.Equal(t, 3, transmit(&Client{flushEveryRequests: 3}, 10), "transmitQuiet: the buffer should be flushed per requests")
+	assert.Equal(t, 5, transmit(&Client{flushEveryBytes: 2 * req.Size()}, 10), "transmitQuiet: the buffer should be flushed per bytes")
+}
+
+// shortWriter accepts limit bytes, then fails, like the broken connection.
+type shortWriter struct {
+	limit  int
+	closed bool
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, io.ErrShortWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func (w *shortWriter) Read(_ []byte) (int, error) { return 0, io.EOF }
+
+func (w *shortWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestConn_writeBatch(t *testing.T) {
+	keys := []string{"key1", "key2", "key3", "key4"}
+	frame := HDR_LEN + len(keys[0])
+
+	// the requests are flushed one by one, the third one is written partially
+	w := &shortWriter{limit: 2*frame + 10}
+	c := &Client{flushEveryRequests: 1, disableMemcachedDiagnostic: true}
+	cn := &conn{c: c, addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 11211}, rc: w, healthy: true,
+		wrtBuf: bufio.NewWriter(w)}
+
+	err := cn.writeBatch(cn.newBatch(GETQ, keys), func(i int) *Request {
+		return &Request{Opcode: GETQ, Key: []byte(keys[i])}
+	})
+	var abortErr *BatchAbortError
+	require.ErrorAs(t, err, &abortErr)
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, "127.0.0.1:11211", abortErr.Addr)
+	assert.Equal(t, keys[2:], abortErr.Unsent, "writeBatch: the keys from the truncated request should be unsent")
+	assert.True(t, cn.poisoned, "writeBatch: the connection should be poisoned")
+	assert.True(t, w.closed, "writeBatch: the poisoned connection should be closed at once")
+
+	w.closed = false
+	cn.condRelease(&err)
+	assert.False(t, w.closed, "condRelease: the poisoned connection should not be closed again")
+}
+
+func Test_batch_match(t *testing.T) {
+	cn := &conn{opaque: math.MaxUint32 - 1}
+	b := cn.newBatch(DELETEQ, []string{"key1", "key2"})
+	assert.Equal(t, uint32(1), cn.opaque, "newBatch: opaques of keys and NOOP should be allocated")
+
+	tests := []struct {
+		name     string
+		resp     *Response
+		wantKey  string
+		wantDone bool
+		wantErr  bool
+	}{
+		{name: "first key", resp: &Response{Opcode: DELETEQ, Opaque: b.opaque(0)}, wantKey: "key1"},
+		{name: "last key after wrap", resp: &Response{Opcode: DELETEQ, Opaque: b.opaque(1)}, wantKey: "key2"},
+		{name: NOOP.String(), resp: &Response{Opcode: NOOP, Opaque: b.noopOpaque()}, wantDone: true},
+		{name: "opcode of another batch", resp: &Response{Opcode: GETQ, Opaque: b.opaque(0)}, wantErr: true},
+		{name: "opaque of previous batch", resp: &Response{Opcode: DELETEQ, Opaque: b.opaque(0) - 1}, wantErr: true},
+		{name: "NOOP of another batch", resp: &Response{Opcode: NOOP, Opaque: b.opaque(0)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, done, err := b.match(tt.resp)
+			assert.Equal(t, tt.wantKey, key)
+			assert.Equal(t, tt.wantDone, done)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrProtocolDesync)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_batch_matchKeys(t *testing.T) {
+	cn := &conn{}
+	b := cn.newBatch(GETKQ, []string{"key1", "key2"})
+
+	key, _, err := b.match(&Response{Opcode: GETKQ, Opaque: b.opaque(1), Key: []byte("key2")})
+	assert.Nil(t, err)
+	assert.Equal(t, "key2", key)
+
+	_, _, err = b.match(&Response{Opcode: GETKQ, Opaque: b.opaque(0), Key: []byte("key2")})
+	assert.ErrorIs(t, err, ErrProtocolDesync, "match: key of the response should be verified")
+}
+
+func TestClient_MultiGetKeyVerification(t *testing.T) {
+	var swap atomic.Bool
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case GETKQ:
+			key := req.Key
+			if swap.Load() {
+				key = []byte("other")
+			}
+			return &Response{Key: key, Body: append([]byte("value-"), req.Key...)}
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.verifyKeys = true
+
+	got, err := c.MultiGet([]string{"key1", "key2"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"key1": []byte("value-key1"), "key2": []byte("value-key2")}, got)
+
+	swap.Store(true)
+	_, err = c.MultiGet([]string{"key1", "key2"})
+	assert.ErrorIs(t, err, ErrProtocolDesync, "MultiGet: value of another key should not be returned")
+}
+
+func TestClient_MultiGetMisses(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case GET, GETQ:
+			switch {
+			case strings.HasPrefix(string(req.Key), "hit"):
+				return &Response{Body: []byte("value")}
+			case req.Opcode == GET:
+				return &Response{Status: KEY_ENOENT}
+			}
+			return nil
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.disableMemcachedDiagnostic = false
+	var missed [][]string
+	c.onMultiGetMiss = func(keys []string) {
+		slices.Sort(keys)
+		missed = append(missed, keys)
+	}
+
+	got, err := c.MultiGet([]string{"hit1", "miss1", "hit2", "miss2"})
+	require.Nil(t, err)
+	assert.Len(t, got, 2)
+	_, err = c.MultiGet([]string{"miss3"})
+	require.Nil(t, err)
+	_, err = c.MultiGet([]string{"hit1", "hit2"})
+	require.Nil(t, err)
+
+	assert.Equal(t, [][]string{{"miss1", "miss2"}, {"miss3"}}, missed, "MultiGet: the hook should get the missed keys")
+	// GETQ of the batches writes the header and the key, the single key is got by Get, the misses are not answered.
+	assert.Equal(t, DebugMethod{
+		Calls: 3, Hits: 4, Misses: 3, HitRatio: 4. / 7,
+		BytesWritten: 6*HDR_LEN + 4 + 5 + 4 + 5 + 4 + 4,
+		BytesRead:    4 * (HDR_LEN + 5),
+	}, c.DebugSnapshot().Methods["MultiGet"])
+	assert.Zero(t, c.DebugSnapshot().Methods["Get"].Calls, "MultiGet: the single key should not be counted as the call of Get")
+}
+
+func TestClient_MultiGetPartialFailure(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case GET, GETQ:
+			return &Response{Body: []byte("value")}
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	})
+	// the broken node closes the connections without answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			_ = nc.Close()
+		}
+	}()
+	broken := ln.Addr().String()
+
+	c, err := newForTests(srv.addr, broken)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	nodes, err := c.getNodesForKeys(keys)
+	require.Nil(t, err)
+	var brokenKeys []string
+	for node, ks := range nodes {
+		if utils.Repr(node) == broken {
+			brokenKeys = ks
+		}
+	}
+	require.NotEmpty(t, brokenKeys)
+
+	got, err := c.MultiGet(keys)
+	var mgErr *MultiGetError
+	require.ErrorAs(t, err, &mgErr)
+	require.Len(t, mgErr.Nodes, 1)
+	assert.Equal(t, broken, mgErr.Nodes[0].Addr)
+	assert.ElementsMatch(t, brokenKeys, mgErr.Keys(), "MultiGet: the keys of the failed node should be reported")
+	assert.True(t, IsRetryable(err))
+	assert.Len(t, got, len(keys)-len(brokenKeys), "MultiGet: the values of the other node should be returned")
+
+	_, err = c.MultiGet(brokenKeys[:1])
+	require.ErrorAs(t, err, &mgErr)
+	assert.Equal(t, brokenKeys[:1], mgErr.Keys())
+
+	// with the retries the keys of the failed node are got from the next node of the ring.
+	c.retryAttempts = 1
+	c.retryBudget, err = NewRetryBudget(DefaultRetryBudgetTokens, DefaultRetryBudgetRatio)
+	require.Nil(t, err)
+	got, err = c.MultiGet(keys)
+	require.Nil(t, err)
+	assert.Len(t, got, len(keys), "MultiGet: the keys of the failed node should be dispatched again")
+	got, err = c.MultiGet(brokenKeys[:1])
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{brokenKeys[0]: []byte("value")}, got,
+		"MultiGet: the single key of the failed node should be dispatched again")
+}
+
+func TestClient_MultiContext(t *testing.T) {
+	var noops atomic.Int32
+	// the server doesn't answer on the closing NOOP of the batches.
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == NOOP {
+			noops.Add(1)
+		}
+		return nil
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.readTimeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.MultiGetContext(ctx, []string{"key1", "key2"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var mgErr *MultiGetError
+	require.ErrorAs(t, err, &mgErr)
+	assert.ElementsMatch(t, []string{"key1", "key2"}, mgErr.Keys())
+
+	err = c.MultiStoreContext(ctx, Set, map[string][]byte{"key1": []byte("value")}, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	err = c.MultiDeleteContext(ctx, []string{"key1", "key2"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "Multi: the responses should not be waited after the cancellation")
+	assert.Equal(t, int32(1), noops.Load(), "Multi: the batches should not be sent after the cancellation")
+	assert.Equal(t, 0, c.PoolStats()[srv.addr].Idle, "Multi: the connection of the cancelled batch should be closed")
+
+	single, cancelSingle := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelSingle()
+	start = time.Now()
+	_, err = c.MultiGetContext(single, []string{"key1"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "MultiGetContext: the single key should be got with ctx")
+	assert.Less(t, time.Since(start), time.Second, "MultiGetContext: the single key should not be waited after the cancellation")
+}
+
+func TestClient_StoreWithCAS(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		cas = map[string]uint64{"k1": 1, "k2": 1}
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := string(req.Key)
+		switch req.Opcode {
+		case SET, SETQ:
+			current, ok := cas[key]
+			switch {
+			case req.Cas != 0 && !ok:
+				return &Response{Status: KEY_ENOENT}
+			case req.Cas != 0 && req.Cas != current:
+				return &Response{Status: KEY_EEXISTS}
+			}
+			cas[key] = current + 1
+			if req.Opcode == SETQ {
+				return nil
+			}
+			return &Response{Cas: current + 1}
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	resp, err := c.StoreWithCAS(Set, "k1", 0, 1, []byte("value"))
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), resp.Cas)
+	_, err = c.StoreWithCAS(Set, "k1", 0, 1, []byte("value"))
+	assert.ErrorIs(t, err, ErrCASConflict, "StoreWithCAS: changed item")
+	assert.NotNil(t, UnwrapMemcachedError(err))
+	_, err = c.StoreWithCAS(Set, "missing", 0, 1, []byte("value"))
+	assert.ErrorIs(t, err, ErrCacheMiss, "StoreWithCAS: missing item")
+
+	err = c.MultiStoreWithCAS(Set, map[string]CASItem{
+		"k1": {Value: []byte("value"), Cas: 2},
+		"k2": {Value: []byte("value"), Cas: 5},
+		"k3": {Value: []byte("value")},
+	}, 0)
+	assert.ErrorIs(t, err, ErrCASConflict, "MultiStoreWithCAS: changed item")
+	assert.ErrorContains(t, err, "k2")
+	assert.NotErrorIs(t, err, ErrNotStored)
+	mu.Lock()
+	assert.Equal(t, map[string]uint64{"k1": 3, "k2": 1, "k3": 1}, cas)
+	mu.Unlock()
+}
+
+func TestClient_MultiAppend(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		items = map[string][]byte{"k1": []byte("b"), "k2": []byte("b")}
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := string(req.Key)
+		switch req.Opcode {
+		case APPENDQ, PREPENDQ:
+			value, ok := items[key]
+			if !ok {
+				return &Response{Status: NOT_STORED}
+			}
+			if req.Opcode == APPENDQ {
+				items[key] = append(value, req.Body...)
+			} else {
+				items[key] = append(slices.Clone(req.Body), value...)
+			}
+			return nil
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	require.Nil(t, c.MultiAppend(Append, map[string][]byte{"k1": []byte("c"), "k2": []byte("c")}))
+	require.Nil(t, c.MultiAppend(Prepend, map[string][]byte{"k1": []byte("a"), "k2": []byte("a")}))
+	mu.Lock()
+	assert.Equal(t, map[string][]byte{"k1": []byte("abc"), "k2": []byte("abc")}, items)
+	mu.Unlock()
+
+	err = c.MultiAppend(Append, map[string][]byte{"k1": []byte("d"), "missing": []byte("d")})
+	assert.ErrorIs(t, err, ErrNotStored, "MultiAppend: missing item")
+	assert.ErrorContains(t, err, "missing")
+
+	err = c.MultiAppend(Append, map[string][]byte{"k1": make([]byte, MaxBodyLen+1)})
+	assert.ErrorIs(t, err, ErrDataSizeExceedsLimit)
+}
+
+func TestClient_MultiDelta(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		counters = map[string]uint64{"text": 0}
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode != INCREMENT {
+			return &Response{}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := string(req.Key)
+		value, ok := counters[key]
+		switch {
+		case key == "text":
+			return &Response{Status: DELTA_BADVAL}
+		case key == "short":
+			return &Response{Body: []byte{1, 2, 3}}
+		case !ok && binary.BigEndian.Uint32(req.Extras[16:]) == DeltaNoCreate:
+			return &Response{Status: KEY_ENOENT}
+		case !ok:
+			value = binary.BigEndian.Uint64(req.Extras[8:])
+		default:
+			value += binary.BigEndian.Uint64(req.Extras[:8])
+		}
+		counters[key] = value
+		body := make([]byte, 8)
+		binary.BigEndian.PutUint64(body, value)
+		return &Response{Body: body}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	got, err := c.MultiDelta(Increment, map[string]uint64{"k1": 1, "k2": 2}, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"k1": 10, "k2": 10}, got)
+
+	got, err = c.MultiDelta(Increment, map[string]uint64{"k1": 1, "k2": 2, "missing": 1, "text": 1}, 0, DeltaNoCreate)
+	assert.ErrorIs(t, err, ErrInvalidArguments, "MultiDelta: error of the key should be returned")
+	assert.ErrorContains(t, err, "text")
+	assert.Equal(t, map[string]uint64{"k1": 11, "k2": 12}, got, "MultiDelta: missing counter should not be created")
+
+	got, err = c.MultiDelta(Increment, map[string]uint64{"k1": 1}, 0, 0)
+	require.Nil(t, err, "MultiDelta: connection should stay usable after the errors of the keys")
+	assert.Equal(t, map[string]uint64{"k1": 12}, got)
+
+	// the short counter must not panic in the goroutine of the batch.
+	got, err = c.MultiDelta(Increment, map[string]uint64{"k1": 1, "short": 1}, 0, 0)
+	assert.ErrorIs(t, err, ErrMalformedFrame, "MultiDelta: the short counter should be an error of the key")
+	assert.ErrorContains(t, err, "short")
+	assert.Equal(t, map[string]uint64{"k1": 13}, got)
+
+	_, err = c.MultiDelta(Increment, map[string]uint64{"bad key": 1}, 0, 0)
+	assert.ErrorIs(t, err, ErrMalformedKey)
+}
+
 func TestMethodsErrors(t *testing.T) {
 	c := &Client{
 		hr:                         consistenthash.NewHashRing(),
@@ -803,6 +1888,8 @@ func TestMethodsErrors(t *testing.T) {
 	assert.ErrorIsf(t, err, ErrMalformedKey, "Delta: invalid key, want error ErrMalformedKey")
 	_, err = c.Append(Append, invalidKey, []byte("foo"))
 	assert.ErrorIsf(t, err, ErrMalformedKey, "Append: invalid key, want error ErrMalformedKey")
+	_, err = c.Touch(invalidKey, 0)
+	assert.ErrorIsf(t, err, ErrMalformedKey, "Touch: invalid key, want error ErrMalformedKey")
 	_, err = c.MultiGet([]string{invalidKey, "foo", "bar"})
 	assert.ErrorIsf(t, err, ErrMalformedKey, "MultiGet: invalid key, want error ErrMalformedKey")
 	err = c.MultiDelete([]string{invalidKey, "foo", "bar"})
@@ -821,6 +1908,8 @@ func TestMethodsErrors(t *testing.T) {
 	assert.ErrorIsf(t, err, ErrNoServers, "Delta: with empty hash ring, want error ErrNoServers")
 	_, err = c.Append(Append, "append", []byte("foo"))
 	assert.ErrorIsf(t, err, ErrNoServers, "Append: with empty hash ring, want error ErrNoServers")
+	_, err = c.Touch("touch", 0)
+	assert.ErrorIsf(t, err, ErrNoServers, "Touch: with empty hash ring, want error ErrNoServers")
 
 	// add invalid node
 	c.hr.Add("node1")
@@ -836,6 +1925,8 @@ func TestMethodsErrors(t *testing.T) {
 	assert.ErrorIsf(t, err, ErrInvalidAddr, "Delta: invalid node, want error ErrInvalidAddr")
 	_, err = c.Append(Append, "append", []byte("foo"))
 	assert.ErrorIsf(t, err, ErrInvalidAddr, "Append: invalid node, want error ErrInvalidAddr")
+	_, err = c.Touch("touch", 0)
+	assert.ErrorIsf(t, err, ErrInvalidAddr, "Touch: invalid node, want error ErrInvalidAddr")
 	_, err = c.MultiGet([]string{"gopher", "foo", "bar"})
 	assert.ErrorIsf(t, err, ErrInvalidAddr, "MutliGet: invalid node, want error ErrInvalidAddr")
 	err = c.MultiDelete([]string{"gopher", "foo", "bar"})
@@ -877,3 +1968,223 @@ func TestMethodsErrors(t *testing.T) {
 }
 
 const invalidKey = `Loremipsumdolorsitamet,consecteturadipiscingelit.Velelitvoluptateeleifendquisproidentnonfeugaitiriureliberminimveniamillumcupiditataliquid,nihiltefeugiatlobortiseleifendnibhproidenttationatoptionesseconsectetuerdeserunt.Gubergrenveroidsolutaquis.Dignissimlobortisloremveroenimrebumconsetetur.`
+
+// fakeServer is a memcached server for tests, which answers to every request by the handler.
+// If the handler returns nil, the request is left without answer (like quiet commands).
+type fakeServer struct {
+	addr    string
+	handler func(req *Request) *Response
+}
+
+func newFakeServer(t *testing.T, handler func(req *Request) *Response) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	srv := &fakeServer{addr: ln.Addr().String(), handler: handler}
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			go srv.serve(nc)
+		}
+	}()
+
+	return srv
+}
+
+func (s *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	hdr := make([]byte, HDR_LEN)
+	for {
+		req := new(Request)
+		if _, err := req.Receive(nc, hdr); err != nil {
+			return
+		}
+		resp := s.handler(req)
+		if resp == nil {
+			continue
+		}
+		resp.Opcode, resp.Opaque = req.Opcode, req.Opaque
+		if _, err := resp.Transmit(nc); err != nil {
+			return
+		}
+	}
+}
+
+func TestInitFromEnv_UnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "gomemcached")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "memcached.sock")
+
+	ln, err := net.Listen("unix", path)
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	srv := &fakeServer{addr: path, handler: func(req *Request) *Response {
+		return &Response{Body: []byte("value")}
+	}}
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			go srv.serve(nc)
+		}
+	}()
+
+	t.Setenv("MEMCACHED_SERVERS", path+"|2")
+	mcl, err := InitFromEnv(WithDisableMemcachedDiagnostic(), WithPeriodForNodeHealthCheck(10*time.Millisecond))
+	require.Nil(t, err, "InitFromEnv: the path of unix socket should be accepted")
+	defer mcl.CloseAllConns()
+	assert.Equal(t, []string{path}, mcl.Nodes())
+
+	resp, err := mcl.Get("key")
+	require.Nil(t, err, "Get: the node should be dialed by unix socket")
+	assert.Equal(t, []byte("value"), resp.Body)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, mcl.DeadNodes(), "checkNodesHealth: the unix socket node should be alive")
+}
+
+func TestInitFromEnv_IPv6(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback is not available: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	srv := &fakeServer{addr: ln.Addr().String(), handler: func(req *Request) *Response {
+		return &Response{Body: []byte("value")}
+	}}
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			go srv.serve(nc)
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	t.Setenv("MEMCACHED_SERVERS", fmt.Sprintf("[0:0::1]:%d|2", port))
+	mcl, err := InitFromEnv(WithDisableMemcachedDiagnostic(), WithPeriodForNodeHealthCheck(10*time.Millisecond))
+	require.Nil(t, err)
+	defer mcl.CloseAllConns()
+	assert.Equal(t, []string{srv.addr}, mcl.Nodes(), "InitFromEnv: the node should be in the form of the ring")
+
+	resp, err := mcl.Get("key")
+	require.Nil(t, err, "Get: the node should be dialed by IPv6")
+	assert.Equal(t, []byte("value"), resp.Body)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, mcl.DeadNodes(), "checkNodesHealth: the IPv6 node should be alive")
+	assert.Equal(t, []string{srv.addr}, mcl.Nodes(), "checkNodesHealth: the IPv6 node should stay in the ring")
+}
+
+func TestClient_authenticate(t *testing.T) {
+	newAuthServer := func(mechs string, auth Status) *fakeServer {
+		return newFakeServer(t, func(req *Request) *Response {
+			switch req.Opcode {
+			case SASL_LIST_MECHS:
+				if mechs == "" {
+					return &Response{Status: UNKNOWN_COMMAND}
+				}
+				return &Response{Body: []byte(mechs)}
+			case SASL_AUTH:
+				return &Response{Status: auth}
+			case SASL_STEP:
+				return &Response{}
+			}
+			return &Response{}
+		})
+	}
+
+	tests := []struct {
+		name          string
+		srv           *fakeServer
+		wantErr       error
+		wantMechanism bool
+	}{
+		{name: "success", srv: newAuthServer("CRAM-MD5 PLAIN", SUCCESS)},
+		{name: "further auth", srv: newAuthServer("PLAIN", FURTHER_AUTH)},
+		{name: "invalid credentials", srv: newAuthServer("PLAIN", AUTHFAIL), wantErr: ErrAuthFail},
+		{name: "mechanism is not offered", srv: newAuthServer("CRAM-MD5", SUCCESS), wantErr: ErrAuthFail, wantMechanism: true},
+		{name: "SASL is not supported", srv: newAuthServer("", SUCCESS), wantErr: ErrAuthFail, wantMechanism: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newForTests(tt.srv.addr)
+			require.Nil(t, err)
+			defer c.CloseAllConns()
+			c.authEnable, c.authData = true, prepareAuthData("user", "pass")
+
+			_, err = c.Get("key")
+			if tt.wantErr == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantMechanism {
+				assert.ErrorIs(t, err, ErrAuthMechanism)
+			} else {
+				assert.NotErrorIs(t, err, ErrAuthMechanism)
+			}
+		})
+	}
+}
+
+func TestClient_Reauthenticate(t *testing.T) {
+	var (
+		authed    atomic.Bool
+		rejected  atomic.Bool
+		authCount atomic.Int32
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case SASL_LIST_MECHS:
+			return &Response{Body: []byte(SaslMechanism)}
+		case SASL_AUTH:
+			authCount.Add(1)
+			if rejected.Load() {
+				return &Response{Status: AUTHFAIL}
+			}
+			authed.Store(true)
+			return &Response{}
+		}
+		if !authed.Load() {
+			return &Response{Status: AUTHFAIL}
+		}
+		return &Response{Body: []byte("value")}
+	})
+
+	for _, mux := range []int{0, 1} {
+		authed.Store(false)
+		rejected.Store(false)
+		authCount.Store(0)
+
+		c, err := newForTests(srv.addr)
+		require.Nil(t, err)
+		c.authEnable, c.authData = true, prepareAuthData("user", "pass")
+		c.muxConnsPerNode = mux
+
+		_, err = c.Get("key")
+		require.Nil(t, err)
+
+		// the server has lost the sessions.
+		authed.Store(false)
+		resp, err := c.Get("key")
+		require.Nil(t, err, "Get: the connection should be authenticated again (mux conns %d)", mux)
+		assert.Equal(t, []byte("value"), resp.Body)
+		assert.Equal(t, int32(2), authCount.Load())
+
+		authed.Store(false)
+		rejected.Store(true)
+		_, err = c.Get("key")
+		assert.ErrorIs(t, err, ErrAuthFail, "Get: the failed authentication should be returned (mux conns %d)", mux)
+		c.CloseAllConns()
+	}
+}
@@ -7,32 +7,350 @@ import (
 const (
 	methodNameLabel   = "method_name"
 	isSuccessfulLabel = "is_successful"
+	nodeLabel         = "node"
+	eventLabel        = "event"
+	tierLabel         = "tier"
+	resultLabel       = "result"
+	reasonLabel       = "reason"
+)
+
+const (
+	connCreatedEvent = "created"
+	connClosedEvent  = "closed"
+)
+
+const (
+	nodeAddedEvent   = "added"
+	nodeRemovedEvent = "removed"
+)
+
+const (
+	requestSuccessResult = "success"
+	requestErrorResult   = "error"
+)
+
+const (
+	queueEnqueuedEvent = "enqueued"
+	queueAdmittedEvent = "admitted"
+	queueShedEvent     = "shed"
+	queueExpiredEvent  = "expired"
 )
 
 var (
-	methodDurationSeconds = func() *prometheus.HistogramVec {
-		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	// DefaultDurationBuckets are the buckets of gomemcached_method_duration_seconds in seconds, see WithDurationBuckets.
+	DefaultDurationBuckets = []float64{
+		0.0005, 0.001, 0.005, 0.007, 0.015, 0.05, 0.1, 0.2, 0.5, 1,
+	}
+
+	methodDurationSeconds = newMethodDurationSeconds(DefaultDurationBuckets)
+
+	methodRequestsTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_method_requests_total",
+			Help:      "counts successful, missed and failed calls of gomemcached methods",
+		}, []string{
+			methodNameLabel,
+			resultLabel,
+		})
+	}()
+
+	methodKeysTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_method_keys_total",
+			Help:      "counts hits and misses of the keys read by gomemcached methods",
+		}, []string{
+			methodNameLabel,
+			resultLabel,
+		})
+	}()
+
+	poolConnectionsTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "",
-			Name:      "gomemcached_method_duration_seconds",
-			Help:      "counts the execution time of successful and failed gomemcached methods",
-			Buckets: []float64{
-				0.0005, 0.001, 0.005, 0.007, 0.015, 0.05, 0.1, 0.2, 0.5, 1,
-			},
+			Name:      "gomemcached_pool_connections_total",
+			Help:      "counts created and closed connections in the pools of nodes",
+		}, []string{
+			nodeLabel,
+			eventLabel,
+		})
+	}()
+
+	nodeQueueRequestsTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_node_queue_requests_total",
+			Help:      "counts enqueued, admitted, shed and expired requests in the request queues of nodes",
+		}, []string{
+			nodeLabel,
+			eventLabel,
+		})
+	}()
+
+	nodesTotalDesc = prometheus.NewDesc(
+		"gomemcached_nodes_total",
+		"number of nodes in the hash ring",
+		nil, nil,
+	)
+
+	deadNodesDesc = prometheus.NewDesc(
+		"gomemcached_dead_nodes",
+		"number of nodes, that failed the health check and were removed from the hash ring",
+		nil, nil,
+	)
+
+	poolIdleDesc = prometheus.NewDesc(
+		"gomemcached_pool_idle",
+		"number of idle connections in the pool of the node",
+		[]string{nodeLabel}, nil,
+	)
+
+	poolInUseDesc = prometheus.NewDesc(
+		"gomemcached_pool_inuse",
+		"number of connections taken from the pool of the node",
+		[]string{nodeLabel}, nil,
+	)
+
+	retriesTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_retries_total",
+			Help:      "counts retried and throttled by the retry budget requests and hedges",
+		}, []string{
+			resultLabel,
+		})
+	}()
+
+	bytesTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_bytes_total",
+			Help:      "counts bytes written to and read from the nodes by gomemcached methods",
 		}, []string{
 			methodNameLabel,
-			isSuccessfulLabel,
+			nodeLabel,
+			eventLabel,
+		})
+	}()
+
+	ringChangesTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_ring_changes_total",
+			Help:      "counts changes of the hash ring by the reason of TopologyEvent",
+		}, []string{
+			reasonLabel,
+		})
+	}()
+
+	ringNodesChangedTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_ring_nodes_changed_total",
+			Help:      "counts nodes added to and removed from the hash ring",
+		}, []string{
+			reasonLabel,
+			eventLabel,
+		})
+	}()
+
+	ringKeyspaceMovedRatio = func() *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "",
+			Name:      "gomemcached_ring_keyspace_moved_ratio",
+			Help:      "estimates the share of the keys, that moved to the other nodes by the change of the hash ring",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1},
+		}, []string{
+			reasonLabel,
+		})
+	}()
+
+	ringRebuildDurationSeconds = func() *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "",
+			Name:      "gomemcached_ring_rebuild_duration_seconds",
+			Help:      "counts the time of building and swapping the hash ring",
+			Buckets:   []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1},
+		}, []string{
+			reasonLabel,
+		})
+	}()
+
+	cacheRequestsTotal = func() *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "",
+			Name:      "gomemcached_cache_requests_total",
+			Help:      "counts hits and misses of Get in the local cache (l1) and memcached (l2) tiers",
+		}, []string{
+			tierLabel,
+			resultLabel,
 		})
 	}()
 )
 
-// observeMultiMethodDurationSeconds is observing the duration of a method.
-func observeMethodDurationSeconds(methodName string, duration float64, isSuccessful bool) {
+func newMethodDurationSeconds(buckets []float64) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "",
+		Name:      "gomemcached_method_duration_seconds",
+		Help:      "counts the execution time of successful and failed gomemcached methods",
+		Buckets:   buckets,
+	}, []string{
+		methodNameLabel,
+		isSuccessfulLabel,
+	})
+}
+
+// observeMethodDuration is observing the duration of a method by the histogram hv.
+func observeMethodDuration(hv *prometheus.HistogramVec, methodName string, duration float64, isSuccessful bool) {
 	flag := "0"
 	if isSuccessful {
 		flag = "1"
 	}
 
-	methodDurationSeconds.
+	hv.
 		WithLabelValues(methodName, flag).
 		Observe(duration)
 }
+
+// observeMethodRequest is counting the call of a method with the result.
+func observeMethodRequest(methodName, result string) {
+	methodRequestsTotal.
+		WithLabelValues(methodName, result).
+		Inc()
+}
+
+// observeMethodKeys is counting the hits and the misses of the keys read by a method.
+func observeMethodKeys(methodName string, hits, misses int) {
+	if hits > 0 {
+		methodKeysTotal.
+			WithLabelValues(methodName, cacheHitResult).
+			Add(float64(hits))
+	}
+	if misses > 0 {
+		methodKeysTotal.
+			WithLabelValues(methodName, cacheMissResult).
+			Add(float64(misses))
+	}
+}
+
+// observePoolConnection is counting the event of a connection in the pool of the node.
+func observePoolConnection(node, event string) {
+	poolConnectionsTotal.
+		WithLabelValues(node, event).
+		Inc()
+}
+
+// observeNodeQueue is counting the event of a request in the queue of the node.
+func observeNodeQueue(node, event string) {
+	nodeQueueRequestsTotal.
+		WithLabelValues(node, event).
+		Inc()
+}
+
+// observeCacheRequest is counting the hit or the miss of Get in the cache tier.
+func observeCacheRequest(tier, result string) {
+	cacheRequestsTotal.
+		WithLabelValues(tier, result).
+		Inc()
+}
+
+// observeRetry is counting the retry or the retry throttled by the retry budget.
+func observeRetry(result string) {
+	retriesTotal.
+		WithLabelValues(result).
+		Inc()
+}
+
+// observeBytes is counting the bytes written to or read from the node by a method.
+func observeBytes(methodName, node, event string, n int) {
+	bytesTotal.
+		WithLabelValues(methodName, node, event).
+		Add(float64(n))
+}
+
+// observeRingChange is counting the change of the hash ring with the added and removed nodes.
+func observeRingChange(reason string, added, removed int) {
+	ringChangesTotal.
+		WithLabelValues(reason).
+		Inc()
+	if added > 0 {
+		ringNodesChangedTotal.
+			WithLabelValues(reason, nodeAddedEvent).
+			Add(float64(added))
+	}
+	if removed > 0 {
+		ringNodesChangedTotal.
+			WithLabelValues(reason, nodeRemovedEvent).
+			Add(float64(removed))
+	}
+}
+
+// observeRingRebuild is observing the duration of the rebuilding of the hash ring and the share of the moved keys.
+func observeRingRebuild(reason string, duration, moved float64) {
+	ringRebuildDurationSeconds.
+		WithLabelValues(reason).
+		Observe(duration)
+	ringKeyspaceMovedRatio.
+		WithLabelValues(reason).
+		Observe(moved)
+}
+
+// metricsCollectors returns the counters and the histograms of the package, that are shared by all clients.
+func metricsCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		methodRequestsTotal,
+		methodKeysTotal,
+		poolConnectionsTotal,
+		nodeQueueRequestsTotal,
+		retriesTotal,
+		bytesTotal,
+		ringChangesTotal,
+		ringNodesChangedTotal,
+		ringKeyspaceMovedRatio,
+		ringRebuildDurationSeconds,
+		cacheRequestsTotal,
+	}
+}
+
+// RegisterMetrics registers the counters of the package in reg (prometheus.DefaultRegisterer, if reg is nil),
+// e.g. gomemcached_method_requests_total, gomemcached_bytes_total and gomemcached_ring_changes_total.
+// They are shared by all clients of the process, so they are registered once,
+// the gauges and the histogram of the durations are collected by every client (see Client.Collect).
+func RegisterMetrics(reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	for _, col := range metricsCollectors() {
+		if err := reg.Register(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ prometheus.Collector = (*Client)(nil)
+
+// Describe implements prometheus.Collector, the client is registered as
+// prometheus.MustRegister(mcl). Several clients in one registry must be distinguished by the const labels,
+// e.g. prometheus.WrapRegistererWith(prometheus.Labels{"cluster": "sessions"}, registry).MustRegister(mcl).
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodesTotalDesc
+	ch <- deadNodesDesc
+	ch <- poolIdleDesc
+	ch <- poolInUseDesc
+	c.getMethodDurationSeconds().Describe(ch)
+}
+
+// Collect implements prometheus.Collector, the gauges of the hash ring and the pools are read on every scrape.
+// The histogram of the durations of the methods is collected too, with the buckets of WithDurationBuckets
+// or the default one, that is shared by the clients without them.
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	c.getMethodDurationSeconds().Collect(ch)
+	ch <- prometheus.MustNewConstMetric(nodesTotalDesc, prometheus.GaugeValue, float64(len(c.hr.GetAllNodes())))
+	ch <- prometheus.MustNewConstMetric(deadNodesDesc, prometheus.GaugeValue, float64(len(c.safeGetDeadNodes())))
+	for addr, stats := range c.PoolStats() {
+		ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stats.Idle), addr)
+		ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue, float64(stats.InUse), addr)
+	}
+}
@@ -1,13 +1,16 @@
 package memcached
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func Test_observeMethodDurationSeconds(t *testing.T) {
+func Test_observeMethodDuration(t *testing.T) {
 	type args struct {
 		methodName   string
 		duration     float64
@@ -68,7 +71,7 @@ func Test_observeMethodDurationSeconds(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			observeMethodDurationSeconds(tt.args.methodName, tt.args.duration, tt.args.isSuccessful)
+			observeMethodDuration(methodDurationSeconds, tt.args.methodName, tt.args.duration, tt.args.isSuccessful)
 
 			var success = "0"
 			if tt.args.isSuccessful {
@@ -80,3 +83,176 @@ func Test_observeMethodDurationSeconds(t *testing.T) {
 		})
 	}
 }
+
+func Test_observePoolConnection(t *testing.T) {
+	for _, event := range []string{connCreatedEvent, connClosedEvent} {
+		observePoolConnection("127.0.0.1:11211", event)
+
+		_, err := poolConnectionsTotal.GetMetricWith(map[string]string{nodeLabel: "127.0.0.1:11211", eventLabel: event})
+		assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	}
+}
+
+func Test_observeNodeQueue(t *testing.T) {
+	for _, event := range []string{queueEnqueuedEvent, queueAdmittedEvent, queueShedEvent, queueExpiredEvent} {
+		observeNodeQueue("127.0.0.1:11211", event)
+
+		_, err := nodeQueueRequestsTotal.GetMetricWith(map[string]string{nodeLabel: "127.0.0.1:11211", eventLabel: event})
+		assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	}
+}
+
+func Test_observeCacheRequest(t *testing.T) {
+	for _, tier := range []string{localTier, remoteTier} {
+		for _, result := range []string{cacheHitResult, cacheMissResult} {
+			observeCacheRequest(tier, result)
+
+			_, err := cacheRequestsTotal.GetMetricWith(map[string]string{tierLabel: tier, resultLabel: result})
+			assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+		}
+	}
+}
+
+func Test_observeRetry(t *testing.T) {
+	for _, result := range []string{retryAttemptedResult, retryThrottledResult} {
+		observeRetry(result)
+
+		_, err := retriesTotal.GetMetricWith(map[string]string{resultLabel: result})
+		assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	}
+}
+
+func Test_observeRingChange(t *testing.T) {
+	observeRingChange(string(TopologyManual), 1, 2)
+
+	_, err := ringChangesTotal.GetMetricWith(map[string]string{reasonLabel: string(TopologyManual)})
+	assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	for _, event := range []string{nodeAddedEvent, nodeRemovedEvent} {
+		_, err = ringNodesChangedTotal.GetMetricWith(map[string]string{reasonLabel: string(TopologyManual), eventLabel: event})
+		assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	}
+}
+
+func Test_observeBytes(t *testing.T) {
+	observeBytes("Store", "127.0.0.1:11211", bytesWrittenEvent, 40)
+	observeBytes("Store", "127.0.0.1:11211", bytesReadEvent, 24)
+
+	for _, event := range []string{bytesWrittenEvent, bytesReadEvent} {
+		_, err := bytesTotal.GetMetricWith(map[string]string{methodNameLabel: "Store", nodeLabel: "127.0.0.1:11211", eventLabel: event})
+		assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	}
+}
+
+func Test_observeRingRebuild(t *testing.T) {
+	observeRingRebuild(string(TopologyRebuild), 0.001, 0.3)
+
+	_, err := ringRebuildDurationSeconds.GetMetricWith(map[string]string{reasonLabel: string(TopologyRebuild)})
+	assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+	_, err = ringKeyspaceMovedRatio.GetMetricWith(map[string]string{reasonLabel: string(TopologyRebuild)})
+	assert.Nil(t, err, "GetMetricWith: returned error is not nil - %v", err)
+}
+
+func TestClient_Collect(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response { return &Response{} })
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.deadNodes = map[string]struct{}{"127.0.0.3:11211": {}}
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+
+	descs := make(chan *prometheus.Desc, 10)
+	c.Describe(descs)
+	close(descs)
+	assert.Len(t, descs, 5)
+
+	metrics := make(chan prometheus.Metric, 100)
+	c.Collect(metrics)
+	close(metrics)
+	var names []string
+	for m := range metrics {
+		if name := m.Desc().String(); !strings.Contains(name, "gomemcached_method_duration_seconds") {
+			names = append(names, name)
+		}
+	}
+	require.Len(t, names, 4, "Collect: ring gauges and pool gauges of the node")
+	for i, name := range []string{"gomemcached_nodes_total", "gomemcached_dead_nodes", "gomemcached_pool_idle", "gomemcached_pool_inuse"} {
+		assert.Contains(t, names[i], name)
+	}
+
+	c.disableMemcachedDiagnostic = false
+	c.methodDurationSeconds = newMethodDurationSeconds([]float64{0.0001, 0.001})
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+
+	metrics = make(chan prometheus.Metric, 10)
+	c.Collect(metrics)
+	close(metrics)
+	require.Len(t, metrics, 5, "Collect: the histogram with the buckets of the client should be collected")
+	assert.Contains(t, (<-metrics).Desc().String(), "gomemcached_method_duration_seconds")
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		if req.Opcode == GET {
+			return &Response{Status: KEY_ENOENT}
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.disableMemcachedDiagnostic = false
+
+	reg := prometheus.NewRegistry()
+	require.Nil(t, RegisterMetrics(reg))
+	require.Nil(t, reg.Register(c), "Register: the client should not conflict with the metrics of the package")
+
+	_, err = c.Store(Set, "key", 0, []byte("value"))
+	require.Nil(t, err)
+	_, err = c.Get("key")
+	require.ErrorIs(t, err, ErrCacheMiss)
+	observeRetry(retryThrottledResult)
+	observeRingChange(string(TopologyRebuild), 1, 0)
+	observeRingRebuild(string(TopologyRebuild), 0.001, 0.1)
+	observePoolConnection(srv.addr, connCreatedEvent)
+	observeNodeQueue(srv.addr, queueShedEvent)
+	observeCacheRequest(localTier, cacheMissResult)
+
+	families, err := reg.Gather()
+	require.Nil(t, err)
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	for _, name := range []string{
+		"gomemcached_method_duration_seconds",
+		"gomemcached_method_requests_total",
+		"gomemcached_method_keys_total",
+		"gomemcached_bytes_total",
+		"gomemcached_retries_total",
+		"gomemcached_ring_changes_total",
+		"gomemcached_ring_nodes_changed_total",
+		"gomemcached_ring_rebuild_duration_seconds",
+		"gomemcached_ring_keyspace_moved_ratio",
+		"gomemcached_pool_connections_total",
+		"gomemcached_node_queue_requests_total",
+		"gomemcached_cache_requests_total",
+		"gomemcached_nodes_total",
+	} {
+		assert.Contains(t, names, name, "Gather: the metric should be scraped")
+	}
+
+	assert.NotNil(t, RegisterMetrics(reg), "RegisterMetrics: the second registration should fail")
+}
+
+func Test_observeMethodKeys(t *testing.T) {
+	observeMethodRequest("TestMeth", requestSuccessResult)
+	observeMethodRequest("TestMeth", cacheMissResult)
+	observeMethodRequest("TestMeth", requestErrorResult)
+	observeMethodKeys("TestMeth", 3, 1)
+	observeMethodKeys("TestMeth", 0, 0)
+}
@@ -0,0 +1,361 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// muxConn is a connection, that pipelines many requests at once and matches responses to them by opaque.
+	muxConn struct {
+		nc           net.Conn
+		writeTimeout time.Duration
+		// maxBodyLen - the maximum size of the body of the received responses.
+		maxBodyLen int
+		// countBytes - counts the bytes of the commands written and read by the connection, nil if not set.
+		countBytes func(opcode OpCode, event string, n int)
+
+		// wmu - mutex for wrtBuf, requests are written one by one.
+		wmu    sync.Mutex
+		wrtBuf *bufio.Writer
+
+		// opaque - the counter of opaque for requests of this connection.
+		opaque atomic.Uint32
+
+		// mu - mutex for pending and err
+		mu sync.Mutex
+		// pending - requests waiting for the response by their opaque,
+		// nil channel is left for the timed out request, so its late response is recognized and dropped.
+		pending map[uint32]chan muxResult
+		// err - the reason why the connection is broken, nil for the working connection.
+		err error
+	}
+
+	muxResult struct {
+		resp *Response
+		err  error
+	}
+
+	// muxNode is a set of multiplexed connections to one node, used in turn.
+	muxNode struct {
+		next  atomic.Uint32
+		mu    sync.Mutex
+		conns []*muxConn
+	}
+)
+
+func newMuxConn(nc net.Conn, writeTimeout time.Duration, maxBodyLen int,
+	countBytes func(opcode OpCode, event string, n int)) *muxConn {
+	mc := &muxConn{
+		nc:           nc,
+		writeTimeout: writeTimeout,
+		maxBodyLen:   maxBodyLen,
+		countBytes:   countBytes,
+		wrtBuf:       bufio.NewWriter(nc),
+		pending:      make(map[uint32]chan muxResult),
+	}
+	go mc.readLoop(bufio.NewReader(nc))
+	return mc
+}
+
+// roundTrip sends req and waits for the response not longer than timeout.
+// The opaque of req is replaced with the opaque of the connection.
+func (mc *muxConn) roundTrip(req *Request, timeout time.Duration) (*Response, error) {
+	ch := make(chan muxResult, 1)
+
+	mc.mu.Lock()
+	if mc.err != nil {
+		mc.mu.Unlock()
+		return nil, mc.err
+	}
+	req.Opaque = mc.opaque.Add(1)
+	mc.pending[req.Opaque] = ch
+	mc.mu.Unlock()
+
+	if err := mc.write(req); err != nil {
+		// the stream may contain a part of the request, so the connection can't be used anymore.
+		mc.fail(err)
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-ch:
+		if res.resp != nil {
+			if err := checkResponse(res.resp, req.Opcode, req.Opaque); err != nil {
+				mc.fail(err)
+				return nil, err
+			}
+		}
+		return res.resp, res.err
+	case <-timer.C:
+		mc.mu.Lock()
+		if _, ok := mc.pending[req.Opaque]; ok {
+			mc.pending[req.Opaque] = nil
+		}
+		mc.mu.Unlock()
+		return nil, fmt.Errorf("%w: no response from %s", os.ErrDeadlineExceeded, mc.nc.RemoteAddr())
+	}
+}
+
+func (mc *muxConn) write(req *Request) error {
+	mc.wmu.Lock()
+	defer mc.wmu.Unlock()
+
+	if mc.writeTimeout > 0 {
+		if err := mc.nc.SetWriteDeadline(time.Now().Add(mc.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	n, err := transmitRequest(mc.wrtBuf, req)
+	if mc.countBytes != nil {
+		mc.countBytes(req.Opcode, bytesWrittenEvent, n)
+	}
+	if err != nil {
+		return err
+	}
+	return mc.wrtBuf.Flush()
+}
+
+// readLoop reads responses and passes them to the waiting requests until the connection is broken.
+func (mc *muxConn) readLoop(r io.Reader) {
+	hdr := make([]byte, HDR_LEN)
+	for {
+		resp := &Response{}
+		n, err := resp.receive(r, hdr, false, mc.maxBodyLen)
+		if err != nil {
+			mc.fail(err)
+			return
+		}
+		if mc.countBytes != nil {
+			mc.countBytes(resp.Opcode, bytesReadEvent, n)
+		}
+
+		err = nil
+		if resp.Status != SUCCESS {
+			err = wrapMemcachedResp(resp)
+		}
+
+		mc.mu.Lock()
+		ch, ok := mc.pending[resp.Opaque]
+		delete(mc.pending, resp.Opaque)
+		mc.mu.Unlock()
+
+		if !ok {
+			mc.fail(unexpectedResponse(resp))
+			return
+		}
+		// the request could be already timed out
+		if ch != nil {
+			ch <- muxResult{resp: resp, err: err}
+		}
+	}
+}
+
+// fail marks the connection as broken, closes it and returns err to all waiting requests.
+func (mc *muxConn) fail(err error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.err != nil {
+		return
+	}
+	mc.err = err
+	for opaque, ch := range mc.pending {
+		if ch != nil {
+			ch <- muxResult{err: err}
+		}
+		delete(mc.pending, opaque)
+	}
+	_ = mc.nc.Close()
+}
+
+func (mc *muxConn) broken() bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.err != nil
+}
+
+func (mc *muxConn) inFlight() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	var inFlight int
+	for _, ch := range mc.pending {
+		if ch != nil {
+			inFlight++
+		}
+	}
+	return inFlight
+}
+
+// close sends QUITQ and closes the connection.
+func (mc *muxConn) close() {
+	if !mc.broken() {
+		_ = mc.write(&Request{Opcode: QUITQ})
+	}
+	mc.fail(net.ErrClosed)
+}
+
+// sendToNodeOnce sends req to the node by the multiplexed connection, if it is turned on,
+// otherwise by the connection from the pool. The opaque of req is set by sendToNodeOnce.
+// The timeout overrides the read and write timeouts of the client, if it is not zero.
+// The deadline, if it is not zero, limits the whole attempt in addition to timeout.
+func (c *Client) sendToNodeOnce(node any, req *Request, timeout time.Duration, deadline time.Time) (*Response, error) {
+	if err := c.injectFault(node, req.Opcode); err != nil {
+		return UnwrapMemcachedError(err), err
+	}
+
+	release, err := c.acquireNode(node, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if c.muxConnsPerNode <= 0 {
+		cn, err := c.getConnForNode(node)
+		if err != nil {
+			return nil, err
+		}
+		req.Opaque = cn.nextOpaque()
+		return c.send(cn, req, timeout, deadline)
+	}
+
+	addr, ok := node.(net.Addr)
+	if !ok {
+		return nil, ErrInvalidAddr
+	}
+	mc, err := c.getMuxConn(addr)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = c.getReadTimeout()
+	}
+	if left := time.Until(deadline); !deadline.IsZero() && left < timeout {
+		timeout = left
+	}
+	resp, err := mc.roundTrip(req, timeout)
+	if c.authEnable && StatusOf(err) == AUTHFAIL {
+		// the session is lost, the connection is dialed and authenticated again.
+		mc.fail(ErrAuthFail)
+		if mc, err = c.getMuxConn(addr); err != nil {
+			return nil, err
+		}
+		resp, err = mc.roundTrip(req, timeout)
+	}
+	return resp, err
+}
+
+// getMuxConn returns the next multiplexed connection to addr, broken connections are dialed again.
+func (c *Client) getMuxConn(addr net.Addr) (*muxConn, error) {
+	if c.closed.Load() {
+		return nil, ErrClientClosed
+	}
+
+	c.mmu.Lock()
+	if c.muxNodes == nil {
+		c.muxNodes = make(map[string]*muxNode)
+	}
+	n, ok := c.muxNodes[addr.String()]
+	if !ok {
+		n = &muxNode{conns: make([]*muxConn, c.muxConnsPerNode)}
+		c.muxNodes[addr.String()] = n
+	}
+	c.mmu.Unlock()
+
+	i := int(n.next.Add(1) % uint32(len(n.conns)))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if mc := n.conns[i]; mc != nil && !mc.broken() {
+		return mc, nil
+	}
+	mc, err := c.dialMux(addr)
+	if err != nil {
+		return nil, err
+	}
+	n.conns[i] = mc
+	return mc, nil
+}
+
+func (c *Client) dialMux(addr net.Addr) (*muxConn, error) {
+	nc, err := c.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.authEnable {
+		tc := newTimeoutConn(nc, c.getReadTimeout(), c.getWriteTimeout())
+		cn := &conn{
+			rc:     tc,
+			addr:   addr,
+			c:      c,
+			hdrBuf: make([]byte, HDR_LEN),
+			wrtBuf: bufio.NewWriter(tc),
+		}
+		if err = c.authenticate(cn); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+		// the read loop waits for responses without deadline
+		if err = nc.SetReadDeadline(time.Time{}); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+
+	return newMuxConn(nc, c.getWriteTimeout(), c.getRecvBodyLen(), func(opcode OpCode, event string, n int) {
+		c.countBytes(addr, opcode, event, n)
+	}), nil
+}
+
+// muxRequestsInFlight returns the number of multiplexed requests waiting for the response.
+func (c *Client) muxRequestsInFlight() int {
+	c.mmu.Lock()
+	defer c.mmu.Unlock()
+
+	var inFlight int
+	for _, n := range c.muxNodes {
+		n.mu.Lock()
+		for _, mc := range n.conns {
+			if mc != nil {
+				inFlight += mc.inFlight()
+			}
+		}
+		n.mu.Unlock()
+	}
+	return inFlight
+}
+
+// closeMuxConns closes the multiplexed connections to addr, or to all nodes if addr is nil.
+func (c *Client) closeMuxConns(addr net.Addr) {
+	c.mmu.Lock()
+	var nodes []*muxNode
+	for key, n := range c.muxNodes {
+		if addr == nil || key == addr.String() {
+			nodes = append(nodes, n)
+			delete(c.muxNodes, key)
+		}
+	}
+	c.mmu.Unlock()
+
+	for _, n := range nodes {
+		n.mu.Lock()
+		for _, mc := range n.conns {
+			if mc != nil {
+				mc.close()
+			}
+		}
+		n.mu.Unlock()
+	}
+}
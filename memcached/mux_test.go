@@ -0,0 +1,107 @@
+package memcached
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Multiplexing(t *testing.T) {
+	const (
+		connsPerNode = 2
+		requests     = 100
+	)
+
+	var (
+		mu    sync.Mutex
+		store = make(map[string][]byte)
+		dials atomic.Int32
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Opcode {
+		case SET:
+			store[string(req.Key)] = req.Body
+			return &Response{}
+		case GET:
+			if v, ok := store[string(req.Key)]; ok {
+				return &Response{Body: v}
+			}
+			return &Response{Status: KEY_ENOENT}
+		}
+		return nil
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	t.Cleanup(c.CloseAllConns)
+	c.muxConnsPerNode = connsPerNode
+	dialTimeout := c.nw.dialTimeout
+	c.nw.dialTimeout = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		dials.Add(1)
+		return dialTimeout(network, address, timeout)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "key" + strconv.Itoa(i)
+			_, sErr := c.Store(Set, key, 0, []byte(strconv.Itoa(i)))
+			assert.Nil(t, sErr, "Store: multiplexed request")
+
+			resp, gErr := c.Get(key)
+			if assert.Nil(t, gErr, "Get: multiplexed request") {
+				assert.Equal(t, strconv.Itoa(i), string(resp.Body), "Get: response should match the request")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(connsPerNode), dials.Load(), "Multiplexing: requests should share connections")
+	assert.Equal(t, 0, len(c.freeConns), "Multiplexing: pool should not be used")
+
+	_, err = c.Get("unknown")
+	assert.ErrorIs(t, err, ErrCacheMiss, "Get: status error should be returned")
+	assert.Equal(t, 0, c.muxRequestsInFlight())
+
+	c.CloseAllConns()
+	assert.Equal(t, 0, len(c.muxNodes), "CloseAllConns: multiplexed conns should be closed")
+}
+
+func TestMuxConn(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		// QUITQ is quiet, memcached doesn't answer on it.
+		if string(req.Key) == "silent" || req.Opcode == QUITQ {
+			return nil
+		}
+		return &Response{}
+	})
+
+	nc, err := net.Dial("tcp", srv.addr)
+	require.Nil(t, err)
+	mc := newMuxConn(nc, time.Second, MaxBodyLen, nil)
+
+	_, err = mc.roundTrip(&Request{Opcode: GET, Key: []byte("key")}, time.Second)
+	assert.Nil(t, err)
+
+	_, err = mc.roundTrip(&Request{Opcode: GET, Key: []byte("silent")}, 20*time.Millisecond)
+	var nErr net.Error
+	assert.True(t, errors.As(err, &nErr) && nErr.Timeout(), "roundTrip: want timeout error, got %v", err)
+	assert.Equal(t, 0, mc.inFlight(), "roundTrip: timed out request should be forgotten")
+	assert.False(t, mc.broken(), "roundTrip: timeout should not break the conn")
+
+	mc.close()
+	assert.True(t, mc.broken())
+	_, err = mc.roundTrip(&Request{Opcode: GET, Key: []byte("key")}, time.Second)
+	assert.ErrorIs(t, err, net.ErrClosed, "roundTrip: closed conn")
+}
@@ -1,74 +1,110 @@
 package memcached
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"net/netip"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/exp/maps"
 
+	"github.com/aliexpressru/gomemcached/consistenthash"
 	"github.com/aliexpressru/gomemcached/logger"
 	"github.com/aliexpressru/gomemcached/utils"
 )
 
+const (
+	serverWeightSeparator = "|"
+
+	// slowStartMinPercent is a percent of the full weight, that a new node gets at the start of slow start.
+	slowStartMinPercent = 10
+)
+
+// NodeRecoveryHook is called in a separate goroutine, when NodeProvider adds the node to the hash ring,
+// e.g. the node recovered after a failure. owns reports whether the key is stored on the node now,
+// so the application can re-populate its critical keys instead of serving misses.
+// ctx is canceled, when the client is closed.
+type NodeRecoveryHook func(ctx context.Context, node net.Addr, owns func(key string) bool)
+
+// topologySnapshot is the result of one discovery of the nodes, that is shared by the phases of updateTopology.
+type topologySnapshot struct {
+	// nodes - the sorted discovered nodes.
+	nodes []string
+	// version - the version of the cache of the discovered nodes, see discoverNodesVersion.
+	version uint64
+}
+
+// initNodesProvider starts the topology loop, that updates the ring with the shorter of the periods
+// of the health check and the rebuilding of the nodes.
 func (c *Client) initNodesProvider() {
 	var (
-		periodHC = c.getHCPeriod()
-		tHC      = time.NewTimer(periodHC)
-
-		periodRB = c.getRBPeriod()
-		tRB      = time.NewTimer(periodRB)
+		period = min(c.getHCPeriod(), c.getRBPeriod())
+		timer  = time.NewTimer(period)
 	)
 
 	if c.deadNodes == nil {
 		c.deadNodes = make(map[string]struct{})
 	}
+	c.topologyResync = make(chan struct{}, 1)
 
+	c.bgWG.Add(1)
 	go func() {
+		defer c.bgWG.Done()
 		for {
 			select {
-			case <-tHC.C:
-				c.checkNodesHealth()
-				tHC.Reset(periodHC)
+			case <-timer.C:
+				c.updateTopology()
+				timer.Reset(period)
+			case <-c.topologyResync:
+				c.updateTopology()
 			case <-c.ctx.Done():
-				tHC.Stop()
-				return
-			}
-		}
-	}()
-	go func() {
-		for {
-			select {
-			case <-tRB.C:
-				c.rebuildNodes()
-				tRB.Reset(periodRB)
-			case <-c.ctx.Done():
-				tRB.Stop()
+				timer.Stop()
 				return
 			}
 		}
 	}()
 }
 
-func (c *Client) checkNodesHealth() {
-	currentNodes, err := getNodes(c.nw.lookupHost, c.cfg)
+// updateTopology discovers the nodes once and passes the snapshot through the health check and the rebuilding
+// of the ring in this order, so the dead nodes are filtered out before the ring diff and
+// both phases see the same nodes.
+func (c *Client) updateTopology() {
+	if c.topologyPaused.Load() {
+		return
+	}
+
+	nodes, version, err := c.discoverNodesVersion()
+	c.safeSetDiscoveryResult(err)
 	if err != nil {
-		logger.Warnf("%s: Error occurred while checking nodes health, getNodes error - %s", libPrefix, err.Error())
+		c.getLogger().Warnf("%s: Error occurred while updating topology, getNodes error - %s", libPrefix, err.Error())
 		return
 	}
+	slices.Sort(nodes)
+
+	snap := topologySnapshot{nodes: nodes, version: version}
+	c.checkNodesHealth(snap)
+	c.rebuildNodes(snap)
+}
 
+// checkNodesHealth checks the nodes of the ring and the dead nodes, the dead nodes are removed from the ring.
+// The dead nodes, that are not discovered anymore, are forgotten.
+func (c *Client) checkNodesHealth(snap topologySnapshot) {
 	recheckDeadNodes := func(node any) {
 		sNode := utils.Repr(node)
-		if !slices.Contains(currentNodes, sNode) {
+		if _, ok := slices.BinarySearch(snap.nodes, sNode); !ok {
 			c.safeRemoveFromDeadNodes(sNode)
 			return
 		}
 
-		if c.nodeIsDead(node) {
-			c.safeAddToDeadNodes(sNode)
+		if err := c.checkNode(node); err != nil {
+			c.safeAddToDeadNodesWithReason(sNode, err)
 		} else {
 			c.safeRemoveFromDeadNodes(sNode)
 		}
@@ -93,9 +129,16 @@ func (c *Client) checkNodesHealth() {
 		wg.Add(1)
 		go func(n any) {
 			defer wg.Done()
-			if c.nodeIsDead(n) {
-				sNode := utils.Repr(n)
-				c.safeAddToDeadNodes(sNode)
+			sNode := utils.Repr(n)
+			if err := c.checkNode(n); err != nil {
+				if failures := c.safeCountNodeFailure(sNode); failures < c.removalGraceChecks {
+					c.nodeLogger(n).Warnf("%s: Node failed %d of %d health checks before removal, error - %s",
+						libPrefix, failures, c.removalGraceChecks, err.Error())
+					return
+				}
+				c.safeAddToDeadNodesWithReason(sNode, err)
+			} else {
+				c.safeResetNodeFailures(sNode)
 			}
 		}(node)
 	}
@@ -106,28 +149,64 @@ func (c *Client) checkNodesHealth() {
 	if len(deadNodes) != 0 {
 		nodes := maps.Keys(deadNodes)
 
-		logger.Warnf("%s: Dead nodes - %s", libPrefix, nodes)
+		c.getLogger().Warnf("%s: Dead nodes - %s", libPrefix, nodes)
 
-		for _, node := range nodes {
-			addr, cErr := utils.AddrRepr(node)
-			if cErr != nil {
-				continue
+		var deadAddrs []net.Addr
+		c.batchRing(TopologyNodeDead, func(ring consistenthash.ConsistentHash) {
+			inRing := make(map[string]struct{})
+			for _, n := range ring.GetAllNodes() {
+				inRing[utils.Repr(n)] = struct{}{}
 			}
-			c.hr.Remove(addr)
+
+			for _, node := range nodes {
+				// dead nodes stay in deadNodes until recovery, but they are removed from the ring once.
+				if _, ok := inRing[node]; !ok {
+					continue
+				}
+				addr, cErr := c.nodeAddr(node)
+				if cErr != nil {
+					continue
+				}
+				ring.Remove(addr)
+				deadAddrs = append(deadAddrs, addr)
+			}
+		})
+		for _, addr := range deadAddrs {
 			c.removeFromFreeConns(addr)
 		}
+		if len(deadAddrs) != 0 {
+			c.emitTopologyEvent(TopologyNodeDead, nil, deadAddrs, nil)
+		}
 	}
 }
 
-func (c *Client) rebuildNodes() {
-	currentNodes, err := getNodes(c.nw.lookupHost, c.cfg)
-	if err != nil {
-		logger.Warnf("%s: Error occurred while rebuild nodes health, getNodes error - %s", libPrefix, err.Error())
-		return
+// rebuildNodes adds the discovered nodes, that are not dead, to the ring and removes the rest,
+// then maintains the connections of the pools.
+func (c *Client) rebuildNodes(snap topologySnapshot) {
+	// with the cache of the discovered nodes the ring is left as is, until the nodes or the dead nodes are changed.
+	deadNodes := c.safeGetDeadNodes()
+	if c.discoveryTTL <= 0 || snap.version != c.rebuiltVersion || !maps.Equal(deadNodes, c.rebuiltDeadNodes) ||
+		len(c.slowStartNodes) != 0 || len(c.absentNodes) != 0 {
+		c.rebuiltVersion, c.rebuiltDeadNodes = snap.version, deadNodes
+		c.rebuildRing(slices.Clone(snap.nodes), deadNodes)
 	}
-	slices.Sort(currentNodes)
 
-	for node := range c.safeGetDeadNodes() {
+	// connections are retired by the pool itself, if the lifetime or idle time is limited.
+	if refresh := c.getConnsRefreshPerPeriod(); refresh > 0 && c.maxConnLifetime <= 0 && c.maxConnIdleTime <= 0 {
+		_ = c.CloseAvailableConnsInAllShardPools(refresh)
+	}
+
+	if c.minIdleConns > 0 {
+		if err := c.Warmup(c.ctx); err != nil {
+			c.getLogger().Warnf("%s: Error occurred while rebuild nodes, warmup error - %s", libPrefix, err.Error())
+		}
+	}
+}
+
+// rebuildRing adds the discovered nodes, that are not dead, to the ring and removes the rest.
+// currentNodes must be sorted.
+func (c *Client) rebuildRing(currentNodes []string, deadNodes map[string]struct{}) {
+	for node := range deadNodes {
 		currentNodes = slices.DeleteFunc(currentNodes, func(a string) bool { return a == node })
 	}
 
@@ -150,36 +229,154 @@ func (c *Client) rebuildNodes() {
 			nodesToRemove = append(nodesToRemove, node)
 		}
 	}
+	nodesToRemove = c.graceAbsentNodes(nodesToRemove)
+
+	var addedAddrs, removedAddrs, reweightedAddrs []net.Addr
+	if len(nodesToAdd) != 0 || len(nodesToRemove) != 0 || len(c.slowStartNodes) != 0 {
+		// the new ring is built aside and swapped at once, so requests don't see intermediate states.
+		c.batchRing(TopologyRebuild, func(ring consistenthash.ConsistentHash) {
+			now := time.Now()
+			reweightedAddrs = c.rampUpNodes(ring, currentNodes, nodesToAdd, now)
+
+			for _, node := range nodesToAdd {
+				addr, cErr := c.nodeAddr(node)
+				if cErr != nil {
+					continue
+				}
+				addedAddrs = append(addedAddrs, addr)
+				if c.slowStartWindow > 0 {
+					if c.slowStartNodes == nil {
+						c.slowStartNodes = make(map[string]time.Time)
+					}
+					c.slowStartNodes[node] = now
+					ring.AddWithWeight(addr, c.slowStartWeight(node, 0))
+					continue
+				}
+				c.addNodeToRing(ring, node, addr)
+			}
 
-	if len(nodesToAdd) != 0 {
-		for _, node := range nodesToAdd {
-			addr, cErr := utils.AddrRepr(node)
-			if cErr != nil {
-				continue
+			for _, node := range nodesToRemove {
+				addr, cErr := c.nodeAddr(node)
+				if cErr != nil {
+					continue
+				}
+				ring.Remove(addr)
+				removedAddrs = append(removedAddrs, addr)
 			}
-			c.hr.Add(addr)
+		})
+	}
+
+	// only the nodes that left the ring lose their connections.
+	for _, addr := range removedAddrs {
+		c.removeFromFreeConns(addr)
+	}
+
+	if len(addedAddrs) != 0 || len(removedAddrs) != 0 || len(reweightedAddrs) != 0 {
+		c.emitTopologyEvent(TopologyRebuild, addedAddrs, removedAddrs, reweightedAddrs)
+	}
+
+	if c.nodeRecoveryHook != nil {
+		for _, addr := range addedAddrs {
+			c.runNodeRecoveryHook(addr)
 		}
 	}
+}
 
-	if len(nodesToRemove) != 0 {
-		for _, node := range nodesToRemove {
-			addr, cErr := utils.AddrRepr(node)
-			if cErr != nil {
-				continue
-			}
-			c.hr.Remove(addr)
+// graceAbsentNodes returns the nodes of absent, that are not discovered for removalGraceChecks consecutive
+// rebuildings, so they are removed from the ring, the rest stay in the ring for now (see WithNodeRemovalGrace).
+// The nodes, that are discovered again, are forgotten.
+func (c *Client) graceAbsentNodes(absent []string) []string {
+	if c.removalGraceChecks <= 1 {
+		return absent
+	}
+
+	var (
+		remove []string
+		counts = make(map[string]int, len(absent))
+	)
+	for _, node := range absent {
+		count := c.absentNodes[node] + 1
+		if count >= c.removalGraceChecks {
+			remove = append(remove, node)
+			continue
+		}
+		c.getLogger().Warnf("%s: Node %s is not discovered %d of %d times before removal",
+			libPrefix, node, count, c.removalGraceChecks)
+		counts[node] = count
+	}
+	c.absentNodes = counts
+	return remove
+}
+
+// runNodeRecoveryHook calls nodeRecoveryHook for the node added to the ring in the background.
+func (c *Client) runNodeRecoveryHook(addr net.Addr) {
+	owns := func(key string) bool {
+		node, ok := c.hr.Get(c.routingKey(key))
+		return ok && utils.Repr(node) == utils.Repr(addr)
+	}
+
+	c.bgWG.Add(1)
+	go func() {
+		defer c.bgWG.Done()
+		c.nodeRecoveryHook(c.ctx, addr, owns)
+	}()
+}
+
+// addNodeToRing adds the node to the ring with the weight from the configuration, if it is specified.
+func (c *Client) addNodeToRing(ring consistenthash.ConsistentHash, node string, addr net.Addr) {
+	if weight, ok := c.nodesWeight[node]; ok {
+		ring.AddWithWeight(addr, weight)
+		return
+	}
+	ring.Add(addr)
+}
+
+// rampUpNodes increases the weight of the nodes in slow start, nodes which passed the window get the full weight.
+// currentNodes and nodesToAdd must be sorted, nodes from nodesToAdd start slow start again.
+// It returns the nodes with the changed weight.
+func (c *Client) rampUpNodes(ring consistenthash.ConsistentHash, currentNodes, nodesToAdd []string, now time.Time) (reweighted []net.Addr) {
+	for node, addedAt := range c.slowStartNodes {
+		if _, ok := slices.BinarySearch(currentNodes, node); !ok {
+			delete(c.slowStartNodes, node)
+			continue
+		}
+		if _, ok := slices.BinarySearch(nodesToAdd, node); ok {
+			continue
+		}
+		addr, err := c.nodeAddr(node)
+		if err != nil {
+			continue
+		}
+
+		reweighted = append(reweighted, addr)
+		elapsed := now.Sub(addedAt)
+		if elapsed >= c.slowStartWindow {
+			delete(c.slowStartNodes, node)
+			c.addNodeToRing(ring, node, addr)
+			continue
 		}
+		ring.AddWithWeight(addr, c.slowStartWeight(node, elapsed))
 	}
+	return reweighted
+}
 
-	if !c.disableRefreshConns {
-		_ = c.CloseAvailableConnsInAllShardPools(DefaultOfNumberConnsToDestroyPerRBPeriod)
+// slowStartWeight returns the weight of the node after elapsed time of slow start.
+// The weight grows linearly from slowStartMinPercent to the full weight of the node during the window.
+func (c *Client) slowStartWeight(node string, elapsed time.Duration) int {
+	full := consistenthash.TopWeight
+	if weight, ok := c.nodesWeight[node]; ok {
+		full = weight
 	}
+	percent := max(slowStartMinPercent, int(100*elapsed/c.slowStartWindow))
+	return max(1, full*percent/100)
 }
 
-func (c *Client) nodeIsDead(node any) bool {
-	addr, err := utils.AddrRepr(utils.Repr(node))
+// checkNode dials the node and authenticates the connection, if the authentication is on,
+// so the nodes with the rejected credentials are dead too. Returns the reason, why the node is dead.
+func (c *Client) checkNode(node any) error {
+	addr, err := c.nodeAddr(utils.Repr(node))
 	if err != nil {
-		return true
+		return err
 	}
 
 	var (
@@ -192,24 +389,32 @@ func (c *Client) nodeIsDead(node any) bool {
 		if err != nil {
 			var tErr *ConnectTimeoutError
 			if errors.As(err, &tErr) {
-				if countRetry < DefaultRetryCountForConn {
+				if countRetry < c.getConnRetryCount() {
 					countRetry++
 					continue
 				}
-				logger.Errorf("%s. Node health check failed. error - %s, with timeout - %d",
-					ErrServerError.Error(), err.Error(), c.netTimeout(),
+				c.nodeLogger(addr).Errorf("%s. Node health check failed. error - %s, with timeout - %d",
+					ErrServerError.Error(), err.Error(), c.getDialTimeout(),
 				)
-				return true
+				return err
 			} else {
-				logger.Errorf("%s. %s", ErrServerError.Error(), err.Error())
-				return true
+				c.nodeLogger(addr).Errorf("%s. %s", ErrServerError.Error(), err.Error())
+				return err
 			}
 		}
-		_ = cn.Close()
 		break
 	}
+	defer cn.Close()
+
+	if c.authEnable {
+		tc := newTimeoutConn(cn, c.getReadTimeout(), c.getWriteTimeout())
+		if err = c.authenticate(&conn{rc: tc, addr: addr, c: c, hdrBuf: make([]byte, HDR_LEN), wrtBuf: bufio.NewWriter(tc)}); err != nil {
+			c.nodeLogger(addr).Errorf("%s. Node health check failed. error - %s", ErrServerError.Error(), err.Error())
+			return err
+		}
+	}
 
-	return false
+	return nil
 }
 
 func (c *Client) safeGetDeadNodes() map[string]struct{} {
@@ -218,16 +423,161 @@ func (c *Client) safeGetDeadNodes() map[string]struct{} {
 	return maps.Clone(c.deadNodes)
 }
 
-func (c *Client) safeAddToDeadNodes(node string) {
+// safeAddToDeadNodesWithReason adds the node to the dead nodes with the error of its health check.
+func (c *Client) safeAddToDeadNodesWithReason(node string, reason error) {
 	c.dmu.Lock()
 	defer c.dmu.Unlock()
 	c.deadNodes[node] = struct{}{}
+	if c.deadReasons == nil {
+		c.deadReasons = make(map[string]error)
+	}
+	c.deadReasons[node] = reason
+	delete(c.nodeFailures, node)
 }
 
 func (c *Client) safeRemoveFromDeadNodes(node string) {
 	c.dmu.Lock()
 	defer c.dmu.Unlock()
 	delete(c.deadNodes, node)
+	delete(c.deadReasons, node)
+	delete(c.nodeFailures, node)
+}
+
+// safeCountNodeFailure counts the failed health check of the node in the ring,
+// returns the number of the consecutive failures.
+func (c *Client) safeCountNodeFailure(node string) int {
+	c.dmu.Lock()
+	defer c.dmu.Unlock()
+	if c.nodeFailures == nil {
+		c.nodeFailures = make(map[string]int)
+	}
+	c.nodeFailures[node]++
+	return c.nodeFailures[node]
+}
+
+// safeResetNodeFailures forgets the failed health checks of the node, that passed the check.
+func (c *Client) safeResetNodeFailures(node string) {
+	c.dmu.Lock()
+	defer c.dmu.Unlock()
+	delete(c.nodeFailures, node)
+}
+
+// safeGetDeadReason returns the error of the health check of the dead node, nil if it is unknown.
+func (c *Client) safeGetDeadReason(node string) error {
+	c.dmu.RLock()
+	defer c.dmu.RUnlock()
+	return c.deadReasons[node]
+}
+
+// discoverNodes returns the current nodes from the configuration, see discoverNodesVersion.
+func (c *Client) discoverNodes() ([]string, error) {
+	nodes, _, err := c.discoverNodesVersion()
+	return nodes, err
+}
+
+// discoverNodesVersion returns the current nodes and the version of the cache of the discovered nodes,
+// that is changed with the nodes. The nodes are cached for discoveryTTL (see WithDiscoveryCacheTTL),
+// without the cache every call looks the nodes up and the version is zero.
+func (c *Client) discoverNodesVersion() ([]string, uint64, error) {
+	if c.discoveryTTL <= 0 {
+		nodes, err := c.lookupNodes()
+		return nodes, 0, err
+	}
+
+	c.dcmu.Lock()
+	defer c.dcmu.Unlock()
+	if c.discoveredNodes != nil && time.Since(c.discoveredAt) < c.discoveryTTL {
+		return slices.Clone(c.discoveredNodes), c.discoveryVersion, nil
+	}
+
+	nodes, err := c.lookupNodes()
+	if err != nil {
+		return nil, c.discoveryVersion, err
+	}
+	// the nodes are kept in the order of the configuration (jump hash depends on it), only the diff is sorted.
+	if c.discoveredNodes == nil || !sameNodes(nodes, c.discoveredNodes) {
+		c.discoveryVersion++
+	}
+	c.discoveredNodes, c.discoveredAt = nodes, time.Now()
+	return slices.Clone(nodes), c.discoveryVersion, nil
+}
+
+// sameNodes returns true, if a and b have the same nodes in any order.
+func sameNodes(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// lookupNodes returns the current nodes from the configuration,
+// with stable hostnames instead of ip addresses if it is enabled.
+func (c *Client) lookupNodes() ([]string, error) {
+	nodes, err := getNodes(c.nw.lookupHost, c.cfg)
+	if err != nil || !c.stableHostnames {
+		return nodes, err
+	}
+	return resolveHostnames(c.getLogger(), c.nw.lookupAddr, nodes), nil
+}
+
+// nodeAddr returns net.Addr of the node. With stable hostnames the host of the node is not resolved,
+// so the connections are dialed to the current ip address of the host.
+func (c *Client) nodeAddr(node string) (net.Addr, error) {
+	if c.stableHostnames {
+		return utils.HostAddrRepr(node)
+	}
+	return utils.AddrRepr(node)
+}
+
+// resolveHostnames replaces ip addresses of the nodes with their hostnames from the reverse lookup.
+// If the hostname is not found, the node is left as is, see stableHostname for the choice of the names.
+func resolveHostnames(log logger.Logger, lookupAddr func(addr string) (names []string, err error), nodes []string) []string {
+	resolved := make([]string, len(nodes))
+	for i, node := range nodes {
+		resolved[i] = node
+
+		host, port, err := net.SplitHostPort(node)
+		if err != nil || net.ParseIP(host) == nil {
+			continue
+		}
+
+		names, err := lookupAddr(host)
+		if err != nil || len(names) == 0 {
+			log.Warnf("%s: Hostname for node %s not found, ip address is used. error - %v", libPrefix, node, err)
+			continue
+		}
+
+		resolved[i] = net.JoinHostPort(stableHostname(host, names), port)
+	}
+
+	return resolved
+}
+
+// stableHostname returns the name of the ip address, that doesn't change with the address: the names derived
+// from the ip address (e.g. 10-0-0-1.memcached.default.svc.cluster.local of Kubernetes) are skipped in favour
+// of the other ones (e.g. the pod name of StatefulSet memcached-1.memcached.default.svc.cluster.local).
+// The first name in the sorted order is returned among the equal ones, so the choice doesn't depend on DNS.
+func stableHostname(ip string, names []string) string {
+	dashed := strings.NewReplacer(".", "-", ":", "-").Replace(ip)
+	ipDerived := func(name string) bool {
+		label, _, _ := strings.Cut(name, ".")
+		return strings.Contains(label, dashed) || strings.HasPrefix(name, ip)
+	}
+
+	trimmed := make([]string, len(names))
+	for i, name := range names {
+		trimmed[i] = strings.TrimSuffix(name, ".")
+	}
+	slices.SortFunc(trimmed, func(a, b string) int {
+		if da, db := ipDerived(a), ipDerived(b); da != db {
+			if da {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a, b)
+	})
+	return trimmed[0]
 }
 
 func getNodes(lookup func(host string) (addrs []string, err error), cfg *config) ([]string, error) {
@@ -240,20 +590,99 @@ func getNodes(lookup func(host string) (addrs []string, err error), cfg *config)
 
 			nodesWithHost := make([]string, len(nodes))
 			for i := range nodes {
-				nodesWithHost[i] = net.JoinHostPort(nodes[i], strconv.Itoa(cfg.MemcachedPort))
+				nodesWithHost[i] = normalizeNode(net.JoinHostPort(nodes[i], strconv.Itoa(cfg.MemcachedPort)))
 			}
 
 			return nodesWithHost, nil
 		} else if len(cfg.Servers) != 0 {
-			for _, s := range cfg.Servers {
-				_, _, err := net.SplitHostPort(s)
+			nodes := make([]string, len(cfg.Servers))
+			for i, s := range cfg.Servers {
+				node, _, err := parseServer(s)
 				if err != nil {
 					return nil, err
 				}
+				nodes[i] = node
 			}
-			return cfg.Servers, nil
+			return nodes, nil
 		}
 	}
 
 	return []string{}, nil
 }
+
+// getNodesWeight returns the weights of servers from the configuration scaled to consistenthash.TopWeight,
+// the heaviest server gets consistenthash.TopWeight. Returns nil if none of the servers has a weight.
+// Invalid servers are skipped, they are reported by getNodes.
+func getNodesWeight(cfg *config) map[string]int {
+	if cfg == nil || cfg.HeadlessServiceAddress != "" {
+		return nil
+	}
+
+	var (
+		weights   = make(map[string]int, len(cfg.Servers))
+		maxWeight int
+		weighted  bool
+	)
+
+	for _, s := range cfg.Servers {
+		node, weight, err := parseServer(s)
+		if err != nil {
+			continue
+		}
+		if weight == 0 {
+			weight = 1
+		} else {
+			weighted = true
+		}
+		weights[node] = weight
+		maxWeight = max(maxWeight, weight)
+	}
+
+	if !weighted {
+		return nil
+	}
+
+	for node, weight := range weights {
+		weights[node] = max(weight*consistenthash.TopWeight/maxWeight, 1)
+	}
+
+	return weights
+}
+
+// parseServer splits the server from configuration in format host:port|weight,
+// the weight is optional and returns as zero if it is not specified.
+func parseServer(server string) (string, int, error) {
+	node, rawWeight, weighted := strings.Cut(server, serverWeightSeparator)
+
+	// The path of a unix domain socket has no port, it is dialed as is.
+	if !utils.IsUnixAddr(node) {
+		if _, _, err := net.SplitHostPort(node); err != nil {
+			if strings.Count(node, ":") > 1 && !strings.HasPrefix(node, "[") {
+				return "", 0, fmt.Errorf("IPv6 address of server %s must be in brackets with port, e.g. [::1]:11211", node)
+			}
+			return "", 0, err
+		}
+		node = normalizeNode(node)
+	}
+	if !weighted {
+		return node, 0, nil
+	}
+
+	weight, err := strconv.Atoi(rawWeight)
+	if err != nil || weight <= 0 {
+		return "", 0, fmt.Errorf("invalid weight %q for server %s", rawWeight, node)
+	}
+
+	return node, weight, nil
+}
+
+// normalizeNode returns the node with ip address in the form of net.TCPAddr (e.g. [2001:db8::1]:11211
+// for [2001:db8:0::1]:11211 and 10.0.0.1:11211 for [::ffff:10.0.0.1]:11211), so the node from the configuration
+// matches its address in the hash ring. The nodes with hostnames are returned as is.
+func normalizeNode(node string) string {
+	addrPort, err := netip.ParseAddrPort(node)
+	if err != nil {
+		return node
+	}
+	return netip.AddrPortFrom(addrPort.Addr().Unmap(), addrPort.Port()).String()
+}
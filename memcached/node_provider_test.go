@@ -3,9 +3,12 @@ package memcached
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -47,6 +50,97 @@ func Test_getNodes(t *testing.T) {
 				return true
 			},
 		},
+		{
+			name: "Servers with weight",
+			args: args{
+				mock: new(network),
+				cfg: &config{
+					Servers: []string{"server1:11211|3", "server2:11211"},
+				}},
+			want: []string{"server1:11211", "server2:11211"},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				if err != nil {
+					t.Errorf("getNodes have error - %v", err)
+					return false
+				}
+				return true
+			},
+		},
+		{
+			name: "Servers with unix socket",
+			args: args{
+				mock: new(network),
+				cfg: &config{
+					Servers: []string{"/var/run/memcached.sock|2", "server2:11211"},
+				}},
+			want: []string{"/var/run/memcached.sock", "server2:11211"},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				if err != nil {
+					t.Errorf("getNodes have error - %v", err)
+					return false
+				}
+				return true
+			},
+		},
+		{
+			name: "Servers with IPv6",
+			args: args{
+				mock: new(network),
+				cfg: &config{
+					Servers: []string{"[2001:db8:0::1]:11211|2", "[::ffff:10.0.0.1]:11211", "[fe80::1%eth0]:11211"},
+				}},
+			want: []string{"[2001:db8::1]:11211", "10.0.0.1:11211", "[fe80::1%eth0]:11211"},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				if err != nil {
+					t.Errorf("getNodes have error - %v", err)
+					return false
+				}
+				return true
+			},
+		},
+		{
+			name: "error IPv6 servers without brackets",
+			args: args{
+				mock: new(network),
+				cfg:  &config{Servers: []string{"2001:db8::1:11211", "2001:db8::1"}}},
+			want: nil,
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorContains(t, err, "must be in brackets")
+			},
+		},
+		{
+			name: "Headless IPv6",
+			args: args{
+				mock: &network{lookupHost: func(host string) (addrs []string, err error) {
+					return []string{"2001:db8::1", "2001:db8:0:0::2"}, nil
+				}},
+				cfg: &config{
+					HeadlessServiceAddress: "example.com",
+					MemcachedPort:          11211,
+				}},
+			want: []string{"[2001:db8::1]:11211", "[2001:db8::2]:11211"},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				if err != nil {
+					t.Errorf("getNodes have error - %v", err)
+					return false
+				}
+				return true
+			},
+		},
+		{
+			name: "error servers weight",
+			args: args{
+				mock: new(network),
+				cfg:  &config{Servers: []string{"server1:11211|0", "server2:11211|a"}}},
+			want: nil,
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				if err != nil {
+					return true
+				}
+				t.Errorf("getNodes dot't have error")
+				return false
+			},
+		},
 		{
 			name: "Headless",
 			args: args{
@@ -128,6 +222,162 @@ func Test_getNodes(t *testing.T) {
 	}
 }
 
+func Test_stableHostname(t *testing.T) {
+	tests := []struct {
+		name  string
+		ip    string
+		names []string
+		want  string
+	}{
+		{
+			name:  "pod name before ip-derived name",
+			ip:    "10.0.0.1",
+			names: []string{"10-0-0-1.memcached.default.svc.cluster.local.", "memcached-1.memcached.default.svc.cluster.local."},
+			want:  "memcached-1.memcached.default.svc.cluster.local",
+		},
+		{
+			name:  "sorted names",
+			ip:    "10.0.0.1",
+			names: []string{"b.example.", "a.example."},
+			want:  "a.example",
+		},
+		{
+			name:  "only ip-derived name",
+			ip:    "10.0.0.1",
+			names: []string{"ip-10-0-0-1.ec2.internal."},
+			want:  "ip-10-0-0-1.ec2.internal",
+		},
+		{
+			name:  "ipv6",
+			ip:    "fd00::1",
+			names: []string{"fd00--1.memcached.default.svc.cluster.local.", "memcached-0.memcached.default.svc.cluster.local."},
+			want:  "memcached-0.memcached.default.svc.cluster.local",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stableHostname(tt.ip, tt.names))
+		})
+	}
+}
+
+func Test_resolveHostnames(t *testing.T) {
+	lookupAddr := func(addr string) ([]string, error) {
+		switch addr {
+		case "10.0.0.1":
+			return []string{"memcached-1.memcached.default.svc.cluster.local.", "10-0-0-1.memcached.default.svc.cluster.local."}, nil
+		case "10.0.0.2":
+			return nil, &net.DNSError{Err: "no such host", Name: addr}
+		default:
+			return nil, nil
+		}
+	}
+
+	got := resolveHostnames(logger.Nop(), lookupAddr, []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211", "memcached:11211"})
+	assert.Equal(t, []string{
+		"memcached-1.memcached.default.svc.cluster.local:11211",
+		"10.0.0.2:11211",
+		"10.0.0.3:11211",
+		"memcached:11211",
+	}, got)
+}
+
+func Test_discoverNodes(t *testing.T) {
+	cl := &Client{
+		cfg: &config{HeadlessServiceAddress: "memcached", MemcachedPort: 11211},
+		nw: &network{
+			lookupHost: func(_ string) ([]string, error) {
+				return []string{"10.0.0.1"}, nil
+			},
+			lookupAddr: func(_ string) ([]string, error) {
+				return []string{"memcached-0.memcached."}, nil
+			},
+		},
+	}
+
+	nodes, err := cl.discoverNodes()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:11211"}, nodes)
+	addr, err := cl.nodeAddr(nodes[0])
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.1:11211", addr.String())
+
+	cl.stableHostnames = true
+	nodes, err = cl.discoverNodes()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"memcached-0.memcached:11211"}, nodes)
+	addr, err = cl.nodeAddr(nodes[0])
+	require.Nil(t, err)
+	assert.Equal(t, "memcached-0.memcached:11211", addr.String())
+}
+
+func Test_getNodesWeight(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config
+		want map[string]int
+	}{
+		{
+			name: "config nil",
+			cfg:  nil,
+			want: nil,
+		},
+		{
+			name: "headless",
+			cfg:  &config{HeadlessServiceAddress: "example.com"},
+			want: nil,
+		},
+		{
+			name: "without weights",
+			cfg:  &config{Servers: []string{"server1:11211", "server2:11211"}},
+			want: nil,
+		},
+		{
+			name: "with weights",
+			cfg:  &config{Servers: []string{"server1:11211|3", "server2:11211|1", "server3:11211", "invalid|2"}},
+			want: map[string]int{"server1:11211": 100, "server2:11211": 33, "server3:11211": 33},
+		},
+		{
+			name: "IPv6 with weights",
+			cfg:  &config{Servers: []string{"[2001:db8:0::1]:11211|2", "[2001:db8::2]:11211|1"}},
+			want: map[string]int{"[2001:db8::1]:11211": 100, "[2001:db8::2]:11211": 50},
+		},
+		{
+			name: "minimal weight",
+			cfg:  &config{Servers: []string{"server1:11211|1000", "server2:11211|1"}},
+			want: map[string]int{"server1:11211": 100, "server2:11211": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getNodesWeight(tt.cfg))
+		})
+	}
+}
+
+func Test_addNodeToRing(t *testing.T) {
+	cl := &Client{
+		hr:          consistenthash.NewHashRing(),
+		nodesWeight: getNodesWeight(&config{Servers: []string{"127.0.0.1:11211|1", "127.0.0.2:11211|100"}}),
+	}
+
+	for _, node := range []string{"127.0.0.1:11211", "127.0.0.2:11211"} {
+		addr, err := utils.AddrRepr(node)
+		require.Nil(t, err)
+		cl.addNodeToRing(cl.hr, node, addr)
+	}
+	assert.Equal(t, 2, cl.hr.GetNodesCount())
+
+	const requestSize = 1000
+	counts := make(map[string]int)
+	for i := 0; i < requestSize; i++ {
+		node, ok := cl.hr.Get(i)
+		require.True(t, ok)
+		counts[utils.Repr(node)]++
+	}
+	assert.Greater(t, counts["127.0.0.2:11211"], counts["127.0.0.1:11211"]*10)
+}
+
 func Test_safeGetDeadNodes(t *testing.T) {
 	client := &Client{
 		deadNodes: map[string]struct{}{
@@ -162,26 +412,29 @@ func Test_safeGetDeadNodes(t *testing.T) {
 	wg.Wait()
 }
 
-func Test_safeAddToDeadNodes(t *testing.T) {
+func Test_safeAddToDeadNodesWithReason(t *testing.T) {
 	client := &Client{
-		deadNodes: map[string]struct{}{},
+		deadNodes:    map[string]struct{}{},
+		nodeFailures: map[string]int{"node1": 2},
 	}
+	reason1, reason2 := errors.New("reason1"), errors.New("reason2")
 
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		client.safeAddToDeadNodes("node1")
+		client.safeAddToDeadNodesWithReason("node1", reason1)
 	}()
 	go func() {
 		defer wg.Done()
-		client.safeAddToDeadNodes("node2")
+		client.safeAddToDeadNodesWithReason("node2", reason2)
 	}()
 
 	wg.Wait()
 
-	assert.Contains(t, client.deadNodes, "node1")
-	assert.Contains(t, client.deadNodes, "node2")
+	assert.Equal(t, map[string]struct{}{"node1": {}, "node2": {}}, client.deadNodes)
+	assert.Equal(t, map[string]error{"node1": reason1, "node2": reason2}, client.deadReasons)
+	assert.NotContains(t, client.nodeFailures, "node1", "safeAddToDeadNodesWithReason: the failures of the dead node should be reset")
 }
 
 func Test_safeRemoveFromDeadNodes(t *testing.T) {
@@ -209,7 +462,7 @@ func Test_safeRemoveFromDeadNodes(t *testing.T) {
 	assert.NotContains(t, client.deadNodes, "node2")
 }
 
-func Test_nodeIsDead(t *testing.T) {
+func Test_checkNode(t *testing.T) {
 	logger.DisableLogger()
 	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345}
 
@@ -218,15 +471,15 @@ func Test_nodeIsDead(t *testing.T) {
 		dialTimeout: mockNetworkError.DialTimeout,
 	}}
 
-	assert.True(t, client.nodeIsDead("wrongarrd.r"), "nodeIsDead: wrong addr should be return true")
+	assert.NotNil(t, client.checkNode("wrongarrd.r"), "checkNode: wrong addr should be return error")
 
 	expectedErr := errors.New("mocked dial error")
 
 	mockNetworkError.On("DialTimeout", addr.Network(), addr.String(), client.netTimeout()).Return(nil, expectedErr)
 
-	result := client.nodeIsDead(addr)
+	err := client.checkNode(addr)
 
-	assert.True(t, result)
+	assert.ErrorIs(t, err, expectedErr)
 
 	mockNetworkError.AssertCalled(t, "DialTimeout", addr.Network(), addr.String(), client.netTimeout())
 
@@ -238,13 +491,23 @@ func Test_nodeIsDead(t *testing.T) {
 	expectedErr = &ConnectTimeoutError{addr}
 
 	mockNetworkRetry.On("DialTimeout", addr.Network(), addr.String(), client.netTimeout()).Return(nil, expectedErr)
-	result = client.nodeIsDead(addr)
+	err = client.checkNode(addr)
 
-	assert.True(t, result)
+	assert.ErrorIs(t, err, expectedErr)
 
 	// int(DefaultRetryCountForConn)+1 - the default number of retries plus the first execution.
 	mockNetworkRetry.AssertNumberOfCalls(t, "DialTimeout", int(DefaultRetryCountForConn)+1)
 
+	var retryCount uint8 = 1
+	mockNetworkRetry = new(MockNetworkOperations)
+	client = &Client{
+		nw:             &network{dialTimeout: mockNetworkRetry.DialTimeout},
+		connRetryCount: &retryCount,
+	}
+	mockNetworkRetry.On("DialTimeout", addr.Network(), addr.String(), client.netTimeout()).Return(nil, expectedErr)
+	assert.ErrorIs(t, client.checkNode(addr), expectedErr)
+	mockNetworkRetry.AssertNumberOfCalls(t, "DialTimeout", int(retryCount)+1)
+
 	mockNetworkSuccess := new(MockNetworkOperations)
 	client = &Client{nw: &network{
 		dialTimeout: mockNetworkSuccess.DialTimeout,
@@ -252,13 +515,48 @@ func Test_nodeIsDead(t *testing.T) {
 
 	mockNetworkSuccess.On("DialTimeout", addr.Network(), addr.String(), client.netTimeout()).Return(&FakeConn{}, nil)
 
-	result = client.nodeIsDead(addr)
+	err = client.checkNode(addr)
 
-	assert.False(t, result)
+	assert.Nil(t, err)
 
 	mockNetworkSuccess.AssertCalled(t, "DialTimeout", addr.Network(), addr.String(), client.netTimeout())
 }
 
+func TestClient_checkNodeAuth(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case SASL_LIST_MECHS:
+			return &Response{Body: []byte(SaslMechanism)}
+		case SASL_AUTH:
+			if string(req.Body) != "\x00user\x00pass" {
+				return &Response{Status: AUTHFAIL}
+			}
+		}
+		return &Response{}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.authEnable, c.authData = true, prepareAuthData("user", "pass")
+	assert.Nil(t, c.checkNode(srv.addr))
+
+	c.authData = prepareAuthData("user", "wrong")
+	err = c.checkNode(srv.addr)
+	assert.ErrorIs(t, err, ErrAuthFail, "checkNode: the node with the rejected credentials should be dead")
+
+	c.deadNodes = make(map[string]struct{})
+	c.safeAddToDeadNodesWithReason(srv.addr, err)
+	c.hr = consistenthash.NewHashRing()
+	report := c.Health(context.Background())
+	require.Len(t, report.Nodes, 1)
+	assert.Equal(t, NodeAuthFailed, report.Nodes[0].Status)
+	assert.ErrorIs(t, report.Nodes[0].Err, ErrAuthFail)
+
+	c.safeRemoveFromDeadNodes(srv.addr)
+	assert.Nil(t, c.safeGetDeadReason(srv.addr))
+}
+
 func Test_initNodesProvider(t *testing.T) {
 	var (
 		mockNetworkErr = new(MockNetworkOperations)
@@ -313,7 +611,7 @@ func Test_checkNodesHealth(t *testing.T) {
 	mockNetworkErr.On("LookupHost", cl.cfg.HeadlessServiceAddress).Return(nil, expectedErr)
 	mockNetworkErr.On("Dial", mock.Anything, mock.Anything).Return(&FakeConn{}, nil)
 
-	cl.checkNodesHealth()
+	cl.updateTopology()
 
 	mockNetworkErr.AssertNotCalled(t, "Dial")
 	mockNetworkErr.AssertNumberOfCalls(t, "LookupHost", 1)
@@ -354,12 +652,179 @@ func Test_checkNodesHealth(t *testing.T) {
 		cl.deadNodes[node] = struct{}{}
 	}
 
-	cl.checkNodesHealth()
+	cl.checkNodesHealth(discoverForTests(t, cl))
 
 	assert.Equal(t, 3, len(cl.hr.GetAllNodes()))
 	assert.Equal(t, 2, len(cl.deadNodes))
 }
 
+func Test_updateTopology(t *testing.T) {
+	var (
+		alive = "127.0.0.1:12345"
+		dead  = "127.0.0.2:12345"
+
+		mockNetwork = new(MockNetworkOperations)
+		events      = make(chan TopologyEvent, 10)
+	)
+	cl := &Client{
+		ctx: context.TODO(),
+		nw: &network{
+			dial:       mockNetwork.Dial,
+			lookupHost: mockNetwork.LookupHost,
+		},
+		cfg:                 &config{HeadlessServiceAddress: "example.com", MemcachedPort: 12345},
+		timeout:             -1,
+		hr:                  consistenthash.NewHashRing(),
+		disableRefreshConns: true,
+		deadNodes:           make(map[string]struct{}),
+		topologyEvents:      events,
+	}
+	mockNetwork.On("LookupHost", "example.com").Return([]string{"127.0.0.1", "127.0.0.2"}, nil)
+	mockNetwork.On("Dial", "tcp", dead).Return(nil, errors.New("mocked dial error"))
+	mockNetwork.On("Dial", mock.Anything, mock.Anything).Return(&FakeConn{}, nil)
+	for _, node := range []string{alive, dead} {
+		addr, _ := utils.AddrRepr(node)
+		cl.hr.Add(addr)
+	}
+
+	cl.updateTopology()
+
+	mockNetwork.AssertNumberOfCalls(t, "LookupHost", 1)
+	assert.Equal(t, []string{alive}, cl.Nodes(), "updateTopology: the dead node should not be returned to the ring by the rebuild")
+	assert.Equal(t, []string{dead}, cl.DeadNodes())
+	require.Len(t, events, 1, "updateTopology: only the removal of the dead node should be sent")
+	assert.Equal(t, TopologyNodeDead, (<-events).Reason)
+}
+
+func Test_nodeRemovalGrace(t *testing.T) {
+	var (
+		stable  = "127.0.0.1:12345"
+		flaky   = "127.0.0.2:12345"
+		leaving = "127.0.0.3:12345"
+
+		dialErr     atomic.Bool
+		mockNetwork = &network{
+			dial: func(_, address string) (net.Conn, error) {
+				if address == flaky && dialErr.Load() {
+					return nil, errors.New("mocked dial error")
+				}
+				return &FakeConn{}, nil
+			},
+		}
+	)
+	cl := &Client{
+		ctx:                 context.TODO(),
+		nw:                  mockNetwork,
+		cfg:                 &config{Servers: []string{stable, flaky, leaving}},
+		timeout:             -1,
+		hr:                  consistenthash.NewHashRing(),
+		disableRefreshConns: true,
+		deadNodes:           make(map[string]struct{}),
+		removalGraceChecks:  3,
+	}
+	for _, node := range cl.cfg.Servers {
+		addr, _ := utils.AddrRepr(node)
+		cl.hr.Add(addr)
+	}
+
+	dialErr.Store(true)
+	cl.updateTopology()
+	cl.updateTopology()
+	assert.Len(t, cl.Nodes(), 3, "checkNodesHealth: the node should stay in the ring during the grace")
+	dialErr.Store(false)
+	cl.updateTopology()
+	dialErr.Store(true)
+	cl.updateTopology()
+	cl.updateTopology()
+	assert.Len(t, cl.Nodes(), 3, "checkNodesHealth: the passed check should reset the failures")
+	cl.updateTopology()
+	assert.Equal(t, []string{stable, leaving}, cl.Nodes(), "checkNodesHealth: the node should be removed after the grace")
+	assert.Equal(t, []string{flaky}, cl.DeadNodes())
+	dialErr.Store(false)
+	cl.updateTopology()
+	assert.Equal(t, []string{stable, flaky, leaving}, cl.Nodes(), "rebuildNodes: the recovered node should be added back")
+
+	cl.cfg.Servers = []string{stable, flaky}
+	cl.updateTopology()
+	cl.updateTopology()
+	assert.Len(t, cl.Nodes(), 3, "rebuildNodes: the absent node should stay in the ring during the grace")
+	cl.cfg.Servers = []string{stable, flaky, leaving}
+	cl.updateTopology()
+	cl.cfg.Servers = []string{stable, flaky}
+	cl.updateTopology()
+	cl.updateTopology()
+	assert.Len(t, cl.Nodes(), 3, "rebuildNodes: the discovered node should reset the absences")
+	cl.updateTopology()
+	assert.Equal(t, []string{stable, flaky}, cl.Nodes(), "rebuildNodes: the absent node should be removed after the grace")
+	assert.Empty(t, cl.absentNodes)
+}
+
+// discoverForTests returns the snapshot of the discovered nodes for the phases of updateTopology.
+func discoverForTests(t *testing.T, c *Client) topologySnapshot {
+	nodes, version, err := c.discoverNodesVersion()
+	require.Nil(t, err)
+	slices.Sort(nodes)
+	return topologySnapshot{nodes: nodes, version: version}
+}
+
+func Test_discoverNodesCache(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		lookups int
+		ips     = []string{"127.0.0.2", "127.0.0.1"}
+	)
+	cl := &Client{
+		ctx: context.TODO(),
+		nw: &network{
+			lookupHost: func(host string) ([]string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				lookups++
+				return slices.Clone(ips), nil
+			},
+			dialTimeout: func(_, _ string, _ time.Duration) (net.Conn, error) {
+				return &FakeConn{}, nil
+			},
+		},
+		cfg:                 &config{HeadlessServiceAddress: "example.com", MemcachedPort: 11211},
+		hr:                  consistenthash.NewHashRing(),
+		disableRefreshConns: true,
+		discoveryTTL:        time.Hour,
+	}
+
+	cl.updateTopology()
+	nodes, err := cl.discoverNodes()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.2:11211", "127.0.0.1:11211"}, nodes, "discoverNodes: the order of the lookup should be kept")
+	assert.Equal(t, 1, lookups, "discoverNodes: the nodes should be looked up once per ttl")
+	assert.Equal(t, []string{"127.0.0.1:11211", "127.0.0.2:11211"}, cl.Nodes())
+
+	// the ring is not diffed again, while the discovered and the dead nodes are the same.
+	addr, _ := utils.AddrRepr("127.0.0.1:11211")
+	cl.hr.Remove(addr)
+	cl.rebuildNodes(discoverForTests(t, cl))
+	assert.Equal(t, []string{"127.0.0.2:11211"}, cl.Nodes(), "rebuildNodes: the unchanged nodes should not touch the ring")
+	assert.Equal(t, uint64(1), cl.discoveryVersion)
+
+	// the same nodes in the other order don't change the version.
+	mu.Lock()
+	ips = []string{"127.0.0.1", "127.0.0.2"}
+	mu.Unlock()
+	cl.discoveredAt = time.Time{}
+	_, version, err := cl.discoverNodesVersion()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1), version, "discoverNodesVersion: the same nodes should keep the version")
+
+	mu.Lock()
+	ips = []string{"127.0.0.1", "127.0.0.3"}
+	mu.Unlock()
+	cl.discoveredAt = time.Time{}
+	cl.updateTopology()
+	assert.Equal(t, 3, lookups)
+	assert.Equal(t, uint64(2), cl.discoveryVersion)
+	assert.Equal(t, []string{"127.0.0.1:11211", "127.0.0.3:11211"}, cl.Nodes(), "rebuildNodes: the changed nodes should rebuild the ring")
+}
+
 func Test_rebuildNodes(t *testing.T) {
 	var (
 		mockNetworkErr = new(MockNetworkOperations)
@@ -379,7 +844,7 @@ func Test_rebuildNodes(t *testing.T) {
 	mockNetworkErr.On("LookupHost", cl.cfg.HeadlessServiceAddress).Return(nil, expectedErr)
 	mockNetworkErr.On("Dial", mock.Anything, mock.Anything).Return(&FakeConn{}, nil)
 
-	cl.rebuildNodes()
+	cl.updateTopology()
 
 	mockNetworkErr.AssertNotCalled(t, "Dial")
 	mockNetworkErr.AssertNumberOfCalls(t, "LookupHost", 1)
@@ -428,7 +893,7 @@ func Test_rebuildNodes(t *testing.T) {
 		cn.condRelease(new(error))
 	}
 
-	cl.rebuildNodes()
+	cl.rebuildNodes(discoverForTests(t, cl))
 
 	assert.Equal(t, 3, cl.hr.GetNodesCount())
 
@@ -444,6 +909,137 @@ func Test_rebuildNodes(t *testing.T) {
 	for _, pool := range cl.freeConns {
 		assert.Equal(t, 0, pool.Len())
 	}
+	for _, node := range alreadyDeadNodes {
+		_, ok := cl.freeConns[node]
+		assert.False(t, ok, "rebuildNodes: pool of the removed node should be destroyed")
+	}
+	for _, node := range expectedNodesInRing[:2] {
+		_, ok := cl.freeConns[node]
+		assert.True(t, ok, "rebuildNodes: pool of the remaining node should be kept")
+	}
+}
+
+func Test_rebuildNodesSlowStart(t *testing.T) {
+	const window = time.Hour
+	var (
+		oldNode = "127.0.0.1:12345"
+		newNode = "127.0.0.2:12345"
+
+		mockNetwork = new(MockNetworkOperations)
+	)
+	cl := &Client{
+		ctx: context.TODO(),
+		nw: &network{
+			dial:       mockNetwork.Dial,
+			lookupHost: mockNetwork.LookupHost,
+		},
+		cfg: &config{
+			Servers: []string{oldNode, newNode},
+		},
+		hr:                  consistenthash.NewHashRing(),
+		disableRefreshConns: true,
+		slowStartWindow:     window,
+	}
+	oldAddr, _ := utils.AddrRepr(oldNode)
+	cl.hr.Add(oldAddr)
+
+	replicas := func() map[string]int {
+		res := make(map[string]int)
+		for _, n := range cl.hr.(*consistenthash.HashRing).Snapshot().Nodes {
+			res[utils.Repr(n.Node)] = n.Replicas
+		}
+		return res
+	}
+	full := replicas()[oldNode]
+
+	cl.rebuildNodes(discoverForTests(t, cl))
+	assert.Equal(t, map[string]int{oldNode: full, newNode: full * slowStartMinPercent / 100}, replicas(),
+		"rebuildNodes: new node should start with a small weight")
+
+	cl.slowStartNodes[newNode] = time.Now().Add(-window / 2)
+	cl.rebuildNodes(discoverForTests(t, cl))
+	assert.Equal(t, map[string]int{oldNode: full, newNode: full / 2}, replicas(),
+		"rebuildNodes: weight of new node should grow")
+
+	cl.slowStartNodes[newNode] = time.Now().Add(-window)
+	cl.rebuildNodes(discoverForTests(t, cl))
+	assert.Equal(t, map[string]int{oldNode: full, newNode: full}, replicas(),
+		"rebuildNodes: new node should get the full weight after the window")
+	assert.Empty(t, cl.slowStartNodes, "rebuildNodes: slow start should be finished")
+}
+
+func Test_rebuildNodesRecoveryHook(t *testing.T) {
+	var (
+		oldNode = "127.0.0.1:12345"
+		newNode = "127.0.0.2:12345"
+
+		mockNetwork = new(MockNetworkOperations)
+		recovered   = make(chan net.Addr, 1)
+		owned       = make(chan []string, 1)
+	)
+	cl := &Client{
+		ctx: context.TODO(),
+		nw: &network{
+			dial:       mockNetwork.Dial,
+			lookupHost: mockNetwork.LookupHost,
+		},
+		cfg: &config{
+			Servers: []string{oldNode, newNode},
+		},
+		hr:                  consistenthash.NewHashRing(),
+		disableRefreshConns: true,
+		nodeRecoveryHook: func(_ context.Context, node net.Addr, owns func(key string) bool) {
+			var keys []string
+			for i := 0; i < 100; i++ {
+				if key := strconv.Itoa(i); owns(key) {
+					keys = append(keys, key)
+				}
+			}
+			recovered <- node
+			owned <- keys
+		},
+	}
+	oldAddr, _ := utils.AddrRepr(oldNode)
+	cl.hr.Add(oldAddr)
+
+	cl.rebuildNodes(discoverForTests(t, cl))
+	cl.bgWG.Wait()
+
+	require.Len(t, recovered, 1, "rebuildNodes: hook should be called for the added node")
+	assert.Equal(t, newNode, (<-recovered).String())
+	keys := <-owned
+	assert.NotEmpty(t, keys, "owns: the added node should own some keys")
+	for _, key := range keys {
+		node, _ := cl.hr.Get(key)
+		assert.Equal(t, newNode, utils.Repr(node), "owns: the key should be stored on the added node")
+	}
+
+	cl.rebuildNodes(discoverForTests(t, cl))
+	cl.bgWG.Wait()
+	assert.Len(t, recovered, 0, "rebuildNodes: hook should not be called without changes")
+}
+
+func Test_slowStartWeight(t *testing.T) {
+	cl := &Client{
+		slowStartWindow: 100 * time.Second,
+		nodesWeight:     map[string]int{"weighted": 50},
+	}
+	tests := []struct {
+		node    string
+		elapsed time.Duration
+		want    int
+	}{
+		{node: "node", elapsed: 0, want: slowStartMinPercent},
+		{node: "node", elapsed: 5 * time.Second, want: slowStartMinPercent},
+		{node: "node", elapsed: 40 * time.Second, want: 40},
+		{node: "weighted", elapsed: 40 * time.Second, want: 20},
+		{node: "weighted", elapsed: 0, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.node, tt.elapsed), func(t *testing.T) {
+			assert.Equal(t, tt.want, cl.slowStartWeight(tt.node, tt.elapsed))
+		})
+	}
 }
 
 type MockNetworkOperations struct {
@@ -1,14 +1,35 @@
 package memcached
 
 import (
+	"maps"
 	"time"
 
 	"github.com/aliexpressru/gomemcached/consistenthash"
+	"github.com/aliexpressru/gomemcached/logger"
 )
 
 type options struct {
 	Client
 	disableLogger bool
+
+	// hashReplicas, hashFunc and jumpHash are used to build the hash ring,
+	// if a custom one is not set by WithCustomHashRing.
+	hashReplicas int
+	hashFunc     consistenthash.Func
+	jumpHash     bool
+
+	// minServerVersion - minimum version of memcached checked at initialization, the check fails
+	// the initialization, if versionCheckFailFast, otherwise it is logged.
+	minServerVersion     string
+	versionCheckFailFast bool
+
+	// logFields - key-value pairs added to every record of the logger of the client.
+	logFields []any
+	// logSamplingPeriod, logSamplingFirst and logSamplingThereafter are the params of logger.NewSampled,
+	// the logs are not sampled, if logSamplingFirst is zero.
+	logSamplingPeriod     time.Duration
+	logSamplingFirst      int
+	logSamplingThereafter int
 }
 
 type Option func(*options)
@@ -21,7 +42,19 @@ func WithMaxIdleConns(num int) Option {
 	}
 }
 
+// WithMaxBodyLen is sets a custom maximum size of the value for Store, Append and MultiStore.
+// Larger values are rejected by the client with ErrDataSizeExceedsLimit before sending,
+// it makes sense to set the item size limit of memcached (-I, 1MB by default).
+// The received values are limited by the larger of size and MaxBodyLen.
+// By default, MaxBodyLen will be used.
+func WithMaxBodyLen(size int) Option {
+	return func(o *options) {
+		o.Client.maxBodyLen = size
+	}
+}
+
 // WithTimeout is sets custom timeout for connections.
+// It is used for dial, read and write, if they are not set by WithDialTimeout, WithReadTimeout, WithWriteTimeout.
 // By default, DefaultTimeout will be used.
 func WithTimeout(tm time.Duration) Option {
 	return func(o *options) {
@@ -29,6 +62,57 @@ func WithTimeout(tm time.Duration) Option {
 	}
 }
 
+// WithDialTimeout is sets custom timeout for establishing connections, also used by node health check.
+// By default, the value of WithTimeout will be used.
+func WithDialTimeout(tm time.Duration) Option {
+	return func(o *options) {
+		o.Client.dialTimeout = tm
+	}
+}
+
+// WithReadTimeout is sets custom timeout for waiting data from memcached.
+// The timeout limits every read from the socket, not the whole response.
+// By default, the value of WithTimeout will be used.
+func WithReadTimeout(tm time.Duration) Option {
+	return func(o *options) {
+		o.Client.readTimeout = tm
+	}
+}
+
+// WithWriteTimeout is sets custom timeout for sending data to memcached,
+// e.g. bulk MultiStore may need a longer timeout than dial.
+// The timeout limits every write to the socket, not the whole request.
+// By default, the value of WithTimeout will be used.
+func WithWriteTimeout(tm time.Duration) Option {
+	return func(o *options) {
+		o.Client.writeTimeout = tm
+	}
+}
+
+// WithTCPKeepAlive is sets a custom period between TCP keep-alive probes for connections.
+// Keep-alive prevents idle connections in the pool from being silently dropped by NATs and load balancers.
+// Negative value disables keep-alive. By default, the system keep-alive settings will be used.
+func WithTCPKeepAlive(period time.Duration) Option {
+	return func(o *options) {
+		o.Client.tcpKeepAlive = period
+	}
+}
+
+// WithDisableTCPNoDelay is disabled TCP_NODELAY for connections, so small writes may be buffered by the system.
+func WithDisableTCPNoDelay() Option {
+	return func(o *options) {
+		o.Client.disableTCPNoDelay = true
+	}
+}
+
+// WithTCPLinger is sets SO_LINGER in seconds for connections, see net.TCPConn.SetLinger.
+// By default, the system behavior will be used.
+func WithTCPLinger(sec int) Option {
+	return func(o *options) {
+		o.Client.tcpLinger = &sec
+	}
+}
+
 // WithCustomHashRing for setup use consistenthash.NewCustomHashRing
 func WithCustomHashRing(hr *consistenthash.HashRing) Option {
 	return func(o *options) {
@@ -36,7 +120,239 @@ func WithCustomHashRing(hr *consistenthash.HashRing) Option {
 	}
 }
 
+// WithJumpHash for setup use consistenthash.NewJumpHash instead of the hash ring.
+// Suitable for clusters with a fixed set of nodes, where nodes are only appended or removed from the end.
+// It requires WithDisableNodeProvider, because NodeProvider removes the dead nodes from any place of the list
+// and adds the recovered ones to the end, so the keys are remapped and the clients disagree on their nodes.
+func WithJumpHash() Option {
+	return func(o *options) {
+		o.jumpHash = true
+	}
+}
+
+// WithHashReplicas is sets a custom number of virtual nodes per server in the hash ring.
+// Values less than the default (256) are ignored.
+func WithHashReplicas(replicas int) Option {
+	return func(o *options) {
+		o.hashReplicas = replicas
+	}
+}
+
+// WithHashFunc is sets a custom hash function for distributing keys between servers,
+// e.g. consistenthash.Murmur3 or consistenthash.Fnv1a.
+// By default, consistenthash.Hash (xxhash) will be used.
+func WithHashFunc(fn consistenthash.Func) Option {
+	return func(o *options) {
+		o.hashFunc = fn
+	}
+}
+
+// WithRoutingKeyFunc is sets the function, that extracts the routing key from the key. The node of the key
+// is found by the hash of the routing key, so the keys with the same routing key (e.g. "user:123:profile"
+// and "user:123:settings" by "user:123") are placed on one node and MultiGet of them is sent to a single node.
+// The routing key is used only for hashing, the key itself is sent to memcached.
+// Changing the function moves the keys between the nodes, as changing the hash function.
+func WithRoutingKeyFunc(fn func(key string) string) Option {
+	return func(o *options) {
+		o.Client.routingKeyFunc = fn
+	}
+}
+
+// WithStableHostnames is turn on use hostnames of nodes in the hash ring instead of ip addresses.
+// Addresses from MEMCACHED_HEADLESS_SERVICE_ADDRESS are replaced with hostnames from the reverse lookup,
+// e.g. pod names of a StatefulSet. A restarted pod with a new ip address keeps its keys,
+// because connections resolve the hostname on every dial. If the address has several names, the names derived
+// from the ip address (e.g. 10-0-0-1.memcached.default.svc.cluster.local) are used only without the other ones.
+func WithStableHostnames() Option {
+	return func(o *options) {
+		o.Client.stableHostnames = true
+	}
+}
+
+// WithTTLDumpFallback is turn on the fallback of GetWithTTL for memcached older than 1.6 without the meta commands:
+// the value is got by GET and the TTL is found by the dump of all keys of the node within the timeout of the call.
+// The dump reads every key of the node on every call, so it is only for the small caches.
+// By default, ErrUnknownCommand is returned by such nodes.
+func WithTTLDumpFallback() Option {
+	return func(o *options) {
+		o.Client.ttlDumpFallback = true
+	}
+}
+
+// WithConnValidation is turn on the check of connections, that were idle in the pool longer than idle.
+// Such connection is checked by NOOP with DefaultConnValidationTimeout before use and replaced, if it is broken,
+// so the first request after an idle period doesn't fail on a connection dropped by NAT or load balancer.
+func WithConnValidation(idle time.Duration) Option {
+	return func(o *options) {
+		o.Client.connValidationIdle = idle
+	}
+}
+
+// WithSlowStart is turn on slow start for nodes added by NodeProvider. A new node gets a small part
+// of its weight in the hash ring, and the weight grows to the full one during the window,
+// so the cold node takes the keys gradually. The weight is updated every rebuild period
+// (see WithPeriodForRebuildingNodes), so the window should be several periods long.
+// It doesn't work with WithJumpHash, because it doesn't support weights.
+func WithSlowStart(window time.Duration) Option {
+	return func(o *options) {
+		o.Client.slowStartWindow = window
+	}
+}
+
+// WithNodeRecoveryHook is sets a hook, that is called when NodeProvider adds a node to the hash ring,
+// e.g. after the node recovery. The hook can re-populate the keys, which the node owns now.
+// It isn't called for the nodes of the initial configuration.
+func WithNodeRecoveryHook(hook NodeRecoveryHook) Option {
+	return func(o *options) {
+		o.Client.nodeRecoveryHook = hook
+	}
+}
+
+// WithFaultInjector is sets a hook, that can fail or slow down the requests before they are sent to the nodes,
+// e.g. NewFaultInjector. It is intended for chaos tests of the fallback paths, don't use it in production.
+func WithFaultInjector(injector FaultInjector) Option {
+	return func(o *options) {
+		o.Client.faultInjector = injector
+	}
+}
+
+// WithLocalCache is turn on the in-process LRU cache of Get and MultiGet results in front of memcached
+// for ultra-hot keys. size is the maximum number of items, ttl is the time of life of an item.
+// The keys are invalidated by the writes of this client, the writes of other clients are seen after ttl,
+// so ttl should be as short as the application can tolerate stale values.
+// The values cached by MultiGet are returned by Get without Extras.
+// Zero size or ttl turns off the cache.
+func WithLocalCache(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.Client.localCache = nil
+		if size > 0 && ttl > 0 {
+			o.Client.localCache = newLocalCache(size, ttl)
+		}
+	}
+}
+
+// WithOnLocalWrite is sets a hook, that is called with the keys written by Store, Append, Delta and MultiStore,
+// when the local cache is turned on (see WithLocalCache). It is used for invalidation of the keys
+// in the local caches of other instances.
+func WithOnLocalWrite(hook LocalInvalidationHook) Option {
+	return func(o *options) {
+		o.Client.onLocalWrite = hook
+	}
+}
+
+// WithOnLocalDelete is sets a hook, that is called with the keys deleted by Delete and MultiDelete,
+// and with nil keys by FlushAll, when the local cache is turned on (see WithLocalCache).
+func WithOnLocalDelete(hook LocalInvalidationHook) Option {
+	return func(o *options) {
+		o.Client.onLocalDelete = hook
+	}
+}
+
+// WithOnMultiGetMiss is sets a hook, that is called with the keys missed by MultiGet, e.g. to refill them
+// from the database. The keys of the nodes failed with errors are not misses. The hook is called synchronously
+// before MultiGet returns.
+func WithOnMultiGetMiss(hook MissHook) Option {
+	return func(o *options) {
+		o.Client.onMultiGetMiss = hook
+	}
+}
+
+// WithRetries is turn on the retries of the failed requests, that are safe to repeat (Get, Set without CAS, Touch,
+// the health checks), the other commands are not retried. The keys of the failed nodes of MultiGet are asked once again
+// from the next nodes of the ring. The request is retried up to attempts
+// times on the errors of IsRetryable, the first retry is sent after backoff, that is doubled for every next one.
+// The attempts share one deadline of the timeout of the call (see WithCallTimeout) or the read timeout of the client,
+// so the retries don't extend the latency of the call, the retry, that doesn't fit into the deadline, is not sent.
+// The retries are limited by the retry budget (see WithRetryBudget), by default each client has its own budget
+// of DefaultRetryBudgetTokens with DefaultRetryBudgetRatio.
+func WithRetries(attempts int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.Client.retryAttempts = attempts
+		o.Client.retryBackoff = backoff
+	}
+}
+
+// WithRetryBudget is sets the retry budget of the retries (see WithRetries) and the hedged requests (see WithHedging),
+// it may be shared by the clients of one cluster. The request, that is not retried because of the budget,
+// fails with ErrRetryBudgetExhausted.
+func WithRetryBudget(budget *RetryBudget) Option {
+	return func(o *options) {
+		o.Client.retryBudget = budget
+	}
+}
+
+// WithBatchWorkers is sets the number of the long-lived goroutines, that run the batches of the nodes
+// of MultiGet, MultiStore, MultiDelete, MultiDelta and Pipeline, so the frequent small batches don't start
+// the goroutines on every call. If all workers are busy, the batch is run by the new goroutine.
+// By default, every batch is run by the new goroutine.
+func WithBatchWorkers(n int) Option {
+	return func(o *options) {
+		o.Client.batchWorkers = n
+	}
+}
+
+// WithFlushEvery is sets when the write buffer of the batches of MultiGet, MultiStore, MultiDelete, MultiDelta,
+// Pipeline and the batched Gets is flushed: after the number of requests or after the number of buffered bytes,
+// zero turns the threshold off. So the server starts processing the long batch before it is written completely
+// and the socket gets less bursty. The write buffer is raised to bytes, if it is smaller.
+// By default, the buffer is flushed, when it is full (4KB), and at the end of the batch.
+func WithFlushEvery(requests, bytes int) Option {
+	return func(o *options) {
+		o.Client.flushEveryRequests = requests
+		o.Client.flushEveryBytes = bytes
+	}
+}
+
+// WithProxyProtocol is turn on PROXY protocol v2 header, that is sent after dialing the nodes behind
+// L4 load balancers, that require it. The header is sent to the nodes of addrs (see Client.Nodes)
+// or to all nodes, if none are given.
+func WithProxyProtocol(addrs ...string) Option {
+	return func(o *options) {
+		o.Client.proxyProtocol = true
+		if len(addrs) != 0 {
+			o.Client.proxyProtocolNodes = make(map[string]struct{}, len(addrs))
+			for _, addr := range addrs {
+				o.Client.proxyProtocolNodes[normalizeNode(addr)] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithHedging is turn on hedged requests for Get. If the node doesn't answer within the delay,
+// the same request is sent to the next node of the hash ring, and the first successful response is returned.
+// Makes sense only if the data is written to several nodes.
+func WithHedging(delay time.Duration) Option {
+	return func(o *options) {
+		o.Client.hedgingDelay = delay
+	}
+}
+
+// WithGetBatching is turn on the coalescing of the single Gets. The Gets of a node arriving within the window
+// (e.g. 500µs) are sent by one pipelined GETQ batch, so at high QPS they cost fewer syscalls and connections
+// for the latency of up to the window. The batch is sent before the end of the window, when it has maxKeys keys,
+// if maxKeys is not positive, DefaultGetBatchKeys is used. The hedged Gets (see WithHedging) are not batched.
+func WithGetBatching(window time.Duration, maxKeys int) Option {
+	return func(o *options) {
+		if maxKeys <= 0 {
+			maxKeys = DefaultGetBatchKeys
+		}
+		o.Client.getBatchWindow = window
+		o.Client.getBatchKeys = maxKeys
+	}
+}
+
+// WithKeyVerification is turn on the verification of the keys in MultiGet. The values are requested by GETKQ
+// instead of GETQ, and the key of every response is compared with the key of the request, not only the opaque,
+// so a desynced connection fails with ErrProtocolDesync instead of mixing up the values.
+// It costs the keys in the responses.
+func WithKeyVerification() Option {
+	return func(o *options) {
+		o.Client.verifyKeys = true
+	}
+}
+
 // WithPeriodForNodeHealthCheck is sets a custom frequency for health checker of physical nodes.
+// The health check and the rebuilding of the nodes run by one loop with the shorter of their periods.
 // By default, DefaultNodeHealthCheckPeriod will be used.
 func WithPeriodForNodeHealthCheck(t time.Duration) Option {
 	return func(o *options) {
@@ -52,6 +368,52 @@ func WithPeriodForRebuildingNodes(t time.Duration) Option {
 	}
 }
 
+// WithDiscoveryCacheTTL is sets the time for which the nodes discovered by lookup of the headless service
+// (or from MEMCACHED_SERVERS) are cached, so the health check and the rebuilding of the nodes share one lookup.
+// The ring is rebuilt only when the discovered nodes or the dead nodes are changed.
+// By default (or with zero ttl), DefaultDiscoveryCacheTTL will be used, only the negative ttl turns the cache off.
+func WithDiscoveryCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.Client.discoveryTTL = ttl
+	}
+}
+
+// WithNodeRemovalGrace is sets the number of the consecutive health checks, that the node must fail,
+// or the discoveries, that must miss the node, before it is removed from the ring. So the short restart
+// of the node (e.g. the pod of the rolling restart) doesn't reshuffle its keys twice.
+// By default, the node is removed after the first failure.
+func WithNodeRemovalGrace(checks int) Option {
+	return func(o *options) {
+		o.Client.removalGraceChecks = checks
+	}
+}
+
+// Method is the method of the client, that can have its own timeout, see WithMethodTimeouts.
+type Method string
+
+const (
+	MethodGet         Method = "Get"
+	MethodStore       Method = "Store"
+	MethodDelete      Method = "Delete"
+	MethodTouch       Method = "Touch"
+	MethodDelta       Method = "Delta"
+	MethodAppend      Method = "Append"
+	MethodMultiGet    Method = "MultiGet"
+	MethodMultiStore  Method = "MultiStore"
+	MethodMultiDelete Method = "MultiDelete"
+	MethodMultiDelta  Method = "MultiDelta"
+)
+
+// WithMethodTimeouts is sets the read and write timeouts of the methods instead of the timeouts of the client,
+// e.g. MultiStore of big batches can have 2s, while Get stays at 50ms. The timeout of the multi-key method
+// limits every read and write of the batches of the nodes, MultiStore covers MultiStoreWithCAS and Preload too.
+// WithCallTimeout of the call overrides the timeout of its method.
+func WithMethodTimeouts(timeouts map[Method]time.Duration) Option {
+	return func(o *options) {
+		o.Client.methodTimeouts = maps.Clone(timeouts)
+	}
+}
+
 // WithDisableNodeProvider is disabled node health cheek and rebuild nodes for hash ring
 func WithDisableNodeProvider() Option {
 	return func(o *options) {
@@ -67,15 +429,166 @@ func WithDisableRefreshConnsInPool() Option {
 	}
 }
 
+// WithConnsRefreshPerPeriod is sets a number of connections closed in every pool per rebuild period,
+// so the pools are refreshed gradually. Zero disables the refresh like WithDisableRefreshConnsInPool.
+// By default, DefaultOfNumberConnsToDestroyPerRBPeriod will be used.
+func WithConnsRefreshPerPeriod(num int) Option {
+	return func(o *options) {
+		o.Client.connsRefreshPerPeriod = &num
+	}
+}
+
+// WithConnRetryCount is sets a number of dial retries on timeout before the node is considered dead
+// by the health check. Zero disables the retries.
+// By default, DefaultRetryCountForConn will be used.
+func WithConnRetryCount(num uint8) Option {
+	return func(o *options) {
+		o.Client.connRetryCount = &num
+	}
+}
+
+// WithMultiplexing is turn on the multiplexed transport for Store, Get, Delete, Delta and Append.
+// Requests are pipelined on connsPerNode connections per node and responses are matched by opaque,
+// so a few connections serve many concurrent requests without waiting for the pool.
+// MultiGet, MultiStore and MultiDelete still use connections from the pool.
+func WithMultiplexing(connsPerNode int) Option {
+	return func(o *options) {
+		o.Client.muxConnsPerNode = connsPerNode
+	}
+}
+
+// WithMaxInflightPerNode is sets the maximum number of requests in flight to one node (Multi* methods take
+// one slot per node). The request to the saturated node fails with ErrNodeSaturated at once (or waits in the queue,
+// see WithRequestQueue), so a degraded node doesn't hold all goroutines of the process, while the healthy nodes are
+// waiting. By default, there is no limit.
+func WithMaxInflightPerNode(n int) Option {
+	return func(o *options) {
+		o.Client.maxInflightPerNode = n
+	}
+}
+
+// WithRequestQueue is turn on the bounded queue of the requests to the node, when its requests in flight
+// (see WithMaxInflightPerNode) or its pool of connections are exhausted, so a short burst waits for a slot instead of
+// failing at once. At most size requests wait in the queue not longer than maxWait and the timeout of the call,
+// the expired requests fail with ErrNodeSaturated. When the queue is full, the policy rejects the new
+// or the oldest request. By default, maxWait is DefaultSocketPoolingTimeout.
+func WithRequestQueue(size int, maxWait time.Duration, policy ShedPolicy) Option {
+	return func(o *options) {
+		o.Client.queueSize = size
+		o.Client.queueMaxWait = maxWait
+		o.Client.shedPolicy = policy
+	}
+}
+
+// WithMinIdleConns is sets a number of connections per node, that Client.Warmup dials ahead of traffic.
+// The pools are also refilled up to this number by NodeProvider.
+func WithMinIdleConns(num int) Option {
+	return func(o *options) {
+		o.Client.minIdleConns = num
+	}
+}
+
+// WithMaxConnLifetime is sets a maximum amount of time a connection may be reused.
+// Connections are retired by the pool, so the refresh of conns in NodeProvider is turned off.
+// By default, the lifetime is not limited.
+func WithMaxConnLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.Client.maxConnLifetime = d
+	}
+}
+
+// WithMaxConnIdleTime is sets a maximum amount of time a connection may be idle in the pool.
+// Connections are retired by the pool, so the refresh of conns in NodeProvider is turned off.
+// By default, the idle time is not limited.
+func WithMaxConnIdleTime(d time.Duration) Option {
+	return func(o *options) {
+		o.Client.maxConnIdleTime = d
+	}
+}
+
 // WithDisableMemcachedDiagnostic is disabled write library metrics.
 //
 //	gomemcached_method_duration_seconds
+//	gomemcached_method_requests_total
+//	gomemcached_method_keys_total
+//	gomemcached_pool_connections_total
 func WithDisableMemcachedDiagnostic() Option {
 	return func(o *options) {
 		o.Client.disableMemcachedDiagnostic = true
 	}
 }
 
+// WithDurationBuckets is sets the buckets of gomemcached_method_duration_seconds in seconds for the client,
+// e.g. prometheus.ExponentialBuckets(0.0001, 2, 16) for the clusters in RAM of the same host or larger ones
+// for the cross-region clusters. By default, DefaultDurationBuckets are used.
+// The histogram with the buckets is collected by the client, see Client.Collect.
+func WithDurationBuckets(buckets ...float64) Option {
+	return func(o *options) {
+		if len(buckets) != 0 {
+			o.Client.methodDurationSeconds = newMethodDurationSeconds(buckets)
+		}
+	}
+}
+
+// WithLogger is sets the logger of the client, e.g. logger.NewSlog(slog.Default()) or logger.NewZap(sugared),
+// so the logs of every client go to the logging of the application.
+// By default, the global logger of the package logger is used (see logger.SetLogger).
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) {
+		o.Client.logger = l
+	}
+}
+
+// WithLogFields is sets the fields added to every log record of the client, e.g. the name of the cluster
+// and the environment, args are key-value pairs: WithLogFields("cluster", "sessions", "env", "prod").
+// The records about the operations also have the fields of the node, the opcode and the hash of the key.
+func WithLogFields(args ...any) Option {
+	return func(o *options) {
+		o.logFields = append(o.logFields, args...)
+	}
+}
+
+// WithLogSampling is turn on the sampling of the logs of the client: in every period the first records of every message
+// are written and then every thereafter-th one, e.g. WithLogSampling(time.Minute, 5, 100). So a dead node, that is
+// reported on every health check, or the repeated errors of the batches don't flood the logs (see logger.NewSampled).
+func WithLogSampling(period time.Duration, first, thereafter int) Option {
+	return func(o *options) {
+		o.logSamplingPeriod = period
+		o.logSamplingFirst = first
+		o.logSamplingThereafter = thereafter
+	}
+}
+
+// WithWireLogging is turn on the debug logging of the frames of the binary protocol, that are written and read
+// by the connections: the magic, opcode, status, lengths, opaque and CAS of every header. The bodies with keys and values
+// are dumped in hex (up to 1 KiB), only if withBodies. It is used for diagnosing of desync and the issues with proxies,
+// as it logs every request, it must not be turned on in production.
+func WithWireLogging(withBodies bool) Option {
+	return func(o *options) {
+		o.Client.wireLogging = true
+		o.Client.wireLoggingBodies = withBodies
+	}
+}
+
+// WithTrafficCapture is turn on the capture of the traffic of the connections to rec, see NewTrafficRecorder.
+// The capture is replayed by Client.Replay in the load tests. rec is not closed by the client.
+func WithTrafficCapture(rec *TrafficRecorder) Option {
+	return func(o *options) {
+		o.Client.trafficRecorder = rec
+	}
+}
+
+// WithEncryption is turn on AES-GCM encryption of the values by the keys of kp, the id of the key is stored
+// in the flags of the item (see KeyProvider), so WithFlags can't be used with it. The values are encrypted by Store,
+// MultiStore and Pipeline and decrypted by Get, Lookup, MultiGet and Pipeline, the items with zero flags
+// are read as is. Append is failed with ErrInvalidArguments, the counters of Delta and RoundTrip are not encrypted.
+// The encrypted value is 28 bytes longer, than the plain one.
+func WithEncryption(kp KeyProvider) Option {
+	return func(o *options) {
+		o.Client.keyProvider = kp
+	}
+}
+
 // WithDisableLogger is disabled internal library logs.
 func WithDisableLogger() Option {
 	return func(o *options) {
@@ -83,6 +596,16 @@ func WithDisableLogger() Option {
 	}
 }
 
+// WithMinServerVersion is turn on the check of memcached versions at initialization. The versions of all nodes
+// are got by VERSION and logged, if any node is below minVersion (e.g. "1.6.9") or didn't answer,
+// InitFromEnv fails with ErrServerVersion, if failFast, otherwise the warning is logged.
+func WithMinServerVersion(minVersion string, failFast bool) Option {
+	return func(o *options) {
+		o.minServerVersion = minVersion
+		o.versionCheckFailFast = failFast
+	}
+}
+
 // WithAuthentication is turn on authenticate for memcached
 func WithAuthentication(user, pass string) Option {
 	return func(o *options) {
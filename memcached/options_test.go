@@ -1,14 +1,18 @@
 package memcached
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/aliexpressru/gomemcached/consistenthash"
 	"github.com/aliexpressru/gomemcached/logger"
+	"github.com/aliexpressru/gomemcached/utils"
 )
 
 func TestWithOptions(t *testing.T) {
@@ -17,6 +21,58 @@ func TestWithOptions(t *testing.T) {
 	hMcl, _ := InitFromEnv()
 	assert.NotNil(t, hMcl.hr, "InitFromEnv: hash ring is nil")
 
+	jMcl, _ := InitFromEnv(WithJumpHash(), WithDisableNodeProvider())
+	assert.IsType(t, &consistenthash.JumpHash{}, jMcl.hr, "WithJumpHash should set jump hash")
+	assert.Equal(t, 1, jMcl.hr.GetNodesCount(), "WithJumpHash: nodes from config should be added")
+	_, err := InitFromEnv(WithJumpHash())
+	assert.ErrorIs(t, err, ErrNotConfigured, "WithJumpHash without WithDisableNodeProvider should be rejected")
+
+	// jump hash supports the changes only at the tail, so the nodes are added in the order of the config.
+	servers := []string{"127.0.0.9:11211", "127.0.0.10:11211", "127.0.0.11:11211"}
+	os.Setenv("MEMCACHED_SERVERS", strings.Join(servers, ","))
+	oMcl, err := InitFromEnv(WithJumpHash(), WithDisableNodeProvider())
+	os.Setenv("MEMCACHED_SERVERS", "localhost:11211")
+	require.Nil(t, err)
+	wantJump := consistenthash.NewJumpHash()
+	for _, server := range servers {
+		addr, aErr := utils.AddrRepr(server)
+		require.Nil(t, aErr)
+		wantJump.Add(addr)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		got, _ := oMcl.hr.Get(key)
+		want, _ := wantJump.Get(key)
+		assert.Equal(t, utils.Repr(want), utils.Repr(got), "WithJumpHash: placement of %s should follow the order of the config", key)
+	}
+
+	os.Setenv("MEMCACHED_SERVERS", "127.0.0.1:11211,127.0.0.2:11211,127.0.0.3:11211")
+	rMcl, _ := InitFromEnv(WithHashReplicas(512), WithHashFunc(consistenthash.Murmur3), WithDisableNodeProvider())
+	os.Setenv("MEMCACHED_SERVERS", "localhost:11211")
+	require.IsType(t, &consistenthash.HashRing{}, rMcl.hr)
+	snapshot := rMcl.hr.(*consistenthash.HashRing).Snapshot()
+	require.Len(t, snapshot.Nodes, 3, "WithHashReplicas: nodes from config should be added")
+	for _, n := range snapshot.Nodes {
+		assert.Equal(t, 512, n.Replicas, "WithHashReplicas should set the replicas of the nodes")
+	}
+	wantRing := consistenthash.NewCustomHashRing(512, consistenthash.Murmur3)
+	defaultRing := consistenthash.NewCustomHashRing(512, nil)
+	for _, n := range rMcl.hr.GetAllNodes() {
+		wantRing.Add(n)
+		defaultRing.Add(n)
+	}
+	var moved int
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		got, _ := rMcl.hr.Get(key)
+		want, _ := wantRing.Get(key)
+		assert.Equal(t, utils.Repr(want), utils.Repr(got), "WithHashFunc: placement of %s should match Murmur3 ring", key)
+		if def, _ := defaultRing.Get(key); utils.Repr(def) != utils.Repr(got) {
+			moved++
+		}
+	}
+	assert.NotZero(t, moved, "WithHashFunc: placement should differ from the default hash func")
+
 	const (
 		maxIdleConns = 10
 		disable      = true
@@ -31,6 +87,12 @@ func TestWithOptions(t *testing.T) {
 	mcl, _ := InitFromEnv(
 		WithMaxIdleConns(maxIdleConns),
 		WithTimeout(timeout),
+		WithDialTimeout(time.Second),
+		WithReadTimeout(2*time.Second),
+		WithWriteTimeout(3*time.Second),
+		WithTCPKeepAlive(period),
+		WithDisableTCPNoDelay(),
+		WithTCPLinger(0),
 		WithCustomHashRing(hr),
 		WithPeriodForNodeHealthCheck(period),
 		WithPeriodForRebuildingNodes(period),
@@ -39,6 +101,33 @@ func TestWithOptions(t *testing.T) {
 		WithDisableMemcachedDiagnostic(),
 		WithAuthentication(authUser, authPass),
 		WithDisableLogger(),
+		WithStableHostnames(),
+		WithConnValidation(period),
+		WithMaxConnLifetime(timeout),
+		WithMaxConnIdleTime(period),
+		WithMinIdleConns(maxIdleConns),
+		WithConnsRefreshPerPeriod(2),
+		WithConnRetryCount(0),
+		WithLocalCache(maxIdleConns, period),
+		WithOnLocalWrite(func([]string) {}),
+		WithOnLocalDelete(func([]string) {}),
+		WithKeyVerification(),
+		WithRoutingKeyFunc(func(key string) string { return key }),
+		WithMaxInflightPerNode(10),
+		WithLogger(logger.Nop()),
+		WithLogFields("cluster", "test"),
+		WithLogSampling(time.Minute, 5, 100),
+		WithDurationBuckets(0.0001, 0.001, 0.01),
+		WithRequestQueue(100, time.Second, ShedOldest),
+		WithRetries(3, period),
+		WithBatchWorkers(4),
+		WithProxyProtocol("127.0.0.1:11211"),
+		WithDiscoveryCacheTTL(time.Minute),
+		WithNodeRemovalGrace(3),
+		WithMethodTimeouts(map[Method]time.Duration{MethodMultiStore: time.Second}),
+		WithGetBatching(500*time.Microsecond, 0),
+		WithFlushEvery(100, 64<<10),
+		WithTTLDumpFallback(),
 	)
 	t.Cleanup(func() {
 		mcl.CloseAllConns()
@@ -46,6 +135,14 @@ func TestWithOptions(t *testing.T) {
 
 	assert.Equal(t, maxIdleConns, mcl.maxIdleConns, "WithMaxIdleConns should set maxIdleConns")
 	assert.Equal(t, timeout, mcl.timeout, "WithTimeout should set timeout")
+	assert.Equal(t, time.Second, mcl.dialTimeout, "WithDialTimeout should set dialTimeout")
+	assert.Equal(t, 2*time.Second, mcl.readTimeout, "WithReadTimeout should set readTimeout")
+	assert.Equal(t, 3*time.Second, mcl.writeTimeout, "WithWriteTimeout should set writeTimeout")
+	assert.Equal(t, period, mcl.tcpKeepAlive, "WithTCPKeepAlive should set tcpKeepAlive")
+	assert.Equal(t, disable, mcl.disableTCPNoDelay, "WithDisableTCPNoDelay should set disable")
+	if assert.NotNil(t, mcl.tcpLinger, "WithTCPLinger should set tcpLinger") {
+		assert.Equal(t, 0, *mcl.tcpLinger, "WithTCPLinger should set tcpLinger")
+	}
 	assert.Equal(t, hr, mcl.hr, "WithCustomHashRing should set hr")
 	assert.Equal(t, period, mcl.nodeHCPeriod, "WithPeriodForNodeHealthCheck should set period")
 	assert.Equal(t, period, mcl.nodeRBPeriod, "WithPeriodForRebuildingNodes should set period")
@@ -54,4 +151,47 @@ func TestWithOptions(t *testing.T) {
 	assert.Equal(t, disable, mcl.disableMemcachedDiagnostic, "WithDisableMemcachedDiagnostic should set disable")
 	assert.Equal(t, enable, mcl.authEnable, "WithAuthentication should set enable")
 	assert.Equal(t, disable, logger.LoggerIsDisable(), "WithDisableLogger should set disable")
+	assert.IsType(t, logger.NewSampled(logger.Nop(), 0, 1, 1), mcl.logger, "WithLogger and WithLogSampling should set logger")
+	assert.NotNil(t, mcl.methodDurationSeconds, "WithDurationBuckets should set methodDurationSeconds")
+	assert.Equal(t, enable, mcl.stableHostnames, "WithStableHostnames should set enable")
+	assert.Equal(t, period, mcl.connValidationIdle, "WithConnValidation should set connValidationIdle")
+	assert.Equal(t, timeout, mcl.maxConnLifetime, "WithMaxConnLifetime should set maxConnLifetime")
+	assert.Equal(t, period, mcl.maxConnIdleTime, "WithMaxConnIdleTime should set maxConnIdleTime")
+	assert.Equal(t, maxIdleConns, mcl.minIdleConns, "WithMinIdleConns should set minIdleConns")
+	if assert.NotNil(t, mcl.connsRefreshPerPeriod, "WithConnsRefreshPerPeriod should set connsRefreshPerPeriod") {
+		assert.Equal(t, 2, *mcl.connsRefreshPerPeriod, "WithConnsRefreshPerPeriod should set connsRefreshPerPeriod")
+	}
+	if assert.NotNil(t, mcl.connRetryCount, "WithConnRetryCount should set connRetryCount") {
+		assert.Equal(t, uint8(0), *mcl.connRetryCount, "WithConnRetryCount should set connRetryCount")
+	}
+	if assert.NotNil(t, mcl.localCache, "WithLocalCache should set localCache") {
+		assert.Equal(t, maxIdleConns, mcl.localCache.size, "WithLocalCache should set size")
+		assert.Equal(t, period, mcl.localCache.ttl, "WithLocalCache should set ttl")
+	}
+	assert.NotNil(t, mcl.onLocalWrite, "WithOnLocalWrite should set onLocalWrite")
+	assert.NotNil(t, mcl.onLocalDelete, "WithOnLocalDelete should set onLocalDelete")
+	assert.True(t, mcl.verifyKeys, "WithKeyVerification should set verifyKeys")
+	assert.NotNil(t, mcl.routingKeyFunc, "WithRoutingKeyFunc should set routingKeyFunc")
+	assert.Equal(t, 10, mcl.maxInflightPerNode, "WithMaxInflightPerNode should set maxInflightPerNode")
+	assert.Equal(t, 100, mcl.queueSize, "WithRequestQueue should set queueSize")
+	assert.Equal(t, time.Second, mcl.queueMaxWait, "WithRequestQueue should set queueMaxWait")
+	assert.Equal(t, ShedOldest, mcl.shedPolicy, "WithRequestQueue should set shedPolicy")
+	assert.Equal(t, 3, mcl.retryAttempts, "WithRetries should set retryAttempts")
+	assert.Equal(t, period, mcl.retryBackoff, "WithRetries should set retryBackoff")
+	assert.NotNil(t, mcl.retryBudget, "WithRetries should set the default retryBudget")
+	assert.Equal(t, 4, mcl.batchWorkers, "WithBatchWorkers should set batchWorkers")
+	assert.True(t, mcl.proxyProtocol, "WithProxyProtocol should set proxyProtocol")
+	assert.Contains(t, mcl.proxyProtocolNodes, "127.0.0.1:11211", "WithProxyProtocol should set proxyProtocolNodes")
+	assert.Equal(t, time.Minute, mcl.discoveryTTL, "WithDiscoveryCacheTTL should set discoveryTTL")
+	zMcl, _ := InitFromEnv(WithDiscoveryCacheTTL(0), WithDisableNodeProvider())
+	assert.Equal(t, DefaultDiscoveryCacheTTL, zMcl.discoveryTTL, "WithDiscoveryCacheTTL: zero ttl should be the default")
+	nMcl, _ := InitFromEnv(WithDiscoveryCacheTTL(-1), WithDisableNodeProvider())
+	assert.Equal(t, time.Duration(-1), nMcl.discoveryTTL, "WithDiscoveryCacheTTL: negative ttl should turn the cache off")
+	assert.Equal(t, 3, mcl.removalGraceChecks, "WithNodeRemovalGrace should set removalGraceChecks")
+	assert.Equal(t, time.Second, mcl.methodTimeouts[MethodMultiStore], "WithMethodTimeouts should set methodTimeouts")
+	assert.Equal(t, 500*time.Microsecond, mcl.getBatchWindow, "WithGetBatching should set getBatchWindow")
+	assert.Equal(t, DefaultGetBatchKeys, mcl.getBatchKeys, "WithGetBatching should set the default getBatchKeys")
+	assert.Equal(t, 100, mcl.flushEveryRequests, "WithFlushEvery should set flushEveryRequests")
+	assert.Equal(t, 64<<10, mcl.writeBufferSize(), "WithFlushEvery should raise the write buffer")
+	assert.True(t, mcl.ttlDumpFallback, "WithTTLDumpFallback should set ttlDumpFallback")
 }
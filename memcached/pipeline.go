@@ -0,0 +1,257 @@
+package memcached
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// Pipeline is a set of operations with different keys and commands, that Exec sends in one batch per node
+	// with a single flush, e.g. the reads and the writes of one request of the application.
+	// Get, Store, Delete and Append are sent by the quiet commands, Delta by the usual one, as it returns the value.
+	// Pipeline is not safe for concurrent use.
+	Pipeline struct {
+		c   *Client
+		ops []pipelineOp
+	}
+
+	// PipelineResult is the result of the operation of Pipeline.
+	PipelineResult struct {
+		// Key is the key of the operation.
+		Key string
+		// Response is the response of memcached, it is nil for the successful Store, Delete and Append,
+		// as the quiet commands don't respond on success.
+		Response *Response
+		// Err is the error of the operation, e.g. ErrCacheMiss for the missing item of Get.
+		Err error
+	}
+
+	pipelineOp struct {
+		req *Request
+		// err is the error of the validation, the operation with it is not sent.
+		err error
+	}
+)
+
+// Pipeline returns a new empty Pipeline of the client.
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Len returns the number of the operations in the pipeline.
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+// Get is add the getting of the item for provided key.
+func (p *Pipeline) Get(key string) {
+	req := &Request{Opcode: GETQ, Key: []byte(key)}
+	req.prepareExtras(0, 0, 0)
+	p.add(req, nil)
+}
+
+// Store is add the writing of the provided item with expiration.
+func (p *Pipeline) Store(storeMode StoreMode, key string, exp uint32, body []byte) {
+	var (
+		flags uint32
+		err   error
+	)
+	if p.c.keyProvider != nil {
+		flags, body, err = p.c.encryptValue(key, body)
+	}
+	req := &Request{Opcode: storeMode.Resolve().changeOnQuiet(SETQ), Key: []byte(key), Body: body}
+	req.prepareExtras(exp, 0, 0)
+	binary.BigEndian.PutUint32(req.Extras[:4], flags)
+	if err == nil {
+		err = p.c.checkBodyLen(key, body)
+	}
+	p.add(req, err)
+}
+
+// Delete is add the deleting of the item with the provided key.
+func (p *Pipeline) Delete(key string) {
+	req := &Request{Opcode: DELETEQ, Key: []byte(key)}
+	req.prepareExtras(0, 0, 0)
+	p.add(req, nil)
+}
+
+// Delta is add the increment/decrement of the counter, the new value is in the body of the response.
+func (p *Pipeline) Delta(deltaMode DeltaMode, key string, delta, initial uint64, exp uint32) {
+	req := &Request{Opcode: deltaMode.Resolve(), Key: []byte(key)}
+	req.prepareExtras(exp, delta, initial)
+	p.add(req, nil)
+}
+
+// Append is add the appending/prepending of data to the existing item.
+func (p *Pipeline) Append(appendMode AppendMode, key string, data []byte) {
+	req := &Request{Opcode: appendMode.Resolve().changeOnQuiet(APPENDQ), Key: []byte(key), Body: data}
+	req.prepareExtras(0, 0, 0)
+	err := p.c.checkEncryptedAppend()
+	if err == nil {
+		err = p.c.checkBodyLen(key, data)
+	}
+	p.add(req, err)
+}
+
+func (p *Pipeline) add(req *Request, err error) {
+	if err == nil && !legalKey(string(req.Key)) {
+		err = ErrMalformedKey
+	}
+	p.ops = append(p.ops, pipelineOp{req: req, err: err})
+}
+
+// Exec sends the operations to their nodes in parallel and returns the results in order of adding.
+// The deadline of ctx limits the reads and writes of the batches. The errors of the operations are in the results,
+// the returned error joins the errors of the nodes (the results of their operations have the same error).
+func (p *Pipeline) Exec(ctx context.Context) (_ []PipelineResult, err error) {
+	c := p.c
+	timerMethod := time.Now()
+	defer c.writeMethodDiagnostics("Pipeline", timerMethod, &err)
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]PipelineResult, len(p.ops))
+	byNode := make(map[any][]int)
+	var written, deleted []string
+	for i, op := range p.ops {
+		key := string(op.req.Key)
+		results[i] = PipelineResult{Key: key, Err: op.err}
+		if op.err != nil {
+			continue
+		}
+
+		node, nErr := c.nodeForKey(key, &CallOptions{})
+		if nErr != nil {
+			results[i].Err = nErr
+			continue
+		}
+		byNode[node] = append(byNode[node], i)
+
+		switch op.req.Opcode {
+		case GETQ:
+		case DELETEQ:
+			deleted = append(deleted, key)
+		default:
+			written = append(written, key)
+		}
+	}
+	if len(written) != 0 {
+		defer c.invalidateLocal(c.onLocalWrite, written...)
+	}
+	if len(deleted) != 0 {
+		defer c.invalidateLocal(c.onLocalDelete, deleted...)
+	}
+
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		multiErr error
+	)
+	for node, idx := range byNode {
+		node, idx := node, idx
+		wg.Add(1)
+		c.goBatch(func() {
+			defer wg.Done()
+
+			if nErr := p.execNode(node, idx, results, timeout); nErr != nil {
+				for _, i := range idx {
+					if results[i].Err == nil {
+						results[i].Err = nErr
+					}
+				}
+				mu.Lock()
+				multiErr = errors.Join(multiErr, nErr)
+				mu.Unlock()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return results, multiErr
+}
+
+// execNode sends the operations idx to the node and writes their results, every goroutine has its own idx.
+func (p *Pipeline) execNode(node any, idx []int, results []PipelineResult, timeout time.Duration) (cnErr error) {
+	c := p.c
+	if fErr := c.injectFault(node, p.ops[idx[0]].req.Opcode); fErr != nil {
+		return fErr
+	}
+
+	release, err := c.acquireNode(node, timeout)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	cn, err := c.getConnForNode(node)
+	if err != nil {
+		return err
+	}
+	defer cn.condRelease(&cnErr)
+	defer cn.setTimeout(timeout)()
+
+	opcodes := make([]OpCode, len(idx))
+	keys := make([]string, len(idx))
+	for j, i := range idx {
+		opcodes[j] = p.ops[i].req.Opcode
+		keys[j] = results[i].Key
+	}
+	b := cn.newMixedBatch(opcodes, keys)
+
+	cnErr = cn.writeBatch(b, func(j int) *Request {
+		req := *p.ops[idx[j]].req
+		return &req
+	})
+	if cnErr != nil {
+		return cnErr
+	}
+
+	answered := make([]bool, len(idx))
+	for {
+		var resp *Response
+		resp, _, cnErr = cn.receive()
+		// the errors of the statuses are the responses to the requests of the batch.
+		if cnErr != nil && UnwrapMemcachedError(cnErr) == nil {
+			cn.healthy = false
+			return cnErr
+		}
+
+		_, done, mErr := b.match(resp)
+		if mErr != nil {
+			cnErr = mErr
+			cn.healthy = false
+			return mErr
+		}
+		if done {
+			break
+		}
+
+		j := b.index(resp)
+		answered[j] = true
+		if cnErr == nil && opcodes[j] == GETQ {
+			cnErr = c.decryptResponse(keys[j], resp)
+		}
+		results[idx[j]].Response = resp
+		results[idx[j]].Err = cnErr
+		cnErr = nil
+	}
+
+	// GETQ doesn't respond on the cache miss.
+	for j, i := range idx {
+		if !answered[j] && opcodes[j] == GETQ {
+			results[i].Err = ErrCacheMiss
+		}
+	}
+	return nil
+}
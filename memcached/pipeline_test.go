@@ -0,0 +1,103 @@
+package memcached
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Pipeline(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		items = map[string][]byte{"a": []byte("1"), "deleted": []byte("2")}
+	)
+	handler := func(req *Request) *Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := string(req.Key)
+		value, ok := items[key]
+		switch req.Opcode {
+		case GETQ:
+			if !ok {
+				return nil
+			}
+			return &Response{Body: value}
+		case SETQ:
+			items[key] = req.Body
+			return nil
+		case ADDQ:
+			if ok {
+				return &Response{Status: KEY_EEXISTS}
+			}
+			items[key] = req.Body
+			return nil
+		case DELETEQ:
+			if !ok {
+				return &Response{Status: KEY_ENOENT}
+			}
+			delete(items, key)
+			return nil
+		case APPENDQ:
+			items[key] = append(value, req.Body...)
+			return nil
+		case INCREMENT:
+			body := make([]byte, 8)
+			binary.BigEndian.PutUint64(body, binary.BigEndian.Uint64(req.Extras[:8]))
+			return &Response{Body: body}
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	}
+	srv1 := newFakeServer(t, handler)
+	srv2 := newFakeServer(t, handler)
+
+	c, err := newForTests(srv1.addr, srv2.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	p := c.Pipeline()
+	p.Get("a")
+	p.Get("missing")
+	p.Store(Set, "b", 0, []byte("value"))
+	p.Store(Add, "a", 0, []byte("value"))
+	p.Delete("deleted")
+	p.Delete("missing")
+	p.Append(Append, "a", []byte("2"))
+	p.Delta(Increment, "counter", 5, 0, 0)
+	p.Get("bad key")
+	require.Equal(t, 9, p.Len())
+
+	res, err := p.Exec(context.Background())
+	require.Nil(t, err)
+	require.Len(t, res, 9)
+
+	assert.Equal(t, "a", res[0].Key)
+	assert.Nil(t, res[0].Err)
+	assert.Equal(t, []byte("1"), res[0].Response.Body, "Get: the value before Append")
+	assert.ErrorIs(t, res[1].Err, ErrCacheMiss, "Get: missing item")
+	assert.Nil(t, res[2].Err)
+	assert.Nil(t, res[2].Response, "Store: quiet command doesn't respond")
+	assert.ErrorIs(t, res[3].Err, ErrNotStored, "Store: Add of the existing item")
+	assert.Nil(t, res[4].Err)
+	assert.ErrorIs(t, res[5].Err, ErrCacheMiss, "Delete: missing item")
+	assert.Nil(t, res[6].Err)
+	require.Nil(t, res[7].Err)
+	assert.Equal(t, uint64(5), binary.BigEndian.Uint64(res[7].Response.Body), "Delta: value should be returned")
+	assert.ErrorIs(t, res[8].Err, ErrMalformedKey)
+
+	mu.Lock()
+	assert.Equal(t, map[string][]byte{"a": []byte("12"), "b": []byte("value")}, items)
+	mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	cancel()
+	_, err = p.Exec(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,210 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultPreloadBatch is a default number of the records stored by one MultiStore of Preload.
+const DefaultPreloadBatch = 100
+
+// PreloadFormat is a format of the records of Preload.
+type PreloadFormat string
+
+const (
+	// PreloadNDJSON is the JSON object per line with the key, the value and the TTL in seconds, zero for none:
+	//
+	//	{"key": "user:1", "value": "Alice", "ttl": 3600}
+	//	{"key": "user:2", "value_base64": "AAEC", "ttl": 0}
+	PreloadNDJSON PreloadFormat = "ndjson"
+	// PreloadExport is the format of Client.Export, the flags of the items are not stored by Preload (see Import).
+	PreloadExport PreloadFormat = "export"
+)
+
+type (
+	// PreloadOption is an option of Preload.
+	PreloadOption func(*preloadOptions)
+
+	preloadOptions struct {
+		batch    int
+		rate     float64
+		progress func(PreloadProgress)
+	}
+
+	// PreloadProgress is the progress of Preload passed to the callback of WithPreloadProgress.
+	PreloadProgress struct {
+		// Read is the number of the read records, Stored of the stored items, and Skipped of the records
+		// with the illegal keys or expired since the export.
+		Read, Stored, Skipped int
+		// Elapsed is the time since the start of Preload.
+		Elapsed time.Duration
+	}
+
+	// preloadRecord is the item of Preload with the expiration of Store.
+	preloadRecord struct {
+		key   string
+		value []byte
+		exp   uint32
+	}
+
+	// ndjsonRecord is the line of PreloadNDJSON.
+	ndjsonRecord struct {
+		Key         string  `json:"key"`
+		Value       *string `json:"value"`
+		ValueBase64 []byte  `json:"value_base64"`
+		TTL         uint32  `json:"ttl"`
+	}
+)
+
+// WithPreloadBatch is sets the number of the records stored by one MultiStore, DefaultPreloadBatch by default.
+func WithPreloadBatch(n int) PreloadOption {
+	return func(o *preloadOptions) {
+		o.batch = n
+	}
+}
+
+// WithPreloadRate is limits the stored items per second, so the warm-up doesn't overload the cluster.
+// By default, the rate is not limited.
+func WithPreloadRate(itemsPerSecond float64) PreloadOption {
+	return func(o *preloadOptions) {
+		o.rate = itemsPerSecond
+	}
+}
+
+// WithPreloadProgress is sets the callback called after every batch and at the end of Preload.
+func WithPreloadProgress(fn func(PreloadProgress)) PreloadOption {
+	return func(o *preloadOptions) {
+		o.progress = fn
+	}
+}
+
+// Preload streams the records from r in format into the batches of MultiStore by Set, e.g. for the controlled warm-up
+// of the cold cluster after the flush or the migration. The records with the illegal keys and the expired ones
+// are skipped. It returns the progress at the end and stops on the first error of the reading or the batch.
+func (c *Client) Preload(ctx context.Context, r io.Reader, format PreloadFormat, opts ...PreloadOption) (_ PreloadProgress, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Preload", timer, &err)
+
+	po := preloadOptions{batch: DefaultPreloadBatch}
+	for _, opt := range opts {
+		opt(&po)
+	}
+	if po.batch <= 0 {
+		return PreloadProgress{}, fmt.Errorf("%w: preload batch %d", ErrInvalidArguments, po.batch)
+	}
+
+	var next func() (preloadRecord, bool, error)
+	switch format {
+	case PreloadNDJSON:
+		next = ndjsonRecords(r)
+	case PreloadExport:
+		next = exportRecords(r)
+	default:
+		return PreloadProgress{}, fmt.Errorf("%w: preload format %q", ErrInvalidArguments, format)
+	}
+
+	var (
+		progress PreloadProgress
+		batch    = make([]preloadRecord, 0, po.batch)
+	)
+	report := func() {
+		progress.Elapsed = time.Since(timer)
+		if po.progress != nil {
+			po.progress(progress)
+		}
+	}
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// MultiStore has one expiration for all items.
+		byExp := make(map[uint32]map[string][]byte)
+		for _, rec := range batch {
+			if byExp[rec.exp] == nil {
+				byExp[rec.exp] = make(map[string][]byte)
+			}
+			byExp[rec.exp][rec.key] = rec.value
+		}
+		for exp, items := range byExp {
+			if err := c.multiStore(ctx, SETQ, items, nil, exp); err != nil {
+				return err
+			}
+		}
+		progress.Stored += len(batch)
+		batch = batch[:0]
+		report()
+
+		if po.rate > 0 {
+			pace := time.Duration(float64(progress.Stored) / po.rate * float64(time.Second))
+			return sleepCtx(ctx, time.Until(timer.Add(pace)))
+		}
+		return nil
+	}
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		rec, ok, rErr := next()
+		if errors.Is(rErr, io.EOF) {
+			break
+		}
+		if rErr != nil {
+			return progress, fmt.Errorf("%w: record %d", rErr, progress.Read+1)
+		}
+		progress.Read++
+		if !ok || !legalKey(rec.key) {
+			progress.Skipped++
+			continue
+		}
+
+		if batch = append(batch, rec); len(batch) == po.batch {
+			if err = flushBatch(); err != nil {
+				return progress, err
+			}
+		}
+	}
+
+	if err = flushBatch(); err != nil {
+		return progress, err
+	}
+	if progress.Stored == 0 {
+		report()
+	}
+	return progress, nil
+}
+
+// ndjsonRecords returns the reader of the records of PreloadNDJSON, the empty lines are skipped.
+func ndjsonRecords(r io.Reader) func() (preloadRecord, bool, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	return func() (preloadRecord, bool, error) {
+		var line ndjsonRecord
+		if err := dec.Decode(&line); err != nil {
+			return preloadRecord{}, false, err
+		}
+		rec := preloadRecord{key: line.Key, value: line.ValueBase64, exp: line.TTL}
+		if line.Value != nil {
+			rec.value = []byte(*line.Value)
+		}
+		return rec, true, nil
+	}
+}
+
+// exportRecords returns the reader of the records of PreloadExport, ok is false for the expired record.
+func exportRecords(r io.Reader) func() (preloadRecord, bool, error) {
+	dr := newDumpReader(r)
+	return func() (preloadRecord, bool, error) {
+		rec, err := dr.next()
+		if err != nil {
+			return preloadRecord{}, false, err
+		}
+		exp, ok := importExpiration(rec.exp, time.Now())
+		return preloadRecord{key: rec.key, value: rec.value, exp: exp}, ok, nil
+	}
+}
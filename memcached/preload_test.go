@@ -0,0 +1,72 @@
+package memcached
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Preload(t *testing.T) {
+	srv := newDumpServer(t, true, map[string]dumpItem{})
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	ndjson := strings.Join([]string{
+		`{"key": "a", "value": "1", "ttl": 60}`,
+		`{"key": "b", "value_base64": "AAEC"}`,
+		``,
+		`{"key": "bad key", "value": "x"}`,
+		`{"key": "c", "value": "3", "ttl": 60}`,
+	}, "\n")
+
+	var progress []PreloadProgress
+	res, err := c.Preload(context.Background(), strings.NewReader(ndjson), PreloadNDJSON,
+		WithPreloadBatch(2),
+		WithPreloadProgress(func(p PreloadProgress) { progress = append(progress, p) }),
+	)
+	require.Nil(t, err)
+	assert.Equal(t, 4, res.Read)
+	assert.Equal(t, 3, res.Stored)
+	assert.Equal(t, 1, res.Skipped, "Preload: the illegal key should be skipped")
+	require.Len(t, progress, 2, "Preload: the progress should be reported after every batch")
+	assert.Equal(t, 2, progress[0].Stored)
+	assert.Equal(t, res, progress[1])
+
+	srv.mu.Lock()
+	assert.Equal(t, "1", string(srv.items["a"].value))
+	assert.InDelta(t, time.Now().Unix()+60, srv.items["a"].exp, 2)
+	assert.Equal(t, dumpItem{value: []byte{0, 1, 2}}, srv.items["b"])
+	assert.Equal(t, "3", string(srv.items["c"].value))
+	srv.mu.Unlock()
+
+	var export bytes.Buffer
+	export.WriteString(dumpMagic)
+	require.Nil(t, writeDumpRecord(&export, "d", 7, time.Time{}, []byte("4")))
+	require.Nil(t, writeDumpRecord(&export, "expired", 0, time.Now().Add(-time.Minute), []byte("5")))
+	res, err = c.Preload(context.Background(), &export, PreloadExport)
+	require.Nil(t, err)
+	assert.Equal(t, PreloadProgress{Read: 2, Stored: 1, Skipped: 1, Elapsed: res.Elapsed}, res)
+	srv.mu.Lock()
+	assert.Equal(t, dumpItem{value: []byte("4")}, srv.items["d"], "Preload: the flags are not stored")
+	srv.mu.Unlock()
+
+	timer := time.Now()
+	res, err = c.Preload(context.Background(), strings.NewReader(ndjson), PreloadNDJSON,
+		WithPreloadBatch(1), WithPreloadRate(30))
+	require.Nil(t, err)
+	assert.Equal(t, 3, res.Stored)
+	assert.GreaterOrEqual(t, time.Since(timer), 90*time.Millisecond, "Preload: the rate should be limited")
+
+	_, err = c.Preload(context.Background(), strings.NewReader(`{"key": `), PreloadNDJSON)
+	assert.Error(t, err)
+	_, err = c.Preload(context.Background(), strings.NewReader(ndjson), "csv")
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = c.Preload(context.Background(), strings.NewReader(ndjson), PreloadNDJSON, WithPreloadBatch(0))
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+}
@@ -0,0 +1,71 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+)
+
+// proxyV2Signature is the signature of the header of PROXY protocol v2.
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	proxyV2Proxy = 0x21 // version 2, command PROXY
+	proxyV2Local = 0x20 // version 2, command LOCAL
+
+	proxyV2TCP4 = 0x11
+	proxyV2TCP6 = 0x21
+)
+
+// proxyProtocolFor returns true, if PROXY protocol header is sent to the node of addr (see WithProxyProtocol).
+func (c *Client) proxyProtocolFor(addr net.Addr) bool {
+	if !c.proxyProtocol {
+		return false
+	}
+	if len(c.proxyProtocolNodes) == 0 {
+		return true
+	}
+	_, ok := c.proxyProtocolNodes[addr.String()]
+	return ok
+}
+
+// sendProxyHeader writes PROXY protocol v2 header with the addresses of nc, it must be the first bytes of nc.
+func (c *Client) sendProxyHeader(nc net.Conn) error {
+	if err := nc.SetWriteDeadline(time.Now().Add(c.getWriteTimeout())); err != nil {
+		return err
+	}
+	if err := writeProxyHeader(nc, nc.LocalAddr(), nc.RemoteAddr()); err != nil {
+		return err
+	}
+	return nc.SetWriteDeadline(time.Time{})
+}
+
+// writeProxyHeader writes PROXY protocol v2 header of the TCP connection from src to dst.
+// The addresses of the other networks (e.g. unix sockets) are sent by LOCAL command without addresses,
+// so the proxy uses the addresses of the connection itself.
+func writeProxyHeader(w io.Writer, src, dst net.Addr) error {
+	header := append(make([]byte, 0, len(proxyV2Signature)+4+36), proxyV2Signature...)
+
+	srcTCP, srcOK := src.(*net.TCPAddr)
+	dstTCP, dstOK := dst.(*net.TCPAddr)
+	switch {
+	case !srcOK || !dstOK:
+		header = append(header, proxyV2Local, 0, 0, 0)
+	case srcTCP.IP.To4() != nil && dstTCP.IP.To4() != nil:
+		header = append(header, proxyV2Proxy, proxyV2TCP4, 0, 12)
+		header = append(header, srcTCP.IP.To4()...)
+		header = append(header, dstTCP.IP.To4()...)
+		header = binary.BigEndian.AppendUint16(header, uint16(srcTCP.Port))
+		header = binary.BigEndian.AppendUint16(header, uint16(dstTCP.Port))
+	default:
+		header = append(header, proxyV2Proxy, proxyV2TCP6, 0, 36)
+		header = append(header, srcTCP.IP.To16()...)
+		header = append(header, dstTCP.IP.To16()...)
+		header = binary.BigEndian.AppendUint16(header, uint16(srcTCP.Port))
+		header = binary.BigEndian.AppendUint16(header, uint16(dstTCP.Port))
+	}
+
+	_, err := w.Write(header)
+	return err
+}
@@ -0,0 +1,95 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeProxyHeader(t *testing.T) {
+	var buf bytes.Buffer
+	src := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+	dst := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 11211}
+	require.Nil(t, writeProxyHeader(&buf, src, dst))
+	assert.Equal(t, append(append([]byte{}, proxyV2Signature...),
+		0x21, 0x11, 0, 12, 10, 0, 0, 1, 10, 0, 0, 2, 0xC3, 0x50, 0x2B, 0xCB), buf.Bytes())
+
+	buf.Reset()
+	src = &net.TCPAddr{IP: net.ParseIP("::1"), Port: 50000}
+	dst = &net.TCPAddr{IP: net.ParseIP("::2"), Port: 11211}
+	require.Nil(t, writeProxyHeader(&buf, src, dst))
+	header := buf.Bytes()
+	assert.Equal(t, []byte{0x21, 0x21, 0, 36}, header[12:16])
+	assert.Len(t, header, 16+36)
+	assert.Equal(t, []byte(net.ParseIP("::2")), header[32:48])
+
+	buf.Reset()
+	unix := &net.UnixAddr{Name: "/tmp/memcached.sock", Net: "unix"}
+	require.Nil(t, writeProxyHeader(&buf, unix, unix))
+	assert.Equal(t, append(append([]byte{}, proxyV2Signature...), 0x20, 0, 0, 0), buf.Bytes(),
+		"writeProxyHeader: the non-TCP addresses should be sent by LOCAL command")
+}
+
+func TestClient_ProxyProtocol(t *testing.T) {
+	headers := make(chan []byte, 2)
+	newProxyServer := func() string {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		t.Cleanup(func() { _ = ln.Close() })
+		go func() {
+			for {
+				nc, aErr := ln.Accept()
+				if aErr != nil {
+					return
+				}
+				go func() {
+					defer nc.Close()
+					rd := bufio.NewReader(nc)
+					if sig, pErr := rd.Peek(len(proxyV2Signature)); pErr == nil && bytes.Equal(sig, proxyV2Signature) {
+						header := make([]byte, 16+12)
+						if _, pErr = io.ReadFull(rd, header); pErr != nil {
+							return
+						}
+						headers <- header
+					}
+					for {
+						req := new(Request)
+						if _, rErr := req.Receive(rd, nil); rErr != nil {
+							return
+						}
+						resp := &Response{Opcode: req.Opcode, Opaque: req.Opaque, Body: []byte("value")}
+						if _, wErr := resp.Transmit(nc); wErr != nil {
+							return
+						}
+					}
+				}()
+			}
+		}()
+		return ln.Addr().String()
+	}
+	proxied, direct := newProxyServer(), newProxyServer()
+
+	c, err := newForTests(proxied, direct)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.proxyProtocol = true
+	c.proxyProtocolNodes = map[string]struct{}{proxied: {}}
+
+	for _, addr := range []string{proxied, direct} {
+		node, nErr := net.ResolveTCPAddr("tcp", addr)
+		require.Nil(t, nErr)
+		resp, sErr := c.sendToNode(node, &Request{Opcode: GET, Key: []byte("key")}, 0)
+		require.Nil(t, sErr, "sendToNode: the node should answer after the header")
+		assert.Equal(t, []byte("value"), resp.Body)
+	}
+
+	require.Len(t, headers, 1, "dial: the header should be sent to the given nodes only")
+	header := <-headers
+	assert.Equal(t, []byte{0x21, 0x11, 0, 12}, header[12:16])
+	assert.Equal(t, []byte{127, 0, 0, 1}, header[20:24], "dial: the destination should be the node")
+}
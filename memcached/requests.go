@@ -9,6 +9,7 @@ import (
 const (
 	// MaxBodyLen a maximum reasonable body length to expect.
 	// Anything larger than this will result in an error.
+	// It is also the default limit of the value sent by Client, see WithMaxBodyLen.
 	MaxBodyLen = int(22 * 1e6) // 22 MB
 
 	BUF_LEN = 256
@@ -28,6 +29,9 @@ type Request struct {
 	Opaque uint32
 	// Command extras, key, and body
 	Extras, Key, Body []byte
+
+	// extras - the storage for Extras filled by prepareExtras, so they don't need a separate allocation.
+	extras [20]byte
 }
 
 // Size is a number of bytes this request requires.
@@ -168,23 +172,15 @@ func (r *Request) Receive(rd io.Reader, hdrBytes []byte) (int, error) {
 		return n, err
 	}
 
-	if hdrBytes[0] != RES_MAGIC && hdrBytes[0] != REQ_MAGIC {
-		return n, fmt.Errorf("bad magic: 0x%02x", hdrBytes[0])
+	klen, elen, bodyLen, err := frameLens(hdrBytes, MaxBodyLen)
+	if err != nil {
+		return n, err
 	}
 	r.Opcode = OpCode(hdrBytes[1])
-
-	klen := int(binary.BigEndian.Uint16(hdrBytes[2:]))
-	elen := int(hdrBytes[4])
-	bodyLen := int(binary.BigEndian.Uint32(hdrBytes[8:]) - uint32(klen) - uint32(elen))
-	if bodyLen > MaxBodyLen {
-		return n, fmt.Errorf("%d is too big (max %d)",
-			bodyLen, MaxBodyLen)
-	}
 	r.Opaque = binary.BigEndian.Uint32(hdrBytes[12:])
 	r.Cas = binary.BigEndian.Uint64(hdrBytes[16:])
 
-	buf := make([]byte, klen+elen+bodyLen)
-	m, err := io.ReadFull(rd, buf)
+	buf, m, err := readFrame(rd, nil, klen+elen+bodyLen)
 	n += m
 	if err == nil {
 		if elen > 0 {
@@ -193,7 +189,7 @@ func (r *Request) Receive(rd io.Reader, hdrBytes []byte) (int, error) {
 		if klen > 0 {
 			r.Key = buf[elen : klen+elen]
 		}
-		if klen+elen > 0 {
+		if bodyLen > 0 {
 			r.Body = buf[klen+elen:]
 		}
 	}
@@ -218,7 +214,7 @@ func (r *Request) prepareExtras(expiration uint32, delta uint64, initVal uint64)
 		     Total 8 bytes
 		*/
 
-		r.Extras = make([]byte, 8)
+		r.Extras = r.extras[:8]
 		// flags always is 0
 		binary.BigEndian.PutUint32(r.Extras[:4], uint32(0))
 		binary.BigEndian.PutUint32(r.Extras[4:], expiration)
@@ -239,11 +235,11 @@ func (r *Request) prepareExtras(expiration uint32, delta uint64, initVal uint64)
 		     +---------------+---------------+---------------+---------------+
 		     Total 20 bytes
 		*/
-		r.Extras = make([]byte, 20)
+		r.Extras = r.extras[:20]
 		binary.BigEndian.PutUint64(r.Extras[:8], delta)
 		binary.BigEndian.PutUint64(r.Extras[8:], initVal)
 		binary.BigEndian.PutUint32(r.Extras[16:], expiration)
-	case FLUSH, FLUSHQ:
+	case FLUSH, FLUSHQ, TOUCH:
 		/*
 		   Byte/     0       |       1       |       2       |       3       |
 		      /              |               |               |               |
@@ -253,7 +249,7 @@ func (r *Request) prepareExtras(expiration uint32, delta uint64, initVal uint64)
 		     +---------------+---------------+---------------+---------------+
 		   Total 4 bytes
 		*/
-		r.Extras = make([]byte, 4)
+		r.Extras = r.extras[:4]
 		binary.BigEndian.PutUint32(r.Extras, expiration)
 	}
 }
@@ -3,6 +3,8 @@ package memcached
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"reflect"
@@ -310,6 +312,44 @@ func TestReceiveRequestLongBody(t *testing.T) {
 	}
 }
 
+func TestReceiveRequestMalformedFrame(t *testing.T) {
+	req := Request{
+		Opcode: SET,
+		Extras: []byte{1},
+		Key:    []byte("somekey"),
+		Body:   []byte("somevalue"),
+	}
+
+	data := req.Bytes()
+	// the total body length is less than the key, so the body length would be negative
+	binary.BigEndian.PutUint32(data[8:12], 2)
+
+	req2 := Request{}
+	_, err := req2.Receive(bytes.NewReader(data), nil)
+	if !errors.Is(err, ErrMalformedFrame) {
+		t.Fatalf("Expected ErrMalformedFrame, got %v", err)
+	}
+}
+
+func FuzzRequestReceive(f *testing.F) {
+	req := Request{Opcode: SET, Extras: make([]byte, 8), Key: []byte("key"), Body: []byte("value")}
+	f.Add(req.Bytes())
+	f.Add([]byte{REQ_MAGIC, 0, 0, 0, 0xff, 0, 0, 0, 0, 0, 0, 1})
+	// the body without key and extras
+	f.Add((&Request{Opcode: NOOP, Body: []byte("0")}).Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req := Request{}
+		n, err := req.Receive(bytes.NewReader(data), nil)
+		if n > len(data) {
+			t.Fatalf("read %d bytes of %d", n, len(data))
+		}
+		if err == nil && req.Size() != n {
+			t.Fatalf("size %d of the request differs from %d bytes read", req.Size(), n)
+		}
+	})
+}
+
 func BenchmarkReceiveRequest(b *testing.B) {
 	req := Request{
 		Opcode: SET,
@@ -437,6 +477,20 @@ func TestRequest_prepareExtras(t *testing.T) {
 				0x00, 0x00, 0x01, 0x00,
 			},
 		},
+		{
+			name: "TOUCH",
+			fields: fields{
+				Opcode: TOUCH,
+			},
+			args: args{
+				expiration: 256,
+				delta:      0,
+				initVal:    0,
+			},
+			expect: []byte{
+				0x00, 0x00, 0x01, 0x00,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -448,6 +502,13 @@ func TestRequest_prepareExtras(t *testing.T) {
 			if !bytes.Equal(r.Extras, tt.expect) {
 				t.Fatalf("Expected %#v == %#v", r.Extras, tt.expect)
 			}
+
+			allocs := testing.AllocsPerRun(10, func() {
+				r.prepareExtras(tt.args.expiration, tt.args.delta, tt.args.initVal)
+			})
+			if allocs != 0 {
+				t.Fatalf("Expected no allocations, got %v", allocs)
+			}
 		})
 	}
 }
@@ -4,8 +4,19 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
 )
 
+// maxPooledBufLen is a maximum capacity of the buffer, that is kept by the pooled Response.
+// Larger buffers are left to GC, so a rare big item doesn't stay in memory.
+const maxPooledBufLen = 64 * 1024
+
+var responsePool = sync.Pool{
+	New: func() any {
+		return new(Response)
+	},
+}
+
 // Response is a memcached response
 type Response struct {
 	// The command opcode of the command that sent the request
@@ -18,6 +29,19 @@ type Response struct {
 	Cas uint64
 	// Extras, key, and body for this response
 	Extras, Key, Body []byte
+
+	// buf - the buffer for extras, key and body of the pooled response, it is reused by the next receive.
+	buf []byte
+}
+
+// releaseResponse returns r to the pool. Nothing must refer to r and its Extras, Key and Body after the call.
+func releaseResponse(r *Response) {
+	buf := r.buf[:0]
+	if cap(buf) > maxPooledBufLen {
+		buf = nil
+	}
+	*r = Response{buf: buf}
+	responsePool.Put(r)
 }
 
 // String a debugging string representation of this response
@@ -37,7 +61,7 @@ func isFatal(e error) bool {
 	if e == nil {
 		return false
 	}
-	switch errStatus(e) {
+	switch StatusOf(e) {
 	case KEY_ENOENT, KEY_EEXISTS, NOT_STORED, TMPFAIL, AUTHFAIL:
 		return false
 	}
@@ -146,7 +170,14 @@ func (r *Response) Transmit(w io.Writer) (n int, err error) {
 }
 
 // Receive - fill this Response with the data from this reader.
+// The body is limited by MaxBodyLen.
 func (r *Response) Receive(rd io.Reader, hdrBytes []byte) (int, error) {
+	return r.receive(rd, hdrBytes, false, MaxBodyLen)
+}
+
+// receive fills r with the data from rd, the buffer of r is reused for extras, key and body, if reuse is true.
+// ErrMalformedFrame is returned for the body larger than maxBodyLen.
+func (r *Response) receive(rd io.Reader, hdrBytes []byte, reuse bool, maxBodyLen int) (int, error) {
 	/*
 	   Byte/     0       |       1       |       2       |       3       |
 	      /              |               |               |               |
@@ -175,22 +206,24 @@ func (r *Response) Receive(rd io.Reader, hdrBytes []byte) (int, error) {
 		return n, err
 	}
 
-	if hdrBytes[0] != RES_MAGIC && hdrBytes[0] != REQ_MAGIC {
-		return n, fmt.Errorf("Bad magic: 0x%02x", hdrBytes[0])
+	klen, elen, bodyLen, err := frameLens(hdrBytes, maxBodyLen)
+	if err != nil {
+		return n, err
 	}
 
-	klen := int(binary.BigEndian.Uint16(hdrBytes[2:4]))
-	elen := int(hdrBytes[4])
-
 	r.Opcode = OpCode(hdrBytes[1])
 	r.Status = Status(binary.BigEndian.Uint16(hdrBytes[6:8]))
 	r.Opaque = binary.BigEndian.Uint32(hdrBytes[12:16])
 	r.Cas = binary.BigEndian.Uint64(hdrBytes[16:24])
 
-	bodyLen := int(binary.BigEndian.Uint32(hdrBytes[8:12])) - (klen + elen)
-
-	buf := make([]byte, klen+elen+bodyLen)
-	m, err := io.ReadFull(rd, buf)
+	var buf []byte
+	if reuse {
+		buf = r.buf
+	}
+	buf, m, err := readFrame(rd, buf, klen+elen+bodyLen)
+	if reuse {
+		r.buf = buf
+	}
 	if err == nil {
 		if elen > 0 {
 			r.Extras = buf[0:elen]
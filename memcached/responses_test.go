@@ -3,8 +3,10 @@ package memcached
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"reflect"
 	"testing"
@@ -306,6 +308,113 @@ func TestReceiveResponseShortBody(t *testing.T) {
 	}
 }
 
+func TestReceiveResponseMalformedFrame(t *testing.T) {
+	res := Response{
+		Opcode: GET,
+		Extras: []byte{0, 0, 0, 1},
+		Key:    []byte("somekey"),
+		Body:   []byte("somevalue"),
+	}
+
+	tests := map[string]func(data []byte){
+		"bad magic": func(data []byte) { data[0] = 0x13 },
+		"key longer than total": func(data []byte) {
+			binary.BigEndian.PutUint32(data[8:12], 3)
+		},
+		"body too big": func(data []byte) {
+			binary.BigEndian.PutUint32(data[8:12], uint32(MaxBodyLen+100))
+		},
+	}
+	for name, corrupt := range tests {
+		data := res.Bytes()
+		corrupt(data)
+
+		res2 := Response{}
+		n, err := res2.Receive(bytes.NewReader(data), nil)
+		if !errors.Is(err, ErrMalformedFrame) {
+			t.Fatalf("%s: expected ErrMalformedFrame, got %v", name, err)
+		}
+		if n != HDR_LEN {
+			t.Fatalf("%s: expected to read only the header, read %v", name, n)
+		}
+	}
+}
+
+func TestReceiveResponseMaxBodyLen(t *testing.T) {
+	res := Response{
+		Opcode: GET,
+		Extras: []byte{0, 0, 0, 1},
+		Body:   []byte("somevalue"),
+	}
+	data := res.Bytes()
+	// the declared body is above MaxBodyLen, the frame is cut after the header
+	binary.BigEndian.PutUint32(data[8:12], uint32(MaxBodyLen+100))
+
+	res2 := Response{}
+	_, err := res2.receive(bytes.NewReader(data), nil, false, 2*MaxBodyLen)
+	if errors.Is(err, ErrMalformedFrame) {
+		t.Fatalf("Expected the body within the raised limit to be read, got %v", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected io.ErrUnexpectedEOF on the short body, got %v", err)
+	}
+
+	c := &Client{maxBodyLen: 2 * MaxBodyLen}
+	if got := c.getRecvBodyLen(); got != 2*MaxBodyLen {
+		t.Fatalf("Expected the receive limit of WithMaxBodyLen, got %d", got)
+	}
+	c.maxBodyLen = 256 << 10
+	if got := c.getRecvBodyLen(); got != MaxBodyLen {
+		t.Fatalf("Expected the receive limit not lower than MaxBodyLen, got %d", got)
+	}
+}
+
+func TestReceiveResponseLargeShortBody(t *testing.T) {
+	res := Response{
+		Opcode: GET,
+		Body:   make([]byte, 3*frameChunkLen),
+	}
+	data := res.Bytes()
+	rd := bytes.NewReader(data[:len(data)-10])
+
+	res2 := Response{}
+	n, err := res2.Receive(rd, nil)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if n != len(data)-10 {
+		t.Fatalf("Expected to have read %v bytes, read %v", len(data)-10, n)
+	}
+
+	res2 = Response{}
+	if _, err = res2.Receive(bytes.NewReader(data), nil); err != nil {
+		t.Fatalf("Error receiving: %v", err)
+	}
+	if len(res2.Body) != len(res.Body) {
+		t.Fatalf("Expected body of %v bytes, got %v", len(res.Body), len(res2.Body))
+	}
+}
+
+func FuzzResponseReceive(f *testing.F) {
+	res := Response{Opcode: GET, Status: KEY_ENOENT, Extras: []byte{1, 2, 3, 4}, Key: []byte("key"), Body: []byte("value")}
+	f.Add(res.Bytes())
+	f.Add(res.Bytes()[:HDR_LEN])
+	f.Add([]byte{RES_MAGIC, 0, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, reuse := range []bool{false, true} {
+			res := Response{}
+			n, err := res.receive(bytes.NewReader(data), nil, reuse, MaxBodyLen)
+			if n > len(data) {
+				t.Fatalf("read %d bytes of %d", n, len(data))
+			}
+			if err == nil && res.Size() != n {
+				t.Fatalf("size %d of the response differs from %d bytes read", res.Size(), n)
+			}
+		}
+	})
+}
+
 func TestReceiveResponseWithBuffer(t *testing.T) {
 	res := Response{
 		Opcode: SET,
@@ -401,6 +510,73 @@ func BenchmarkReceiveResponseNoBuf(b *testing.B) {
 	}
 }
 
+func TestReceivePooledResponse(t *testing.T) {
+	res := Response{
+		Opcode: GETQ,
+		Opaque: 7242,
+		Extras: []byte{1},
+		Key:    []byte("somekey"),
+		Body:   []byte("somevalue"),
+	}
+	data := res.Bytes()
+
+	res2, _, err := getPooledResponse(bytes.NewReader(data), nil, MaxBodyLen)
+	if err != nil {
+		t.Fatalf("Error receiving: %v", err)
+	}
+	if !bytes.Equal(res.Body, res2.Body) || !bytes.Equal(res.Key, res2.Key) || res.Opaque != res2.Opaque {
+		t.Fatalf("Expected %#v == %#v", res, res2)
+	}
+	buf := res2.buf
+
+	releaseResponse(res2)
+	if res2.Body != nil || res2.Opaque != 0 {
+		t.Fatalf("Expected released response to be reset, got %#v", res2)
+	}
+
+	// res2 is in the pool, so the reuse of the buffer is checked on the response of the test.
+	res3 := &Response{buf: res2.buf}
+	_, err = res3.receive(bytes.NewReader(data), nil, true, MaxBodyLen)
+	if err != nil {
+		t.Fatalf("Error receiving: %v", err)
+	}
+	if &res3.buf[0] != &buf[0] {
+		t.Fatal("Expected buffer of pooled response to be reused")
+	}
+	if string(res3.Body) != "somevalue" {
+		t.Fatalf("Expected body %q, got %q", "somevalue", res3.Body)
+	}
+
+	large := &Response{buf: make([]byte, maxPooledBufLen+1)}
+	releaseResponse(large)
+	if large.buf != nil {
+		t.Fatal("Expected large buffer not to be kept")
+	}
+}
+
+func BenchmarkReceivePooledResponse(b *testing.B) {
+	req := Response{
+		Opcode: GETQ,
+		Opaque: 7242,
+		Extras: []byte{1},
+		Key:    []byte("somekey"),
+		Body:   []byte("somevalue"),
+	}
+
+	data := req.Bytes()
+	rdr := bytes.NewReader(data)
+
+	b.SetBytes(int64(len(data)))
+
+	b.ResetTimer()
+	buf := make([]byte, HDR_LEN)
+	for i := 0; i < b.N; i++ {
+		rdr.Seek(0, 0)
+		res, _, _ := getPooledResponse(rdr, buf, MaxBodyLen)
+		releaseResponse(res)
+	}
+}
+
 func isNotFound(e error) bool {
-	return errStatus(e) == KEY_ENOENT
+	return StatusOf(e) == KEY_ENOENT
 }
@@ -0,0 +1,187 @@
+package memcached
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+const (
+	// DefaultRetryBudgetTokens and DefaultRetryBudgetRatio are the parameters of the retry budget of WithRetries,
+	// if WithRetryBudget is not set.
+	DefaultRetryBudgetTokens = 10
+	DefaultRetryBudgetRatio  = 0.1
+)
+
+const (
+	retryAttemptedResult = "retried"
+	retryThrottledResult = "throttled"
+)
+
+// RetryBudget is the token bucket, that throttles the retries like the retry throttling of gRPC: every failed
+// attempt takes one token, every successful one returns ratio of the token, and the retries are allowed,
+// while more than half of maxTokens is left. So the retries can't multiply the load on the struggling cluster,
+// while the occasional failures of the healthy one are retried. The budget may be shared by several clients.
+type RetryBudget struct {
+	maxTokens, ratio float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewRetryBudget returns the full budget of maxTokens, ratio is the share of the token returned by the success.
+func NewRetryBudget(maxTokens, ratio float64) (*RetryBudget, error) {
+	if maxTokens <= 0 || ratio <= 0 {
+		return nil, fmt.Errorf("%w: retry budget of %v tokens with ratio %v", ErrInvalidArguments, maxTokens, ratio)
+	}
+	return &RetryBudget{maxTokens: maxTokens, ratio: ratio, tokens: maxTokens}, nil
+}
+
+// Tokens returns the tokens left in the budget.
+func (b *RetryBudget) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens
+}
+
+// record takes the token for the failed attempt or returns ratio of the token for the successful one.
+func (b *RetryBudget) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if failed {
+		b.tokens = max(b.tokens-1, 0)
+	} else {
+		b.tokens = min(b.tokens+b.ratio, b.maxTokens)
+	}
+}
+
+// allow returns true, if the retry is in the budget.
+func (b *RetryBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.maxTokens/2
+}
+
+// idempotentRequest returns true for the requests, that are safe to send again, if the result is unknown.
+// SET with CAS is not, as its repeat fails with KEY_EEXISTS after the successful first attempt.
+func idempotentRequest(req *Request) bool {
+	switch req.Opcode {
+	case GET, GETK, TOUCH, NOOP, VERSION:
+		return true
+	case SET:
+		return req.Cas == 0
+	}
+	return false
+}
+
+// sendToNode sends req to the node with the retries of WithRetries, see sendToNodeOnce.
+// All attempts share the deadline of one timeout (of the call or the read timeout of the client),
+// it is set on the connection of each attempt, the retry is not sent, if the deadline comes
+// before the backoff is over.
+func (c *Client) sendToNode(node any, req *Request, timeout time.Duration) (*Response, error) {
+	var deadline time.Time
+	if c.retryAttempts > 0 {
+		deadline = time.Now().Add(c.operationTimeout(timeout))
+	}
+
+	resp, err := c.sendToNodeOnce(node, req, timeout, deadline)
+	if c.retryBudget == nil {
+		return resp, err
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 1; ; attempt++ {
+		failed := IsRetryable(err)
+		c.retryBudget.record(failed)
+		if !failed || attempt > c.retryAttempts || !idempotentRequest(req) {
+			return resp, err
+		}
+		if !c.retryBudget.allow() {
+			c.observeRetry(retryThrottledResult)
+			return resp, fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+
+		left := time.Until(deadline) - backoff
+		if left <= 0 {
+			return resp, err
+		}
+
+		c.observeRetry(retryAttemptedResult)
+		if backoff > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		resp, err = c.sendToNodeOnce(node, req, left, deadline)
+	}
+}
+
+// operationTimeout returns the timeout of the whole operation with the retries.
+func (c *Client) operationTimeout(timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return c.getReadTimeout()
+}
+
+// redispatch returns the keys of the failed nodes of MultiGet by the next nodes of the ring, that are asked
+// once again, if the retries are on (see WithRetries), and the failures, that are not retried.
+// The ring of one node returns the same node, its connection is dialed again.
+func (c *Client) redispatch(failed []NodeKeysError) (map[any][]string, []NodeKeysError) {
+	if c.retryAttempts == 0 {
+		return nil, failed
+	}
+
+	var (
+		retry = make(map[any][]string)
+		rest  []NodeKeysError
+	)
+	for _, f := range failed {
+		if c.retryBudget != nil {
+			c.retryBudget.record(true)
+		}
+		if !IsRetryable(f.Err) || !c.allowRetry() {
+			rest = append(rest, f)
+			continue
+		}
+
+		for _, key := range f.Keys {
+			nodes, find := c.hr.GetN(c.routingKey(key), 2)
+			if !find {
+				rest = append(rest, NodeKeysError{Addr: f.Addr, Keys: []string{key}, Err: f.Err})
+				continue
+			}
+			next := nodes[0]
+			for _, node := range nodes {
+				if utils.Repr(node) != f.Addr {
+					next = node
+					break
+				}
+			}
+			retry[next] = append(retry[next], key)
+		}
+	}
+	return retry, rest
+}
+
+// allowRetry returns true, if the retry is in the retry budget, the hedged requests are the retries
+// of the slow requests.
+func (c *Client) allowRetry() bool {
+	if c.retryBudget == nil {
+		return true
+	}
+	if !c.retryBudget.allow() {
+		c.observeRetry(retryThrottledResult)
+		return false
+	}
+	c.observeRetry(retryAttemptedResult)
+	return true
+}
+
+func (c *Client) observeRetry(result string) {
+	if c.disableMemcachedDiagnostic {
+		return
+	}
+	observeRetry(result)
+}
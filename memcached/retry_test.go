@@ -0,0 +1,136 @@
+package memcached
+
+import (
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBudget(t *testing.T) {
+	_, err := NewRetryBudget(0, 0.1)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+	_, err = NewRetryBudget(10, 0)
+	assert.ErrorIs(t, err, ErrInvalidArguments)
+
+	b, err := NewRetryBudget(4, 0.5)
+	require.Nil(t, err)
+	assert.Equal(t, 4.0, b.Tokens())
+	assert.True(t, b.allow())
+
+	b.record(true)
+	b.record(true)
+	assert.Equal(t, 2.0, b.Tokens())
+	assert.False(t, b.allow(), "allow: the retries should be throttled at the half of the tokens")
+
+	b.record(false)
+	assert.True(t, b.allow(), "allow: the successes should return the tokens")
+	for i := 0; i < 10; i++ {
+		b.record(false)
+	}
+	assert.Equal(t, 4.0, b.Tokens(), "record: the tokens should not exceed the maximum")
+	for i := 0; i < 10; i++ {
+		b.record(true)
+	}
+	assert.Equal(t, 0.0, b.Tokens(), "record: the tokens should not be negative")
+}
+
+func TestClient_Retries(t *testing.T) {
+	var (
+		failures atomic.Int32
+		requests atomic.Int32
+	)
+	srv := newFakeServer(t, func(req *Request) *Response {
+		requests.Add(1)
+		if failures.Add(-1) >= 0 {
+			return &Response{Opcode: req.Opcode, Opaque: req.Opaque, Status: TMPFAIL}
+		}
+		return &Response{Opcode: req.Opcode, Opaque: req.Opaque, Body: []byte("value")}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.retryAttempts, c.retryBackoff = 2, time.Millisecond
+	c.retryBudget, err = NewRetryBudget(DefaultRetryBudgetTokens, DefaultRetryBudgetRatio)
+	require.Nil(t, err)
+
+	failures.Store(2)
+	resp, err := c.Get("key")
+	require.Nil(t, err, "Get: the transient failures should be retried")
+	assert.Equal(t, []byte("value"), resp.Body)
+	assert.Equal(t, int32(3), requests.Load())
+
+	failures.Store(3)
+	requests.Store(0)
+	_, err = c.Get("key")
+	assert.Equal(t, TMPFAIL, StatusOf(err), "Get: the error of the last attempt should be returned")
+	assert.Equal(t, int32(3), requests.Load(), "Get: the retries should be limited by the attempts")
+
+	failures.Store(1)
+	requests.Store(0)
+	_, err = c.Append(Append, "key", []byte("value"))
+	assert.Equal(t, TMPFAIL, StatusOf(err))
+	assert.Equal(t, int32(1), requests.Load(), "Append: the non-idempotent commands should not be retried")
+
+	// 1 token is left after the failures above, less than the half of the budget.
+	failures.Store(10)
+	requests.Store(0)
+	_, err = c.Get("key")
+	assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+	assert.Equal(t, TMPFAIL, StatusOf(err), "Get: the exhausted budget should wrap the error of the attempt")
+	assert.Equal(t, int32(1), requests.Load(), "Get: the retries should be throttled by the budget")
+	assert.False(t, c.allowRetry(), "allowRetry: the hedges should be throttled by the budget")
+}
+
+func TestClient_RetriesDeadline(t *testing.T) {
+	var requests atomic.Int32
+	srv := newFakeServer(t, func(req *Request) *Response {
+		requests.Add(1)
+		return &Response{Opcode: req.Opcode, Opaque: req.Opaque, Status: TMPFAIL}
+	})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.retryAttempts, c.retryBackoff = 5, 40*time.Millisecond
+	c.retryBudget, err = NewRetryBudget(100, DefaultRetryBudgetRatio)
+	require.Nil(t, err)
+
+	start := time.Now()
+	_, err = c.Get("key", WithCallTimeout(100*time.Millisecond))
+	assert.Equal(t, TMPFAIL, StatusOf(err))
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "Get: the retries should not exceed the timeout of the call")
+	assert.Equal(t, int32(2), requests.Load(), "Get: the retry after the deadline should not be sent")
+}
+
+func TestTimeoutConn_Deadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		// every byte comes before the read timeout, only the deadline stops the reading.
+		for {
+			time.Sleep(10 * time.Millisecond)
+			if _, err := server.Write([]byte{0}); err != nil {
+				return
+			}
+		}
+	}()
+
+	tc := newTimeoutConn(client, 50*time.Millisecond, 50*time.Millisecond)
+	tc.deadline = time.Now().Add(100 * time.Millisecond)
+
+	start := time.Now()
+	var err error
+	for err == nil {
+		_, err = tc.Read(make([]byte, 1))
+	}
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), 200*time.Millisecond, "Read: the deadline should limit the whole operation")
+}
@@ -0,0 +1,197 @@
+package memcached
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// NodeSlabs are the slab classes of one node from "stats slabs" and "stats items".
+	NodeSlabs struct {
+		// ActiveSlabs is the number of the allocated slab classes, TotalMalloced is the memory allocated to the slabs.
+		ActiveSlabs   uint64
+		TotalMalloced uint64
+		// Classes are the slab classes sorted by id.
+		Classes []SlabClass
+	}
+
+	// SlabClass is the statistics of one slab class, the items of the class have the same size of the chunk.
+	SlabClass struct {
+		ID int
+		// ChunkSize is the size of the chunk of the item, ChunksPerPage is the number of the chunks in the page of 1 MB.
+		ChunkSize     uint64
+		ChunksPerPage uint64
+		// TotalPages, TotalChunks, UsedChunks and FreeChunks are the memory of the class.
+		TotalPages  uint64
+		TotalChunks uint64
+		UsedChunks  uint64
+		FreeChunks  uint64
+		// MemRequested is the memory requested by the items of the class, it is less than the memory of the chunks.
+		MemRequested uint64
+
+		// Items is the number of the items of the class, Age is the age of the oldest item.
+		Items uint64
+		Age   time.Duration
+		// Evicted is the number of the items evicted from the class to free the memory, EvictedNonzero
+		// of them had the expiration, EvictedTime is the time since the last access of the last evicted item.
+		Evicted        uint64
+		EvictedNonzero uint64
+		EvictedTime    time.Duration
+		// Reclaimed is the number of the new items stored in the memory of the expired ones,
+		// OutOfMemory is the number of the failed writes to the class.
+		Reclaimed   uint64
+		OutOfMemory uint64
+	}
+
+	// SlabEviction is the slab class of the node with evictions in EvictionReport.
+	SlabEviction struct {
+		Node      string
+		Class     int
+		ChunkSize uint64
+		Evicted   uint64
+		Items     uint64
+		// Age is the age of the oldest item of the class, EvictedTime is the time since the last access
+		// of the last evicted item, the small one means, that the class evicts the items in use.
+		Age         time.Duration
+		EvictedTime time.Duration
+	}
+)
+
+// SlabStats returns the slab classes of the nodes by their addresses (see Client.Nodes). The errors of the nodes
+// are joined, the statistics of the other nodes are returned.
+func (c *Client) SlabStats() (map[string]NodeSlabs, error) {
+	slabs, err := c.Stats("slabs")
+	items, iErr := c.Stats("items")
+	err = errors.Join(err, iErr)
+
+	ret := make(map[string]NodeSlabs, len(slabs))
+	for addr, stats := range slabs {
+		ret[addr] = parseSlabs(stats, items[addr])
+	}
+	return ret, err
+}
+
+// EvictionReport returns the slab classes of all nodes with evictions sorted by their number descending,
+// so the classes evicting hard (e.g. the values of one size, that don't fit into the memory) are visible.
+func (c *Client) EvictionReport() ([]SlabEviction, error) {
+	nodes, err := c.SlabStats()
+
+	var report []SlabEviction
+	for addr, node := range nodes {
+		for _, class := range node.Classes {
+			if class.Evicted == 0 {
+				continue
+			}
+			report = append(report, SlabEviction{
+				Node:        addr,
+				Class:       class.ID,
+				ChunkSize:   class.ChunkSize,
+				Evicted:     class.Evicted,
+				Items:       class.Items,
+				Age:         class.Age,
+				EvictedTime: class.EvictedTime,
+			})
+		}
+	}
+	slices.SortFunc(report, func(a, b SlabEviction) int {
+		if a.Evicted != b.Evicted {
+			return cmp.Compare(b.Evicted, a.Evicted)
+		}
+		if a.Node != b.Node {
+			return strings.Compare(a.Node, b.Node)
+		}
+		return cmp.Compare(a.Class, b.Class)
+	})
+	return report, err
+}
+
+// parseSlabs parses "stats slabs" (e.g. "1:chunk_size") and "stats items" (e.g. "items:1:evicted") of the node.
+func parseSlabs(slabs, items map[string]string) NodeSlabs {
+	var (
+		node    NodeSlabs
+		classes = make(map[int]*SlabClass)
+	)
+	class := func(id string) *SlabClass {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil
+		}
+		if classes[n] == nil {
+			classes[n] = &SlabClass{ID: n}
+		}
+		return classes[n]
+	}
+
+	for name, value := range slabs {
+		v, _ := strconv.ParseUint(value, 10, 64)
+		id, field, ok := strings.Cut(name, ":")
+		if !ok {
+			switch name {
+			case "active_slabs":
+				node.ActiveSlabs = v
+			case "total_malloced":
+				node.TotalMalloced = v
+			}
+			continue
+		}
+		sc := class(id)
+		if sc == nil {
+			continue
+		}
+		switch field {
+		case "chunk_size":
+			sc.ChunkSize = v
+		case "chunks_per_page":
+			sc.ChunksPerPage = v
+		case "total_pages":
+			sc.TotalPages = v
+		case "total_chunks":
+			sc.TotalChunks = v
+		case "used_chunks":
+			sc.UsedChunks = v
+		case "free_chunks":
+			sc.FreeChunks = v
+		case "mem_requested":
+			sc.MemRequested = v
+		}
+	}
+
+	for name, value := range items {
+		v, _ := strconv.ParseUint(value, 10, 64)
+		rest, ok := strings.CutPrefix(name, "items:")
+		if !ok {
+			continue
+		}
+		id, field, _ := strings.Cut(rest, ":")
+		sc := class(id)
+		if sc == nil {
+			continue
+		}
+		switch field {
+		case "number":
+			sc.Items = v
+		case "age":
+			sc.Age = time.Duration(v) * time.Second
+		case "evicted":
+			sc.Evicted = v
+		case "evicted_nonzero":
+			sc.EvictedNonzero = v
+		case "evicted_time":
+			sc.EvictedTime = time.Duration(v) * time.Second
+		case "reclaimed":
+			sc.Reclaimed = v
+		case "outofmemory":
+			sc.OutOfMemory = v
+		}
+	}
+
+	for _, sc := range classes {
+		node.Classes = append(node.Classes, *sc)
+	}
+	slices.SortFunc(node.Classes, func(a, b SlabClass) int { return cmp.Compare(a.ID, b.ID) })
+	return node
+}
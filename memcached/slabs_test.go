@@ -0,0 +1,56 @@
+package memcached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SlabStats(t *testing.T) {
+	srv1 := newGroupStatsServer(t, map[string]map[string]string{
+		"slabs": {
+			"active_slabs": "2", "total_malloced": "2097152",
+			"1:chunk_size": "96", "1:chunks_per_page": "10922", "1:total_pages": "1", "1:used_chunks": "10", "1:mem_requested": "900",
+			"5:chunk_size": "240", "5:total_pages": "1",
+		},
+		"items": {
+			"items:1:number": "10", "items:1:age": "3600", "items:1:evicted": "5", "items:1:evicted_time": "60",
+			"items:5:number": "1", "items:5:reclaimed": "3", "items:5:outofmemory": "2",
+		},
+	})
+	srv2 := newGroupStatsServer(t, map[string]map[string]string{
+		"slabs": {"3:chunk_size": "152"},
+		"items": {"items:3:number": "4", "items:3:evicted": "50", "items:3:age": "30", "items:3:evicted_time": "2"},
+	})
+
+	c, err := newForTests(srv1, srv2)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	nodes, err := c.SlabStats()
+	require.Nil(t, err)
+	assert.Equal(t, NodeSlabs{
+		ActiveSlabs:   2,
+		TotalMalloced: 2097152,
+		Classes: []SlabClass{
+			{ID: 1, ChunkSize: 96, ChunksPerPage: 10922, TotalPages: 1, UsedChunks: 10, MemRequested: 900,
+				Items: 10, Age: time.Hour, Evicted: 5, EvictedTime: time.Minute},
+			{ID: 5, ChunkSize: 240, TotalPages: 1, Items: 1, Reclaimed: 3, OutOfMemory: 2},
+		},
+	}, nodes[srv1])
+
+	report, err := c.EvictionReport()
+	require.Nil(t, err)
+	assert.Equal(t, []SlabEviction{
+		{Node: srv2, Class: 3, ChunkSize: 152, Evicted: 50, Items: 4, Age: 30 * time.Second, EvictedTime: 2 * time.Second},
+		{Node: srv1, Class: 1, ChunkSize: 96, Evicted: 5, Items: 10, Age: time.Hour, EvictedTime: time.Minute},
+	}, report, "EvictionReport: the classes with evictions sorted by their number")
+
+	c, err = newForTests(newStatsServer(t, map[string]string{"pid": "1"}))
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	_, err = c.EvictionReport()
+	assert.ErrorIs(t, err, ErrCacheMiss, "EvictionReport: the errors of the nodes should be returned")
+}
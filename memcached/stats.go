@@ -0,0 +1,95 @@
+package memcached
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+// Stats returns the statistics of memcached by the addresses of the nodes (see Client.Nodes).
+// The group is the argument of the stats command (e.g. "items", "slabs" or "settings"), the empty one returns
+// the general statistics. The errors of the nodes are joined, the statistics of the other nodes are returned.
+func (c *Client) Stats(group string) (_ map[string]map[string]string, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("Stats", timer, &err)
+
+	nodes := c.hr.GetAllNodes()
+	if len(nodes) == 0 {
+		return nil, ErrNoServers
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		multiErr error
+
+		ret = make(map[string]map[string]string, len(nodes))
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node any) {
+			defer wg.Done()
+
+			addr := utils.Repr(node)
+			stats, sErr := c.statsFromNode(node, group)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if sErr != nil {
+				multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for node - %s", sErr, addr))
+				return
+			}
+			ret[addr] = stats
+		}(node)
+	}
+
+	wg.Wait()
+
+	return ret, multiErr
+}
+
+// statsFromNode sends STAT to the node and reads the responses with the statistics until the one without key.
+func (c *Client) statsFromNode(node any, group string) (_ map[string]string, cnErr error) {
+	cn, err := c.getConnForNode(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cn.condRelease(&cnErr)
+
+	req := &Request{Opcode: STAT, Key: []byte(group), Opaque: cn.nextOpaque()}
+	if _, cnErr = cn.transmit(req); cnErr != nil {
+		cn.healthy = false
+		return nil, cnErr
+	}
+	if cnErr = cn.wrtBuf.Flush(); cnErr != nil {
+		cn.healthy = false
+		return nil, cnErr
+	}
+
+	stats := make(map[string]string)
+	for {
+		var resp *Response
+		resp, _, cnErr = cn.receive()
+		if cnErr != nil {
+			// the unknown group is answered by the single response with the error status.
+			cn.healthy = !isFatal(cnErr)
+			return nil, cnErr
+		}
+		if cnErr = checkResponse(resp, STAT, req.Opaque); cnErr != nil {
+			cn.healthy = false
+			return nil, cnErr
+		}
+		if len(resp.Key) == 0 {
+			break
+		}
+		stats[string(resp.Key)] = string(resp.Body)
+	}
+
+	if len(stats) == 0 {
+		return nil, ErrNoStats
+	}
+	return stats, nil
+}
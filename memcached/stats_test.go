@@ -0,0 +1,84 @@
+package memcached
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatsServer returns the address of the server, that answers STAT with stats and the terminating response,
+// and the unknown group with KEY_ENOENT.
+func newStatsServer(t *testing.T, stats map[string]string) string {
+	return newGroupStatsServer(t, map[string]map[string]string{"": stats})
+}
+
+// newGroupStatsServer is newStatsServer with the statistics of the groups (e.g. "slabs"), the general ones are "".
+func newGroupStatsServer(t *testing.T, groups map[string]map[string]string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			nc, aErr := ln.Accept()
+			if aErr != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				for {
+					req := new(Request)
+					if _, rErr := req.Receive(nc, nil); rErr != nil {
+						return
+					}
+					var resps []*Response
+					if stats, ok := groups[string(req.Key)]; ok {
+						for k, v := range stats {
+							resps = append(resps, &Response{Key: []byte(k), Body: []byte(v)})
+						}
+						resps = append(resps, &Response{})
+					} else {
+						resps = append(resps, &Response{Status: KEY_ENOENT})
+					}
+					for _, resp := range resps {
+						resp.Opcode, resp.Opaque = req.Opcode, req.Opaque
+						if _, wErr := resp.Transmit(nc); wErr != nil {
+							return
+						}
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestClient_Stats(t *testing.T) {
+	srv1 := newStatsServer(t, map[string]string{"pid": "1", "curr_items": "10"})
+	srv2 := newStatsServer(t, map[string]string{"pid": "2"})
+
+	c, err := newForTests(srv1, srv2)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	stats, err := c.Stats("")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		srv1: {"pid": "1", "curr_items": "10"},
+		srv2: {"pid": "2"},
+	}, stats)
+
+	stats, err = c.Stats("unknown")
+	assert.ErrorIs(t, err, ErrCacheMiss, "Stats: the unknown group should fail on every node")
+	assert.Empty(t, stats)
+
+	empty := newStatsServer(t, nil)
+	c, err = newForTests(empty)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	_, err = c.Stats("")
+	assert.ErrorIs(t, err, ErrNoStats)
+}
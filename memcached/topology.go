@@ -0,0 +1,287 @@
+package memcached
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/consistenthash"
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+// topologyEventsBuffer is a size of the buffer of the channel returned by Client.TopologyEvents.
+const topologyEventsBuffer = 64
+
+// ringSampleKeys are the keys, whose nodes are compared before and after the change of the ring
+// to estimate the share of the moved keyspace.
+var ringSampleKeys = func() []string {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "gomemcached-ring-sample-" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// TopologyReason is a reason of the change of the hash ring.
+type TopologyReason string
+
+const (
+	// TopologyInit is the initial ring built from the configuration.
+	TopologyInit TopologyReason = "init"
+	// TopologyRebuild is the change found by the periodic discovery of nodes, including slow start steps.
+	TopologyRebuild TopologyReason = "rebuild"
+	// TopologyNodeDead is the removal of the nodes, that failed the health check.
+	TopologyNodeDead TopologyReason = "node_dead"
+	// TopologyManual is the change made by AddNode, RemoveNode or SetNodes.
+	TopologyManual TopologyReason = "manual"
+)
+
+// TopologyEvent describes one change of the hash ring, i.e. the moment, when the placement of keys changed.
+type TopologyEvent struct {
+	// Version is a number of the ring version, it grows by one on every change.
+	// A gap between versions of received events means, that events were dropped.
+	Version uint64
+	Reason  TopologyReason
+	Time    time.Time
+	// Added and Removed are the nodes added to and removed from the ring.
+	Added, Removed []net.Addr
+	// Reweighted are the nodes, whose weight in the ring changed (see WithSlowStart).
+	Reweighted []net.Addr
+	// Nodes is the number of nodes in the ring after the change.
+	Nodes int
+}
+
+// TopologyEvents returns the channel with the changes of the hash ring.
+// The channel is buffered, if the reader is slow, new events are dropped.
+// The channel is closed by Close or Shutdown.
+func (c *Client) TopologyEvents() <-chan TopologyEvent {
+	return c.topologyEvents
+}
+
+// PauseTopologyUpdates freezes the hash ring during the planned maintenance (e.g. the rolling restart
+// of memcached), so the restarted nodes are neither removed as dead nor added back and the keys are not
+// reshuffled by every step. The requests to the unavailable nodes fail until ResumeTopologyUpdates.
+// AddNode, RemoveNode and SetNodes still change the ring.
+func (c *Client) PauseTopologyUpdates() {
+	if !c.topologyPaused.Swap(true) {
+		c.getLogger().Infof("%s: Topology updates are paused", libPrefix)
+	}
+}
+
+// ResumeTopologyUpdates resumes the updates of the hash ring after PauseTopologyUpdates
+// and syncs the ring with the cluster at once, without waiting for the next period.
+func (c *Client) ResumeTopologyUpdates() {
+	if !c.topologyPaused.Swap(false) {
+		return
+	}
+	c.getLogger().Infof("%s: Topology updates are resumed", libPrefix)
+	if c.topologyResync != nil {
+		select {
+		case c.topologyResync <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// TopologyUpdatesPaused returns true, if the updates of the hash ring are paused by PauseTopologyUpdates.
+func (c *Client) TopologyUpdatesPaused() bool {
+	return c.topologyPaused.Load()
+}
+
+// AddNode adds the node of addr (host:port or the path of unix socket) to the hash ring for the applications,
+// that manage the topology by their own control plane (see WithDisableNodeProvider), otherwise the next
+// rebuilding of the nodes may remove it. The node gets its weight from MEMCACHED_SERVERS, if it is specified.
+// ErrClientClosed is returned after Close or Shutdown.
+func (c *Client) AddNode(addr string) error {
+	return c.changeNodes([]string{addr}, nil, false)
+}
+
+// RemoveNode removes the node of addr from the hash ring and closes its connections, see AddNode.
+func (c *Client) RemoveNode(addr string) error {
+	return c.changeNodes(nil, []string{addr}, false)
+}
+
+// SetNodes replaces the nodes of the hash ring with the nodes of addrs at once, the connections of the removed
+// nodes are closed, see AddNode. The new nodes are added in the order of addrs, so the clients with the same
+// addrs agree on the placement of the keys with WithJumpHash.
+func (c *Client) SetNodes(addrs []string) error {
+	return c.changeNodes(addrs, nil, true)
+}
+
+// changeNodes adds the nodes of add to the ring and removes the nodes of remove, with replace the nodes,
+// that are not in add, are removed too. The ring is changed at once and only if all addresses are valid.
+func (c *Client) changeNodes(add, remove []string, replace bool) error {
+	if c.closed.Load() {
+		return ErrClientClosed
+	}
+
+	type nodeAddr struct {
+		node string
+		addr net.Addr
+	}
+	// the nodes are kept in the order of the caller, as the placement of jump hash depends on the order of addition.
+	parse := func(nodes []string) ([]nodeAddr, error) {
+		addrs := make([]nodeAddr, 0, len(nodes))
+		for _, node := range nodes {
+			node = normalizeNode(node)
+			addr, err := c.nodeAddr(node)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidAddr, err.Error())
+			}
+			addrs = append(addrs, nodeAddr{node: node, addr: addr})
+		}
+		return addrs, nil
+	}
+	toAdd, err := parse(add)
+	if err != nil {
+		return err
+	}
+	toRemove, err := parse(remove)
+	if err != nil {
+		return err
+	}
+
+	var added, removed []net.Addr
+	c.batchRing(TopologyManual, func(ring consistenthash.ConsistentHash) {
+		var (
+			inRing = make(map[string]net.Addr)
+			order  []nodeAddr
+		)
+		for _, n := range ring.GetAllNodes() {
+			if addr, ok := n.(net.Addr); ok {
+				inRing[utils.Repr(n)] = addr
+				order = append(order, nodeAddr{node: utils.Repr(n), addr: addr})
+			}
+		}
+		if replace {
+			keep := make(map[string]struct{}, len(toAdd))
+			for _, na := range toAdd {
+				keep[na.node] = struct{}{}
+			}
+			for _, na := range order {
+				if _, ok := keep[na.node]; !ok {
+					toRemove = append(toRemove, na)
+				}
+			}
+		}
+
+		for _, na := range toAdd {
+			if _, ok := inRing[na.node]; ok {
+				continue
+			}
+			c.addNodeToRing(ring, na.node, na.addr)
+			inRing[na.node] = na.addr
+			added = append(added, na.addr)
+		}
+		for _, na := range toRemove {
+			if _, ok := inRing[na.node]; !ok {
+				continue
+			}
+			ring.Remove(na.addr)
+			delete(inRing, na.node)
+			removed = append(removed, na.addr)
+		}
+	})
+
+	for _, addr := range removed {
+		c.removeFromFreeConns(addr)
+		c.safeRemoveFromDeadNodes(utils.Repr(addr))
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	c.emitTopologyEvent(TopologyManual, added, removed, nil)
+
+	for _, addr := range added {
+		if c.minIdleConns > 0 {
+			if wErr := c.safeGetOrInitFreeConn(addr).Warmup(c.ctx); wErr != nil {
+				c.nodeLogger(addr).Warnf("%s: Error occurred while adding node, warmup error - %s", libPrefix, wErr.Error())
+			}
+		}
+		if c.nodeRecoveryHook != nil {
+			c.runNodeRecoveryHook(addr)
+		}
+	}
+	return nil
+}
+
+// batchRing changes the ring by fn at once (see consistenthash.ConsistentHash.Batch) and observes the duration
+// of the change and the estimated share of the keyspace, that moved to the other nodes.
+func (c *Client) batchRing(reason TopologyReason, fn func(ring consistenthash.ConsistentHash)) {
+	if c.disableMemcachedDiagnostic {
+		c.hr.Batch(fn)
+		return
+	}
+
+	before := c.sampleRingOwners()
+	start := time.Now()
+	c.hr.Batch(fn)
+	duration := time.Since(start)
+
+	var moved int
+	for i, owner := range c.sampleRingOwners() {
+		if owner != before[i] {
+			moved++
+		}
+	}
+	observeRingRebuild(string(reason), duration.Seconds(), float64(moved)/float64(len(ringSampleKeys)))
+}
+
+// sampleRingOwners returns the nodes of ringSampleKeys in the current ring.
+func (c *Client) sampleRingOwners() []string {
+	owners := make([]string, len(ringSampleKeys))
+	for i, key := range ringSampleKeys {
+		if node, ok := c.hr.Get(key); ok {
+			owners[i] = utils.Repr(node)
+		}
+	}
+	return owners
+}
+
+func (c *Client) emitTopologyEvent(reason TopologyReason, added, removed, reweighted []net.Addr) {
+	c.observeRingChange(reason, len(added), len(removed))
+
+	ev := TopologyEvent{
+		Version:    c.topologyVersion.Add(1),
+		Reason:     reason,
+		Time:       time.Now(),
+		Added:      added,
+		Removed:    removed,
+		Reweighted: reweighted,
+		Nodes:      c.hr.GetNodesCount(),
+	}
+
+	c.tpmu.Lock()
+	defer c.tpmu.Unlock()
+	if c.topologyClosed {
+		// the client is closed, nobody waits for the events
+		return
+	}
+	select {
+	case c.topologyEvents <- ev:
+	default:
+		if c.topologyEvents != nil {
+			c.getLogger().Debugf("%s: Topology event %d is dropped, the channel is full", libPrefix, ev.Version)
+		}
+	}
+}
+
+func (c *Client) closeTopologyEvents() {
+	c.tpmu.Lock()
+	defer c.tpmu.Unlock()
+	if c.topologyClosed {
+		return
+	}
+	c.topologyClosed = true
+	if c.topologyEvents != nil {
+		close(c.topologyEvents)
+	}
+}
+
+func (c *Client) observeRingChange(reason TopologyReason, added, removed int) {
+	if c.disableMemcachedDiagnostic {
+		return
+	}
+	observeRingChange(string(reason), added, removed)
+}
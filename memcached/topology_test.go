@@ -0,0 +1,294 @@
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/consistenthash"
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+func TestClient_TopologyEvents(t *testing.T) {
+	var (
+		oldNode = "127.0.0.1:12345"
+		newNode = "127.0.0.2:12345"
+
+		mockNetwork = new(MockNetworkOperations)
+	)
+	op := &options{
+		Client: Client{
+			ctx: context.TODO(),
+			nw: &network{
+				dial:       mockNetwork.Dial,
+				lookupHost: mockNetwork.LookupHost,
+			},
+			cfg: &config{
+				Servers: []string{oldNode},
+			},
+			timeout:             -1,
+			hr:                  consistenthash.NewHashRing(),
+			disableNodeProvider: true,
+			disableRefreshConns: true,
+			deadNodes:           make(map[string]struct{}),
+		},
+	}
+	mockNetwork.On("Dial", "tcp", newNode).Return(nil, errors.New("mocked dial error"))
+	mockNetwork.On("Dial", mock.Anything, mock.Anything).Return(&FakeConn{}, nil)
+
+	cl, err := newFromConfig(op)
+	require.Nil(t, err)
+	events := cl.TopologyEvents()
+
+	ev := <-events
+	assert.Equal(t, uint64(1), ev.Version)
+	assert.Equal(t, TopologyInit, ev.Reason)
+	assert.Equal(t, []string{oldNode}, addrsRepr(ev.Added))
+	assert.Equal(t, 1, ev.Nodes)
+
+	cl.cfg.Servers = []string{oldNode, newNode}
+	cl.rebuildNodes(discoverForTests(t, cl))
+	ev = <-events
+	assert.Equal(t, uint64(2), ev.Version)
+	assert.Equal(t, TopologyRebuild, ev.Reason)
+	assert.Equal(t, []string{newNode}, addrsRepr(ev.Added))
+	assert.Empty(t, ev.Removed)
+	assert.Equal(t, 2, ev.Nodes)
+
+	cl.rebuildNodes(discoverForTests(t, cl))
+	assert.Len(t, events, 0, "rebuildNodes: event should not be sent without changes")
+
+	cl.checkNodesHealth(discoverForTests(t, cl))
+	ev = <-events
+	assert.Equal(t, uint64(3), ev.Version)
+	assert.Equal(t, TopologyNodeDead, ev.Reason)
+	assert.Equal(t, []string{newNode}, addrsRepr(ev.Removed))
+	assert.Equal(t, 1, ev.Nodes)
+
+	cl.checkNodesHealth(discoverForTests(t, cl))
+	assert.Len(t, events, 0, "checkNodesHealth: event should not be sent for the node already removed")
+
+	require.Nil(t, cl.Close())
+	_, ok := <-events
+	assert.False(t, ok, "Close: channel should be closed")
+	require.Nil(t, cl.Close(), "Close: second call should not panic")
+}
+
+func TestClient_emitTopologyEvent(t *testing.T) {
+	cl := &Client{hr: consistenthash.NewHashRing()}
+	assert.NotPanics(t, func() {
+		cl.emitTopologyEvent(TopologyRebuild, nil, nil, nil)
+	}, "emitTopologyEvent: client without channel")
+
+	cl.topologyEvents = make(chan TopologyEvent, 1)
+	cl.emitTopologyEvent(TopologyRebuild, nil, nil, nil)
+	cl.emitTopologyEvent(TopologyRebuild, nil, nil, nil)
+	cl.emitTopologyEvent(TopologyRebuild, nil, nil, nil)
+
+	require.Len(t, cl.topologyEvents, 1, "emitTopologyEvent: events should be dropped, if the channel is full")
+	assert.Equal(t, uint64(2), (<-cl.topologyEvents).Version)
+	cl.emitTopologyEvent(TopologyRebuild, nil, nil, nil)
+	assert.Equal(t, uint64(5), (<-cl.topologyEvents).Version, "Version: the gap should show the dropped events")
+}
+
+func addrsRepr(addrs []net.Addr) []string {
+	res := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		res = append(res, utils.Repr(addr))
+	}
+	return res
+}
+
+func TestClient_ManualTopology(t *testing.T) {
+	newServer := func(value string) *fakeServer {
+		return newFakeServer(t, func(*Request) *Response {
+			return &Response{Body: []byte(value)}
+		})
+	}
+	srv1, srv2, srv3 := newServer("1"), newServer("2"), newServer("3")
+
+	c, err := newForTests(srv1.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.topologyEvents = make(chan TopologyEvent, 10)
+
+	require.Nil(t, c.AddNode(srv2.addr))
+	require.Nil(t, c.AddNode(srv2.addr), "AddNode: the node already in the ring should be skipped")
+	assert.Equal(t, sortedAddrs(srv1.addr, srv2.addr), c.Nodes())
+	require.Len(t, c.topologyEvents, 1)
+	ev := <-c.topologyEvents
+	assert.Equal(t, TopologyManual, ev.Reason)
+	assert.Equal(t, []string{srv2.addr}, addrsRepr(ev.Added))
+
+	_, err = c.Get("key")
+	require.Nil(t, err)
+	require.Nil(t, c.RemoveNode(srv1.addr))
+	require.Nil(t, c.RemoveNode(srv1.addr))
+	assert.Equal(t, []string{srv2.addr}, c.Nodes())
+	addr1, _ := utils.AddrRepr(srv1.addr)
+	_, ok := c.safeGetFreeConn(addr1)
+	assert.False(t, ok, "RemoveNode: the pool of the removed node should be closed")
+	resp, err := c.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("2"), resp.Body, "Get: the key should be routed to the rest node")
+
+	require.Nil(t, c.SetNodes([]string{srv1.addr, srv3.addr}))
+	assert.Equal(t, sortedAddrs(srv1.addr, srv3.addr), c.Nodes())
+	require.Len(t, c.topologyEvents, 2)
+	<-c.topologyEvents
+	ev = <-c.topologyEvents
+	assert.Equal(t, sortedAddrs(srv1.addr, srv3.addr), sortedAddrs(addrsRepr(ev.Added)...))
+	assert.Equal(t, []string{srv2.addr}, addrsRepr(ev.Removed))
+
+	assert.ErrorIs(t, c.AddNode("invalid"), ErrInvalidAddr)
+	assert.ErrorIs(t, c.SetNodes([]string{srv2.addr, "invalid"}), ErrInvalidAddr)
+	assert.Equal(t, sortedAddrs(srv1.addr, srv3.addr), c.Nodes(), "SetNodes: the ring should not be changed by the invalid addresses")
+}
+
+func TestClient_SetNodesJumpHash(t *testing.T) {
+	nodes := make([]string, 16)
+	for i := range nodes {
+		nodes[i] = "127.0.0." + strconv.Itoa(i+1) + ":11211"
+	}
+	newClient := func() *Client {
+		c, err := newForTests(nodes[0])
+		require.Nil(t, err)
+		t.Cleanup(c.CloseAllConns)
+		c.hr = consistenthash.NewJumpHash()
+		require.Nil(t, c.SetNodes(nodes))
+		return c
+	}
+	c1, c2 := newClient(), newClient()
+
+	var order []string
+	for _, n := range c1.hr.GetAllNodes() {
+		order = append(order, utils.Repr(n))
+	}
+	assert.Equal(t, nodes, order, "SetNodes: the nodes should be added in the order of the caller")
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		n1, _ := c1.hr.Get(key)
+		n2, _ := c2.hr.Get(key)
+		assert.Equal(t, utils.Repr(n1), utils.Repr(n2), "SetNodes: the clients should route %s to the same node", key)
+	}
+}
+
+func TestClient_ManualTopologyAfterClose(t *testing.T) {
+	srv := newFakeServer(t, func(*Request) *Response { return &Response{} })
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	c.topologyEvents = make(chan TopologyEvent, 10)
+	require.Nil(t, c.Close())
+
+	assert.NotPanics(t, func() {
+		assert.ErrorIs(t, c.AddNode("127.0.0.1:12345"), ErrClientClosed)
+		assert.ErrorIs(t, c.RemoveNode(srv.addr), ErrClientClosed)
+		assert.ErrorIs(t, c.SetNodes([]string{"127.0.0.1:12345"}), ErrClientClosed)
+	}, "AddNode: the closed channel of the events should not be sent to")
+	assert.NotPanics(t, func() {
+		c.emitTopologyEvent(TopologyRebuild, nil, nil, nil)
+	}, "emitTopologyEvent: the event after Close should be skipped")
+	_, ok := <-c.topologyEvents
+	assert.False(t, ok, "TopologyEvents: the channel should be closed by Close")
+}
+
+func TestClient_ReAddNodeWithBorrowedConn(t *testing.T) {
+	srv := newFakeServer(t, func(*Request) *Response { return &Response{} })
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	addr, err := utils.AddrRepr(srv.addr)
+	require.Nil(t, err)
+
+	old, err := c.getConnForNode(addr)
+	require.Nil(t, err)
+	require.Nil(t, c.RemoveNode(srv.addr))
+	require.Nil(t, c.AddNode(srv.addr))
+	cn, err := c.getConnForNode(addr)
+	require.Nil(t, err)
+
+	old.release()
+	cn.release()
+	stats := c.PoolStats()[srv.addr]
+	assert.Equal(t, 1, stats.Idle, "release: the connection of the removed pool should not be put into the new one")
+	assert.Zero(t, stats.InUse)
+	assert.NotPanics(t, c.CloseAllConns, "CloseAllConns: the new pool should not release the tokens of the old one")
+}
+
+func sortedAddrs(addrs ...string) []string {
+	res := slices.Clone(addrs)
+	slices.Sort(res)
+	return res
+}
+
+func TestClient_PauseTopologyUpdates(t *testing.T) {
+	var (
+		oldNode = "127.0.0.1:12345"
+		newNode = "127.0.0.2:12345"
+
+		mockNetwork = new(MockNetworkOperations)
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cl := &Client{
+		ctx:    ctx,
+		cancel: cancel,
+		nw: &network{
+			dial:       mockNetwork.Dial,
+			lookupHost: mockNetwork.LookupHost,
+		},
+		cfg:                 &config{Servers: []string{oldNode, newNode}},
+		timeout:             -1,
+		hr:                  consistenthash.NewHashRing(),
+		disableRefreshConns: true,
+		nodeHCPeriod:        time.Hour,
+		nodeRBPeriod:        time.Hour,
+	}
+	mockNetwork.On("Dial", mock.Anything, mock.Anything).Return(&FakeConn{}, nil)
+	oldAddr, _ := utils.AddrRepr(oldNode)
+	cl.hr.Add(oldAddr)
+	cl.initNodesProvider()
+	defer func() {
+		cancel()
+		cl.bgWG.Wait()
+	}()
+
+	cl.PauseTopologyUpdates()
+	assert.True(t, cl.TopologyUpdatesPaused())
+	cl.updateTopology()
+	assert.Equal(t, []string{oldNode}, cl.Nodes(), "updateTopology: the paused ring should not be changed")
+
+	cl.ResumeTopologyUpdates()
+	assert.False(t, cl.TopologyUpdatesPaused())
+	assert.Eventually(t, func() bool {
+		return len(cl.Nodes()) == 2
+	}, time.Second, 10*time.Millisecond, "ResumeTopologyUpdates: the ring should be synced without waiting for the period")
+}
+
+func TestClient_sampleRingOwners(t *testing.T) {
+	c, err := newForTests("127.0.0.1:11211", "127.0.0.2:11211")
+	require.Nil(t, err)
+
+	before := c.sampleRingOwners()
+	assert.NotContains(t, before, "", "sampleRingOwners: every key should have the node")
+	require.Nil(t, c.AddNode("127.0.0.3:11211"))
+
+	var moved int
+	for i, owner := range c.sampleRingOwners() {
+		if owner != before[i] {
+			assert.Equal(t, "127.0.0.3:11211", owner, "sampleRingOwners: the keys should move to the added node only")
+			moved++
+		}
+	}
+	ratio := float64(moved) / float64(len(ringSampleKeys))
+	assert.InDelta(t, 1.0/3, ratio, 0.1, "sampleRingOwners: about a third of the keyspace should move to the third node")
+}
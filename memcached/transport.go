@@ -1,8 +1,17 @@
 package memcached
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/pool"
 )
 
 // UnwrapMemcachedError converts memcached errors to normal responses.
@@ -18,13 +27,42 @@ func UnwrapMemcachedError(err error) *Response {
 	return nil
 }
 
-func getResponse(s io.Reader, hdrBytes []byte) (rv *Response, n int, err error) {
+func getResponse(s io.Reader, hdrBytes []byte, maxBodyLen int) (rv *Response, n int, err error) {
 	if s == nil {
 		return nil, 0, ErrNoServers
 	}
 
 	rv = &Response{}
-	n, err = rv.Receive(s, hdrBytes)
+	n, err = rv.receive(s, hdrBytes, false, maxBodyLen)
+	if err == nil && rv.Status != SUCCESS {
+		err = wrapMemcachedResp(rv)
+	}
+	return rv, n, err
+}
+
+// checkResponse returns ErrProtocolDesync, if resp is not the answer on the request with opcode and opaque.
+func checkResponse(resp *Response, opcode OpCode, opaque uint32) error {
+	if resp.Opcode != opcode || resp.Opaque != opaque {
+		return fmt.Errorf("%w: response %s with opaque %d on request %s with opaque %d",
+			ErrProtocolDesync, resp.Opcode, resp.Opaque, opcode, opaque)
+	}
+	return nil
+}
+
+// unexpectedResponse returns ErrProtocolDesync for resp, that doesn't belong to any sent request.
+func unexpectedResponse(resp *Response) error {
+	return fmt.Errorf("%w: unexpected response %s with opaque %d", ErrProtocolDesync, resp.Opcode, resp.Opaque)
+}
+
+// getPooledResponse is getResponse, that takes the response from the pool.
+// The response should be returned by releaseResponse, if it isn't used after.
+func getPooledResponse(s io.Reader, hdrBytes []byte, maxBodyLen int) (rv *Response, n int, err error) {
+	if s == nil {
+		return nil, 0, ErrNoServers
+	}
+
+	rv = responsePool.Get().(*Response)
+	n, err = rv.receive(s, hdrBytes, true, maxBodyLen)
 	if err == nil && rv.Status != SUCCESS {
 		err = wrapMemcachedResp(rv)
 	}
@@ -38,3 +76,175 @@ func transmitRequest(o io.Writer, req *Request) (int, error) {
 	n, err := req.Transmit(o)
 	return n, err
 }
+
+// frameChunkLen is the size of the chunks, by which the large bodies of the frames are read,
+// so the memory is allocated for the received data, not for the length declared by the peer.
+const frameChunkLen = 64 << 10
+
+// frameLens returns the lengths of the key, extras and body of the frame by its header.
+// The lengths come from the wire, so they are checked: ErrMalformedFrame is returned for the bad magic,
+// if the key and extras don't fit into the total body, or the body is larger than maxBodyLen.
+func frameLens(hdr []byte, maxBodyLen int) (klen, elen, bodyLen int, err error) {
+	if hdr[0] != RES_MAGIC && hdr[0] != REQ_MAGIC {
+		return 0, 0, 0, fmt.Errorf("%w: bad magic 0x%02x", ErrMalformedFrame, hdr[0])
+	}
+
+	klen = int(binary.BigEndian.Uint16(hdr[2:4]))
+	elen = int(hdr[4])
+	total := int64(binary.BigEndian.Uint32(hdr[8:12]))
+	if int64(klen+elen) > total {
+		return 0, 0, 0, fmt.Errorf("%w: key length %d and extras length %d exceed total body length %d",
+			ErrMalformedFrame, klen, elen, total)
+	}
+	if body := total - int64(klen+elen); body > int64(maxBodyLen) {
+		return 0, 0, 0, fmt.Errorf("%w: body length %d is too big (max %d)", ErrMalformedFrame, body, maxBodyLen)
+	}
+	return klen, elen, int(total) - klen - elen, nil
+}
+
+// readFrame reads size bytes of the frame after the header into buf, if it has the capacity.
+// The larger frames are read by io.LimitReader in chunks, so the peer, that declares the length and sends less,
+// doesn't make the whole length allocated.
+func readFrame(rd io.Reader, buf []byte, size int) ([]byte, int, error) {
+	if cap(buf) >= size || size <= frameChunkLen {
+		if cap(buf) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		n, err := io.ReadFull(rd, buf)
+		return buf, n, err
+	}
+
+	var b bytes.Buffer
+	b.Grow(frameChunkLen)
+	m, err := b.ReadFrom(io.LimitReader(rd, int64(size)))
+	switch {
+	case err != nil:
+	case m == 0:
+		err = io.EOF
+	case int(m) < size:
+		err = io.ErrUnexpectedEOF
+	}
+	return b.Bytes(), int(m), err
+}
+
+// timeoutConn sets the deadline before every read and write on the connection,
+// so the timeout limits the waiting for each portion of data, not the whole operation.
+// The deadline, if it is set, limits the whole operation, the reads and writes don't wait after it.
+type timeoutConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	deadline     time.Time
+}
+
+func newTimeoutConn(nc net.Conn, readTimeout, writeTimeout time.Duration) *timeoutConn {
+	return &timeoutConn{
+		Conn:         nc,
+		readTimeout:  readTimeout,
+		writeTimeout: writeTimeout,
+	}
+}
+
+func (tc *timeoutConn) Read(b []byte) (int, error) {
+	if d := tc.deadlineAfter(tc.readTimeout); !d.IsZero() {
+		if err := tc.Conn.SetReadDeadline(d); err != nil {
+			return 0, err
+		}
+	}
+	return tc.Conn.Read(b)
+}
+
+func (tc *timeoutConn) Write(b []byte) (int, error) {
+	if d := tc.deadlineAfter(tc.writeTimeout); !d.IsZero() {
+		if err := tc.Conn.SetWriteDeadline(d); err != nil {
+			return 0, err
+		}
+	}
+	return tc.Conn.Write(b)
+}
+
+// deadlineAfter returns the earliest of the timeout from now and the deadline of the operation,
+// zero time means no deadline.
+func (tc *timeoutConn) deadlineAfter(timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout > 0 {
+		d = time.Now().Add(timeout)
+	}
+	if !tc.deadline.IsZero() && (d.IsZero() || tc.deadline.Before(d)) {
+		d = tc.deadline
+	}
+	return d
+}
+
+// textConn is the connection of the text protocol of memcached for the commands without the binary ones
+// (e.g. lru_crawler). The wrappers of the binary protocol (see WithWireLogging) are not used for it.
+type textConn struct {
+	nc net.Conn
+	rd *bufio.Reader
+	// lastUsed - time of the return to the pool of GetWithTTL, it is zero for the new connection.
+	lastUsed time.Time
+	// pool - the pool of the connections of GetWithTTL, that created the connection, nil for the other ones.
+	pool *pool.Pool[*textConn]
+}
+
+// dialText dials the node for the text protocol with the timeouts of the client, PROXY protocol header is sent
+// like by the binary connections. ErrTextProtocolAuth is returned, if the client uses SASL authentication.
+func (c *Client) dialText(node any) (*textConn, error) {
+	addr, ok := node.(net.Addr)
+	if !ok {
+		return nil, ErrInvalidAddr
+	}
+	if c.authEnable {
+		return nil, ErrTextProtocolAuth
+	}
+	nc, err := c.nw.dialTimeout(addr.Network(), addr.String(), c.getDialTimeout())
+	if err != nil {
+		return nil, err
+	}
+	if err = c.setSocketOptions(nc); err != nil {
+		_ = nc.Close()
+		return nil, err
+	}
+	if c.proxyProtocolFor(addr) {
+		if err = c.sendProxyHeader(nc); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	nc = newTimeoutConn(nc, c.getReadTimeout(), c.getWriteTimeout())
+	return &textConn{nc: nc, rd: bufio.NewReader(nc)}, nil
+}
+
+func (tc *textConn) writeLine(line string) error {
+	_, err := tc.nc.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// readLine reads the line of the response, the errors of memcached are returned as ErrServerError.
+func (tc *textConn) readLine() (string, error) {
+	line, err := tc.rd.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "SERVER_ERROR") || strings.HasPrefix(line, "CLIENT_ERROR") {
+		return "", fmt.Errorf("%w: %s", ErrServerError, line)
+	}
+	return line, nil
+}
+
+// command sends the line and reads the line of the response.
+func (tc *textConn) command(line string) (string, error) {
+	if err := tc.writeLine(line); err != nil {
+		return "", err
+	}
+	return tc.readLine()
+}
+
+func (tc *textConn) close() {
+	_ = tc.nc.Close()
+}
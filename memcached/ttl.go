@@ -0,0 +1,345 @@
+package memcached
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/pool"
+)
+
+// NoTTL is the TTL of the item without the expiration, see GetWithTTL.
+const NoTTL = time.Duration(-1)
+
+// GetWithTTL gets the value of the key with its remaining TTL, so the callers can refresh the item ahead
+// of its expiration without storing the expiry inside the value. The TTL is rounded to seconds by memcached,
+// NoTTL is returned for the item without the expiration. If the key is not found, ErrCacheMiss is returned.
+//
+// The meta command mg of the text protocol is used, it needs memcached 1.6 or later, ErrUnknownCommand is returned
+// by the older ones. With WithTTLDumpFallback they are asked by GET for the value and by the dump of the keys
+// of the node for the expiration (see KeysIterator) within the timeout of the call, it reads all keys of the node,
+// so it is slow for the large caches, and ErrCacheMiss is returned, if the key is not found by the dump.
+// The local cache, the hedging and the batching of Get are not used for it.
+// The text protocol is not available with SASL authentication, so ErrTextProtocolAuth is returned with
+// WithAuthentication. PROXY protocol header (see WithProxyProtocol) is sent like by the binary connections.
+func (c *Client) GetWithTTL(key string, opts ...CallOption) (value []byte, ttl time.Duration, err error) {
+	timer := time.Now()
+	defer c.writeMethodDiagnostics("GetWithTTL", timer, &err)
+
+	if !legalKey(key) {
+		return nil, 0, ErrMalformedKey
+	}
+
+	co := c.resolveCallOptions(MethodGet, opts...)
+	node, err := c.nodeForKey(key, &co)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, ttl, err := c.metaGet(node, key, co.Timeout)
+	if errors.Is(err, ErrUnknownCommand) && c.ttlDumpFallback {
+		resp, ttl, err = c.dumpGet(node, key, co.Timeout)
+	}
+	if err == nil {
+		err = c.decryptResponse(key, resp)
+	}
+	c.writeGetKeys("GetWithTTL", err)
+	co.readResponse(resp, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, ttl, nil
+}
+
+// metaGet gets the key from the node by mg with the value, the TTL, the flags and the CAS of the item.
+// The response is the same as the response of GET.
+func (c *Client) metaGet(node any, key string, timeout time.Duration) (_ *Response, ttl time.Duration, err error) {
+	if err = c.injectFault(node, GET); err != nil {
+		return nil, 0, err
+	}
+
+	release, err := c.acquireNode(node, timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	for {
+		tc, err := c.getTextConn(node)
+		if err != nil {
+			return nil, 0, err
+		}
+		reused := !tc.lastUsed.IsZero()
+		resp, ttl, err := tc.metaGet(key, timeout, c.getRecvBodyLen())
+
+		// the connection with the unread response is not reused
+		if err == nil || errors.Is(err, ErrCacheMiss) || errors.Is(err, ErrUnknownCommand) {
+			tc.release()
+		} else {
+			tc.pool.Close(tc)
+		}
+		if reused && brokenTextConn(err) {
+			// the idle connection is closed by the server, mg is sent once more by another one
+			continue
+		}
+		return resp, ttl, err
+	}
+}
+
+// metaGet sends mg of the key and reads its response.
+func (tc *textConn) metaGet(key string, timeout time.Duration, maxBodyLen int) (*Response, time.Duration, error) {
+	defer tc.setTimeout(timeout)()
+
+	line, err := tc.command("mg " + metaKey(key) + " v t f c")
+	if err != nil {
+		return nil, 0, err
+	}
+	return tc.readMetaValue(line, maxBodyLen)
+}
+
+// brokenTextConn returns true for the errors of the connection itself, not of the response of memcached.
+func brokenTextConn(err error) bool {
+	switch {
+	case err == nil, IsTimeout(err):
+		return false
+	case errors.Is(err, ErrCacheMiss), errors.Is(err, ErrUnknownCommand),
+		errors.Is(err, ErrServerError), errors.Is(err, ErrMalformedFrame):
+		return false
+	}
+	return true
+}
+
+// dumpGet gets the key from the node by GET and finds its TTL by the dump of the keys of the node,
+// it is the fallback of metaGet for memcached without the meta commands. The dump is limited by timeout,
+// or by the read timeout of the client, if it is zero.
+func (c *Client) dumpGet(node any, key string, timeout time.Duration) (*Response, time.Duration, error) {
+	req := &Request{
+		Opcode: GET,
+		Key:    []byte(key),
+	}
+	req.prepareExtras(0, 0, 0)
+	resp, err := c.sendToNode(node, req, timeout)
+	if err != nil {
+		return resp, 0, err
+	}
+
+	if timeout <= 0 {
+		timeout = c.getReadTimeout()
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	defer cancel()
+
+	it := &KeysIterator{c: c, ctx: ctx, nodes: []any{node}}
+	defer it.Close()
+	for it.Next() {
+		info := it.Key()
+		if info.Key != key {
+			continue
+		}
+		if info.Expiration.IsZero() {
+			return resp, NoTTL, nil
+		}
+		return resp, max(time.Until(info.Expiration).Truncate(time.Second), 0), nil
+	}
+	if err = it.Err(); err != nil {
+		return nil, 0, err
+	}
+	// the item is expired or evicted after GET
+	resp = &Response{Opcode: GET, Status: KEY_ENOENT}
+	return resp, 0, wrapMemcachedResp(resp)
+}
+
+// metaKey returns the key for the meta commands, the keys with the non-ASCII bytes are sent in base64 with the flag b.
+func metaKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		if key[i] >= 0x7f {
+			return base64.StdEncoding.EncodeToString([]byte(key)) + " b"
+		}
+	}
+	return key
+}
+
+// readMetaValue parses the response of mg: VA <size> <flags>*\r\n<data>\r\n for the hit and EN\r\n for the miss.
+func (tc *textConn) readMetaValue(line string, maxBodyLen int) (*Response, time.Duration, error) {
+	fields := strings.Fields(line)
+	switch {
+	case line == "EN":
+		resp := &Response{Opcode: GET, Status: KEY_ENOENT}
+		return resp, 0, wrapMemcachedResp(resp)
+	case line == "ERROR":
+		return nil, 0, ErrUnknownCommand
+	case len(fields) < 2 || fields[0] != "VA":
+		return nil, 0, fmt.Errorf("%w: unexpected response of mg - %q", ErrServerError, line)
+	}
+
+	size, err := strconv.Atoi(fields[1])
+	if err != nil || size < 0 || size > maxBodyLen {
+		return nil, 0, fmt.Errorf("%w: wrong size of mg - %q", ErrMalformedFrame, line)
+	}
+
+	var (
+		ttl   time.Duration
+		flags uint64
+		cas   uint64
+	)
+	for _, f := range fields[2:] {
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case 't':
+			var t int64
+			if t, err = strconv.ParseInt(f[1:], 10, 64); err == nil {
+				ttl = time.Duration(t) * time.Second
+				if t < 0 {
+					ttl = NoTTL
+				}
+			}
+		case 'f':
+			flags, err = strconv.ParseUint(f[1:], 10, 32)
+		case 'c':
+			cas, err = strconv.ParseUint(f[1:], 10, 64)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: wrong flag of mg - %q", ErrMalformedFrame, line)
+		}
+	}
+
+	data := make([]byte, size+2)
+	if _, err = io.ReadFull(tc.rd, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	if string(data[size:]) != "\r\n" {
+		return nil, 0, fmt.Errorf("%w: value of mg is not terminated", ErrMalformedFrame)
+	}
+
+	resp := &Response{
+		Opcode: GET,
+		Status: SUCCESS,
+		Cas:    cas,
+		Extras: binary.BigEndian.AppendUint32(nil, uint32(flags)),
+		Body:   data[:size],
+	}
+	return resp, ttl, nil
+}
+
+// setTimeout sets the timeout of the call for the reads and writes of the connection,
+// the returned function restores the timeouts of the client.
+func (tc *textConn) setTimeout(timeout time.Duration) (restore func()) {
+	tmc, ok := tc.nc.(*timeoutConn)
+	if !ok || timeout <= 0 {
+		return func() {}
+	}
+	readTimeout, writeTimeout := tmc.readTimeout, tmc.writeTimeout
+	tmc.readTimeout, tmc.writeTimeout = timeout, timeout
+	return func() { tmc.readTimeout, tmc.writeTimeout = readTimeout, writeTimeout }
+}
+
+// getTextConn returns the text connection of the node from its pool, the pool retires the idle connections
+// by the limits of the client (see WithMaxConnLifetime and WithMaxConnIdleTime). The connections, that were idle
+// longer than WithConnValidation, are checked by version before use.
+func (c *Client) getTextConn(node any) (*textConn, error) {
+	if c.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	addr, ok := node.(net.Addr)
+	if !ok {
+		return nil, ErrInvalidAddr
+	}
+
+	connPool := c.safeGetOrInitTextConns(addr)
+
+	ctx, cancel := context.WithTimeout(c.ctx, DefaultSocketPoolingTimeout)
+	defer cancel()
+
+	for {
+		tc, err := connPool.GetContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: Get from pool error - %w", libPrefix, err)
+		}
+		if c.connValidationIdle <= 0 || tc.lastUsed.IsZero() || time.Since(tc.lastUsed) <= c.connValidationIdle {
+			return tc, nil
+		}
+		if err = tc.ping(DefaultConnValidationTimeout); err == nil {
+			return tc, nil
+		}
+		c.nodeLogger(node).Warnf("%s: Idle text connection to %s is broken, closed - %s", libPrefix, addr.String(), err.Error())
+		connPool.Close(tc)
+	}
+}
+
+func (c *Client) safeGetOrInitTextConns(addr net.Addr) *pool.Pool[*textConn] {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	connPool, ok := c.textConns[addr.String()]
+	if ok {
+		return connPool
+	}
+
+	var newPool *pool.Pool[*textConn]
+	dialConn := func() (*textConn, error) {
+		tc, err := c.dialText(addr)
+		if err != nil {
+			return nil, err
+		}
+		tc.pool = newPool
+		return tc, nil
+	}
+
+	newPool = pool.New(c.ctx, int32(c.getMaxIdleConns()), DefaultSocketPoolingTimeout, dialConn, (*textConn).close,
+		pool.WithMaxConnLifetime[*textConn](c.maxConnLifetime),
+		pool.WithMaxIdleTime[*textConn](c.maxConnIdleTime),
+	)
+
+	if c.textConns == nil {
+		c.textConns = make(map[string]*pool.Pool[*textConn])
+	}
+	c.textConns[addr.String()] = newPool
+
+	return newPool
+}
+
+// ping checks the text connection by version.
+func (tc *textConn) ping(timeout time.Duration) error {
+	defer tc.setTimeout(timeout)()
+
+	line, err := tc.command("version")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "VERSION") {
+		return fmt.Errorf("%w: unexpected response of version - %q", ErrServerError, line)
+	}
+	return nil
+}
+
+// release returns the text connection to its pool.
+func (tc *textConn) release() {
+	tc.lastUsed = time.Now()
+	tc.pool.Put(tc)
+}
+
+// closeTextConns destroys the pools of the text connections of the node of addr, nil destroys them for all nodes.
+func (c *Client) closeTextConns(addr net.Addr) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+
+	for key, connPool := range c.textConns {
+		if addr != nil && key != addr.String() {
+			continue
+		}
+		connPool.Destroy()
+		delete(c.textConns, key)
+	}
+}
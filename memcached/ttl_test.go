@@ -0,0 +1,157 @@
+package memcached
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/pool"
+	"github.com/aliexpressru/gomemcached/utils"
+)
+
+func TestClient_GetWithTTL(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	srv := newDumpServer(t, true, map[string]dumpItem{
+		"expiring": {flags: 42, exp: exp, value: []byte("value")},
+		"eternal":  {value: []byte("forever")},
+		"ключ":     {exp: exp, value: []byte("unicode")},
+	})
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	var flags uint32
+	value, ttl, err := c.GetWithTTL("expiring", WithFlagsOut(&flags))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.InDelta(t, time.Hour, ttl, float64(2*time.Second), "GetWithTTL: remaining TTL of the item")
+	assert.EqualValues(t, 42, flags, "GetWithTTL: flags of the item")
+
+	value, ttl, err = c.GetWithTTL("eternal")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("forever"), value)
+	assert.Equal(t, NoTTL, ttl, "GetWithTTL: item without the expiration")
+
+	value, _, err = c.GetWithTTL("ключ")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("unicode"), value, "GetWithTTL: non-ASCII key is sent in base64")
+
+	_, _, err = c.GetWithTTL("missing")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	_, _, err = c.GetWithTTL("with space")
+	assert.ErrorIs(t, err, ErrMalformedKey)
+
+	c.tmu.Lock()
+	stats := c.textConns[srv.addr].Stats()
+	c.tmu.Unlock()
+	assert.Equal(t, 1, stats.Idle, "GetWithTTL: text connection should be reused")
+	assert.Zero(t, stats.InUse)
+}
+
+func TestClient_GetWithTTLFallback(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	items := map[string]dumpItem{
+		"expiring": {flags: 42, exp: exp, value: []byte("value")},
+		"eternal":  {value: []byte("forever")},
+	}
+	for _, crawler := range []bool{true, false} {
+		srv := newDumpServer(t, crawler, items)
+		srv.noMeta = true
+		c, err := newForTests(srv.addr)
+		require.Nil(t, err)
+
+		_, _, err = c.GetWithTTL("expiring")
+		assert.ErrorIs(t, err, ErrUnknownCommand, "GetWithTTL: the dump should be opt-in")
+		c.ttlDumpFallback = true
+
+		var flags uint32
+		value, ttl, err := c.GetWithTTL("expiring", WithFlagsOut(&flags))
+		require.Nil(t, err)
+		assert.Equal(t, []byte("value"), value)
+		assert.InDelta(t, time.Hour, ttl, float64(2*time.Second), "GetWithTTL: TTL by the dump, crawler - %v", crawler)
+		assert.EqualValues(t, 42, flags)
+
+		_, ttl, err = c.GetWithTTL("eternal")
+		require.Nil(t, err)
+		assert.Equal(t, NoTTL, ttl, "GetWithTTL: item without the expiration by the dump, crawler - %v", crawler)
+
+		_, _, err = c.GetWithTTL("missing")
+		assert.ErrorIs(t, err, ErrCacheMiss)
+
+		// the key is got by GET, but it is not in the dump.
+		srv.mu.Lock()
+		srv.undumped = "eternal"
+		srv.mu.Unlock()
+		_, _, err = c.GetWithTTL("eternal")
+		assert.ErrorIs(t, err, ErrCacheMiss, "GetWithTTL: the key missed by the dump, crawler - %v", crawler)
+		c.CloseAllConns()
+	}
+}
+
+func TestClient_GetWithTTLConnection(t *testing.T) {
+	srv := newDumpServer(t, true, map[string]dumpItem{"key": {value: []byte("value")}})
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.proxyProtocol = true
+
+	_, _, err = c.GetWithTTL("key")
+	require.Nil(t, err, "GetWithTTL: the node should answer after PROXY header")
+	srv.mu.Lock()
+	assert.Equal(t, 1, srv.proxied, "GetWithTTL: PROXY header should be sent like by the binary connections")
+	srv.mu.Unlock()
+
+	auth, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	auth.authEnable = true
+	_, _, err = auth.GetWithTTL("key")
+	assert.ErrorIs(t, err, ErrTextProtocolAuth, "GetWithTTL: text protocol should not bypass SASL")
+}
+
+func TestClient_textConns(t *testing.T) {
+	srv := newDumpServer(t, true, map[string]dumpItem{"key": {value: []byte("value")}})
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	stats := func() pool.Stats {
+		c.tmu.Lock()
+		defer c.tmu.Unlock()
+		if p, ok := c.textConns[srv.addr]; ok {
+			return p.Stats()
+		}
+		return pool.Stats{}
+	}
+	addr, err := utils.AddrRepr(srv.addr)
+	require.Nil(t, err)
+
+	_, _, err = c.GetWithTTL("key")
+	require.Nil(t, err)
+	require.Equal(t, 1, stats().Idle)
+
+	srv.dropConns()
+	_, _, err = c.GetWithTTL("key")
+	require.Nil(t, err, "GetWithTTL: the idle connection closed by the server should be redialed")
+	assert.Equal(t, pool.Stats{Idle: 1}, stats(), "GetWithTTL: the broken connection should be closed by the pool")
+
+	c.connValidationIdle = time.Nanosecond
+	srv.dropConns()
+	tc, err := c.getTextConn(addr)
+	require.Nil(t, err)
+	assert.True(t, tc.lastUsed.IsZero(), "getTextConn: the broken idle connection should be closed by the validation")
+	tc.release()
+	c.connValidationIdle = 0
+
+	old, err := c.getTextConn(addr)
+	require.Nil(t, err)
+	c.removeFromFreeConns(addr)
+	assert.Zero(t, stats().Idle, "removeFromFreeConns: the text connections of the removed node should be closed")
+	_, _, err = c.GetWithTTL("key")
+	require.Nil(t, err)
+	old.release()
+	assert.Equal(t, pool.Stats{Idle: 1}, stats(), "release: the connection of the removed pool should not be put into the new one")
+}
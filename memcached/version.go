@@ -0,0 +1,70 @@
+package memcached
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Versions returns the versions of memcached by the addresses of the nodes (see Client.Nodes).
+// The nodes, that didn't answer, are missing in the result, their errors are joined.
+func (c *Client) Versions() (map[string]string, error) {
+	resps, err := c.Broadcast(&Request{Opcode: VERSION})
+	versions := make(map[string]string, len(resps))
+	for addr, resp := range resps {
+		if resp.Status == SUCCESS {
+			versions[addr] = string(resp.Body)
+		}
+	}
+	return versions, err
+}
+
+// checkVersions logs the versions of the nodes and returns ErrServerVersion,
+// if any node is below minVersion or its version is unknown.
+func (c *Client) checkVersions(minVersion string) error {
+	versions, err := c.Versions()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrServerVersion, err)
+	}
+
+	var outdated []string
+	for _, addr := range c.Nodes() {
+		version, ok := versions[addr]
+		c.getLogger().Infof("%s: memcached %s has version %s", libPrefix, addr, version)
+		if !ok || CompareVersions(version, minVersion) < 0 {
+			outdated = append(outdated, fmt.Sprintf("%s (%s)", addr, version))
+		}
+	}
+	if len(outdated) != 0 {
+		return fmt.Errorf("%w: %s is required, nodes: %s", ErrServerVersion, minVersion, strings.Join(outdated, ", "))
+	}
+	return nil
+}
+
+// CompareVersions compares the dot-separated versions by the numeric prefixes of their parts,
+// e.g. "1.6.21" > "1.6.9", and "1.6.9-beta" == "1.6.9". The missing parts are zeros.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		if d := versionPart(as, i) - versionPart(bs, i); d != 0 {
+			if d < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	part := parts[i]
+	end := 0
+	for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(part[:end])
+	return n
+}
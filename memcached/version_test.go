@@ -0,0 +1,59 @@
+package memcached
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.6.9", b: "1.6.9", want: 0},
+		{a: "1.6.21", b: "1.6.9", want: 1},
+		{a: "1.5.22", b: "1.6.9", want: -1},
+		{a: "1.6.9-beta", b: "1.6.9", want: 0},
+		{a: "1.6", b: "1.6.0", want: 0},
+		{a: "", b: "1.4", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, CompareVersions(tt.a, tt.b))
+		})
+	}
+}
+
+func TestMinServerVersion(t *testing.T) {
+	newServer := func(version string) *fakeServer {
+		return newFakeServer(t, func(*Request) *Response {
+			return &Response{Body: []byte(version)}
+		})
+	}
+	srv1, srv2 := newServer("1.6.21"), newServer("1.5.22")
+
+	t.Setenv("MEMCACHED_SERVERS", srv1.addr+","+srv2.addr)
+	mcl, err := InitFromEnv(WithDisableNodeProvider(), WithDisableMemcachedDiagnostic())
+	require.Nil(t, err)
+	versions, err := mcl.Versions()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{srv1.addr: "1.6.21", srv2.addr: "1.5.22"}, versions)
+	mcl.CloseAllConns()
+
+	mcl, err = InitFromEnv(WithDisableNodeProvider(), WithDisableMemcachedDiagnostic(), WithMinServerVersion("1.6.9", true))
+	assert.ErrorIs(t, err, ErrServerVersion, "InitFromEnv: should fail with outdated node")
+	assert.ErrorContains(t, err, srv2.addr)
+	assert.Nil(t, mcl)
+
+	mcl, err = InitFromEnv(WithDisableNodeProvider(), WithDisableMemcachedDiagnostic(), WithMinServerVersion("1.6.9", false))
+	assert.Nil(t, err, "InitFromEnv: should only warn without failFast")
+	require.NotNil(t, mcl)
+	mcl.CloseAllConns()
+
+	mcl, err = InitFromEnv(WithDisableNodeProvider(), WithDisableMemcachedDiagnostic(), WithMinServerVersion("1.5", true))
+	assert.Nil(t, err)
+	require.NotNil(t, mcl)
+	mcl.CloseAllConns()
+}
@@ -0,0 +1,127 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aliexpressru/gomemcached/logger"
+)
+
+// wireBodyLimit is a maximum number of bytes of the body dumped by the wire logging.
+const wireBodyLimit = 1024
+
+// wireConn is net.Conn, that logs the frames of the binary protocol written and read by the connection,
+// see WithWireLogging.
+type wireConn struct {
+	net.Conn
+	out, in *wireParser
+}
+
+func newWireConn(nc net.Conn, log logger.Logger, withBodies bool) *wireConn {
+	return &wireConn{
+		Conn: nc,
+		out:  newWireParser(log, "->", withBodies),
+		in:   newWireParser(log, "<-", withBodies),
+	}
+}
+
+func (wc *wireConn) Write(p []byte) (int, error) {
+	n, err := wc.Conn.Write(p)
+	wc.out.feed(p[:n])
+	return n, err
+}
+
+func (wc *wireConn) Read(p []byte) (int, error) {
+	n, err := wc.Conn.Read(p)
+	wc.in.feed(p[:n])
+	return n, err
+}
+
+// frameSplitter splits the stream of one direction into the frames of the binary protocol.
+type frameSplitter struct {
+	// bodyLimit is a maximum number of bytes of the body passed to onFrame, a negative one passes the whole body.
+	bodyLimit int
+	// onFrame is called with the header and the body of every frame, they are valid only during the call.
+	onFrame func(hdr, body []byte)
+
+	hdr  [HDR_LEN]byte
+	hdrN int
+	// bodyLeft is a number of bytes of the body of the current frame, that are not read yet.
+	bodyLeft int
+	body     []byte
+}
+
+func (fs *frameSplitter) feed(p []byte) {
+	for len(p) > 0 {
+		if fs.hdrN < HDR_LEN {
+			n := copy(fs.hdr[fs.hdrN:], p)
+			fs.hdrN += n
+			p = p[n:]
+			if fs.hdrN < HDR_LEN {
+				return
+			}
+			fs.bodyLeft = int(binary.BigEndian.Uint32(fs.hdr[8:12]))
+		}
+
+		n := min(fs.bodyLeft, len(p))
+		keep := n
+		if fs.bodyLimit >= 0 {
+			keep = min(n, max(fs.bodyLimit-len(fs.body), 0))
+		}
+		fs.body = append(fs.body, p[:keep]...)
+		fs.bodyLeft -= n
+		p = p[n:]
+
+		if fs.bodyLeft == 0 {
+			fs.onFrame(fs.hdr[:], fs.body)
+			fs.hdrN = 0
+			fs.body = fs.body[:0]
+		}
+	}
+}
+
+// wireParser splits the stream of one direction into frames and logs them.
+type wireParser struct {
+	frameSplitter
+	log        logger.Logger
+	direction  string
+	withBodies bool
+}
+
+func newWireParser(log logger.Logger, direction string, withBodies bool) *wireParser {
+	wp := &wireParser{log: log, direction: direction, withBodies: withBodies}
+	if withBodies {
+		wp.bodyLimit = wireBodyLimit
+	}
+	wp.onFrame = func(hdr, body []byte) {
+		wp.log.Debugf("%s: wire %s %s", libPrefix, wp.direction, wp.frame(hdr, body))
+	}
+	return wp
+}
+
+// frame returns the description of the header and the body of the frame.
+func (wp *wireParser) frame(h, body []byte) string {
+	bodyLen := binary.BigEndian.Uint32(h[8:12])
+
+	var b strings.Builder
+	switch h[0] {
+	case REQ_MAGIC:
+		fmt.Fprintf(&b, "request opcode=%s vbucket=%d", OpCode(h[1]), binary.BigEndian.Uint16(h[6:8]))
+	case RES_MAGIC:
+		fmt.Fprintf(&b, "response opcode=%s status=%s", OpCode(h[1]), Status(binary.BigEndian.Uint16(h[6:8])))
+	default:
+		fmt.Fprintf(&b, "unknown magic=0x%02x opcode=0x%02x", h[0], h[1])
+	}
+	fmt.Fprintf(&b, " key_len=%d extras_len=%d body_len=%d opaque=%d cas=%d",
+		binary.BigEndian.Uint16(h[2:4]), h[4], bodyLen, binary.BigEndian.Uint32(h[12:16]), binary.BigEndian.Uint64(h[16:24]))
+	if wp.withBodies && bodyLen > 0 {
+		fmt.Fprintf(&b, " body=%s", hex.EncodeToString(body))
+		if bodyLen > uint32(len(body)) {
+			b.WriteString("...")
+		}
+	}
+	return b.String()
+}
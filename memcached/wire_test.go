@@ -0,0 +1,66 @@
+package memcached
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type debugRecorder struct {
+	records []string
+}
+
+func (r *debugRecorder) Debugf(format string, args ...any) {
+	r.records = append(r.records, fmt.Sprintf(format, args...))
+}
+func (r *debugRecorder) Infof(string, ...any)  {}
+func (r *debugRecorder) Warnf(string, ...any)  {}
+func (r *debugRecorder) Errorf(string, ...any) {}
+
+func Test_wireParser(t *testing.T) {
+	var buf bytes.Buffer
+	req := &Request{Opcode: SET, Key: []byte("key"), Body: []byte("value"), Opaque: 7}
+	req.prepareExtras(0, 0, 0)
+	_, err := req.Transmit(&buf)
+	require.Nil(t, err)
+	_, err = (&Response{Opcode: GET, Status: KEY_ENOENT, Opaque: 8, Cas: 9}).Transmit(&buf)
+	require.Nil(t, err)
+	stream := buf.Bytes()
+
+	r := &debugRecorder{}
+	wp := newWireParser(r, "->", false)
+	// the frames are split between the writes.
+	for _, b := range stream {
+		wp.feed([]byte{b})
+	}
+	assert.Equal(t, []string{
+		"gomemcached: wire -> request opcode=SET vbucket=0 key_len=3 extras_len=8 body_len=16 opaque=7 cas=0",
+		"gomemcached: wire -> response opcode=GET status=KEY_ENOENT key_len=0 extras_len=0 body_len=0 opaque=8 cas=9",
+	}, r.records)
+
+	r.records = nil
+	wp = newWireParser(r, "<-", true)
+	wp.feed(stream)
+	require.Len(t, r.records, 2)
+	assert.Contains(t, r.records[0], "body=0000000000000000"+"6b6579"+"76616c7565", "wireParser: extras, key and value")
+}
+
+func TestClient_WireLogging(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response { return &Response{} })
+
+	c, err := newForTests(srv.addr)
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+	c.wireLogging = true
+
+	addr, err := net.ResolveTCPAddr("tcp", srv.addr)
+	require.Nil(t, err)
+	nc, err := c.dial(addr)
+	require.Nil(t, err)
+	defer nc.Close()
+	assert.IsType(t, &wireConn{}, nc, "dial: connection should log the frames")
+}
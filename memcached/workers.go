@@ -0,0 +1,34 @@
+package memcached
+
+// startBatchWorkers starts n long-lived goroutines, that run the batches of the nodes of the multi-key operations
+// (see WithBatchWorkers). They are stopped with the other background goroutines of the client.
+func (c *Client) startBatchWorkers(n int) {
+	c.batchTasks = make(chan func())
+	c.bgWG.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer c.bgWG.Done()
+			for {
+				select {
+				case task := <-c.batchTasks:
+					task()
+				case <-c.ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// goBatch runs the batch of the node by the idle batch worker, if there is one, otherwise by the new goroutine,
+// so the operation never waits for the batches of the other calls.
+func (c *Client) goBatch(task func()) {
+	if c.batchTasks != nil {
+		select {
+		case c.batchTasks <- task:
+			return
+		default:
+		}
+	}
+	go task()
+}
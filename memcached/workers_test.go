@@ -0,0 +1,50 @@
+package memcached
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_BatchWorkers(t *testing.T) {
+	srv := newFakeServer(t, func(req *Request) *Response {
+		switch req.Opcode {
+		case GETQ:
+			return &Response{Body: req.Key}
+		case NOOP:
+			return &Response{}
+		}
+		return &Response{Status: UNKNOWN_COMMAND}
+	})
+	srv2 := newFakeServer(t, srv.handler)
+
+	c, err := newForTests(srv.addr, srv2.addr)
+	require.Nil(t, err)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.startBatchWorkers(2)
+
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, gErr := c.MultiGet(keys)
+			assert.Nil(t, gErr)
+			assert.Len(t, got, len(keys), "MultiGet: the batches should be run by the workers or the new goroutines")
+		}()
+	}
+	wg.Wait()
+
+	c.CloseAllConns()
+	done := make(chan struct{})
+	c.goBatch(func() { close(done) })
+	<-done
+}
@@ -0,0 +1,275 @@
+package memcachedtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+// ConformanceMaxValueLen is the size of the largest value of the conformance suite,
+// it is stored by memcached with the default limit of the item size (-I 1m).
+const ConformanceMaxValueLen = 1<<20 - 1<<10
+
+type (
+	// toucher is implemented by Mock and the client, Touch is checked, if the implementation has it.
+	toucher interface {
+		Touch(key string, exp uint32, opts ...memcached.CallOption) (*memcached.Response, error)
+	}
+
+	// casStorer is implemented by Mock and the client, StoreWithCAS is checked, if the implementation has it.
+	casStorer interface {
+		StoreWithCAS(storeMode memcached.StoreMode, key string, exp uint32, cas uint64, body []byte, opts ...memcached.CallOption) (*memcached.Response, error)
+	}
+)
+
+// RunConformance runs the suite of the behavior of memcached.Memcached against mc: all operations, the edge
+// expirations, the unicode keys, the values of the maximum size and CAS. So the mock, the client and
+// the other implementations (e.g. the client with another transport) stay behaviorally aligned.
+//
+// The keys of the suite have a unique prefix and are deleted at the end, FlushAll is not called,
+// so the suite can be run against a shared memcached. Touch and StoreWithCAS are checked, if mc has them.
+func RunConformance(t *testing.T, mc memcached.Memcached) {
+	t.Helper()
+
+	prefix := fmt.Sprintf("conformance:%d:", time.Now().UnixNano())
+	var keys []string
+	key := func(name string) string {
+		keys = append(keys, prefix+name)
+		return prefix + name
+	}
+	t.Cleanup(func() {
+		_ = mc.MultiDelete(keys)
+	})
+
+	t.Run("Store and Get", func(t *testing.T) {
+		k := key("store")
+		resp, err := mc.Store(memcached.Set, k, 0, []byte("value"))
+		require.Nil(t, err)
+		assert.NotZero(t, resp.Cas, "Store: response should have CAS")
+
+		resp, err = mc.Get(k)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("value"), resp.Body)
+		assert.NotZero(t, resp.Cas, "Get: response should have CAS")
+
+		_, err = mc.Get(key("missing"))
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+	})
+
+	t.Run("Store modes", func(t *testing.T) {
+		k := key("modes")
+		_, err := mc.Store(memcached.Replace, k, 0, []byte("replaced"))
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Replace: missing item should not be stored")
+		_, err = mc.Store(memcached.Add, k, 0, []byte("added"))
+		require.Nil(t, err)
+		_, err = mc.Store(memcached.Add, k, 0, []byte("added again"))
+		assert.ErrorIs(t, err, memcached.ErrNotStored, "Add: existing item should not be stored")
+		_, err = mc.Store(memcached.Replace, k, 0, []byte("replaced"))
+		require.Nil(t, err)
+
+		resp, err := mc.Get(k)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("replaced"), resp.Body)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		k := key("delete")
+		_, err := mc.Store(memcached.Set, k, 0, []byte("value"))
+		require.Nil(t, err)
+		_, err = mc.Delete(k)
+		require.Nil(t, err)
+		_, err = mc.Get(k)
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+		_, err = mc.Delete(k)
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Delete: missing item should be a miss")
+	})
+
+	t.Run("Append and Prepend", func(t *testing.T) {
+		k := key("append")
+		_, err := mc.Append(memcached.Append, k, []byte("c"))
+		assert.ErrorIs(t, err, memcached.ErrNotStored, "Append: missing item should not be stored")
+
+		_, err = mc.Store(memcached.Set, k, 0, []byte("b"))
+		require.Nil(t, err)
+		_, err = mc.Append(memcached.Append, k, []byte("c"))
+		require.Nil(t, err)
+		_, err = mc.Append(memcached.Prepend, k, []byte("a"))
+		require.Nil(t, err)
+
+		resp, err := mc.Get(k)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("abc"), resp.Body)
+	})
+
+	t.Run("Delta", func(t *testing.T) {
+		k := key("delta")
+		_, err := mc.Delta(memcached.Increment, k, 1, 0, 0, memcached.WithoutCreate())
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Delta: missing item should not be created")
+
+		value, err := mc.Delta(memcached.Increment, k, 5, 10, 0)
+		require.Nil(t, err)
+		assert.EqualValues(t, 10, value, "Delta: missing item should be created with the initial value")
+		value, err = mc.Delta(memcached.Increment, k, 5, 10, 0)
+		require.Nil(t, err)
+		assert.EqualValues(t, 15, value)
+		value, err = mc.Delta(memcached.Decrement, k, 100, 0, 0)
+		require.Nil(t, err)
+		assert.Zero(t, value, "Delta: decrement should not go below zero")
+
+		resp, err := mc.Get(k)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("0"), bytes.TrimSpace(resp.Body), "Delta: value should be stored as a decimal")
+
+		_, err = mc.Store(memcached.Set, k, 0, []byte("not a number"))
+		require.Nil(t, err)
+		_, err = mc.Delta(memcached.Increment, k, 1, 0, 0)
+		assert.ErrorIs(t, err, memcached.ErrInvalidArguments, "Delta: value should be a number")
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		items := map[string][]byte{
+			key("multi1"): []byte("value1"),
+			key("multi2"): []byte("value2"),
+			key("multi3"): {},
+		}
+		require.Nil(t, mc.MultiStore(memcached.Set, items, 0))
+
+		missing := key("multi-missing")
+		got, err := mc.MultiGet([]string{prefix + "multi1", prefix + "multi2", prefix + "multi3", missing})
+		require.Nil(t, err)
+		assert.Len(t, got, len(items), "MultiGet: missing keys should not be returned")
+		for k, v := range items {
+			assert.True(t, bytes.Equal(v, got[k]), "MultiGet: wrong value of %s", k)
+		}
+
+		require.Nil(t, mc.MultiDelete([]string{prefix + "multi1", prefix + "multi2"}))
+		got, err = mc.MultiGet([]string{prefix + "multi1", prefix + "multi2", prefix + "multi3"})
+		require.Nil(t, err)
+		assert.Len(t, got, 1, "MultiDelete: deleted keys should not be returned")
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			exp     uint32
+			expired bool
+		}{
+			{name: "never", exp: 0},
+			{name: "relative", exp: 3600},
+			{name: "max relative", exp: maxRelativeExp},
+			{name: "absolute", exp: uint32(time.Now().Add(time.Hour).Unix())},
+			// larger than 30 days is the unix time, so it is in 1970
+			{name: "absolute in the past", exp: maxRelativeExp + 1, expired: true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				k := key("exp-" + strings.ReplaceAll(tt.name, " ", "-"))
+				_, err := mc.Store(memcached.Set, k, tt.exp, []byte("value"))
+				require.Nil(t, err)
+
+				_, err = mc.Get(k)
+				if tt.expired {
+					assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Get: item should be expired")
+				} else {
+					assert.Nil(t, err, "Get: item should not be expired")
+				}
+			})
+		}
+
+		tm, ok := mc.(toucher)
+		if !ok {
+			return
+		}
+		k := key("touch")
+		_, err := tm.Touch(k, 3600)
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Touch: missing item should be a miss")
+		_, err = mc.Store(memcached.Set, k, 3600, []byte("value"))
+		require.Nil(t, err)
+		_, err = tm.Touch(k, 0)
+		require.Nil(t, err)
+		_, err = mc.Get(k)
+		assert.Nil(t, err, "Touch: item should not be expired")
+		_, err = tm.Touch(k, maxRelativeExp+1)
+		require.Nil(t, err)
+		_, err = mc.Get(k)
+		assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Touch: item should be expired by the time in the past")
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		for _, name := range []string{"ключ", "キー", "🔑", strings.Repeat("k", 250-len(prefix))} {
+			k := key(name)
+			_, err := mc.Store(memcached.Set, k, 0, []byte(name))
+			require.Nil(t, err, "Store: key %q should be legal", k)
+			resp, err := mc.Get(k)
+			require.Nil(t, err)
+			assert.Equal(t, []byte(name), resp.Body)
+		}
+
+		for _, k := range []string{strings.Repeat("k", 251), prefix + "with space", prefix + "with\nnewline"} {
+			_, err := mc.Store(memcached.Set, k, 0, []byte("value"))
+			assert.ErrorIs(t, err, memcached.ErrMalformedKey, "Store: key %q should be malformed", k)
+			_, err = mc.Get(k)
+			assert.ErrorIs(t, err, memcached.ErrMalformedKey, "Get: key %q should be malformed", k)
+		}
+	})
+
+	t.Run("Values", func(t *testing.T) {
+		binary := make([]byte, 256)
+		for i := range binary {
+			binary[i] = byte(i)
+		}
+		large := bytes.Repeat([]byte("v"), ConformanceMaxValueLen)
+
+		for name, value := range map[string][]byte{"empty": {}, "binary": binary, "large": large} {
+			k := key("value-" + name)
+			_, err := mc.Store(memcached.Set, k, 0, value)
+			require.Nil(t, err, "Store: %s value should be stored", name)
+			resp, err := mc.Get(k)
+			require.Nil(t, err)
+			assert.True(t, bytes.Equal(value, resp.Body), "Get: %s value should be the same", name)
+		}
+
+		_, err := mc.Store(memcached.Set, key("value-too-large"), 0, make([]byte, memcached.MaxBodyLen+1))
+		assert.ErrorIs(t, err, memcached.ErrDataSizeExceedsLimit)
+	})
+
+	t.Run("CAS and flags", func(t *testing.T) {
+		k := key("cas")
+		_, err := mc.Store(memcached.Set, k, 0, []byte("v1"), memcached.WithFlags(42))
+		require.Nil(t, err)
+
+		var (
+			cas   uint64
+			flags uint32
+		)
+		_, err = mc.Get(k, memcached.WithCASOut(&cas), memcached.WithFlagsOut(&flags))
+		require.Nil(t, err)
+		assert.NotZero(t, cas)
+		assert.EqualValues(t, 42, flags, "Get: flags should be stored with the value")
+
+		_, err = mc.Store(memcached.Set, k, 0, []byte("v2"), memcached.WithCAS(cas))
+		require.Nil(t, err, "Store: item should be stored with the actual CAS")
+		_, err = mc.Store(memcached.Set, k, 0, []byte("v3"), memcached.WithCAS(cas))
+		assert.ErrorIs(t, err, memcached.ErrNotStored, "Store: item should not be stored with the stale CAS")
+
+		resp, err := mc.Get(k)
+		require.Nil(t, err)
+		assert.Equal(t, []byte("v2"), resp.Body)
+
+		cs, ok := mc.(casStorer)
+		if !ok {
+			return
+		}
+		_, err = cs.StoreWithCAS(memcached.Set, k, 0, cas, []byte("v4"))
+		assert.ErrorIs(t, err, memcached.ErrCASConflict, "StoreWithCAS: stale CAS should be a conflict")
+		_, err = cs.StoreWithCAS(memcached.Set, k, 0, resp.Cas, []byte("v4"))
+		assert.Nil(t, err, "StoreWithCAS: item should be stored with the actual CAS")
+	})
+}
@@ -0,0 +1,31 @@
+package memcachedtest
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+const localhostTCPAddr = "localhost:11211"
+
+func TestRunConformance_Mock(t *testing.T) {
+	RunConformance(t, New())
+}
+
+func TestRunConformance_Client(t *testing.T) {
+	nc, err := net.Dial("tcp", localhostTCPAddr)
+	if err != nil {
+		t.Skipf("skipping test; no server running at %s", localhostTCPAddr)
+	}
+	_ = nc.Close()
+
+	t.Setenv("MEMCACHED_SERVERS", localhostTCPAddr)
+	c, err := memcached.InitFromEnv(memcached.WithDisableNodeProvider(), memcached.WithDisableMemcachedDiagnostic())
+	require.Nil(t, err)
+	defer c.CloseAllConns()
+
+	RunConformance(t, c)
+}
@@ -0,0 +1,550 @@
+// Package memcachedtest provides utilities for testing the code, that depends on the memcached client.
+package memcachedtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+// maxRelativeExp is the maximum expiration in seconds, that is treated as relative to the current time,
+// larger values are unix timestamps (as in memcached).
+const maxRelativeExp = 30 * 24 * 60 * 60
+
+// mockAddr is the address of the single node of Mock for FlushAll options and RouteTo.
+const mockAddr = "mock"
+
+var _ memcached.Memcached = (*Mock)(nil)
+
+type (
+	// Mock is an in-memory implementation of memcached.Memcached with TTL expiry, CAS and flags.
+	// It returns the same responses and errors as the client with a single memcached node.
+	// It is safe for unlocked use by multiple concurrent goroutines.
+	Mock struct {
+		mu    sync.Mutex
+		items map[string]Item
+		// cas - the last CAS identifier given to an item.
+		cas uint64
+		// offset - shift of the clock of the mock by Advance.
+		offset time.Duration
+	}
+
+	// Item is an item stored in Mock.
+	Item struct {
+		Value []byte
+		Flags uint32
+		// Expiration is the time, when the item expires, zero means never.
+		Expiration time.Time
+		// Cas is the CAS identifier of the item, it is set by Mock on every change.
+		Cas uint64
+	}
+)
+
+// New returns an empty Mock.
+func New() *Mock {
+	return &Mock{items: make(map[string]Item)}
+}
+
+// Advance moves the clock of the mock forward by d, so the items expire without waiting.
+func (m *Mock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offset += d
+}
+
+// SetItem stores the item as is, e.g. with the exact time of expiration.
+// The CAS identifier of the item is replaced by a new one.
+func (m *Mock) SetItem(key string, it Item) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it.Value = bytes.Clone(it.Value)
+	m.put(key, it)
+}
+
+// GetItem returns a copy of the item, if it is stored and not expired.
+func (m *Mock) GetItem(key string) (Item, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.get(key)
+	it.Value = bytes.Clone(it.Value)
+	return it, ok
+}
+
+// Len returns the number of not expired items.
+func (m *Mock) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int
+	for key := range m.items {
+		if _, ok := m.get(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Store is a wrote the provided item with expiration.
+// The call options are applied as by the client, except the timeout.
+func (m *Mock) Store(storeMode memcached.StoreMode, key string, exp uint32, body []byte, opts ...memcached.CallOption) (*memcached.Response, error) {
+	co := memcached.ResolveCallOptions(opts...)
+	resp, err := m.store(storeMode.Resolve(), key, exp, body, co)
+	readResponse(co, resp, err)
+	return resp, err
+}
+
+// StoreWithCAS is a wrote the provided item with expiration, if its CAS identifier is equal to cas.
+// Zero cas is not checked. ErrCASConflict is returned, if the item was changed.
+func (m *Mock) StoreWithCAS(storeMode memcached.StoreMode, key string, exp uint32, cas uint64, body []byte, opts ...memcached.CallOption) (*memcached.Response, error) {
+	co := memcached.ResolveCallOptions(opts...)
+	co.CAS = cas
+	resp, err := m.store(storeMode.Resolve(), key, exp, body, co)
+	if cas != 0 && resp != nil && resp.Status == memcached.KEY_EEXISTS {
+		return resp, fmt.Errorf("%w. %w", memcached.ErrCASConflict, resp)
+	}
+	readResponse(co, resp, err)
+	return resp, err
+}
+
+func (m *Mock) store(opcode memcached.OpCode, key string, exp uint32, body []byte, co memcached.CallOptions) (*memcached.Response, error) {
+	if err := checkCall(key, co); err != nil {
+		return nil, err
+	}
+	if err := checkBodyLen(key, body); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.get(key)
+	if status := checkCAS(it, ok, co.CAS); status != memcached.SUCCESS {
+		return statusResponse(opcode, status)
+	}
+	switch {
+	case opcode == memcached.ADD && ok:
+		return statusResponse(opcode, memcached.KEY_EEXISTS)
+	case opcode == memcached.REPLACE && !ok:
+		return statusResponse(opcode, memcached.KEY_ENOENT)
+	}
+
+	it = Item{Value: bytes.Clone(body), Flags: co.Flags, Expiration: m.expiration(exp)}
+	m.put(key, it)
+	return &memcached.Response{Opcode: opcode, Status: memcached.SUCCESS, Cas: m.cas}, nil
+}
+
+// Get is return an item for provided key.
+func (m *Mock) Get(key string, opts ...memcached.CallOption) (*memcached.Response, error) {
+	co := memcached.ResolveCallOptions(opts...)
+	if err := checkCall(key, co); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.get(key)
+	if !ok {
+		return statusResponse(memcached.GET, memcached.KEY_ENOENT)
+	}
+	resp := itemResponse(it)
+	readResponse(co, resp, nil)
+	return resp, nil
+}
+
+// Lookup is return the value for provided key and whether it is found, the cache miss is not an error.
+func (m *Mock) Lookup(key string, opts ...memcached.CallOption) ([]byte, bool, error) {
+	resp, err := m.Get(key, opts...)
+	switch {
+	case err == nil:
+		return resp.Body, true, nil
+	case errors.Is(err, memcached.ErrCacheMiss):
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+// Delete is a deletes the element with the provided key.
+// If the element does not exist, an ErrCacheMiss error is returned.
+func (m *Mock) Delete(key string, opts ...memcached.CallOption) (*memcached.Response, error) {
+	co := memcached.ResolveCallOptions(opts...)
+	if err := checkCall(key, co); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.get(key)
+	if !ok {
+		return statusResponse(memcached.DELETE, memcached.KEY_ENOENT)
+	}
+	if status := checkCAS(it, ok, co.CAS); status != memcached.SUCCESS {
+		return statusResponse(memcached.DELETE, status)
+	}
+	delete(m.items, key)
+	return &memcached.Response{Opcode: memcached.DELETE, Status: memcached.SUCCESS}, nil
+}
+
+// Touch is a changes the expiration of the element with the provided key without fetching it.
+// If the element does not exist, an ErrCacheMiss error is returned.
+func (m *Mock) Touch(key string, exp uint32, opts ...memcached.CallOption) (*memcached.Response, error) {
+	co := memcached.ResolveCallOptions(opts...)
+	if err := checkCall(key, co); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.get(key)
+	if !ok {
+		return statusResponse(memcached.TOUCH, memcached.KEY_ENOENT)
+	}
+	it.Expiration = m.expiration(exp)
+	m.put(key, it)
+	resp := &memcached.Response{Opcode: memcached.TOUCH, Status: memcached.SUCCESS, Cas: m.cas}
+	readResponse(co, resp, nil)
+	return resp, nil
+}
+
+// Delta is an atomically increments/decrements value by delta. The return value is
+// the new value after being incremented/decrements or an error.
+// If the item doesn't exist, it is created with the initial value.
+func (m *Mock) Delta(deltaMode memcached.DeltaMode, key string, delta, initial uint64, exp uint32, opts ...memcached.CallOption) (uint64, error) {
+	opcode := deltaMode.Resolve()
+	co := memcached.ResolveCallOptions(opts...)
+	if err := checkCall(key, co); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.get(key)
+	if status := checkCAS(it, ok, co.CAS); status != memcached.SUCCESS {
+		_, err := statusResponse(opcode, status)
+		return 0, err
+	}
+	if !ok {
+		if exp == memcached.DeltaNoCreate || co.NoCreate {
+			_, err := statusResponse(opcode, memcached.KEY_ENOENT)
+			return 0, err
+		}
+		m.put(key, Item{Value: []byte(strconv.FormatUint(initial, 10)), Expiration: m.expiration(exp)})
+		readResponse(co, &memcached.Response{Cas: m.cas}, nil)
+		return initial, nil
+	}
+
+	value, err := strconv.ParseUint(string(it.Value), 10, 64)
+	if err != nil {
+		_, err = statusResponse(opcode, memcached.DELTA_BADVAL)
+		return 0, err
+	}
+	if opcode == memcached.INCREMENT {
+		value += delta
+	} else {
+		value -= min(value, delta)
+	}
+
+	it.Value = []byte(strconv.FormatUint(value, 10))
+	m.put(key, it)
+	readResponse(co, &memcached.Response{Cas: m.cas}, nil)
+	return value, nil
+}
+
+// Append is an appends/prepends the given item to the existing item, if a value already
+// exists for its key. ErrNotStored is returned if that condition is not met.
+func (m *Mock) Append(appendMode memcached.AppendMode, key string, data []byte, opts ...memcached.CallOption) (*memcached.Response, error) {
+	opcode := appendMode.Resolve()
+	co := memcached.ResolveCallOptions(opts...)
+	if err := checkCall(key, co); err != nil {
+		return nil, err
+	}
+	if err := checkBodyLen(key, data); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.get(key)
+	if !ok {
+		return statusResponse(opcode, memcached.NOT_STORED)
+	}
+	if status := checkCAS(it, ok, co.CAS); status != memcached.SUCCESS {
+		return statusResponse(opcode, status)
+	}
+	if len(it.Value)+len(data) > memcached.MaxBodyLen {
+		return statusResponse(opcode, memcached.E2BIG)
+	}
+
+	if opcode == memcached.APPEND {
+		it.Value = append(bytes.Clone(it.Value), data...)
+	} else {
+		it.Value = append(bytes.Clone(data), it.Value...)
+	}
+	m.put(key, it)
+	resp := &memcached.Response{Opcode: opcode, Status: memcached.SUCCESS, Cas: m.cas}
+	readResponse(co, resp, nil)
+	return resp, nil
+}
+
+// FlushAll is a deletes all items in the cache. If exp is not zero, the items expire after exp instead.
+// Mock is a single node with the address "mock", so the stagger is not applied.
+func (m *Mock) FlushAll(exp uint32, opts ...memcached.FlushOption) error {
+	fo := memcached.ResolveFlushOptions(opts...)
+	for _, addr := range fo.Nodes {
+		if err := checkNode(addr); err != nil {
+			return err
+		}
+	}
+	if fo.DryRun != nil {
+		*fo.DryRun = []memcached.FlushStep{{Addr: mockAddr, Exp: exp}}
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if exp == 0 {
+		clear(m.items)
+		return nil
+	}
+
+	flushAt := m.expiration(exp)
+	for key, it := range m.items {
+		if it.Expiration.IsZero() || it.Expiration.After(flushAt) {
+			it.Expiration = flushAt
+			m.items[key] = it
+		}
+	}
+	return nil
+}
+
+// FlushNode is a deletes all items of the node, the address of the single node of Mock is "mock".
+func (m *Mock) FlushNode(addr string, exp uint32) error {
+	return m.FlushAll(exp, memcached.WithFlushNodes(addr))
+}
+
+// MultiDelete is a batch version of Delete, the ErrCacheMiss error is ignored.
+func (m *Mock) MultiDelete(keys []string) error {
+	var multiErr error
+	for _, key := range keys {
+		if _, err := m.Delete(key); err != nil && !errors.Is(err, memcached.ErrCacheMiss) {
+			multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for key - %s", err, key))
+		}
+	}
+	return multiErr
+}
+
+// MultiStore is a batch version of Store.
+func (m *Mock) MultiStore(storeMode memcached.StoreMode, items map[string][]byte, exp uint32) error {
+	var multiErr error
+	for key, body := range items {
+		if _, err := m.Store(storeMode, key, exp, body); err != nil {
+			multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for key - %s", err, key))
+		}
+	}
+	return multiErr
+}
+
+// MultiStoreWithCAS is a batch version of StoreWithCAS.
+func (m *Mock) MultiStoreWithCAS(storeMode memcached.StoreMode, items map[string]memcached.CASItem, exp uint32) error {
+	var multiErr error
+	for key, it := range items {
+		if _, err := m.StoreWithCAS(storeMode, key, exp, it.Cas, it.Value); err != nil {
+			multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for key - %s", err, key))
+		}
+	}
+	return multiErr
+}
+
+// MultiAppend is a batch version of Append.
+func (m *Mock) MultiAppend(appendMode memcached.AppendMode, items map[string][]byte) error {
+	var multiErr error
+	for key, data := range items {
+		if _, err := m.Append(appendMode, key, data); err != nil {
+			multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for key - %s", err, key))
+		}
+	}
+	return multiErr
+}
+
+// MultiDelta is a batch version of Delta, the counters, that are missing and not created, are not present in the result.
+func (m *Mock) MultiDelta(deltaMode memcached.DeltaMode, deltas map[string]uint64, initial uint64, exp uint32) (map[string]uint64, error) {
+	var multiErr error
+	ret := make(map[string]uint64, len(deltas))
+	for key, delta := range deltas {
+		value, err := m.Delta(deltaMode, key, delta, initial, exp)
+		switch {
+		case err == nil:
+			ret[key] = value
+		case !errors.Is(err, memcached.ErrCacheMiss):
+			multiErr = errors.Join(multiErr, fmt.Errorf("%w. Error for key - %s", err, key))
+		}
+	}
+	return ret, multiErr
+}
+
+// MultiGet is a batch version of Get, the missing keys are not present in the result.
+func (m *Mock) MultiGet(keys []string) (map[string][]byte, error) {
+	ret := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		resp, err := m.Get(key)
+		switch {
+		case err == nil:
+			ret[key] = resp.Body
+		case !errors.Is(err, memcached.ErrCacheMiss):
+			return ret, err
+		}
+	}
+	return ret, nil
+}
+
+// CloseAllConns does nothing, Mock has no connections.
+func (m *Mock) CloseAllConns() {}
+
+// CloseAvailableConnsInAllShardPools does nothing, Mock has no connections.
+func (m *Mock) CloseAvailableConnsInAllShardPools(int) int { return 0 }
+
+func (m *Mock) now() time.Time {
+	return time.Now().Add(m.offset)
+}
+
+// expiration converts exp of the request to the time of expiration.
+func (m *Mock) expiration(exp uint32) time.Time {
+	switch {
+	case exp == 0:
+		return time.Time{}
+	case exp <= maxRelativeExp:
+		return m.now().Add(time.Duration(exp) * time.Second)
+	default:
+		return time.Unix(int64(exp), 0)
+	}
+}
+
+// get returns the item, the expired item is removed. m.mu must be held.
+func (m *Mock) get(key string) (Item, bool) {
+	it, ok := m.items[key]
+	if !ok {
+		return Item{}, false
+	}
+	if !it.Expiration.IsZero() && !m.now().Before(it.Expiration) {
+		delete(m.items, key)
+		return Item{}, false
+	}
+	return it, true
+}
+
+// put stores the item with a new CAS identifier. m.mu must be held.
+func (m *Mock) put(key string, it Item) {
+	m.cas++
+	it.Cas = m.cas
+	m.items[key] = it
+}
+
+// checkCAS returns the status of the request with cas to the item, that is found, if ok.
+func checkCAS(it Item, ok bool, cas uint64) memcached.Status {
+	switch {
+	case cas == 0:
+		return memcached.SUCCESS
+	case !ok:
+		return memcached.KEY_ENOENT
+	case it.Cas != cas:
+		return memcached.KEY_EEXISTS
+	default:
+		return memcached.SUCCESS
+	}
+}
+
+// readResponse sets the outputs of the call options as the client does.
+func readResponse(co memcached.CallOptions, resp *memcached.Response, err error) {
+	if err != nil || resp == nil {
+		return
+	}
+	if co.CASOut != nil {
+		*co.CASOut = resp.Cas
+	}
+	if co.FlagsOut != nil && len(resp.Extras) >= 4 {
+		*co.FlagsOut = binary.BigEndian.Uint32(resp.Extras)
+	}
+}
+
+func itemResponse(it Item) *memcached.Response {
+	extras := make([]byte, 4)
+	binary.BigEndian.PutUint32(extras, it.Flags)
+	return &memcached.Response{
+		Opcode: memcached.GET,
+		Status: memcached.SUCCESS,
+		Cas:    it.Cas,
+		Extras: extras,
+		Body:   bytes.Clone(it.Value),
+	}
+}
+
+// statusResponse returns the response with the status and the error, that the client returns for it.
+func statusResponse(opcode memcached.OpCode, status memcached.Status) (*memcached.Response, error) {
+	resp := &memcached.Response{Opcode: opcode, Status: status}
+
+	var err error
+	switch status {
+	case memcached.KEY_ENOENT:
+		err = memcached.ErrCacheMiss
+	case memcached.NOT_STORED, memcached.KEY_EEXISTS:
+		err = memcached.ErrNotStored
+	case memcached.DELTA_BADVAL:
+		err = memcached.ErrInvalidArguments
+	case memcached.E2BIG:
+		err = memcached.ErrDataSizeExceedsLimit
+	default:
+		err = memcached.ErrServerError
+	}
+	return resp, fmt.Errorf("%w. %w", err, resp)
+}
+
+// checkCall checks the key and the node of the call.
+func checkCall(key string, co memcached.CallOptions) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if co.RouteTo != "" {
+		return checkNode(co.RouteTo)
+	}
+	return nil
+}
+
+// checkNode checks, that addr is the address of Mock.
+func checkNode(addr string) error {
+	if addr != mockAddr {
+		return fmt.Errorf("%w: %s is not a node of the hash ring", memcached.ErrInvalidAddr, addr)
+	}
+	return nil
+}
+
+func checkKey(key string) error {
+	if len(key) > 250 {
+		return memcached.ErrMalformedKey
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return memcached.ErrMalformedKey
+		}
+	}
+	return nil
+}
+
+func checkBodyLen(key string, body []byte) error {
+	if len(body) > memcached.MaxBodyLen {
+		return fmt.Errorf("%w: %d bytes for key %s (max %d)",
+			memcached.ErrDataSizeExceedsLimit, len(body), key, memcached.MaxBodyLen)
+	}
+	return nil
+}
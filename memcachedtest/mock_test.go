@@ -0,0 +1,326 @@
+package memcachedtest
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+func TestMock_Store(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    memcached.StoreMode
+		exists  bool
+		wantErr error
+	}{
+		{name: "Set new", mode: memcached.Set},
+		{name: "Set existing", mode: memcached.Set, exists: true},
+		{name: "Add new", mode: memcached.Add},
+		{name: "Add existing", mode: memcached.Add, exists: true, wantErr: memcached.ErrNotStored},
+		{name: "Replace new", mode: memcached.Replace, wantErr: memcached.ErrCacheMiss},
+		{name: "Replace existing", mode: memcached.Replace, exists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New()
+			if tt.exists {
+				_, err := m.Store(memcached.Set, "key", 0, []byte("old"))
+				require.Nil(t, err)
+			}
+
+			resp, err := m.Store(tt.mode, "key", 0, []byte("new"))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.NotNil(t, memcached.UnwrapMemcachedError(err), "Store: error should contain the response")
+				return
+			}
+			require.Nil(t, err)
+			assert.NotZero(t, resp.Cas)
+
+			resp, err = m.Get("key")
+			require.Nil(t, err)
+			assert.Equal(t, []byte("new"), resp.Body)
+		})
+	}
+}
+
+func TestMock_Errors(t *testing.T) {
+	m := New()
+
+	_, err := m.Get("bad key")
+	assert.ErrorIs(t, err, memcached.ErrMalformedKey)
+	_, err = m.Store(memcached.Set, strings.Repeat("k", 251), 0, nil)
+	assert.ErrorIs(t, err, memcached.ErrMalformedKey)
+	_, err = m.Store(memcached.Set, "key", 0, make([]byte, memcached.MaxBodyLen+1))
+	assert.ErrorIs(t, err, memcached.ErrDataSizeExceedsLimit)
+
+	_, err = m.Get("key")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+	_, err = m.Delete("key")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+	_, err = m.Append(memcached.Append, "key", []byte("data"))
+	assert.ErrorIs(t, err, memcached.ErrNotStored)
+}
+
+func TestMock_Lookup(t *testing.T) {
+	m := New()
+	m.SetItem("key", Item{Value: []byte("value")})
+
+	value, found, err := m.Lookup("key")
+	require.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("value"), value)
+
+	_, found, err = m.Lookup("missing")
+	assert.Nil(t, err, "Lookup: cache miss should not be an error")
+	assert.False(t, found)
+
+	_, _, err = m.Lookup("bad key")
+	assert.ErrorIs(t, err, memcached.ErrMalformedKey)
+}
+
+func TestMock_Expiration(t *testing.T) {
+	m := New()
+
+	_, err := m.Store(memcached.Set, "relative", 10, []byte("value"))
+	require.Nil(t, err)
+	_, err = m.Store(memcached.Set, "absolute", uint32(time.Now().Add(time.Hour).Unix()), []byte("value"))
+	require.Nil(t, err)
+	_, err = m.Store(memcached.Set, "forever", 0, []byte("value"))
+	require.Nil(t, err)
+	assert.Equal(t, 3, m.Len())
+
+	m.Advance(9 * time.Second)
+	_, err = m.Get("relative")
+	assert.Nil(t, err, "Get: item should not expire before exp")
+
+	m.Advance(time.Second)
+	_, err = m.Get("relative")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+	assert.Equal(t, 2, m.Len())
+
+	m.Advance(time.Hour)
+	_, err = m.Get("absolute")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+	_, err = m.Get("forever")
+	assert.Nil(t, err)
+
+	var plan []memcached.FlushStep
+	require.Nil(t, m.FlushAll(5, memcached.WithFlushDryRun(&plan)))
+	assert.Equal(t, []memcached.FlushStep{{Addr: "mock", Exp: 5}}, plan)
+	assert.Equal(t, 1, m.Len(), "FlushAll: dry run should not flush")
+	assert.ErrorIs(t, m.FlushAll(0, memcached.WithFlushNodes("127.0.0.1:11211")), memcached.ErrInvalidAddr)
+	assert.ErrorIs(t, m.FlushNode("127.0.0.1:11211", 0), memcached.ErrInvalidAddr)
+
+	require.Nil(t, m.FlushAll(5))
+	m.Advance(5 * time.Second)
+	assert.Equal(t, 0, m.Len(), "FlushAll: items should expire after exp")
+}
+
+func TestMock_Touch(t *testing.T) {
+	m := New()
+
+	_, err := m.Touch("key", 10)
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+
+	_, err = m.Store(memcached.Set, "key", 10, []byte("value"))
+	require.Nil(t, err)
+	m.Advance(5 * time.Second)
+	_, err = m.Touch("key", 10)
+	require.Nil(t, err)
+
+	m.Advance(9 * time.Second)
+	_, err = m.Get("key")
+	assert.Nil(t, err, "Touch: expiration should be extended")
+	m.Advance(time.Second)
+	_, err = m.Get("key")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+}
+
+func TestMock_CASAndFlags(t *testing.T) {
+	m := New()
+	m.SetItem("key", Item{Value: []byte("value"), Flags: 42})
+
+	resp, err := m.Get("key")
+	require.Nil(t, err)
+	require.Len(t, resp.Extras, 4)
+	assert.Equal(t, uint32(42), binary.BigEndian.Uint32(resp.Extras))
+	cas := resp.Cas
+
+	_, err = m.StoreWithCAS(memcached.Set, "key", 0, cas+1, []byte("other"))
+	assert.ErrorIs(t, err, memcached.ErrCASConflict)
+	_, err = m.StoreWithCAS(memcached.Set, "missing", 0, cas, []byte("other"))
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+
+	resp, err = m.StoreWithCAS(memcached.Set, "key", 0, cas, []byte("new"))
+	require.Nil(t, err)
+	assert.Greater(t, resp.Cas, cas)
+
+	it, ok := m.GetItem("key")
+	require.True(t, ok)
+	assert.Equal(t, []byte("new"), it.Value)
+	assert.Equal(t, resp.Cas, it.Cas)
+}
+
+func TestMock_Delta(t *testing.T) {
+	m := New()
+
+	_, err := m.Delta(memcached.Increment, "counter", 1, 0, memcached.DeltaNoCreate)
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Delta: item should not be created with exp DeltaNoCreate")
+	_, err = m.Delta(memcached.Increment, "counter", 1, 0, 0, memcached.WithoutCreate())
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss, "Delta: item should not be created WithoutCreate")
+
+	value, err := m.Delta(memcached.Increment, "counter", 5, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(10), value, "Delta: should create the item with initial value")
+
+	value, err = m.Delta(memcached.Increment, "counter", 5, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(15), value)
+
+	value, err = m.Delta(memcached.Decrement, "counter", 100, 0, 0)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), value, "Delta: decrement should not go below zero")
+
+	_, err = m.Store(memcached.Set, "text", 0, []byte("text"))
+	require.Nil(t, err)
+	_, err = m.Delta(memcached.Increment, "text", 1, 0, 0)
+	assert.ErrorIs(t, err, memcached.ErrInvalidArguments)
+}
+
+func TestMock_Append(t *testing.T) {
+	m := New()
+	_, err := m.Store(memcached.Set, "key", 0, []byte("b"))
+	require.Nil(t, err)
+
+	_, err = m.Append(memcached.Append, "key", []byte("c"))
+	require.Nil(t, err)
+	_, err = m.Append(memcached.Prepend, "key", []byte("a"))
+	require.Nil(t, err)
+
+	resp, err := m.Get("key")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("abc"), resp.Body)
+}
+
+func TestMock_Multi(t *testing.T) {
+	m := New()
+
+	items := map[string][]byte{"k1": []byte("v1"), "k2": []byte("v2")}
+	require.Nil(t, m.MultiStore(memcached.Set, items, 0))
+	assert.ErrorIs(t, m.MultiStore(memcached.Add, items, 0), memcached.ErrNotStored)
+
+	got, err := m.MultiGet([]string{"k1", "k2", "missing"})
+	require.Nil(t, err)
+	assert.Equal(t, items, got)
+
+	require.Nil(t, m.MultiDelete([]string{"k1", "missing"}))
+	assert.Equal(t, 1, m.Len())
+
+	require.Nil(t, m.FlushAll(0))
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestMock_MultiStoreWithCAS(t *testing.T) {
+	m := New()
+	resp, err := m.Store(memcached.Set, "k1", 0, []byte("v1"))
+	require.Nil(t, err)
+
+	err = m.MultiStoreWithCAS(memcached.Set, map[string]memcached.CASItem{
+		"k1": {Value: []byte("v2"), Cas: resp.Cas},
+		"k2": {Value: []byte("v2"), Cas: resp.Cas},
+	}, 0)
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss, "MultiStoreWithCAS: missing item")
+
+	err = m.MultiStoreWithCAS(memcached.Set, map[string]memcached.CASItem{"k1": {Value: []byte("v3"), Cas: resp.Cas}}, 0)
+	assert.ErrorIs(t, err, memcached.ErrCASConflict, "MultiStoreWithCAS: changed item")
+
+	it, ok := m.GetItem("k1")
+	require.True(t, ok)
+	assert.Equal(t, []byte("v2"), it.Value)
+}
+
+func TestMock_MultiAppend(t *testing.T) {
+	m := New()
+	require.Nil(t, m.MultiStore(memcached.Set, map[string][]byte{"k1": []byte("b")}, 0))
+
+	require.Nil(t, m.MultiAppend(memcached.Append, map[string][]byte{"k1": []byte("c")}))
+	require.Nil(t, m.MultiAppend(memcached.Prepend, map[string][]byte{"k1": []byte("a")}))
+	err := m.MultiAppend(memcached.Append, map[string][]byte{"missing": []byte("d")})
+	assert.ErrorIs(t, err, memcached.ErrNotStored)
+
+	it, ok := m.GetItem("k1")
+	require.True(t, ok)
+	assert.Equal(t, []byte("abc"), it.Value)
+}
+
+func TestMock_MultiDelta(t *testing.T) {
+	m := New()
+	_, err := m.Store(memcached.Set, "text", 0, []byte("text"))
+	require.Nil(t, err)
+
+	got, err := m.MultiDelta(memcached.Increment, map[string]uint64{"k1": 1, "k2": 2}, 10, 0)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"k1": 10, "k2": 10}, got)
+
+	got, err = m.MultiDelta(memcached.Increment, map[string]uint64{"k1": 1, "k2": 2, "missing": 1, "text": 1}, 0, memcached.DeltaNoCreate)
+	assert.ErrorIs(t, err, memcached.ErrInvalidArguments)
+	assert.Equal(t, map[string]uint64{"k1": 11, "k2": 12}, got)
+}
+
+func TestMock_Concurrent(t *testing.T) {
+	m := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = m.Delta(memcached.Increment, "counter", 1, 1, 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := m.Get("counter")
+	require.Nil(t, err)
+	assert.Equal(t, []byte("1000"), resp.Body)
+}
+
+func TestMock_CallOptions(t *testing.T) {
+	m := New()
+
+	var cas uint64
+	_, err := m.Store(memcached.Set, "key", 0, []byte("value"), memcached.WithFlags(42), memcached.WithCASOut(&cas))
+	require.Nil(t, err)
+	assert.NotZero(t, cas)
+
+	var flags uint32
+	_, err = m.Get("key", memcached.WithFlagsOut(&flags))
+	require.Nil(t, err)
+	assert.Equal(t, uint32(42), flags)
+
+	_, err = m.Store(memcached.Set, "key", 0, []byte("other"), memcached.WithCAS(cas+1))
+	assert.ErrorIs(t, err, memcached.ErrNotStored, "Store: stale CAS")
+	_, err = m.Append(memcached.Append, "key", []byte("other"), memcached.WithCAS(cas+1))
+	assert.ErrorIs(t, err, memcached.ErrNotStored, "Append: stale CAS")
+	_, err = m.Delete("key", memcached.WithCAS(cas+1))
+	assert.ErrorIs(t, err, memcached.ErrNotStored, "Delete: stale CAS")
+
+	_, err = m.Get("key", memcached.RouteTo("mock"))
+	assert.Nil(t, err, "Get: Mock is the single node")
+	_, err = m.Touch("key", 0, memcached.RouteTo("127.0.0.1:11211"))
+	assert.ErrorIs(t, err, memcached.ErrInvalidAddr, "Touch: unknown node")
+
+	_, err = m.Delete("key", memcached.WithCAS(cas))
+	require.Nil(t, err)
+	assert.Equal(t, 0, m.Len())
+}
@@ -3,6 +3,8 @@ package pool
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/semaphore"
@@ -16,76 +18,226 @@ var (
 	ErrAcquireTimeout = fmt.Errorf("timeout for Acquire from the pool. Need to increase the maxCap for pool")
 )
 
-var _ ConnPool = (*Pool)(nil)
+var _ ConnPool[any] = (*Pool[any])(nil)
 
-type ConnPool interface {
-	Get() (any, error)
-	Pop() (any, bool)
-	Put(v any)
+type ConnPool[T comparable] interface {
+	Get() (T, error)
+	GetContext(ctx context.Context) (T, error)
+	Pop() (T, bool)
+	Put(v T)
 	Destroy()
 	Len() int
-	Close(v any)
+	Close(v T)
+	Stats() Stats
+}
+
+// Stats is a snapshot of the pool state.
+type Stats struct {
+	// InUse is a number of connections taken from the pool.
+	InUse int
+	// Idle is a number of connections in the pool.
+	Idle int
+	// Waits is a total number of Get calls, that waited for a connection.
+	Waits int64
+	// WaitDuration is a total time of waiting for a connection.
+	WaitDuration time.Duration
 }
 
 // Pool common connection pool
-type Pool struct {
+type Pool[T comparable] struct {
 	ctx context.Context
 
 	// newConn are functions for creating new connections if maxCap is not reached.
-	newConn func() (any, error)
+	newConn func() (T, error)
 	// closeConn is a function for graceful closed connections.
-	closeConn func(any)
+	closeConn func(T)
+
+	// onCreate, onAcquire, onRelease and onClose are optional callbacks for instrumentation.
+	onCreate  func(T)
+	onAcquire func(T)
+	onRelease func(T)
+	onClose   func(T)
 
 	// sema is a semaphore implementation for control a max capacity of pool
 	sema *semaphore.Weighted
 	// aqSemaTimeout is an amount of time to acquire conn from pool
 	aqSemaTimeout time.Duration
 
+	// maxLifetime is a maximum amount of time a connection may be reused, zero means no limit.
+	maxLifetime time.Duration
+	// minIdle is a number of connections, that Warmup keeps in store.
+	minIdle int32
+	// maxIdleTime is a maximum amount of time a connection may be idle in store, zero means no limit.
+	maxIdleTime time.Duration
+	// cmu is a mutex for created
+	cmu sync.Mutex
+	// created is the creation time of connections, filled only if maxLifetime is set.
+	created map[T]time.Time
+
+	// open is a number of created and not closed connections.
+	open atomic.Int32
+	// waits and waitDuration are counters for Stats.
+	waits        atomic.Int64
+	waitDuration atomic.Int64
+
+	// smu is a mutex for sending to store and closing it.
+	smu sync.RWMutex
 	// store is a chan with connections.
-	store chan any
+	store chan item[T]
 	// storeClose is a flag indicating that store is closed.
 	storeClose chan struct{}
 	// maxCap is maximum of total connections used
 	maxCap int32
 }
 
+// item is a connection in store with the time it was put.
+type item[T comparable] struct {
+	v     T
+	putAt time.Time
+}
+
+// Option is an option of the pool with connections of type T.
+type Option[T comparable] func(*poolOptions[T])
+
+type poolOptions[T comparable] struct {
+	maxLifetime time.Duration
+	maxIdleTime time.Duration
+	minIdle     int32
+
+	onCreate  func(T)
+	onAcquire func(T)
+	onRelease func(T)
+	onClose   func(T)
+}
+
+// WithMaxConnLifetime is sets a maximum amount of time a connection may be reused.
+// Expired connections are closed instead of returning to the pool.
+func WithMaxConnLifetime[T comparable](d time.Duration) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.maxLifetime = d
+	}
+}
+
+// WithMaxIdleTime is sets a maximum amount of time a connection may be idle in the pool.
+// Expired connections are closed on Get instead of being returned.
+func WithMaxIdleTime[T comparable](d time.Duration) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.maxIdleTime = d
+	}
+}
+
+// WithMinIdle is sets a number of idle connections, that Warmup dials ahead of traffic.
+// Values greater than maxCap are limited by maxCap.
+func WithMinIdle[T comparable](n int32) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.minIdle = n
+	}
+}
+
+// WithOnCreate is sets a callback, that is called after a new connection is created.
+func WithOnCreate[T comparable](fn func(v T)) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.onCreate = fn
+	}
+}
+
+// WithOnAcquire is sets a callback, that is called when a connection is taken from the pool by Get or GetContext.
+func WithOnAcquire[T comparable](fn func(v T)) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.onAcquire = fn
+	}
+}
+
+// WithOnRelease is sets a callback, that is called when a connection is returned to the pool by Put.
+func WithOnRelease[T comparable](fn func(v T)) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.onRelease = fn
+	}
+}
+
+// WithOnClose is sets a callback, that is called before a connection is closed.
+func WithOnClose[T comparable](fn func(v T)) Option[T] {
+	return func(p *poolOptions[T]) {
+		p.onClose = fn
+	}
+}
+
 // New create a pool with capacity
-func New(ctx context.Context, maxCap int32, acquireSemaTimeout time.Duration, newFunc func() (any, error), closeFunc func(any)) *Pool {
+func New[T comparable](ctx context.Context, maxCap int32, acquireSemaTimeout time.Duration, newFunc func() (T, error), closeFunc func(T), opts ...Option[T]) *Pool[T] {
 	if maxCap <= 0 {
 		panic("invalid memcached maxCap")
 	}
 
-	return &Pool{
+	var o poolOptions[T]
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	p := &Pool[T]{
 		ctx:           ctx,
 		newConn:       newFunc,
 		closeConn:     closeFunc,
 		sema:          semaphore.NewWeighted(int64(maxCap)),
 		aqSemaTimeout: acquireSemaTimeout,
-		store:         make(chan any, maxCap),
+		maxLifetime:   o.maxLifetime,
+		maxIdleTime:   o.maxIdleTime,
+		minIdle:       min(o.minIdle, maxCap),
+		onCreate:      o.onCreate,
+		onAcquire:     o.onAcquire,
+		onRelease:     o.onRelease,
+		onClose:       o.onClose,
+		store:         make(chan item[T], maxCap),
 		storeClose:    make(chan struct{}),
 		maxCap:        maxCap,
 	}
+	if p.maxLifetime > 0 {
+		p.created = make(map[T]time.Time, maxCap)
+	}
+
+	return p
 }
 
 // Len returns current connections in pool
-func (p *Pool) Len() int {
+func (p *Pool[T]) Len() int {
 	return len(p.store)
 }
 
+// Stats returns the current state of the pool.
+func (p *Pool[T]) Stats() Stats {
+	idle := p.Len()
+	return Stats{
+		InUse:        max(int(p.open.Load())-idle, 0),
+		Idle:         idle,
+		Waits:        p.waits.Load(),
+		WaitDuration: time.Duration(p.waitDuration.Load()),
+	}
+}
+
 // Get returns a conn from store or create one
-func (p *Pool) Get() (any, error) {
-	var aqTimeout bool
+func (p *Pool[T]) Get() (T, error) {
+	return p.acquired(p.get())
+}
+
+func (p *Pool[T]) get() (T, error) {
+	var (
+		aqTimeout bool
+		zero      T
+	)
 
 	for {
 		select {
-		case v, ok := <-p.store:
-			if ok {
-				return v, nil
+		case it, ok := <-p.store:
+			if !ok {
+				return zero, ErrClosedPool
 			}
-			return nil, ErrClosedPool
+			if p.expired(it) {
+				p.close(it.v)
+				continue
+			}
+			return it.v, nil
 		default:
 			if aqTimeout {
-				return nil, ErrAcquireTimeout
+				return zero, ErrAcquireTimeout
 			}
 			if cn, timeout, err := p.create(); timeout {
 				// last try get conn after timeout
@@ -98,82 +250,271 @@ func (p *Pool) Get() (any, error) {
 	}
 }
 
+// GetContext returns a conn from store or create one, if maxCap is not reached.
+// Otherwise, it blocks until a conn is returned to store, a conn is closed or ctx is done.
+// Waiters for new conns are served in FIFO order.
+func (p *Pool[T]) GetContext(ctx context.Context) (T, error) {
+	return p.acquired(p.getContext(ctx))
+}
+
+func (p *Pool[T]) getContext(ctx context.Context) (T, error) {
+	for {
+		select {
+		case it, ok := <-p.store:
+			if !ok {
+				var zero T
+				return zero, ErrClosedPool
+			}
+			if p.expired(it) {
+				p.close(it.v)
+				continue
+			}
+			return it.v, nil
+		default:
+		}
+
+		if p.sema.TryAcquire(token) {
+			return p.createAcquired()
+		}
+
+		v, retry, err := p.wait(ctx)
+		if retry {
+			continue
+		}
+		return v, err
+	}
+}
+
+// wait blocks until a conn is returned to store or the token is acquired for a new conn.
+// retry is true, if the conn from store is expired and the waiting should be repeated.
+func (p *Pool[T]) wait(ctx context.Context) (v T, retry bool, err error) {
+	defer p.trackWait(time.Now())
+
+	aqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- p.sema.Acquire(aqCtx, token)
+	}()
+
+	select {
+	case it, ok := <-p.store:
+		cancel()
+		if aqErr := <-acquired; aqErr == nil {
+			p.sema.Release(token)
+		}
+		if !ok {
+			return v, false, ErrClosedPool
+		}
+		if p.expired(it) {
+			p.close(it.v)
+			return v, true, nil
+		}
+		return it.v, false, nil
+	case aqErr := <-acquired:
+		if aqErr != nil {
+			return v, false, fmt.Errorf("%w: %w", ErrAcquireTimeout, aqErr)
+		}
+		v, err = p.createAcquired()
+		return v, false, err
+	}
+}
+
 // Pop return available conn without block
-func (p *Pool) Pop() (any, bool) {
+func (p *Pool[T]) Pop() (T, bool) {
+	var zero T
 	if p.isClosed() {
-		return nil, false
+		return zero, false
 	}
 
 	select {
-	case v, ok := <-p.store:
-		return v, ok
+	case it, ok := <-p.store:
+		return it.v, ok
 	default:
-		return nil, false
+		return zero, false
 	}
 }
 
 // Put set back conn into store again
-func (p *Pool) Put(v any) {
+func (p *Pool[T]) Put(v T) {
+	if p.put(v) && p.onRelease != nil {
+		p.onRelease(v)
+	}
+}
+
+// put returns true, if the conn is placed into store.
+func (p *Pool[T]) put(v T) bool {
+	p.smu.RLock()
+	defer p.smu.RUnlock()
+
 	if p.isClosed() {
-		return
+		// the conn returned after Destroy must not leak
+		p.close(v)
+		return false
+	}
+	if p.lifetimeExpired(v) {
+		p.close(v)
+		return false
 	}
 	select {
-	case p.store <- v:
+	case p.store <- item[T]{v: v, putAt: time.Now()}:
+		return true
 	default:
+		return false
+	}
+}
+
+// Warmup dials connections until the store has minIdle of them or the pool capacity is reached.
+func (p *Pool[T]) Warmup(ctx context.Context) error {
+	for p.Len() < int(p.minIdle) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p.isClosed() {
+			return ErrClosedPool
+		}
+		if !p.sema.TryAcquire(token) {
+			// all connections are in use
+			return nil
+		}
+
+		cn, err := p.dial()
+		if err != nil {
+			return err
+		}
+		p.put(cn)
 	}
+	return nil
 }
 
 // Destroy close all connections and deactivate the pool
-func (p *Pool) Destroy() {
+func (p *Pool[T]) Destroy() {
+	p.smu.Lock()
 	if p.isClosed() {
 		// pool already destroyed
+		p.smu.Unlock()
 		return
 	}
 
 	close(p.storeClose)
 	close(p.store)
-	for v := range p.store {
-		p.close(v)
+	p.smu.Unlock()
+
+	for it := range p.store {
+		p.close(it.v)
 	}
 }
 
 // Close is closed a connection
-func (p *Pool) Close(v any) {
+func (p *Pool[T]) Close(v T) {
 	p.close(v)
 }
 
-func (p *Pool) create() (any, bool, error) {
+func (p *Pool[T]) create() (T, bool, error) {
+	if !p.sema.TryAcquire(token) {
+		if !p.acquireWithTimeout() {
+			var zero T
+			return zero, true, nil
+		}
+	}
+
+	cn, err := p.createAcquired()
+	return cn, false, err
+}
+
+// acquireWithTimeout waits the token not longer than aqSemaTimeout.
+func (p *Pool[T]) acquireWithTimeout() bool {
+	defer p.trackWait(time.Now())
+
 	ctx, cancel := context.WithTimeout(p.ctx, p.aqSemaTimeout)
 	defer cancel()
 
-	if err := p.sema.Acquire(ctx, token); err != nil {
-		return nil, true, nil
-	}
+	return p.sema.Acquire(ctx, token) == nil
+}
 
+func (p *Pool[T]) trackWait(start time.Time) {
+	p.waits.Add(1)
+	p.waitDuration.Add(int64(time.Since(start)))
+}
+
+// createAcquired creates a new conn for the acquired token, the token is released on error.
+func (p *Pool[T]) createAcquired() (T, error) {
 	if p.isClosed() {
 		p.sema.Release(token)
-		return nil, false, ErrClosedPool
+		var zero T
+		return zero, ErrClosedPool
 	}
+	return p.dial()
+}
 
+// dial creates a new connection for the acquired token, the token is released on error.
+func (p *Pool[T]) dial() (T, error) {
 	if p.newConn == nil {
-		return nil, false, ErrNewFuncNil
+		p.sema.Release(token)
+		var zero T
+		return zero, ErrNewFuncNil
 	}
 	cn, err := p.newConn()
 	if err != nil {
 		p.sema.Release(token)
-		return nil, false, err
+		return cn, err
 	}
-	return cn, false, nil
+	p.open.Add(1)
+	if p.onCreate != nil {
+		p.onCreate(cn)
+	}
+	if p.maxLifetime > 0 {
+		p.cmu.Lock()
+		p.created[cn] = time.Now()
+		p.cmu.Unlock()
+	}
+	return cn, nil
 }
 
-func (p *Pool) close(v any) {
+func (p *Pool[T]) close(v T) {
+	if p.maxLifetime > 0 {
+		p.cmu.Lock()
+		delete(p.created, v)
+		p.cmu.Unlock()
+	}
+	if p.onClose != nil {
+		p.onClose(v)
+	}
+	p.open.Add(-1)
 	p.sema.Release(token)
 	if p.closeConn != nil {
 		p.closeConn(v)
 	}
 }
 
-func (p *Pool) isClosed() bool {
+// acquired calls onAcquire for the conn taken from the pool.
+func (p *Pool[T]) acquired(v T, err error) (T, error) {
+	if err == nil && p.onAcquire != nil {
+		p.onAcquire(v)
+	}
+	return v, err
+}
+
+// expired reports whether the connection from store exceeded maxIdleTime or maxLifetime.
+func (p *Pool[T]) expired(it item[T]) bool {
+	if p.maxIdleTime > 0 && time.Since(it.putAt) > p.maxIdleTime {
+		return true
+	}
+	return p.lifetimeExpired(it.v)
+}
+
+func (p *Pool[T]) lifetimeExpired(v T) bool {
+	if p.maxLifetime <= 0 {
+		return false
+	}
+	p.cmu.Lock()
+	created, ok := p.created[v]
+	p.cmu.Unlock()
+	return ok && time.Since(created) > p.maxLifetime
+}
+
+func (p *Pool[T]) isClosed() bool {
 	select {
 	case <-p.storeClose:
 		return true
@@ -249,7 +249,7 @@ func TestCountConns(t *testing.T) {
 	assert.Nil(t, cn, "Get: create new conn returned an error, conn should be nil")
 	assert.ErrorIs(t, err, http.ErrHandlerTimeout, "Get: error should be equal - http.ErrHandlerTimeout")
 
-	p5 := New(context.TODO(), 1, time.Second, nil, nil)
+	p5 := New[any](context.TODO(), 1, time.Second, nil, nil)
 
 	cn, err = p5.Get()
 	assert.Nil(t, cn, "Get: newFunc equal nil, conn should be nil")
@@ -277,3 +277,235 @@ func TestCountConns(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestPoolMaxLifetimeAndIdleTime(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+
+	var created, closed atomic.Int32
+	newConn := func() (any, error) {
+		id := int(created.Add(1))
+		return &id, nil
+	}
+	closeConn := func(any) {
+		closed.Add(1)
+	}
+
+	t.Run("MaxConnLifetime", func(t *testing.T) {
+		created.Store(0)
+		closed.Store(0)
+		p := New(context.TODO(), 2, defaultSocketPoolingTimeout, newConn, closeConn, WithMaxConnLifetime[any](ttl))
+		defer p.Destroy()
+
+		cn, err := p.Get()
+		assert.Nil(t, err)
+		p.Put(cn)
+		cn2, err := p.Get()
+		assert.Nil(t, err)
+		assert.Equal(t, cn, cn2, "Get: conn within lifetime should be reused")
+
+		time.Sleep(2 * ttl)
+		p.Put(cn2)
+		assert.Equal(t, 0, p.Len(), "Put: expired conn should be closed instead of returning to the pool")
+		assert.Equal(t, int32(1), closed.Load())
+
+		cn3, err := p.Get()
+		assert.Nil(t, err)
+		assert.NotEqual(t, cn, cn3, "Get: new conn should be created")
+		p.Close(cn3)
+		assert.Empty(t, p.created, "Close: conn should be removed from created")
+	})
+
+	t.Run("MaxIdleTime", func(t *testing.T) {
+		created.Store(0)
+		closed.Store(0)
+		p := New(context.TODO(), 2, defaultSocketPoolingTimeout, newConn, closeConn, WithMaxIdleTime[any](ttl))
+		defer p.Destroy()
+
+		cn, err := p.Get()
+		assert.Nil(t, err)
+		p.Put(cn)
+		time.Sleep(2 * ttl)
+
+		cn2, err := p.Get()
+		assert.Nil(t, err)
+		assert.NotEqual(t, cn, cn2, "Get: idle conn should be closed and new one created")
+		assert.Equal(t, int32(1), closed.Load())
+		assert.Nil(t, p.created, "created should be used only with MaxConnLifetime")
+	})
+}
+
+func TestPoolWarmup(t *testing.T) {
+	p := New(context.TODO(), 3, defaultSocketPoolingTimeout, newTestConnection, closeTestConnection, WithMinIdle[any](5))
+	defer p.Destroy()
+	assert.Equal(t, int32(3), p.minIdle, "WithMinIdle: should be limited by maxCap")
+
+	cn, err := p.Get()
+	assert.Nil(t, err)
+	assert.Nil(t, p.Warmup(context.TODO()))
+	assert.Equal(t, 2, p.Len(), "Warmup: should stop on pool capacity")
+
+	p.Put(cn)
+	assert.Nil(t, p.Warmup(context.TODO()))
+	assert.Equal(t, 3, p.Len(), "Warmup: store already has minIdle conns")
+
+	pErr := New(context.TODO(), 3, defaultSocketPoolingTimeout, newTestConnectionWithErr, closeTestConnection, WithMinIdle[any](1))
+	assert.ErrorIs(t, pErr.Warmup(context.TODO()), http.ErrHandlerTimeout, "Warmup: should return error of newFunc")
+	assert.True(t, pErr.sema.TryAcquire(3), "Warmup: tokens should be released on error")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	pCtx := New(context.TODO(), 3, defaultSocketPoolingTimeout, newTestConnection, closeTestConnection, WithMinIdle[any](1))
+	assert.ErrorIs(t, pCtx.Warmup(ctx), context.Canceled, "Warmup: should stop on canceled ctx")
+
+	pCtx.Destroy()
+	assert.ErrorIs(t, pCtx.Warmup(context.TODO()), ErrClosedPool, "Warmup: pool is closed")
+}
+
+func TestPoolGetContext(t *testing.T) {
+	p := New(context.TODO(), 1, defaultSocketPoolingTimeout, newTestConnection, closeTestConnection)
+	defer p.Destroy()
+
+	cn, err := p.GetContext(context.TODO())
+	assert.Nil(t, err, "GetContext: should create new conn")
+
+	go func() {
+		<-time.After(2 * defaultSocketPoolingTimeout)
+		p.Put(cn)
+	}()
+	cn2, err := p.GetContext(context.TODO())
+	assert.Nil(t, err, "GetContext: should wait for returned conn longer than acquire timeout")
+	assert.Equal(t, cn, cn2)
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		p.Close(cn2)
+	}()
+	_, err = p.GetContext(context.TODO())
+	assert.Nil(t, err, "GetContext: should create new conn after closing")
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.GetContext(ctx)
+	assert.ErrorIs(t, err, ErrAcquireTimeout, "GetContext: ctx is done, want ErrAcquireTimeout")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "GetContext: ctx is done, want context.DeadlineExceeded")
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		p.Destroy()
+	}()
+	_, err = p.GetContext(context.TODO())
+	assert.ErrorIs(t, err, ErrClosedPool, "GetContext: pool is destroyed while waiting")
+}
+
+func TestPoolGetContextConcurrency(t *testing.T) {
+	const (
+		maxCap  = 3
+		workers = 20
+	)
+	p := New(context.TODO(), maxCap, defaultSocketPoolingTimeout, newTestConnection, closeTestConnection)
+	defer p.Destroy()
+
+	var (
+		wg     sync.WaitGroup
+		inUse  atomic.Int32
+		maxUse atomic.Int32
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				cn, err := p.GetContext(context.TODO())
+				if !assert.Nil(t, err) {
+					return
+				}
+				cur := inUse.Add(1)
+				for {
+					m := maxUse.Load()
+					if cur <= m || maxUse.CompareAndSwap(m, cur) {
+						break
+					}
+				}
+				<-time.After(time.Millisecond)
+				inUse.Add(-1)
+				p.Put(cn)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxUse.Load(), int32(maxCap), "GetContext: conns in use should not exceed maxCap")
+}
+
+func TestPoolStats(t *testing.T) {
+	type typedConn struct{ id int }
+
+	var created int
+	p := New(context.TODO(), 2, defaultSocketPoolingTimeout, func() (*typedConn, error) {
+		created++
+		return &typedConn{id: created}, nil
+	}, func(*typedConn) {})
+	defer p.Destroy()
+
+	assert.Equal(t, Stats{}, p.Stats(), "Stats: empty pool")
+
+	first, err := p.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, first.id, "Get: should return typed conn")
+	second, err := p.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, Stats{InUse: 2}, p.Stats())
+
+	p.Put(first)
+	st := p.Stats()
+	assert.Equal(t, 1, st.InUse)
+	assert.Equal(t, 1, st.Idle)
+	assert.Equal(t, int64(0), st.Waits, "Stats: there are no waits")
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		p.Put(second)
+	}()
+	_, err = p.GetContext(context.TODO())
+	assert.Nil(t, err)
+	_, err = p.GetContext(context.TODO())
+	assert.Nil(t, err)
+
+	st = p.Stats()
+	assert.Equal(t, 2, st.InUse)
+	assert.Equal(t, 0, st.Idle)
+	assert.Equal(t, int64(1), st.Waits, "Stats: GetContext should wait returned conn")
+	assert.True(t, st.WaitDuration >= 5*time.Millisecond, "Stats: wait duration should be counted")
+
+	p.Close(second)
+	assert.Equal(t, 1, p.Stats().InUse, "Stats: closed conn is not in use")
+}
+
+func TestPoolCallbacks(t *testing.T) {
+	var created, acquired, released, closed atomic.Int32
+	p := New(context.TODO(), 2, defaultSocketPoolingTimeout, newTestConnection, closeTestConnection,
+		WithMinIdle[any](1),
+		WithOnCreate(func(any) { created.Add(1) }),
+		WithOnAcquire(func(any) { acquired.Add(1) }),
+		WithOnRelease(func(any) { released.Add(1) }),
+		WithOnClose(func(any) { closed.Add(1) }),
+	)
+
+	assert.Nil(t, p.Warmup(context.TODO()))
+	assert.Equal(t, int32(1), created.Load(), "Warmup: OnCreate should be called")
+	assert.Equal(t, int32(0), released.Load(), "Warmup: OnRelease should not be called")
+
+	cn, err := p.Get()
+	assert.Nil(t, err)
+	cn2, err := p.GetContext(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), created.Load())
+	assert.Equal(t, int32(2), acquired.Load(), "Get: OnAcquire should be called")
+
+	p.Put(cn)
+	assert.Equal(t, int32(1), released.Load(), "Put: OnRelease should be called")
+
+	p.Close(cn2)
+	p.Destroy()
+	assert.Equal(t, int32(2), closed.Load(), "Close and Destroy: OnClose should be called")
+}
@@ -0,0 +1,281 @@
+// Package server provides the server side of the memcached binary protocol, so the proxies, mocks and shims
+// speaking memcached can be built on the same Request and Response encoding, as the client.
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aliexpressru/gomemcached/logger"
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+const libPrefix = "gomemcached"
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("gomemcached: server is closed")
+
+type (
+	// Handler serves the requests of the connections. It is called sequentially for the requests of a connection
+	// and concurrently for the different connections.
+	Handler interface {
+		// Serve returns the response on req, nil means no response (e.g. for the quiet commands on success).
+		// The opcode and the opaque of the response are set from req. ctx is canceled by Close.
+		Serve(ctx context.Context, req *memcached.Request) *memcached.Response
+	}
+
+	// HandlerFunc is a function, that is used as Handler.
+	HandlerFunc func(ctx context.Context, req *memcached.Request) *memcached.Response
+
+	// Option is an option of Server.
+	Option func(*Server)
+
+	// Server accepts the connections and serves their requests by Handler.
+	Server struct {
+		handler Handler
+		// idleTimeout - connections without requests longer than this are closed, zero means no limit.
+		idleTimeout time.Duration
+		// writeTimeout - limit of the writing of the responses, zero means no limit.
+		writeTimeout time.Duration
+		// logger - logger of the server, if nil, the global logger of the package logger is used.
+		logger logger.Logger
+
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+
+		mu        sync.Mutex
+		closed    bool
+		listeners map[net.Listener]struct{}
+		conns     map[net.Conn]struct{}
+	}
+)
+
+// Serve calls f(ctx, req).
+func (f HandlerFunc) Serve(ctx context.Context, req *memcached.Request) *memcached.Response {
+	return f(ctx, req)
+}
+
+// WithIdleTimeout is sets the time, after that the connections without requests are closed.
+// By default, the connections are not closed by the server.
+func WithIdleTimeout(tm time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = tm
+	}
+}
+
+// WithWriteTimeout is sets the limit of the writing of the responses to the slow clients.
+// By default, the writing is not limited.
+func WithWriteTimeout(tm time.Duration) Option {
+	return func(s *Server) {
+		s.writeTimeout = tm
+	}
+}
+
+// WithLogger is sets the logger of the server, by default, the global logger of the package logger is used.
+func WithLogger(l logger.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// New returns a server, that serves the requests by handler.
+func New(handler Handler, opts ...Option) *Server {
+	s := &Server{
+		handler:   handler,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts the connections on ln and serves every one in its own goroutine.
+// It returns ErrServerClosed after Close, ln is closed on return.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+		_ = ln.Close()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				s.getLogger().Warnf("%s: accept error: %s", libPrefix, err.Error())
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = nc.Close()
+			return ErrServerClosed
+		}
+		// the goroutine is added under the lock, so Close waits for it.
+		s.conns[nc] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(nc)
+
+			s.mu.Lock()
+			delete(s.conns, nc)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close closes the listeners and the connections, cancels the context of the handlers and waits for them.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	for ln := range s.listeners {
+		err = errors.Join(err, ln.Close())
+	}
+	for nc := range s.conns {
+		_ = nc.Close()
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+	return err
+}
+
+// serveConn reads the requests of nc and writes the responses until an error or QUIT.
+// The responses are flushed, when there are no more requests read ahead, so the pipelined batches
+// are answered by a single write.
+func (s *Server) serveConn(nc net.Conn) {
+	defer nc.Close()
+
+	var (
+		rd  = bufio.NewReader(nc)
+		wr  = bufio.NewWriter(nc)
+		hdr = make([]byte, memcached.HDR_LEN)
+	)
+	for {
+		if s.idleTimeout > 0 && rd.Buffered() == 0 {
+			if err := nc.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+				return
+			}
+		}
+
+		req := &memcached.Request{}
+		if _, err := req.Receive(rd, hdr); err != nil {
+			if !s.isClosed() && !isClosedConn(err) {
+				s.getLogger().Debugf("%s: connection %s is closed: %s", libPrefix, nc.RemoteAddr(), err.Error())
+			}
+			return
+		}
+
+		switch req.Opcode {
+		case memcached.QUITQ:
+			_ = wr.Flush()
+			return
+		case memcached.QUIT:
+			_ = s.write(nc, wr, req, &memcached.Response{})
+			_ = wr.Flush()
+			return
+		}
+
+		if resp := s.handler.Serve(s.ctx, req); resp != nil {
+			if err := s.write(nc, wr, req, resp); err != nil {
+				return
+			}
+		}
+
+		if rd.Buffered() == 0 {
+			if err := s.flush(nc, wr); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) write(nc net.Conn, wr *bufio.Writer, req *memcached.Request, resp *memcached.Response) error {
+	resp.Opcode, resp.Opaque = req.Opcode, req.Opaque
+	if wr.Available() < resp.Size() {
+		if err := s.setWriteDeadline(nc); err != nil {
+			return err
+		}
+	}
+	_, err := resp.Transmit(wr)
+	return err
+}
+
+func (s *Server) flush(nc net.Conn, wr *bufio.Writer) error {
+	if wr.Buffered() == 0 {
+		return nil
+	}
+	if err := s.setWriteDeadline(nc); err != nil {
+		return err
+	}
+	return wr.Flush()
+}
+
+func (s *Server) setWriteDeadline(nc net.Conn) error {
+	if s.writeTimeout <= 0 {
+		return nil
+	}
+	return nc.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+}
+
+func (s *Server) getLogger() logger.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	return logger.Default()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func isClosedConn(err error) bool {
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF)
+}
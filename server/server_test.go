@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aliexpressru/gomemcached/memcached"
+)
+
+// kvHandler is a minimal memcached with GET, SET and DELETE and their quiet versions.
+type kvHandler struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func (h *kvHandler) Serve(_ context.Context, req *memcached.Request) *memcached.Response {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := string(req.Key)
+	switch req.Opcode {
+	case memcached.GET, memcached.GETQ:
+		value, ok := h.items[key]
+		if !ok {
+			if req.Opcode.IsQuiet() {
+				return nil
+			}
+			return &memcached.Response{Status: memcached.KEY_ENOENT}
+		}
+		return &memcached.Response{Extras: make([]byte, 4), Body: value}
+	case memcached.SET, memcached.SETQ:
+		h.items[key] = append([]byte(nil), req.Body...)
+	case memcached.DELETE, memcached.DELETEQ:
+		delete(h.items, key)
+	case memcached.NOOP:
+	default:
+		return &memcached.Response{Status: memcached.UNKNOWN_COMMAND}
+	}
+	if req.Opcode.IsQuiet() {
+		return nil
+	}
+	return &memcached.Response{}
+}
+
+func startServer(t *testing.T, handler Handler, opts ...Option) (*Server, string, chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	s := New(handler, opts...)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ln) }()
+	t.Cleanup(func() { _ = s.Close() })
+	return s, ln.Addr().String(), served
+}
+
+func TestServer(t *testing.T) {
+	s, addr, served := startServer(t, &kvHandler{items: make(map[string][]byte)})
+
+	t.Setenv("MEMCACHED_SERVERS", addr)
+	mcl, err := memcached.InitFromEnv(
+		memcached.WithDisableNodeProvider(),
+		memcached.WithDisableMemcachedDiagnostic(),
+	)
+	require.Nil(t, err)
+	defer mcl.Close()
+
+	_, err = mcl.Store(memcached.Set, "foo", 0, []byte("bar"))
+	require.Nil(t, err)
+	resp, err := mcl.Get("foo")
+	require.Nil(t, err)
+	assert.Equal(t, "bar", string(resp.Body))
+
+	_, err = mcl.Get("missing")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+
+	require.Nil(t, mcl.MultiStore(memcached.Set, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, 0))
+	items, err := mcl.MultiGet([]string{"a", "b", "missing"})
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, items, "MultiGet: the quiet batch should be answered")
+
+	_, err = mcl.Delete("foo")
+	require.Nil(t, err)
+	_, err = mcl.Get("foo")
+	assert.ErrorIs(t, err, memcached.ErrCacheMiss)
+
+	_, err = mcl.Append(memcached.Append, "a", []byte("x"))
+	assert.ErrorIs(t, err, memcached.ErrUnknownCommand)
+
+	require.Nil(t, s.Close())
+	select {
+	case err = <-served:
+		assert.ErrorIs(t, err, ErrServerClosed)
+	case <-time.After(time.Second):
+		t.Fatal("Serve: should return after Close")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	assert.ErrorIs(t, s.Serve(ln), ErrServerClosed, "Serve: closed server should not serve")
+}
+
+func TestServer_Quit(t *testing.T) {
+	_, addr, _ := startServer(t, HandlerFunc(func(context.Context, *memcached.Request) *memcached.Response {
+		return &memcached.Response{}
+	}), WithIdleTimeout(time.Second))
+
+	nc, err := net.Dial("tcp", addr)
+	require.Nil(t, err)
+	defer nc.Close()
+
+	_, err = (&memcached.Request{Opcode: memcached.QUIT, Opaque: 5}).Transmit(nc)
+	require.Nil(t, err)
+	resp := &memcached.Response{}
+	_, err = resp.Receive(nc, nil)
+	require.Nil(t, err)
+	assert.Equal(t, memcached.QUIT, resp.Opcode)
+	assert.Equal(t, uint32(5), resp.Opaque)
+
+	require.Nil(t, nc.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = nc.Read(make([]byte, 1))
+	assert.Error(t, err, "QUIT: the connection should be closed by the server")
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	_, addr, _ := startServer(t, HandlerFunc(func(context.Context, *memcached.Request) *memcached.Response {
+		return &memcached.Response{}
+	}), WithIdleTimeout(50*time.Millisecond))
+
+	nc, err := net.Dial("tcp", addr)
+	require.Nil(t, err)
+	defer nc.Close()
+
+	require.Nil(t, nc.SetReadDeadline(time.Now().Add(time.Second)))
+	timer := time.Now()
+	_, err = nc.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(timer), time.Second, "idle connection should be closed by the server")
+}
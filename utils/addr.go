@@ -20,10 +20,15 @@ func newStaticAddr(a net.Addr) net.Addr {
 func (s *staticAddr) Network() string { return s.ntw }
 func (s *staticAddr) String() string  { return s.str }
 
+// IsUnixAddr returns true, if the server address is the path of a unix domain socket.
+func IsUnixAddr(server string) bool {
+	return strings.Contains(server, "/")
+}
+
 // AddrRepr a string representation of the server address implements net.Addr
 func AddrRepr(server string) (net.Addr, error) {
 	var nAddr net.Addr
-	if strings.Contains(server, "/") {
+	if IsUnixAddr(server) {
 		addr, _ := net.ResolveUnixAddr("unix", server)
 		nAddr = newStaticAddr(addr)
 	} else {
@@ -36,3 +41,20 @@ func AddrRepr(server string) (net.Addr, error) {
 
 	return nAddr, nil
 }
+
+// HostAddrRepr a string representation of the server address implements net.Addr
+// without resolving the host, so the address is resolved on every dial.
+func HostAddrRepr(server string) (net.Addr, error) {
+	if IsUnixAddr(server) {
+		return AddrRepr(server)
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return nil, err
+	}
+
+	return &staticAddr{
+		ntw: "tcp",
+		str: server,
+	}, nil
+}
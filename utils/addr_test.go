@@ -22,6 +22,13 @@ func TestNewStaticAddr(t *testing.T) {
 	}
 }
 
+func TestIsUnixAddr(t *testing.T) {
+	assert.True(t, IsUnixAddr("/var/run/memcached.sock"))
+	assert.True(t, IsUnixAddr("run/memcached.sock"))
+	assert.False(t, IsUnixAddr("127.0.0.1:11211"))
+	assert.False(t, IsUnixAddr("memcached-0.memcached:11211"))
+}
+
 func TestAddrRepr(t *testing.T) {
 	type args struct {
 		server string
@@ -67,3 +74,44 @@ func TestAddrRepr(t *testing.T) {
 		})
 	}
 }
+
+func TestHostAddrRepr(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		want    net.Addr
+		wantErr bool
+	}{
+		{
+			name:    "invalid address",
+			server:  "invalid-address",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:   "unix",
+			server: "/var/unix.sock",
+			want: &staticAddr{
+				ntw: "unix",
+				str: "/var/unix.sock",
+			},
+		},
+		{
+			name:   "hostname",
+			server: "memcached-0.memcached.default.svc.cluster.local:11211",
+			want: &staticAddr{
+				ntw: "tcp",
+				str: "memcached-0.memcached.default.svc.cluster.local:11211",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HostAddrRepr(tt.server)
+			if tt.wantErr {
+				assert.NotNilf(t, err, fmt.Sprintf("HostAddrRepr(%v) Expected an error, got nil", tt.server))
+			}
+			assert.Equalf(t, tt.want, got, "HostAddrRepr(%v)", tt.server)
+		})
+	}
+}